@@ -4,15 +4,19 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	apexLog "github.com/apex/log"
 	"github.com/juju/errors"
 
 	"github.com/segmentio/fasthash/fnv1a"
 )
 
 const idsOnCachePage = 1000
+const cachedSearchTotalField = "total"
 
 func cachedSearch(engine *Engine, entities interface{}, indexName string, pager *Pager,
 	arguments []interface{}, references []string) (totalRows int, ids []uint64) {
@@ -35,8 +39,8 @@ func cachedSearch(engine *Engine, entities interface{}, indexName string, pager
 	}
 
 	Where := NewWhere(definition.Query, arguments...)
-	localCache, hasLocalCache := schema.GetLocalCache(engine)
-	redisCache, hasRedis := schema.GetRedisCache(engine)
+	localCache, hasLocalCache := schema.getQueryLocalCache(engine, definition)
+	redisCache, hasRedis := schema.getQueryRedisCache(engine, definition)
 	if !hasLocalCache && !hasRedis {
 		panic(errors.Errorf("cache search not allowed for entity without cache: '%s'", entityType.String()))
 	}
@@ -53,16 +57,12 @@ func cachedSearch(engine *Engine, entities interface{}, indexName string, pager
 	}
 	var fromCache map[string]interface{}
 	var nilsKeys []string
+	fields := append(pages, cachedSearchTotalField)
 	if hasLocalCache {
-		nils := make(map[int]int)
 		nilsKeys = make([]string, 0)
-		i := 0
-		fromCache = localCache.HMget(cacheKey, pages...)
-		index := 0
+		fromCache = localCache.HMget(cacheKey, fields...)
 		for key, val := range fromCache {
 			if val == nil {
-				nils[index] = i
-				i++
 				nilsKeys = append(nilsKeys, key)
 			}
 		}
@@ -73,19 +73,17 @@ func cachedSearch(engine *Engine, entities interface{}, indexName string, pager
 			}
 		}
 	} else if hasRedis {
-		fromCache = redisCache.HMget(cacheKey, pages...)
+		fromCache = redisCache.HMget(cacheKey, fields...)
 	}
+	cachedTotal := fromCache[cachedSearchTotalField]
+	delete(fromCache, cachedSearchTotalField)
 	hasNil := false
 	totalRows = 0
-	minPage := 9999
 	maxPage := 0
 	for key, idsAsString := range fromCache {
 		if idsAsString == nil {
 			hasNil = true
 			p, _ := strconv.Atoi(key)
-			if p < minPage {
-				minPage = p
-			}
 			if p > maxPage {
 				maxPage = p
 			}
@@ -100,24 +98,34 @@ func cachedSearch(engine *Engine, entities interface{}, indexName string, pager
 			filledPages[key] = idsAsUint
 		}
 	}
+	if cachedTotal != nil {
+		totalRows, _ = strconv.Atoi(cachedTotal.(string))
+	}
 
 	if hasNil {
-		searchPager := NewPager(minPage, maxPage*idsOnCachePage)
-		results, total := searchIDsWithCount(true, engine, Where, searchPager, entityType)
+		limit := maxPage * idsOnCachePage
+		if limit > definition.Max {
+			limit = definition.Max
+		}
+		results, total := searchIDsWithCount(true, engine, Where, NewPager(1, limit), entityType)
 		totalRows = total
-		cacheFields := make(map[string]interface{})
+		if total > definition.Max {
+			engine.Log().Warn("[ORM][CACHED_QUERY][MAX_ROWS]", apexLog.Fields{"entity": entityType.String(),
+				"index": indexName, "max": definition.Max, "total": total})
+		}
+		cacheFields := map[string]interface{}{cachedSearchTotalField: strconv.Itoa(total)}
 		for key, ids := range fromCache {
 			if ids == nil {
 				page := key
 				pageInt, _ := strconv.Atoi(page)
-				sliceStart := (pageInt - minPage) * idsOnCachePage
-				if sliceStart > total {
-					cacheFields[page] = total
-					continue
+				sliceStart := (pageInt - 1) * idsOnCachePage
+				found := len(results)
+				if sliceStart > found {
+					sliceStart = found
 				}
 				sliceEnd := sliceStart + idsOnCachePage
-				if sliceEnd > total {
-					sliceEnd = total
+				if sliceEnd > found {
+					sliceEnd = found
 				}
 				values := []uint64{uint64(total)}
 				foundIDs := results[sliceStart:sliceEnd]
@@ -137,6 +145,10 @@ func cachedSearch(engine *Engine, entities interface{}, indexName string, pager
 	if hasLocalCache && nilKeysLen > 0 {
 		fields := make(map[string]interface{}, nilKeysLen)
 		for _, v := range nilsKeys {
+			if v == cachedSearchTotalField {
+				fields[v] = strconv.Itoa(totalRows)
+				continue
+			}
 			values := []uint64{uint64(totalRows)}
 			values = append(values, filledPages[v]...)
 			cacheValue := fmt.Sprintf("%v", values)
@@ -184,8 +196,8 @@ func cachedSearchOne(engine *Engine, entity Entity, indexName string, arguments
 		panic(errors.NotFoundf("index %s", indexName))
 	}
 	Where := NewWhere(definition.Query, arguments...)
-	localCache, hasLocalCache := schema.GetLocalCache(engine)
-	redisCache, hasRedis := schema.GetRedisCache(engine)
+	localCache, hasLocalCache := schema.getQueryLocalCache(engine, definition)
+	redisCache, hasRedis := schema.getQueryRedisCache(engine, definition)
 	if !hasLocalCache && !hasRedis {
 		panic(errors.Errorf("cache search not allowed for entity without cache: '%s'", entityType.String()))
 	}
@@ -229,3 +241,199 @@ func getCacheKeySearch(tableSchema *tableSchema, indexName string, parameters ..
 	hash := fnv1a.HashString32(fmt.Sprintf("%v", parameters))
 	return fmt.Sprintf("%s_%s_%d", tableSchema.cachePrefix, indexName, hash)
 }
+
+func cachedChildren(engine *Engine, parent Entity, children interface{}, pager *Pager) (totalRows int) {
+	parentType := reflect.TypeOf(parent).Elem()
+	childType, has := getEntityTypeForSlice(engine.registry, reflect.TypeOf(children))
+	if !has {
+		panic(EntityNotRegisteredError{Name: strings.Trim(reflect.TypeOf(children).String(), "*[]")})
+	}
+	schema := getTableSchema(engine.registry, childType)
+	indexName, has := schema.collections[parentType.String()]
+	if !has {
+		panic(errors.NotFoundf("cached collection %s in %s", parentType.String(), childType.String()))
+	}
+	totalRows, _ = cachedSearch(engine, children, indexName, pager, []interface{}{parent.GetID()}, nil)
+	return totalRows
+}
+
+type CachedSearchOptions struct {
+	OrderBy    string
+	Filter     func(entity Entity) bool
+	References []string
+	// WithFakeDeleted skips cache and searches MySQL including fake deleted rows
+	WithFakeDeleted bool
+}
+
+func cachedSearchWithOptions(engine *Engine, entities interface{}, indexName string, pager *Pager,
+	arguments []interface{}, options *CachedSearchOptions) (totalRows int) {
+	if options != nil && options.WithFakeDeleted {
+		return cachedSearchWithFakeDeleted(engine, entities, indexName, pager, arguments, options)
+	}
+	if options == nil || (options.OrderBy == "" && options.Filter == nil) {
+		var references []string
+		if options != nil {
+			references = options.References
+		}
+		totalRows, _ = cachedSearch(engine, entities, indexName, pager, arguments, references)
+		return totalRows
+	}
+	value := reflect.ValueOf(entities)
+	entityType, has := getEntityTypeForSlice(engine.registry, value.Type())
+	if !has {
+		panic(EntityNotRegisteredError{Name: strings.Trim(value.Type().String(), "*[]")})
+	}
+	schema := getTableSchema(engine.registry, entityType)
+	definition, has := schema.cachedIndexes[indexName]
+	if !has {
+		panic(errors.NotFoundf("index %s", indexName))
+	}
+	order := parseCachedOrder(schema, options.OrderBy)
+	marker := reflect.New(entityType).Interface()
+	_, ids := cachedSearch(engine, marker, indexName, NewPager(1, definition.Max), arguments, nil)
+	engine.LoadByIDs(ids, entities, options.References...)
+	rows := value.Elem()
+	filtered := reflect.MakeSlice(rows.Type(), 0, rows.Len())
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		if row.IsNil() {
+			continue
+		}
+		if options.Filter == nil || options.Filter(row.Interface().(Entity)) {
+			filtered = reflect.Append(filtered, row)
+		}
+	}
+	if len(order) > 0 {
+		sort.SliceStable(filtered.Interface(), func(i, j int) bool {
+			return compareCachedOrder(filtered.Index(i).Elem(), filtered.Index(j).Elem(), order)
+		})
+	}
+	totalRows = filtered.Len()
+	if pager != nil {
+		start := (pager.GetCurrentPage() - 1) * pager.GetPageSize()
+		if start > totalRows {
+			start = totalRows
+		}
+		end := start + pager.GetPageSize()
+		if end > totalRows {
+			end = totalRows
+		}
+		filtered = filtered.Slice(start, end)
+	}
+	rows.Set(filtered)
+	return totalRows
+}
+
+func cachedSearchWithFakeDeleted(engine *Engine, entities interface{}, indexName string, pager *Pager,
+	arguments []interface{}, options *CachedSearchOptions) (totalRows int) {
+	if options.OrderBy != "" || options.Filter != nil {
+		panic(errors.NotSupportedf("order and filter options with fake deleted rows"))
+	}
+	value := reflect.ValueOf(entities)
+	entityType, has := getEntityTypeForSlice(engine.registry, value.Type())
+	if !has {
+		panic(EntityNotRegisteredError{Name: strings.Trim(value.Type().String(), "*[]")})
+	}
+	definition, has := getTableSchema(engine.registry, entityType).cachedIndexes[indexName]
+	if !has {
+		panic(errors.NotFoundf("index %s", indexName))
+	}
+	if pager == nil {
+		pager = NewPager(1, definition.Max)
+	}
+	where := NewWhere(definition.Query, arguments...).WithFakeDeleted()
+	return search(true, engine, where, pager, true, value.Elem(), options.References...)
+}
+
+type cachedOrderField struct {
+	field string
+	desc  bool
+}
+
+func parseCachedOrder(schema *tableSchema, orderBy string) []cachedOrderField {
+	order := make([]cachedOrderField, 0)
+	if orderBy == "" {
+		return order
+	}
+	for _, part := range strings.Split(orderBy, ",") {
+		words := strings.Fields(strings.ReplaceAll(part, "`", ""))
+		if len(words) == 0 || len(words) > 2 {
+			panic(errors.NotValidf("order by '%s'", orderBy))
+		}
+		field, has := schema.t.FieldByName(words[0])
+		if !has {
+			panic(errors.NotFoundf("field %s in %s", words[0], schema.t.String()))
+		}
+		desc := false
+		if len(words) == 2 {
+			switch strings.ToUpper(words[1]) {
+			case "ASC":
+			case "DESC":
+				desc = true
+			default:
+				panic(errors.NotValidf("order by '%s'", orderBy))
+			}
+		}
+		order = append(order, cachedOrderField{field: field.Name, desc: desc})
+	}
+	return order
+}
+
+func compareCachedOrder(left, right reflect.Value, order []cachedOrderField) bool {
+	for _, field := range order {
+		result := compareCachedValues(left.FieldByName(field.field), right.FieldByName(field.field))
+		if result == 0 {
+			continue
+		}
+		if field.desc {
+			return result > 0
+		}
+		return result < 0
+	}
+	return false
+}
+
+func compareCachedValues(left, right reflect.Value) int {
+	if left.Kind() == reflect.Ptr {
+		if left.IsNil() || right.IsNil() {
+			if left.IsNil() && right.IsNil() {
+				return 0
+			} else if left.IsNil() {
+				return -1
+			}
+			return 1
+		}
+		_, isEntity := left.Interface().(Entity)
+		if isEntity {
+			return compareCachedValues(left.Elem().Field(1), right.Elem().Field(1))
+		}
+		return compareCachedValues(left.Elem(), right.Elem())
+	}
+	switch left.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(left.Int() < right.Int(), left.Int() > right.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(left.Uint() < right.Uint(), left.Uint() > right.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(left.Float() < right.Float(), left.Float() > right.Float())
+	case reflect.String:
+		return strings.Compare(left.String(), right.String())
+	case reflect.Bool:
+		return compareOrdered(!left.Bool() && right.Bool(), left.Bool() && !right.Bool())
+	}
+	leftTime, isTime := left.Interface().(time.Time)
+	if isTime {
+		rightTime := right.Interface().(time.Time)
+		return compareOrdered(leftTime.Before(rightTime), leftTime.After(rightTime))
+	}
+	panic(errors.NotSupportedf("ordering by %s", left.Type().String()))
+}
+
+func compareOrdered(less, greater bool) int {
+	if less {
+		return -1
+	} else if greater {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,273 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apexLog "github.com/apex/log"
+	"github.com/juju/errors"
+)
+
+func cachedSearchComposite(engine *Engine, entities interface{}, indexName string, pager *Pager,
+	arguments map[string]interface{}, references []string) (totalRows int, ids []uint64) {
+	value := reflect.ValueOf(entities)
+	entityType, has := getEntityTypeForSlice(engine.registry, value.Type())
+	if !has {
+		panic(EntityNotRegisteredError{Name: strings.Trim(value.Type().String(), "*[]")})
+	}
+	schema := getTableSchema(engine.registry, entityType)
+	definition, has := schema.cachedIndexes[indexName]
+	if !has || !definition.Composite {
+		panic(errors.NotFoundf("composite index %s", indexName))
+	}
+	for field := range arguments {
+		if field == "FakeDelete" || !hasCompositeField(definition, field) {
+			panic(errors.NotFoundf("field %s in composite index %s", field, indexName))
+		}
+	}
+	if pager == nil {
+		pager = NewPager(1, definition.Max)
+	}
+	normalized := make(map[string]interface{}, len(arguments))
+	fields := make([]string, 0, len(arguments))
+	for _, field := range definition.QueryFields {
+		argument, has := arguments[field]
+		if has {
+			fields = append(fields, field)
+			normalized[field] = normalizeCompositeArgument(schema, field, argument)
+		}
+	}
+	var result []uint64
+	total := 0
+	truncated := false
+	if len(fields) == 0 {
+		result, total = getCompositeIDs(engine, schema, definition, indexName, "", nil)
+		truncated = total > len(result)
+	}
+	for i, field := range fields {
+		fieldIDs, fieldTotal := getCompositeIDs(engine, schema, definition, indexName, field, normalized[field])
+		truncated = truncated || fieldTotal > len(fieldIDs)
+		if i == 0 {
+			result = fieldIDs
+			total = fieldTotal
+		} else {
+			result = intersectIDs(result, fieldIDs)
+		}
+		if len(result) == 0 && !truncated {
+			break
+		}
+	}
+	if truncated && len(fields) > 1 {
+		// cached lists are limited to max rows, so intersection can't be trusted
+		ids, totalRows = searchIDsWithCount(true, engine, buildCompositeWhere(schema, definition, normalized), pager, schema.t)
+	} else {
+		// total of one cached list is stored with it, intersection is exact when lists are not truncated
+		totalRows = len(result)
+		if len(fields) <= 1 {
+			totalRows = total
+		}
+		start := (pager.GetCurrentPage() - 1) * pager.GetPageSize()
+		if start > len(result) {
+			start = len(result)
+		}
+		end := start + pager.GetPageSize()
+		if end > len(result) {
+			end = len(result)
+		}
+		ids = result[start:end]
+	}
+	_, is := entities.(Entity)
+	if !is {
+		engine.LoadByIDs(ids, entities, references...)
+	}
+	return totalRows, ids
+}
+
+// returns IDs matching one field (or all rows when field is empty) and total number of matching rows,
+// list is cut to max rows
+func getCompositeIDs(engine *Engine, schema *tableSchema, definition *cachedQueryDefinition, indexName string,
+	field string, argument interface{}) ([]uint64, int) {
+	localCache, hasLocalCache := schema.getQueryLocalCache(engine, definition)
+	redisCache, hasRedis := schema.getQueryRedisCache(engine, definition)
+	if !hasLocalCache && !hasRedis {
+		panic(errors.Errorf("cache search not allowed for entity without cache: '%s'", schema.t.String()))
+	}
+	arguments := map[string]interface{}{}
+	cacheKey := getCacheKeySearch(schema, getCompositeIndexName(indexName, field))
+	if field != "" {
+		cacheKey = getCacheKeySearch(schema, getCompositeIndexName(indexName, field), argument)
+		arguments[field] = argument
+	}
+	var fromCache map[string]interface{}
+	if hasLocalCache {
+		fromCache = localCache.HMget(cacheKey, "1")
+	}
+	if fromCache["1"] == nil && hasRedis {
+		fromCache = redisCache.HMget(cacheKey, "1")
+		if fromCache["1"] != nil && hasLocalCache {
+			localCache.HMset(cacheKey, map[string]interface{}{"1": fromCache["1"]})
+		}
+	}
+	if fromCache["1"] != nil {
+		values := strings.Split(fromCache["1"].(string), " ")
+		total, _ := strconv.Atoi(values[0])
+		ids := make([]uint64, len(values)-1)
+		for i := 1; i < len(values); i++ {
+			ids[i-1], _ = strconv.ParseUint(values[i], 10, 64)
+		}
+		return ids, total
+	}
+	where := buildCompositeWhere(schema, definition, arguments)
+	ids, total := searchIDsWithCount(true, engine, where, NewPager(1, definition.Max), schema.t)
+	if total > definition.Max {
+		engine.Log().Warn("[ORM][CACHED_QUERY][MAX_ROWS]", apexLog.Fields{"entity": schema.t.String(),
+			"index": indexName, "field": field, "max": definition.Max, "total": total})
+	}
+	values := append([]uint64{uint64(total)}, ids...)
+	fields := map[string]interface{}{"1": strings.Trim(fmt.Sprintf("%v", values), "[]")}
+	if hasLocalCache {
+		localCache.HMset(cacheKey, fields)
+	}
+	if hasRedis {
+		redisCache.HMset(cacheKey, fields)
+	}
+	return ids, total
+}
+
+// builds where from index query, conditions of fields without argument are replaced with 1
+func buildCompositeWhere(schema *tableSchema, definition *cachedQueryDefinition, arguments map[string]interface{}) *Where {
+	condition, _, _ := NewWhere(definition.Query).splitTrailingClauses()
+	query := condition.String()
+	type fieldCondition struct {
+		position int
+		field    string
+	}
+	conditions := make([]fieldCondition, 0, len(definition.QueryFields))
+	for _, field := range definition.QueryFields {
+		if field == "FakeDelete" {
+			continue
+		}
+		position := strings.Index(query, fmt.Sprintf("`%s` = ?", field))
+		if position >= 0 {
+			conditions = append(conditions, fieldCondition{position, field})
+		}
+	}
+	sort.Slice(conditions, func(i, j int) bool {
+		return conditions[i].position < conditions[j].position
+	})
+	var builder strings.Builder
+	parameters := make([]interface{}, 0, len(arguments))
+	last := 0
+	for _, c := range conditions {
+		builder.WriteString(query[last:c.position])
+		argument, has := arguments[c.field]
+		if !has {
+			builder.WriteString("1")
+		} else if argument == nil {
+			builder.WriteString(fmt.Sprintf("`%s` IS NULL", c.field))
+		} else {
+			builder.WriteString(fmt.Sprintf("`%s` = %s", c.field, schema.placeholder(c.field)))
+			parameters = append(parameters, argument)
+		}
+		last = c.position + len(fmt.Sprintf("`%s` = ?", c.field))
+	}
+	builder.WriteString(query[last:])
+	builder.WriteString(" ORDER BY `ID`")
+	return &Where{query: builder.String(), parameters: parameters}
+}
+
+// converts argument to the same form as flush stores in entity data, so cache keys match
+func normalizeCompositeArgument(schema *tableSchema, field string, argument interface{}) interface{} {
+	attributes := schema.tags[field]
+	switch value := argument.(type) {
+	case nil:
+		return nil
+	case Entity:
+		_, isUUID := schema.uuidReferences[field]
+		if isUUID {
+			return getUUIDReferenceValue(schema, field, reflect.ValueOf(value))
+		}
+		if value.GetID() == 0 {
+			return nil
+		}
+		return strconv.FormatUint(value.GetID(), 10)
+	case bool:
+		if value {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return formatTime(schema, attributes, value)
+	case *time.Time:
+		if value == nil {
+			return nil
+		}
+		return formatTime(schema, attributes, *value)
+	case string:
+		if value == "" {
+			if attributes["required"] == "true" {
+				return ""
+			}
+			return nil
+		}
+		uuid, isUUID := attributes["uuidBinary"]
+		if isUUID {
+			return uuidToHex(value, uuid == "ordered")
+		}
+		return value
+	case fmt.Stringer:
+		return value.String()
+	}
+	return fmt.Sprintf("%v", argument)
+}
+
+func getCompositeIndexName(indexName string, field string) string {
+	return indexName + ":" + field
+}
+
+func getCompositeKeys(schema *tableSchema, indexName string, definition *cachedQueryDefinition,
+	bind map[string]interface{}, data map[string]interface{}, addedDeleted bool) []string {
+	keys := make([]string, 0)
+	if addedDeleted {
+		keys = append(keys, getCacheKeySearch(schema, getCompositeIndexName(indexName, "")))
+	}
+	for _, field := range definition.QueryFields {
+		if field == "FakeDelete" {
+			continue
+		}
+		_, has := bind[field]
+		if addedDeleted || has {
+			keys = append(keys, getCacheKeySearch(schema, getCompositeIndexName(indexName, field), data[field]))
+		}
+	}
+	return keys
+}
+
+func hasCompositeField(definition *cachedQueryDefinition, field string) bool {
+	for _, queryField := range definition.QueryFields {
+		if queryField == field {
+			return true
+		}
+	}
+	return false
+}
+
+func intersectIDs(left []uint64, right []uint64) []uint64 {
+	result := make([]uint64, 0)
+	for i, j := 0, 0; i < len(left) && j < len(right); {
+		if left[i] == right[j] {
+			result = append(result, left[i])
+			i++
+			j++
+		} else if left[i] < right[j] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
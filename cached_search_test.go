@@ -1,6 +1,8 @@
 package orm
 
 import (
+	"bytes"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -237,3 +239,371 @@ func TestCachedSearchErrors(t *testing.T) {
 		_ = engine.CachedSearchOne(&row2, "IndexName", 10)
 	})
 }
+
+type cachedChildEntity struct {
+	ORM    `orm:"redisCache;cached_collection=Parent"`
+	ID     uint
+	Name   string
+	Parent *cachedSearchRefEntity
+}
+
+func TestCachedChildren(t *testing.T) {
+	var entity *cachedChildEntity
+	var entityRef *cachedSearchRefEntity
+	engine := PrepareTables(t, &Registry{}, entityRef, entity)
+
+	parent := &cachedSearchRefEntity{Name: "Parent"}
+	engine.Track(parent)
+	engine.Flush()
+	for i := 1; i <= 3; i++ {
+		engine.Track(&cachedChildEntity{Name: "Child " + strconv.Itoa(i), Parent: parent})
+	}
+	engine.Flush()
+
+	var children []*cachedChildEntity
+	totalRows := engine.CachedChildren(parent, &children, nil)
+	assert.Equal(t, 3, totalRows)
+	assert.Len(t, children, 3)
+	assert.Equal(t, "Child 1", children[0].Name)
+
+	DBLogger := memory.New()
+	engine.AddQueryLogger(DBLogger, apexLog.InfoLevel, QueryLoggerSourceDB)
+	totalRows = engine.CachedChildren(parent, &children, nil)
+	assert.Equal(t, 3, totalRows)
+	assert.Len(t, DBLogger.Entries, 0)
+
+	engine.Track(&cachedChildEntity{Name: "Child 4", Parent: parent})
+	engine.Flush()
+	totalRows = engine.CachedChildren(parent, &children, nil)
+	assert.Equal(t, 4, totalRows)
+	assert.Len(t, children, 4)
+
+	engine.MarkToDelete(children[0])
+	engine.Flush()
+	totalRows = engine.CachedChildren(parent, &children, nil)
+	assert.Equal(t, 3, totalRows)
+	assert.Equal(t, "Child 2", children[0].Name)
+
+	var invalid []*cachedSearchRefEntity
+	assert.PanicsWithError(t, "cached collection orm.cachedSearchRefEntity in orm.cachedSearchRefEntity not found", func() {
+		engine.CachedChildren(parent, &invalid, nil)
+	})
+}
+
+func TestCachedSearchOrder(t *testing.T) {
+	schema := &tableSchema{t: reflect.TypeOf(cachedSearchEntity{})}
+	order := parseCachedOrder(schema, "`Age` DESC, Name")
+	assert.Equal(t, []cachedOrderField{{field: "Age", desc: true}, {field: "Name"}}, order)
+	assert.Panics(t, func() {
+		parseCachedOrder(schema, "Missing")
+	})
+	assert.Panics(t, func() {
+		parseCachedOrder(schema, "Age UP")
+	})
+
+	older := reflect.ValueOf(cachedSearchEntity{Age: 30, Name: "B"})
+	younger := reflect.ValueOf(cachedSearchEntity{Age: 20, Name: "A"})
+	sameAge := reflect.ValueOf(cachedSearchEntity{Age: 30, Name: "A"})
+	assert.True(t, compareCachedOrder(older, younger, order))
+	assert.False(t, compareCachedOrder(younger, older, order))
+	assert.True(t, compareCachedOrder(sameAge, older, order))
+
+	now := time.Now()
+	later := now.Add(time.Hour)
+	byAdded := parseCachedOrder(schema, "Added")
+	assert.True(t, compareCachedOrder(reflect.ValueOf(cachedSearchEntity{}), reflect.ValueOf(cachedSearchEntity{Added: &now}), byAdded))
+	assert.True(t, compareCachedOrder(reflect.ValueOf(cachedSearchEntity{Added: &now}), reflect.ValueOf(cachedSearchEntity{Added: &later}), byAdded))
+}
+
+func TestCachedSearchWithOptions(t *testing.T) {
+	var entity *cachedSearchEntity
+	var entityRef *cachedSearchRefEntity
+	engine := PrepareTables(t, &Registry{}, entityRef, entity)
+	engine.GetRegistry().GetTableSchemaForEntity(entity).(*tableSchema).redisCacheName = "default"
+	for i := 1; i <= 5; i++ {
+		engine.Track(&cachedSearchEntity{Name: "Name " + strconv.Itoa(i), Age: uint16(10 + i%2)})
+	}
+	engine.Flush()
+
+	var rows []*cachedSearchEntity
+	options := &CachedSearchOptions{OrderBy: "Age DESC, Name DESC"}
+	totalRows := engine.CachedSearchWithOptions(&rows, "IndexAll", NewPager(1, 2), options)
+	assert.Equal(t, 5, totalRows)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "Name 5", rows[0].Name)
+	assert.Equal(t, "Name 3", rows[1].Name)
+
+	options.Filter = func(entity Entity) bool {
+		return entity.(*cachedSearchEntity).Age == 10
+	}
+	totalRows = engine.CachedSearchWithOptions(&rows, "IndexAll", nil, options)
+	assert.Equal(t, 2, totalRows)
+	assert.Equal(t, "Name 4", rows[0].Name)
+	assert.Equal(t, "Name 2", rows[1].Name)
+
+	DBLogger := memory.New()
+	engine.AddQueryLogger(DBLogger, apexLog.InfoLevel, QueryLoggerSourceDB, QueryLoggerSourceRedis)
+	totalRows, ids := engine.CachedSearchIDs(entity, "IndexAll", NewPager(1, 2))
+	assert.Equal(t, 5, totalRows)
+	assert.Equal(t, []uint64{1, 2}, ids)
+	assert.Len(t, DBLogger.Entries, 1)
+
+	ids, totalRows, err := engine.CachedSearchIDsWithCheck(entity, "IndexAll", NewPager(2, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, totalRows)
+	assert.Equal(t, []uint64{3, 4}, ids)
+	_, _, err = engine.CachedSearchIDsWithCheck(entity, "Invalid", nil)
+	assert.EqualError(t, err, "index Invalid not found")
+}
+
+type cachedSearchReferencedEntity struct {
+	ORM            `orm:"localCache"`
+	ID             uint
+	Age            uint8                  `orm:"index=ParentAge:2"`
+	Parent         *cachedSearchRefEntity `orm:"index=ParentAge;onDelete=SET NULL"`
+	IndexParent    *CachedQuery           `query:":Parent = ?"`
+	IndexParentAge *CachedQuery           `query:":Parent = ? AND :Age = ?"`
+}
+
+func TestCachedSearchReferenceDeleted(t *testing.T) {
+	var entity *cachedSearchReferencedEntity
+	var entityRef *cachedSearchRefEntity
+	engine := PrepareTables(t, &Registry{}, entityRef, entity)
+	parent := &cachedSearchRefEntity{Name: "Parent"}
+	engine.TrackAndFlush(parent)
+	engine.TrackAndFlush(&cachedSearchReferencedEntity{Parent: parent, Age: 10}, &cachedSearchReferencedEntity{Parent: parent, Age: 10})
+
+	var rows []*cachedSearchReferencedEntity
+	assert.Equal(t, 2, engine.CachedSearch(&rows, "IndexParent", nil, 1))
+	assert.Equal(t, 2, engine.CachedSearch(&rows, "IndexParentAge", nil, 1, 10))
+
+	engine.MarkToDelete(parent)
+	engine.Flush()
+	assert.Equal(t, 0, engine.CachedSearch(&rows, "IndexParent", nil, 1))
+	assert.Equal(t, 0, engine.CachedSearch(&rows, "IndexParentAge", nil, 1, 10))
+}
+
+func TestCachedSearchTotal(t *testing.T) {
+	var entity *cachedSearchEntity
+	var entityRef *cachedSearchRefEntity
+	engine := PrepareTables(t, &Registry{}, entityRef, entity)
+	engine.GetRegistry().GetTableSchemaForEntity(entity).(*tableSchema).redisCacheName = "default"
+	for i := 1; i <= 1005; i++ {
+		engine.Track(&cachedSearchEntity{Name: "Name " + strconv.Itoa(i), Age: 10})
+	}
+	engine.Flush()
+
+	var rows []*cachedSearchEntity
+	totalRows := engine.CachedSearch(&rows, "IndexAll", NewPager(2, 1000))
+	assert.Equal(t, 1005, totalRows)
+	assert.Len(t, rows, 5)
+	assert.Equal(t, "Name 1001", rows[0].Name)
+
+	DBLogger := memory.New()
+	engine.AddQueryLogger(DBLogger, apexLog.InfoLevel, QueryLoggerSourceDB)
+	totalRows = engine.CachedSearch(&rows, "IndexAll", NewPager(1, 10))
+	assert.Equal(t, 1005, totalRows)
+	assert.Len(t, rows, 10)
+	assert.Len(t, DBLogger.Entries, 1)
+	total, has := engine.GetRedis().HMget(getCacheKeySearch(engine.GetRegistry().GetTableSchemaForEntity(entity).(*tableSchema), "IndexAll"),
+		"total")["total"]
+	assert.True(t, has)
+	assert.Equal(t, "1005", total)
+}
+
+type cachedSearchTwoTierEntity struct {
+	ORM
+	ID       uint
+	Age      uint16       `orm:"index=Age"`
+	IndexAge *CachedQuery `query:":Age = ?" orm:"localCache;redisCache"`
+}
+
+func TestCachedSearchTwoTier(t *testing.T) {
+	var entity *cachedSearchTwoTierEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+	engine.TrackAndFlush(&cachedSearchTwoTierEntity{Age: 10}, &cachedSearchTwoTierEntity{Age: 10})
+
+	var rows []*cachedSearchTwoTierEntity
+	assert.Equal(t, 2, engine.CachedSearch(&rows, "IndexAge", nil, 10))
+	DBLogger := memory.New()
+	engine.AddQueryLogger(DBLogger, apexLog.InfoLevel, QueryLoggerSourceDB, QueryLoggerSourceRedis)
+	assert.Equal(t, 2, engine.CachedSearch(&rows, "IndexAge", nil, 10))
+	assert.Len(t, DBLogger.Entries, 1)
+
+	engine.GetLocalCache().Clear()
+	DBLogger.Entries = make([]*apexLog.Entry, 0)
+	assert.Equal(t, 2, engine.CachedSearch(&rows, "IndexAge", nil, 10))
+	assert.Len(t, DBLogger.Entries, 2)
+
+	engine.TrackAndFlush(&cachedSearchTwoTierEntity{Age: 10})
+	assert.Equal(t, 3, engine.CachedSearch(&rows, "IndexAge", nil, 10))
+	engine.GetLocalCache().Clear()
+	assert.Equal(t, 3, engine.CachedSearch(&rows, "IndexAge", nil, 10))
+}
+
+func TestCachedQueryPools(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterRedis("localhost:6381", 15)
+	_, _, err := getCachedQueryPools(registry, map[string]string{"localCache": "missing"})
+	assert.EqualError(t, err, "local cache pool 'missing' not found")
+	local, redis, err := getCachedQueryPools(registry, map[string]string{"redisCache": "true"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", local)
+	assert.Equal(t, "default", redis)
+}
+
+type cachedSearchMaxEntity struct {
+	ORM      `orm:"localCache"`
+	ID       uint
+	IndexAll *CachedQuery `query:"" orm:"max=3"`
+}
+
+func TestCachedSearchMax(t *testing.T) {
+	var entity *cachedSearchMaxEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+	for i := 0; i < 5; i++ {
+		engine.Track(&cachedSearchMaxEntity{})
+	}
+	engine.Flush()
+	buffer := &bytes.Buffer{}
+	engine.EnableProductionLogging(buffer)
+
+	var rows []*cachedSearchMaxEntity
+	totalRows := engine.CachedSearch(&rows, "IndexAll", nil)
+	assert.Equal(t, 5, totalRows)
+	assert.Len(t, rows, 3)
+	assert.Contains(t, buffer.String(), "[ORM][CACHED_QUERY][MAX_ROWS]")
+	assert.PanicsWithError(t, "max cache index page size (3) exceeded IndexAll", func() {
+		engine.CachedSearch(&rows, "IndexAll", NewPager(2, 2))
+	})
+}
+
+type cachedSearchCompositeEntity struct {
+	ORM            `orm:"localCache;redisCache"`
+	ID             uint
+	Tenant         uint   `orm:"index=TenantStatus"`
+	Status         string `orm:"index=Status"`
+	FakeDelete     bool
+	IndexComposite *CachedQuery `query:":Tenant = ? AND :Status = ?" orm:"composite"`
+	IndexSmall     *CachedQuery `query:":Tenant = ? AND :Status = ?" orm:"composite;max=2"`
+}
+
+func TestCachedSearchComposite(t *testing.T) {
+	var entity *cachedSearchCompositeEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+	engine.TrackAndFlush(&cachedSearchCompositeEntity{Tenant: 1, Status: "new"}, &cachedSearchCompositeEntity{Tenant: 1, Status: "done"},
+		&cachedSearchCompositeEntity{Tenant: 2, Status: "new"}, &cachedSearchCompositeEntity{Tenant: 1, Status: "new"})
+
+	var rows []*cachedSearchCompositeEntity
+	totalRows := engine.CachedSearchComposite(&rows, "IndexComposite", nil, map[string]interface{}{"Tenant": 1})
+	assert.Equal(t, 3, totalRows)
+	assert.Len(t, rows, 3)
+	totalRows = engine.CachedSearchComposite(&rows, "IndexComposite", nil, map[string]interface{}{"Tenant": 1, "Status": "new"})
+	assert.Equal(t, 2, totalRows)
+	assert.Equal(t, uint(1), rows[0].ID)
+	assert.Equal(t, uint(4), rows[1].ID)
+	totalRows = engine.CachedSearchComposite(&rows, "IndexComposite", NewPager(2, 1), map[string]interface{}{"Status": "new"})
+	assert.Equal(t, 3, totalRows)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, uint(3), rows[0].ID)
+	assert.Equal(t, 4, engine.CachedSearchComposite(&rows, "IndexComposite", nil, nil))
+	totalRows = engine.CachedSearchComposite(&rows, "IndexSmall", nil, map[string]interface{}{"Tenant": 1, "Status": "new"})
+	assert.Equal(t, 2, totalRows)
+	assert.Equal(t, uint(4), rows[1].ID)
+	totalRows = engine.CachedSearchComposite(&rows, "IndexSmall", nil, map[string]interface{}{"Tenant": 1})
+	assert.Equal(t, 3, totalRows)
+	assert.Len(t, rows, 2)
+	totalRows = engine.CachedSearchComposite(&rows, "IndexSmall", nil, map[string]interface{}{"Tenant": 1})
+	assert.Equal(t, 3, totalRows)
+
+	DBLogger := memory.New()
+	engine.AddQueryLogger(DBLogger, apexLog.InfoLevel, QueryLoggerSourceDB)
+	totalRows = engine.CachedSearchComposite(&rows, "IndexComposite", nil, map[string]interface{}{"Tenant": 1, "Status": "new"})
+	assert.Equal(t, 2, totalRows)
+	assert.Len(t, DBLogger.Entries, 0)
+
+	rows[0].Status = "done"
+	engine.TrackAndFlush(rows[0])
+	assert.Equal(t, 1, engine.CachedSearchComposite(&rows, "IndexComposite", nil, map[string]interface{}{"Tenant": 1, "Status": "new"}))
+	assert.Equal(t, 2, engine.CachedSearchComposite(&rows, "IndexComposite", nil, map[string]interface{}{"Status": "done"}))
+	assert.Equal(t, 3, engine.CachedSearchComposite(&rows, "IndexComposite", nil, map[string]interface{}{"Tenant": 1}))
+
+	engine.MarkToDelete(rows[0])
+	engine.Flush()
+	assert.Equal(t, 2, engine.CachedSearchComposite(&rows, "IndexComposite", nil, map[string]interface{}{"Tenant": 1}))
+	assert.Equal(t, 3, engine.CachedSearchComposite(&rows, "IndexComposite", nil, nil))
+
+	assert.PanicsWithError(t, "field Missing in composite index IndexComposite not found", func() {
+		engine.CachedSearchComposite(&rows, "IndexComposite", nil, map[string]interface{}{"Missing": 1})
+	})
+}
+
+func TestCompositeWhere(t *testing.T) {
+	schema := &tableSchema{tags: map[string]map[string]string{"Created": {"time": "true"}}}
+	definition := &cachedQueryDefinition{Query: "`Tenant` = ? AND `Status` = ? AND `Type` != 'x' ORDER BY `ID`",
+		QueryFields: []string{"Status", "Tenant", "FakeDelete"}}
+	where := buildCompositeWhere(schema, definition, map[string]interface{}{"Status": "new"})
+	assert.Equal(t, "1 AND `Status` = ? AND `Type` != 'x' ORDER BY `ID`", where.String())
+	assert.Equal(t, []interface{}{"new"}, where.GetParameters())
+	where = buildCompositeWhere(schema, definition, map[string]interface{}{"Status": 2, "Tenant": nil})
+	assert.Equal(t, "`Tenant` IS NULL AND `Status` = ? AND `Type` != 'x' ORDER BY `ID`", where.String())
+	assert.Equal(t, []interface{}{2}, where.GetParameters())
+
+	assert.Equal(t, "1", normalizeCompositeArgument(schema, "Active", true))
+	assert.Equal(t, "10", normalizeCompositeArgument(schema, "Tenant", 10))
+	assert.Nil(t, normalizeCompositeArgument(schema, "Status", ""))
+	assert.Equal(t, "2020-01-02 03:04:05", normalizeCompositeArgument(schema, "Created",
+		time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+}
+
+type cachedSearchCompositeInvalidEntity struct {
+	ORM            `orm:"localCache"`
+	ID             uint
+	Age            uint         `orm:"index=Age"`
+	IndexComposite *CachedQuery `query:":Age > ?" orm:"composite"`
+}
+
+type cachedSearchCompositeNoIndexEntity struct {
+	ORM            `orm:"localCache"`
+	ID             uint
+	Age            uint         `orm:"index=AgeName"`
+	Name           string       `orm:"index=AgeName:2"`
+	IndexComposite *CachedQuery `query:":Age = ? AND :Name = ?" orm:"composite"`
+}
+
+func TestCachedSearchCompositeSchema(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterLocalCache(100)
+	registry.sqlClients = map[string]*DBConfig{"default": {}}
+	_, err := initTableSchema(registry, reflect.TypeOf(cachedSearchCompositeInvalidEntity{}))
+	assert.EqualError(t, err, "cached query IndexComposite in orm.cachedSearchCompositeInvalidEntity: "+
+		"composite condition for field Age other than equal not supported")
+	_, err = initTableSchema(registry, reflect.TypeOf(cachedSearchCompositeNoIndexEntity{}))
+	assert.EqualError(t, err, "missing index for field Name in composite cached query 'IndexComposite' in orm.cachedSearchCompositeNoIndexEntity")
+}
+
+func TestSearchWithFakeDeleted(t *testing.T) {
+	var entity *cachedSearchCompositeEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+	engine.TrackAndFlush(&cachedSearchCompositeEntity{Tenant: 1, Status: "new"}, &cachedSearchCompositeEntity{Tenant: 1, Status: "done"})
+	entity = &cachedSearchCompositeEntity{}
+	engine.LoadByID(1, entity)
+	engine.MarkToDelete(entity)
+	engine.Flush()
+
+	var rows []*cachedSearchCompositeEntity
+	assert.Equal(t, 1, engine.SearchWithCount(NewWhere("`Tenant` = ?", 1), nil, &rows))
+	assert.Len(t, rows, 1)
+	assert.Equal(t, 2, engine.SearchWithCount(NewWhere("`Tenant` = ?", 1).WithFakeDeleted(), nil, &rows))
+	assert.Len(t, rows, 2)
+	assert.True(t, rows[0].FakeDelete)
+	assert.False(t, rows[1].FakeDelete)
+
+	options := &CachedSearchOptions{WithFakeDeleted: true}
+	assert.Equal(t, 1, engine.CachedSearchWithOptions(&rows, "IndexComposite", nil, options, 1, "new"))
+	assert.Len(t, rows, 1)
+	assert.True(t, rows[0].FakeDelete)
+	assert.Panics(t, func() {
+		engine.CachedSearchWithOptions(&rows, "IndexComposite", nil, &CachedSearchOptions{WithFakeDeleted: true, OrderBy: "ID"})
+	})
+}
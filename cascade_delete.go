@@ -0,0 +1,81 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+func markToDeleteCascade(engine *Engine, entities ...Entity) {
+	visited := make(map[reflect.Type]map[uint64]bool)
+	collected := make([]Entity, 0)
+	for _, entity := range entities {
+		collected = collectCascade(engine, entity, visited, collected)
+	}
+	ranks := make(map[reflect.Type]int)
+	for _, entity := range collected {
+		rankCascadeType(engine, entity.getORM().tableSchema, ranks, make(map[reflect.Type]bool))
+	}
+	sort.SliceStable(collected, func(i, j int) bool {
+		return ranks[collected[i].getORM().tableSchema.t] < ranks[collected[j].getORM().tableSchema.t]
+	})
+	engine.MarkToDelete(collected...)
+}
+
+func collectCascade(engine *Engine, entity Entity, visited map[reflect.Type]map[uint64]bool, collected []Entity) []Entity {
+	schema := initIfNeeded(engine, entity).tableSchema
+	id := entity.GetID()
+	if id > 0 {
+		if visited[schema.t] == nil {
+			visited[schema.t] = make(map[uint64]bool)
+		}
+		if visited[schema.t][id] {
+			return collected
+		}
+		visited[schema.t][id] = true
+		for _, refT := range sortedUsageTypes(engine, schema) {
+			for _, refColumn := range schema.GetUsage(engine.registry)[refT] {
+				/* #nosec */
+				where := NewWhere(fmt.Sprintf("`%s` = ?", refColumn), id)
+				searchInIDOrder(engine, refT, where, 1000, func(rows reflect.Value) {
+					for i := 0; i < rows.Len(); i++ {
+						collected = collectCascade(engine, rows.Index(i).Interface().(Entity), visited, collected)
+					}
+				})
+			}
+		}
+	}
+	return append(collected, entity)
+}
+
+func rankCascadeType(engine *Engine, schema *tableSchema, ranks map[reflect.Type]int, path map[reflect.Type]bool) int {
+	rank, has := ranks[schema.t]
+	if has {
+		return rank
+	}
+	path[schema.t] = true
+	for _, refT := range sortedUsageTypes(engine, schema) {
+		if path[refT] {
+			continue
+		}
+		childRank := rankCascadeType(engine, getTableSchema(engine.registry, refT), ranks, path)
+		if childRank+1 > rank {
+			rank = childRank + 1
+		}
+	}
+	delete(path, schema.t)
+	ranks[schema.t] = rank
+	return rank
+}
+
+func sortedUsageTypes(engine *Engine, schema *tableSchema) []reflect.Type {
+	usage := schema.GetUsage(engine.registry)
+	types := make([]reflect.Type, 0, len(usage))
+	for t := range usage {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].String() < types[j].String()
+	})
+	return types
+}
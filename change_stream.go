@@ -0,0 +1,104 @@
+package orm
+
+import (
+	"context"
+	"encoding/json"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/juju/errors"
+)
+
+const changesQueuePrefix = "orm_changes_"
+
+type EntityChange struct {
+	EntityName string
+	ID         uint64
+	Added      bool
+	Updated    bool
+	Deleted    bool
+	Before     map[string]interface{}
+	After      map[string]interface{}
+}
+
+func (tableSchema *tableSchema) getChangesQueueName() string {
+	return changesQueuePrefix + tableSchema.t.String()
+}
+
+func addEntityChange(engine *Engine, schema *tableSchema, id uint64, before map[string]interface{}, after map[string]interface{}) {
+	if !schema.hasChanges {
+		return
+	}
+	change := &EntityChange{EntityName: schema.t.String(), ID: id, Added: before == nil, Updated: before != nil && after != nil,
+		Deleted: after == nil}
+	if before != nil {
+		change.Before = make(map[string]interface{}, len(before))
+		for k, v := range before {
+			change.Before[k] = v
+		}
+	}
+	if after != nil {
+		change.After = make(map[string]interface{}, len(after))
+		for k, v := range after {
+			change.After[k] = v
+		}
+	}
+	engine.afterCommitChanges = append(engine.afterCommitChanges, change)
+}
+
+func publishEntityChanges(engine *Engine) {
+	changes := engine.afterCommitChanges
+	engine.afterCommitChanges = nil
+	for _, change := range changes {
+		t := engine.registry.entities[change.EntityName]
+		asJSON, _ := jsoniter.ConfigFastest.Marshal(change)
+		engine.GetRabbitMQRouter(getTableSchema(engine.registry, t).getChangesQueueName()).Publish("", asJSON)
+	}
+}
+
+// getChangesSubscriberQueue returns queue bound to change stream router, every subscriber receives all changes
+func getChangesSubscriberQueue(engine *Engine, schema *tableSchema, subscriber string) *RabbitMQRouter {
+	router := engine.GetRabbitMQRouter(schema.getChangesQueueName())
+	config := &RabbitMQQueueConfig{Name: router.config.Name + "_" + subscriber, Router: router.config.Router, Durable: true}
+	return &RabbitMQRouter{&rabbitMQChannel{engine: engine, connection: router.connection, config: config}}
+}
+
+func subscribeChanges(ctx context.Context, engine *Engine, subscriber string, handler func(EntityChange), entities []Entity) {
+	if subscriber == "" {
+		panic(errors.NotValidf("empty change stream subscriber name"))
+	}
+	consumers := make([]RabbitMQConsumer, len(entities))
+	queues := make([]string, len(entities))
+	for i, entity := range entities {
+		schema := initIfNeeded(engine, entity).tableSchema
+		if !schema.hasChanges {
+			panic(errors.Errorf("entity %s has no change stream", schema.t.String()))
+		}
+		queue := getChangesSubscriberQueue(engine, schema, subscriber)
+		queues[i] = queue.config.Name
+		consumers[i] = queue.NewConsumer(subscriber)
+		consumers[i].DisableLoop()
+	}
+	defer func() {
+		for _, consumer := range consumers {
+			consumer.Close()
+		}
+	}()
+	for ctx.Err() == nil {
+		for i, consumer := range consumers {
+			queue := queues[i]
+			consumer.Consume(func(items [][]byte) {
+				for _, item := range items {
+					var change EntityChange
+					err := json.Unmarshal(item, &change)
+					if err != nil {
+						panic(errors.Annotatef(err, "invalid change event in queue %s", queue))
+					}
+					handler(change)
+				}
+			})
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
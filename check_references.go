@@ -0,0 +1,148 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+const checkReferencesBatchSize = 1000
+
+type ReferencesFixMode int
+
+const (
+	ReferencesFixNone ReferencesFixMode = iota
+	ReferencesFixSetNull
+	ReferencesFixDelete
+)
+
+type BrokenReference struct {
+	Entity     string
+	Field      string
+	Reference  string
+	IDs        []uint64
+	MissingIDs []uint64
+	Fixed      bool
+}
+
+func checkReferences(engine *Engine, mode ReferencesFixMode, entities ...Entity) []*BrokenReference {
+	report := make([]*BrokenReference, 0)
+	for _, entity := range entities {
+		schema := initIfNeeded(engine, entity).tableSchema
+		fields := make([]string, len(schema.refOne))
+		copy(fields, schema.refOne)
+		sort.Strings(fields)
+		for _, field := range fields {
+			_, isUUID := schema.uuidReferences[field]
+			if isUUID {
+				continue
+			}
+			refSchema := getTableSchema(engine.registry, engine.registry.entities[schema.tags[field]["ref"]])
+			broken := checkReferenceField(engine, schema, refSchema, field)
+			if broken == nil {
+				continue
+			}
+			if mode != ReferencesFixNone {
+				broken.Fixed = fixReferenceField(engine, schema, field, broken.IDs, mode)
+			}
+			report = append(report, broken)
+		}
+	}
+	return report
+}
+
+func checkReferenceField(engine *Engine, schema *tableSchema, refSchema *tableSchema, field string) *BrokenReference {
+	var broken *BrokenReference
+	lastID := uint64(0)
+	for {
+		/* #nosec */
+		query := fmt.Sprintf("SELECT `ID`, `%s` FROM `%s` WHERE `%s` IS NOT NULL AND `ID` > ? ORDER BY `ID` LIMIT %d",
+			field, schema.getTableName(engine), field, checkReferencesBatchSize)
+		results, def := schema.GetMysql(engine).Query(query, lastID)
+		rows := make(map[uint64][]uint64)
+		refIDs := make([]uint64, 0)
+		total := 0
+		for results.Next() {
+			var id, refID uint64
+			results.Scan(&id, &refID)
+			if rows[refID] == nil {
+				refIDs = append(refIDs, refID)
+			}
+			rows[refID] = append(rows[refID], id)
+			lastID = id
+			total++
+		}
+		def()
+		if len(refIDs) > 0 {
+			existing := make(map[uint64]bool, len(refIDs))
+			where := NewWhere("`ID` IN ?", refIDs)
+			/* #nosec */
+			query = fmt.Sprintf("SELECT `ID` FROM `%s` WHERE %s", refSchema.getTableName(engine), where)
+			results, def = refSchema.GetMysql(engine).Query(query, where.GetParameters()...)
+			for results.Next() {
+				var id uint64
+				results.Scan(&id)
+				existing[id] = true
+			}
+			def()
+			for _, refID := range refIDs {
+				if existing[refID] {
+					continue
+				}
+				if broken == nil {
+					broken = &BrokenReference{Entity: schema.t.String(), Field: field, Reference: refSchema.t.String(),
+						IDs: make([]uint64, 0), MissingIDs: make([]uint64, 0)}
+				}
+				broken.IDs = append(broken.IDs, rows[refID]...)
+				broken.MissingIDs = append(broken.MissingIDs, refID)
+			}
+		}
+		if total < checkReferencesBatchSize {
+			break
+		}
+	}
+	if broken != nil {
+		sort.Slice(broken.IDs, func(i, j int) bool { return broken.IDs[i] < broken.IDs[j] })
+	}
+	return broken
+}
+
+func fixReferenceField(engine *Engine, schema *tableSchema, field string, ids []uint64, mode ReferencesFixMode) bool {
+	if mode == ReferencesFixSetNull && schema.tags[field]["required"] == "true" {
+		return false
+	}
+	_, isField := schema.t.FieldByName(field)
+	if !isField {
+		return false
+	}
+	for start := 0; start < len(ids); start += checkReferencesBatchSize {
+		end := start + checkReferencesBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		rows := reflect.New(reflect.SliceOf(reflect.PtrTo(schema.t)))
+		engine.LoadByIDs(ids[start:end], rows.Interface())
+		// fixed rows are flushed without tracking, so entities tracked in engine are not flushed
+		fixed := make([]Entity, 0, rows.Elem().Len())
+		for i := 0; i < rows.Elem().Len(); i++ {
+			row := rows.Elem().Index(i)
+			if row.IsNil() {
+				continue
+			}
+			entity := row.Interface().(Entity)
+			if mode == ReferencesFixDelete && schema.hasFakeDelete {
+				row.Elem().FieldByName("FakeDelete").SetBool(true)
+			} else if mode == ReferencesFixDelete {
+				entity.getORM().attributes.delete = true
+			} else {
+				value := row.Elem().FieldByName(field)
+				value.Set(reflect.Zero(value.Type()))
+			}
+			fixed = append(fixed, entity)
+		}
+		if len(fixed) > 0 {
+			engine.flushEntities(false, false, fixed...)
+		}
+	}
+	return true
+}
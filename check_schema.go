@@ -0,0 +1,49 @@
+package orm
+
+import (
+	"fmt"
+
+	apexLog "github.com/apex/log"
+	"github.com/juju/errors"
+)
+
+func checkSchema(engine *Engine, applySafe bool) (ok bool, unsafe []Alter, safe []Alter, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			asErr, is := r.(error)
+			if !is {
+				asErr = errors.Errorf("%v", r)
+			}
+			ok = false
+			err = asErr
+			if engine.log != nil {
+				engine.log.Error(asErr, nil)
+			}
+		}
+	}()
+	for _, alter := range getAlters(engine) {
+		if alter.Safe {
+			safe = append(safe, alter)
+		} else {
+			unsafe = append(unsafe, alter)
+		}
+	}
+	for _, alter := range safe {
+		if applySafe {
+			engine.GetMysql(alter.Pool).Exec(alter.SQL)
+		}
+		if engine.log != nil {
+			engine.log.Info("[ORM][SCHEMA][SAFE]", apexLog.Fields{"pool": alter.Pool, "Query": alter.SQL, "applied": applySafe})
+		}
+	}
+	for _, alter := range unsafe {
+		if engine.log != nil {
+			engine.log.Warn("[ORM][SCHEMA][UNSAFE]", apexLog.Fields{"pool": alter.Pool, "Query": alter.SQL})
+		}
+	}
+	ok = len(unsafe) == 0 && (applySafe || len(safe) == 0)
+	if !ok && engine.log != nil {
+		engine.log.ErrorMessage(fmt.Sprintf("[ORM][SCHEMA] %d unsafe and %d safe alters", len(unsafe), len(safe)), nil)
+	}
+	return ok, unsafe, safe, nil
+}
@@ -0,0 +1,274 @@
+// Package cli implements orm command line tool.
+//
+// Schema commands need registered entities, so build own binary:
+//
+//	func main() {
+//		registry := orm.InitByYaml(config)
+//		registry.RegisterEntity(&UserEntity{})
+//		os.Exit(cli.Run(registry, os.Args[1:], os.Stdout, os.Stderr))
+//	}
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/summer-solutions/orm"
+)
+
+const usage = `Usage: orm <command> [options]
+
+Commands:
+  alters        show schema alters
+  apply-safe    apply safe alters, show unsafe ones
+  migration     write alters to SQL migration file
+  validate      validate registry and check schema is up to date
+  flush-cache   flush redis pool (-all) or keys with prefix (-prefix)
+  queues        show number of messages and consumers in RabbitMQ queues
+`
+
+var migrationNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+type command func(registry *orm.Registry, args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"alters":      showAlters,
+	"apply-safe":  applySafeAlters,
+	"migration":   generateMigration,
+	"validate":    validateSchema,
+	"flush-cache": flushCache,
+	"queues":      inspectQueues,
+}
+
+// Run executes command from args and returns process exit code
+func Run(registry *orm.Registry, args []string, stdout io.Writer, stderr io.Writer) (code int) {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	run, has := commands[args[0]]
+	if !has {
+		fmt.Fprintf(stderr, "unknown command '%s'\n\n%s", args[0], usage)
+		return 2
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(stderr, "%v\n", r)
+			code = 1
+		}
+	}()
+	err := run(registry, args[1:], stdout)
+	if err == flag.ErrHelp {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+func createEngine(registry *orm.Registry, withEntities bool) (*orm.Engine, error) {
+	validated, err := registry.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if withEntities && len(validated.GetEntities()) == 0 {
+		return nil, errors.New("no entities registered, build own binary with cli.Run and registered entities")
+	}
+	return validated.CreateEngine(), nil
+}
+
+func newFlagSet(name string, stdout io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	return flags
+}
+
+func showAlters(registry *orm.Registry, args []string, stdout io.Writer) error {
+	if err := newFlagSet("alters", stdout).Parse(args); err != nil {
+		return err
+	}
+	engine, err := createEngine(registry, true)
+	if err != nil {
+		return err
+	}
+	alters := engine.GetAlters()
+	for _, alter := range alters {
+		fmt.Fprintf(stdout, "-- pool: %s, safe: %t\n%s\n", alter.Pool, alter.Safe, alter.SQL)
+	}
+	if len(alters) == 0 {
+		fmt.Fprintln(stdout, "schema is up to date")
+	}
+	return nil
+}
+
+func applySafeAlters(registry *orm.Registry, args []string, stdout io.Writer) error {
+	if err := newFlagSet("apply-safe", stdout).Parse(args); err != nil {
+		return err
+	}
+	engine, err := createEngine(registry, true)
+	if err != nil {
+		return err
+	}
+	_, unsafe, safe, err := engine.CheckSchemaAndApplySafe()
+	if err != nil {
+		return err
+	}
+	for _, alter := range safe {
+		fmt.Fprintf(stdout, "-- applied in pool %s\n%s\n", alter.Pool, alter.SQL)
+	}
+	for _, alter := range unsafe {
+		fmt.Fprintf(stdout, "-- unsafe, not applied in pool %s\n%s\n", alter.Pool, alter.SQL)
+	}
+	if len(unsafe) > 0 {
+		return errors.Errorf("%d unsafe alters not applied", len(unsafe))
+	}
+	return nil
+}
+
+func generateMigration(registry *orm.Registry, args []string, stdout io.Writer) error {
+	flags := newFlagSet("migration", stdout)
+	dir := flags.String("dir", ".", "directory for migration file")
+	name := flags.String("name", "schema", "migration name")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !migrationNamePattern.MatchString(*name) {
+		return errors.NotValidf("migration name '%s'", *name)
+	}
+	engine, err := createEngine(registry, true)
+	if err != nil {
+		return err
+	}
+	alters := engine.GetAlters()
+	if len(alters) == 0 {
+		fmt.Fprintln(stdout, "schema is up to date")
+		return nil
+	}
+	content := buildMigration(alters)
+	file := filepath.Join(*dir, time.Now().UTC().Format("20060102150405")+"_"+*name+".sql")
+	/* #nosec */
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		return errors.Trace(err)
+	}
+	fmt.Fprintln(stdout, file)
+	return nil
+}
+
+func buildMigration(alters []orm.Alter) string {
+	content := ""
+	for _, alter := range alters {
+		content += "-- pool: " + alter.Pool + "\n"
+		if !alter.Safe {
+			content += "-- UNSAFE: review before running\n"
+		}
+		content += alter.SQL + "\n\n"
+	}
+	return content
+}
+
+func validateSchema(registry *orm.Registry, args []string, stdout io.Writer) error {
+	if err := newFlagSet("validate", stdout).Parse(args); err != nil {
+		return err
+	}
+	engine, err := createEngine(registry, true)
+	if err != nil {
+		return err
+	}
+	alters := engine.GetAlters()
+	if len(alters) > 0 {
+		return errors.Errorf("schema is not up to date, %d alters found", len(alters))
+	}
+	fmt.Fprintln(stdout, "registry is valid and schema is up to date")
+	return nil
+}
+
+func flushCache(registry *orm.Registry, args []string, stdout io.Writer) error {
+	flags := newFlagSet("flush-cache", stdout)
+	pool := flags.String("pool", "default", "redis pool")
+	prefix := flags.String("prefix", "", "remove only keys with this prefix")
+	all := flags.Bool("all", false, "remove all keys")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *all == (*prefix != "") {
+		return errors.New("use -prefix or -all")
+	}
+	engine, err := createEngine(registry, false)
+	if err != nil {
+		return err
+	}
+	redis := engine.GetRedis(*pool)
+	if *all {
+		redis.FlushDB()
+		fmt.Fprintf(stdout, "redis pool %s flushed\n", *pool)
+		return nil
+	}
+	removed := redis.DelPrefix(*prefix)
+	fmt.Fprintf(stdout, "%d keys removed from redis pool %s\n", removed, *pool)
+	return nil
+}
+
+func inspectQueues(registry *orm.Registry, args []string, stdout io.Writer) error {
+	flags := newFlagSet("queues", stdout)
+	name := flags.String("name", "", "show only this queue")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	validated, err := registry.Validate()
+	if err != nil {
+		return err
+	}
+	queues := validated.GetRabbitMQQueues()
+	if *name != "" {
+		if !hasQueue(queues, *name) {
+			return errors.NotFoundf("queue '%s'", *name)
+		}
+		queues = []string{*name}
+	}
+	engine := validated.CreateEngine()
+	for _, queue := range queues {
+		rabbitMQQueue, err := engine.TryGetRabbitMQQueue(queue)
+		if err != nil {
+			fmt.Fprintf(stdout, "%s\t%s\n", queue, err.Error())
+			continue
+		}
+		messages, consumers, err := inspectQueue(rabbitMQQueue)
+		if err != nil {
+			fmt.Fprintf(stdout, "%s\t%s\n", queue, err.Error())
+			continue
+		}
+		fmt.Fprintf(stdout, "%s\tmessages: %d\tconsumers: %d\n", queue, messages, consumers)
+	}
+	return nil
+}
+
+func inspectQueue(queue *orm.RabbitMQQueue) (messages int, consumers int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			asErr, is := r.(error)
+			if !is {
+				panic(r)
+			}
+			err = asErr
+		}
+	}()
+	messages, consumers = queue.Inspect()
+	return messages, consumers, nil
+}
+
+func hasQueue(queues []string, name string) bool {
+	for _, queue := range queues {
+		if queue == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/summer-solutions/orm"
+)
+
+func TestRun(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	assert.Equal(t, 2, Run(&orm.Registry{}, nil, stdout, stderr))
+	assert.Equal(t, usage, stderr.String())
+
+	stderr.Reset()
+	assert.Equal(t, 2, Run(&orm.Registry{}, []string{"missing"}, stdout, stderr))
+	assert.Contains(t, stderr.String(), "unknown command 'missing'")
+
+	stderr.Reset()
+	assert.Equal(t, 1, Run(&orm.Registry{}, []string{"migration", "-name", "a b"}, stdout, stderr))
+	assert.Equal(t, "migration name 'a b' not valid\n", stderr.String())
+
+	assert.Equal(t, 1, Run(&orm.Registry{}, []string{"validate", "-unknown"}, stdout, stderr))
+	assert.Contains(t, stdout.String(), "flag provided but not defined: -unknown")
+
+	stderr.Reset()
+	assert.Equal(t, 1, Run(&orm.Registry{}, []string{"flush-cache"}, stdout, stderr))
+	assert.Equal(t, "use -prefix or -all\n", stderr.String())
+	stderr.Reset()
+	assert.Equal(t, 1, Run(&orm.Registry{}, []string{"flush-cache", "-all", "-prefix", "user"}, stdout, stderr))
+	assert.Equal(t, "use -prefix or -all\n", stderr.String())
+}
+
+func TestBuildMigration(t *testing.T) {
+	migration := buildMigration([]orm.Alter{
+		{SQL: "CREATE TABLE `a` (`ID` int);", Safe: true, Pool: "default"},
+		{SQL: "DROP TABLE `b`;", Safe: false, Pool: "log"},
+	})
+	assert.Equal(t, "-- pool: default\nCREATE TABLE `a` (`ID` int);\n\n"+
+		"-- pool: log\n-- UNSAFE: review before running\nDROP TABLE `b`;\n\n", migration)
+}
@@ -39,7 +39,11 @@ func (c *ClickHouse) Exec(query string, args ...interface{}) sql.Result {
 		rows, err = c.client.Exec(query, args...)
 	}
 	if c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
-		c.fillLogFields("[ORM][CLICKHOUSE][EXEC]", start, "exec", query, args, err)
+		affected := int64(-1)
+		if err == nil {
+			affected, _ = rows.RowsAffected()
+		}
+		c.fillLogFields("[ORM][CLICKHOUSE][EXEC]", "clickhouse.query", start, "exec", query, args, affected, err)
 	}
 	c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
 	c.engine.dataDog.incrementCounter(counterClickHouseExec, 1)
@@ -49,20 +53,46 @@ func (c *ClickHouse) Exec(query string, args ...interface{}) sql.Result {
 	return rows
 }
 
+// ClickHouseRows counts rows read with Next, number of read rows is logged when query is closed
+type ClickHouseRows struct {
+	*sqlx.Rows
+	read int64
+}
+
+func (r *ClickHouseRows) Next() bool {
+	next := r.Rows.Next()
+	if next {
+		r.read++
+	}
+	return next
+}
+
 func (c *ClickHouse) Queryx(query string, args ...interface{}) (rows *sqlx.Rows, deferF func()) {
+	counted, def := c.queryx(query, args, -1)
+	return counted.Rows, def
+}
+
+// QueryxCounted works like Queryx, number of rows read with Next is logged when query is closed
+func (c *ClickHouse) QueryxCounted(query string, args ...interface{}) (rows *ClickHouseRows, deferF func()) {
+	return c.queryx(query, args, 0)
+}
+
+func (c *ClickHouse) queryx(query string, args []interface{}, read int64) (rows *ClickHouseRows, deferF func()) {
 	start := time.Now()
-	rows, err := c.client.Queryx(query, args...)
-	if c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
-		c.fillLogFields("[ORM][CLICKHOUSE][SELECT]", start, "select", query, args, err)
-	}
+	result, err := c.client.Queryx(query, args...)
 	c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
 	c.engine.dataDog.incrementCounter(counterClickHouseQuery, 1)
 	if err != nil {
+		if c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
+			c.fillLogFields("[ORM][CLICKHOUSE][SELECT]", "clickhouse.query", start, "select", query, args, -1, err)
+		}
 		panic(err)
 	}
+	rows = &ClickHouseRows{Rows: result, read: read}
 	return rows, func() {
-		if rows != nil {
-			_ = rows.Close()
+		err := rows.Close()
+		if c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
+			c.fillLogFields("[ORM][CLICKHOUSE][SELECT]", "clickhouse.query", start, "select", query, args, rows.read, err)
 		}
 	}
 }
@@ -74,10 +104,10 @@ func (c *ClickHouse) Begin() {
 	start := time.Now()
 	tx, err := c.client.Begin()
 	if c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
-		c.fillLogFields("[ORM][CLICKHOUSE][BEGIN]", start, "transaction", "START TRANSACTION", nil, err)
-		c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
-		c.engine.dataDog.incrementCounter(counterClickHouseTransaction, 1)
+		c.fillLogFields("[ORM][CLICKHOUSE][BEGIN]", "clickhouse.query", start, "transaction", "START TRANSACTION", nil, -1, err)
 	}
+	c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
+	c.engine.dataDog.incrementCounter(counterClickHouseTransaction, 1)
 	if err != nil {
 		panic(err)
 	}
@@ -91,9 +121,9 @@ func (c *ClickHouse) Commit() {
 	start := time.Now()
 	err := c.tx.Commit()
 	if c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
-		c.fillLogFields("[ORM][CLICKHOUSE][COMMIT]", start, "transaction", "COMMIT TRANSACTION", nil, err)
-		c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
+		c.fillLogFields("[ORM][CLICKHOUSE][COMMIT]", "clickhouse.query", start, "transaction", "COMMIT TRANSACTION", nil, -1, err)
 	}
+	c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
 	if err != nil {
 		panic(err)
 	}
@@ -107,9 +137,9 @@ func (c *ClickHouse) Rollback() {
 	start := time.Now()
 	err := c.tx.Rollback()
 	if c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
-		c.fillLogFields("[ORM][CLICKHOUSE][ROLLBACK]", start, "transaction", "ROLLBACK TRANSACTION", nil, err)
-		c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
+		c.fillLogFields("[ORM][CLICKHOUSE][ROLLBACK]", "clickhouse.query", start, "transaction", "ROLLBACK TRANSACTION", nil, -1, err)
 	}
+	c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
 	if err != nil {
 		panic(err)
 	}
@@ -126,10 +156,10 @@ func (p *PreparedStatement) Exec(args ...interface{}) sql.Result {
 	start := time.Now()
 	results, err := p.statement.Exec(args...)
 	if p.c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
-		p.c.fillLogFields("[ORM][CLICKHOUSE][EXEC]", start, "exec", p.query, args, err)
-		p.c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
-		p.c.engine.dataDog.incrementCounter(counterClickHouseExec, 1)
+		p.c.fillLogFields("[ORM][CLICKHOUSE][EXEC]", "clickhouse.query", start, "exec", p.query, args, -1, err)
 	}
+	p.c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
+	p.c.engine.dataDog.incrementCounter(counterClickHouseExec, 1)
 	if err != nil {
 		panic(err)
 	}
@@ -146,10 +176,10 @@ func (c *ClickHouse) Prepare(query string) (prpeparedStatement *PreparedStatemen
 		statement, err = c.client.Prepare(query)
 	}
 	if c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
-		c.fillLogFields("[ORM][CLICKHOUSE][PREPARE]", start, "exec", query, nil, err)
-		c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
-		c.engine.dataDog.incrementCounter(counterClickHouseExec, 1)
+		c.fillLogFields("[ORM][CLICKHOUSE][PREPARE]", "clickhouse.query", start, "exec", query, nil, -1, err)
 	}
+	c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
+	c.engine.dataDog.incrementCounter(counterClickHouseExec, 1)
 	if err != nil {
 		panic(err)
 	}
@@ -161,20 +191,108 @@ func (c *ClickHouse) Prepare(query string) (prpeparedStatement *PreparedStatemen
 	}
 }
 
-func (c *ClickHouse) fillLogFields(message string, start time.Time, typeCode string, query string, args []interface{}, err error) {
+type ClickHouseBatch struct {
+	c         *ClickHouse
+	tx        *sql.Tx
+	statement *sql.Stmt
+	query     string
+	start     time.Time
+	rows      int
+}
+
+func (c *ClickHouse) PrepareBatch(query string) *ClickHouseBatch {
+	start := time.Now()
+	tx, err := c.client.Begin()
+	if err == nil {
+		var statement *sql.Stmt
+		statement, err = tx.Prepare(query)
+		if err == nil {
+			return &ClickHouseBatch{c: c, tx: tx, statement: statement, query: query, start: start}
+		}
+		_ = tx.Rollback()
+	}
+	if c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
+		c.fillLogFields("[ORM][CLICKHOUSE][BATCH]", "clickhouse.batch", start, "exec", query, nil, 0, err)
+	}
+	c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
+	c.engine.dataDog.incrementCounter(counterClickHouseExec, 1)
+	panic(err)
+}
+
+func (b *ClickHouseBatch) Append(args ...interface{}) {
+	if b.tx == nil {
+		panic(errors.Errorf("batch already sent"))
+	}
+	_, err := b.statement.Exec(args...)
+	if err != nil {
+		b.abort(err)
+		panic(err)
+	}
+	b.rows++
+}
+
+func (b *ClickHouseBatch) Rows() int {
+	return b.rows
+}
+
+func (b *ClickHouseBatch) Send() {
+	if b.tx == nil {
+		panic(errors.Errorf("batch already sent"))
+	}
+	err := b.tx.Commit()
+	_ = b.statement.Close()
+	b.tx = nil
+	if b.c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
+		b.c.fillLogFields("[ORM][CLICKHOUSE][BATCH]", "clickhouse.batch", b.start, "exec", b.query, nil, int64(b.rows), err)
+	}
+	b.c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
+	b.c.engine.dataDog.incrementCounter(counterClickHouseExec, 1)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (b *ClickHouseBatch) Abort() {
+	b.abort(nil)
+}
+
+// abort logs not sent batch too, so every batch has its span
+func (b *ClickHouseBatch) abort(err error) {
+	if b.tx == nil {
+		return
+	}
+	_ = b.statement.Close()
+	rollbackErr := b.tx.Rollback()
+	b.tx = nil
+	if err == nil {
+		err = rollbackErr
+	}
+	if b.c.engine.queryLoggers[QueryLoggerSourceClickHouse] != nil {
+		b.c.fillLogFields("[ORM][CLICKHOUSE][BATCH]", "clickhouse.batch", b.start, "exec", b.query, nil, int64(b.rows), err)
+	}
+	b.c.engine.dataDog.incrementCounter(counterClickHouseAll, 1)
+	b.c.engine.dataDog.incrementCounter(counterClickHouseExec, 1)
+}
+
+func (c *ClickHouse) fillLogFields(message string, operation string, start time.Time, typeCode string, query string, args []interface{},
+	rows int64, err error) {
 	now := time.Now()
 	stop := time.Since(start).Microseconds()
-	e := c.engine.queryLoggers[QueryLoggerSourceClickHouse].log.
+	e := c.engine.queryLoggerEntry(QueryLoggerSourceClickHouse).
 		WithField("pool", c.code).
 		WithField("Query", query).
 		WithField("microseconds", stop).
 		WithField("target", "clickhouse").
 		WithField("type", typeCode).
+		WithField("operation", operation).
 		WithField("started", start.UnixNano()).
 		WithField("finished", now.UnixNano())
 	if args != nil {
 		e = e.WithField("args", args)
 	}
+	if rows >= 0 {
+		e = e.WithField("rows", rows)
+	}
 	if err != nil {
 		injectLogError(err, e).Error(message)
 	} else {
@@ -0,0 +1,78 @@
+package orm
+
+import (
+	"reflect"
+)
+
+func cloneEntity(engine *Engine, entity Entity) Entity {
+	source := reflect.ValueOf(entity).Elem()
+	clone := reflect.New(source.Type())
+	cloned := clone.Interface().(Entity)
+	orm := initIfNeeded(engine, cloned)
+	if generated, is := entity.(GeneratedEntity); is {
+		generated.OrmCopy(cloned)
+	} else {
+		for i := 2; i < source.NumField(); i++ {
+			field := orm.attributes.elem.Field(i)
+			if field.CanSet() {
+				field.Set(cloneValue(source.Field(i)))
+			}
+		}
+	}
+	if orm.tableSchema.hasFakeDelete {
+		orm.attributes.elem.FieldByName("FakeDelete").SetBool(false)
+	}
+	return cloned
+}
+
+func cloneValue(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+		_, isEntity := value.Interface().(Entity)
+		if isEntity {
+			return value
+		}
+		clone := reflect.New(value.Type().Elem())
+		clone.Elem().Set(cloneValue(value.Elem()))
+		return clone
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			clone.Index(i).Set(cloneValue(value.Index(i)))
+		}
+		return clone
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			clone.SetMapIndex(iterator.Key(), cloneValue(iterator.Value()))
+		}
+		return clone
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.New(value.Type()).Elem()
+		clone.Set(cloneValue(value.Elem()))
+		return clone
+	case reflect.Struct:
+		clone := reflect.New(value.Type()).Elem()
+		clone.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if clone.Field(i).CanSet() {
+				clone.Field(i).Set(cloneValue(value.Field(i)))
+			}
+		}
+		return clone
+	}
+	return value
+}
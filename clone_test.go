@@ -0,0 +1,57 @@
+package orm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cloneEntityTest struct {
+	ORM
+	ID         uint
+	Name       string
+	Tags       []string
+	Date       *time.Time
+	JSON       interface{}
+	Ref        *cloneEntityRef
+	FakeDelete bool
+}
+
+type cloneEntityRef struct {
+	ORM
+	ID   uint
+	Name string
+}
+
+func TestClone(t *testing.T) {
+	var entity *cloneEntityTest
+	var ref *cloneEntityRef
+	engine := PrepareTables(t, &Registry{}, entity, ref)
+
+	date := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	entity = &cloneEntityTest{Name: "Tom", Tags: []string{"a", "b"}, Date: &date,
+		JSON: map[string]interface{}{"a": []interface{}{1}}, Ref: &cloneEntityRef{Name: "John"}}
+	engine.TrackAndFlush(entity)
+
+	clone := engine.Clone(entity).(*cloneEntityTest)
+	assert.Equal(t, uint(0), clone.ID)
+	assert.False(t, engine.Loaded(clone))
+	assert.True(t, engine.IsDirty(clone))
+	assert.Equal(t, "Tom", clone.Name)
+	assert.Equal(t, entity.Tags, clone.Tags)
+	assert.Equal(t, date, *clone.Date)
+	assert.Equal(t, entity.JSON, clone.JSON)
+	assert.Equal(t, entity.Ref, clone.Ref)
+
+	clone.Tags[0] = "c"
+	*clone.Date = date.Add(time.Hour)
+	clone.JSON.(map[string]interface{})["a"].([]interface{})[0] = 2
+	assert.Equal(t, "a", entity.Tags[0])
+	assert.Equal(t, date, *entity.Date)
+	assert.Equal(t, 1, entity.JSON.(map[string]interface{})["a"].([]interface{})[0])
+
+	engine.TrackAndFlush(clone)
+	assert.Equal(t, uint(2), clone.ID)
+	assert.Equal(t, uint(1), entity.ID)
+}
@@ -0,0 +1,69 @@
+// Command orm runs orm command line tool with registry from yaml config file.
+// Entities are not registered, so schema commands need own binary built with cli.Run.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/summer-solutions/orm"
+	"github.com/summer-solutions/orm/cli"
+	"gopkg.in/yaml.v2"
+)
+
+const usage = `Usage: orm -config config.yaml <command> [options]
+
+Commands:
+  flush-cache   flush redis pool (-all) or keys with prefix (-prefix)
+  queues        show number of messages and consumers in RabbitMQ queues
+
+Schema commands (alters, apply-safe, migration, validate) need registered entities.
+Build own binary which registers entities and calls cli.Run from github.com/summer-solutions/orm/cli:
+
+	registry := orm.InitByYaml(config)
+	registry.RegisterEntity(&UserEntity{})
+	os.Exit(cli.Run(registry, os.Args[1:], os.Stdout, os.Stderr))
+
+Options:
+`
+
+var schemaCommands = map[string]bool{"alters": true, "apply-safe": true, "migration": true, "validate": true}
+
+func main() {
+	config := flag.String("config", "config.yaml", "path to yaml config file")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || schemaCommands[flag.Arg(0)] {
+		flag.Usage()
+		os.Exit(2)
+	}
+	registry, err := loadRegistry(*config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(cli.Run(registry, flag.Args(), os.Stdout, os.Stderr))
+}
+
+func loadRegistry(file string) (registry *orm.Registry, err error) {
+	/* #nosec */
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err = yaml.Unmarshal(content, &data); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid config %s: %v", file, r)
+		}
+	}()
+	return orm.InitByYaml(data), nil
+}
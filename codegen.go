@@ -0,0 +1,280 @@
+package orm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/segmentio/fasthash/fnv1a"
+)
+
+const ormPackagePath = "github.com/summer-solutions/orm"
+
+type GeneratedEntity interface {
+	OrmFill(data []string)
+	OrmBind(id uint64, old map[string]interface{}) map[string]interface{}
+	OrmCopy(target Entity)
+	OrmColumnsHash() string
+}
+
+func (r *Registry) GenerateEntityCode(packageName string, entity ...Entity) (string, error) {
+	prefix := "orm."
+	if packageName == "orm" {
+		prefix = ""
+	}
+	body := &bytes.Buffer{}
+	for _, row := range entity {
+		t := reflect.TypeOf(row).Elem()
+		schema, err := initTableSchema(r, t)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		if err := generateEntityCode(body, schema, prefix); err != nil {
+			return "", err
+		}
+	}
+	imports := make(map[string]bool)
+	for _, name := range []string{"fmt", "strconv"} {
+		if strings.Contains(body.String(), name+".") {
+			imports[name] = true
+		}
+	}
+	if prefix != "" {
+		imports[ormPackagePath] = true
+	}
+	return formatGeneratedCode(packageName, imports, body)
+}
+
+// GenerateReferenceGetters generates Get<Field>(engine) methods which load not loaded references on first access
+func (r *Registry) GenerateReferenceGetters(packageName string, entity ...Entity) (string, error) {
+	prefix := "orm."
+	if packageName == "orm" {
+		prefix = ""
+	}
+	imports := make(map[string]bool)
+	if prefix != "" {
+		imports[ormPackagePath] = true
+	}
+	body := &bytes.Buffer{}
+	for _, row := range entity {
+		t := reflect.TypeOf(row).Elem()
+		schema, err := initTableSchema(r, t)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		for i := 2; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if _, has := schema.tags[f.Name]["ref"]; !has {
+				continue
+			}
+			refType := f.Type.Elem()
+			typeName := refType.Name()
+			if refType.PkgPath() != t.PkgPath() {
+				typeName = refType.String()
+				imports[refType.PkgPath()] = true
+			}
+			fmt.Fprintf(body, "\nfunc (e *%s) Get%s(engine *%sEngine) *%s {\n", t.Name(), f.Name, prefix, typeName)
+			fmt.Fprintf(body, "\treference, _ := engine.GetReference(e, %q).(*%s)\n\treturn reference\n}\n", f.Name, typeName)
+		}
+	}
+	return formatGeneratedCode(packageName, imports, body)
+}
+
+func formatGeneratedCode(packageName string, imports map[string]bool, body *bytes.Buffer) (string, error) {
+	var standard, external []string
+	for name := range imports {
+		if strings.Contains(name, ".") {
+			external = append(external, name)
+		} else {
+			standard = append(standard, name)
+		}
+	}
+	sort.Strings(standard)
+	sort.Strings(external)
+	header := &bytes.Buffer{}
+	header.WriteString("// Code generated by orm. DO NOT EDIT.\n\npackage " + packageName + "\n")
+	if len(imports) > 0 {
+		header.WriteString("\nimport (\n")
+		for _, name := range standard {
+			header.WriteString("\t\"" + name + "\"\n")
+		}
+		if len(standard) > 0 && len(external) > 0 {
+			header.WriteString("\n")
+		}
+		for _, name := range external {
+			header.WriteString("\t\"" + name + "\"\n")
+		}
+		header.WriteString(")\n")
+	}
+	source, err := format.Source(append(header.Bytes(), body.Bytes()...))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return string(source), nil
+}
+
+func generateEntityCode(out *bytes.Buffer, schema *tableSchema, prefix string) error {
+	fields := schema.fields
+	name := schema.t.Name()
+	if len(fields.sliceStrings) > 0 || len(fields.decimals) > 0 || len(fields.decimalsNullable) > 0 || len(fields.valuers) > 0 ||
+		len(fields.uuids) > 0 || len(fields.timesNullable) > 0 || len(fields.times) > 0 || len(fields.jsons) > 0 ||
+		len(fields.structs) > 0 || len(fields.refs) > 0 {
+		return errors.NotSupportedf("code generation for %s fields", schema.t.String())
+	}
+	fill := &bytes.Buffer{}
+	index := 0
+	for _, i := range fields.uintegers {
+		if i == 1 {
+			continue
+		}
+		generateFillNumber(fill, fields.fields[i], index, "strconv.ParseUint(data[%d], 10, 64)", "uint64")
+		index++
+	}
+	for _, i := range fields.integers {
+		generateFillNumber(fill, fields.fields[i], index, "strconv.ParseInt(data[%d], 10, 64)", "int64")
+		index++
+	}
+	for _, i := range fields.strings {
+		fmt.Fprintf(fill, "\te.%s = data[%d]\n", fields.fields[i].Name, index)
+		index++
+	}
+	for _, i := range fields.bytes {
+		fmt.Fprintf(fill, "\tif data[%d] != \"\" {\n\t\te.%s = []byte(data[%d])\n\t} else {\n\t\te.%s = nil\n\t}\n",
+			index, fields.fields[i].Name, index, fields.fields[i].Name)
+		index++
+	}
+	if fields.fakeDelete > 0 {
+		fmt.Fprintf(fill, "\te.FakeDelete = data[%d] != \"0\"\n", index)
+		index++
+	}
+	for _, i := range fields.booleans {
+		fmt.Fprintf(fill, "\te.%s = data[%d] == \"1\"\n", fields.fields[i].Name, index)
+		index++
+	}
+	for _, i := range fields.floats {
+		generateFillNumber(fill, fields.fields[i], index, "strconv.ParseFloat(data[%d], 64)", "float64")
+		index++
+	}
+	bind := &bytes.Buffer{}
+	copyFields := &bytes.Buffer{}
+	for i := 2; i < schema.t.NumField(); i++ {
+		f := schema.t.Field(i)
+		attributes := schema.tags[f.Name]
+		if _, has := attributes["ignore"]; has {
+			if f.PkgPath != "" {
+				continue
+			}
+			switch f.Type.Kind() {
+			case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Interface, reflect.Struct:
+				return errors.NotSupportedf("code generation for ignored field %s in %s", f.Name, schema.t.String())
+			}
+			fmt.Fprintf(copyFields, "\tclone.%s = e.%s\n", f.Name, f.Name)
+			continue
+		}
+		typeName := f.Type.String()
+		if typeName == "[]uint8" {
+			fmt.Fprintf(copyFields, "\tif e.%s != nil {\n\t\tclone.%s = append([]byte{}, e.%s...)\n\t}\n", f.Name, f.Name, f.Name)
+		} else {
+			fmt.Fprintf(copyFields, "\tclone.%s = e.%s\n", f.Name, f.Name)
+		}
+		if err := generateBind(bind, schema, f, attributes); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "\nfunc (e *%s) OrmFill(data []string) {\n%s}\n", name, fill.String())
+	fmt.Fprintf(out, "\nfunc (e *%s) OrmBind(id uint64, old map[string]interface{}) map[string]interface{} {\n", name)
+	fmt.Fprintf(out, "\tbind := make(map[string]interface{})\n\thasOld := len(old) > 0\n%s\treturn bind\n}\n", bind.String())
+	fmt.Fprintf(out, "\nfunc (e *%s) OrmCopy(target %sEntity) {\n\tclone := target.(*%s)\n%s}\n", name, prefix, name, copyFields.String())
+	fmt.Fprintf(out, "\nfunc (e *%s) OrmColumnsHash() string {\n\treturn \"%s\"\n}\n", name, getColumnsHash(schema.t))
+	return nil
+}
+
+// getColumnsHash changes when field used in generated code is added, removed or changed
+func getColumnsHash(t reflect.Type) string {
+	fields := &bytes.Buffer{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fmt.Fprintf(fields, "%s %s %s\n", f.Name, f.Type.String(), f.Tag)
+	}
+	return strconv.FormatUint(uint64(fnv1a.HashBytes32(fields.Bytes())), 10)
+}
+
+func checkGeneratedCode(t reflect.Type) error {
+	generated, is := reflect.New(t).Interface().(GeneratedEntity)
+	if is && generated.OrmColumnsHash() != getColumnsHash(t) {
+		return errors.Errorf("generated code for %s is outdated, run GenerateEntityCode again", t.String())
+	}
+	return nil
+}
+
+func generateFillNumber(out *bytes.Buffer, f reflect.StructField, index int, parse string, parsedType string) {
+	fmt.Fprintf(out, "\tv%d, _ := "+parse+"\n", index, index)
+	if f.Type.String() == parsedType {
+		fmt.Fprintf(out, "\te.%s = v%d\n", f.Name, index)
+	} else {
+		fmt.Fprintf(out, "\te.%s = %s(v%d)\n", f.Name, f.Type.String(), index)
+	}
+}
+
+func generateBind(out *bytes.Buffer, schema *tableSchema, f reflect.StructField, attributes map[string]string) error {
+	isRequired := attributes["required"] == "true"
+	nullable := func(value string, condition string) {
+		fmt.Fprintf(out, "\tif %s {\n", condition)
+		if isRequired {
+			fmt.Fprintf(out, "\t\tbind[%q] = \"\"\n", f.Name)
+		} else {
+			fmt.Fprintf(out, "\t\tbind[%q] = nil\n", f.Name)
+		}
+		fmt.Fprintf(out, "\t} else {\n\t\tbind[%q] = %s\n\t}\n", f.Name, value)
+	}
+	switch f.Type.String() {
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		if attributes["year"] == "true" {
+			return errors.NotSupportedf("code generation for year field %s in %s", f.Name, schema.t.String())
+		}
+		fmt.Fprintf(out, "\tif v := strconv.FormatUint(uint64(e.%s), 10); !hasOld || old[%q] != v {\n\t\tbind[%q] = v\n\t}\n", f.Name, f.Name, f.Name)
+	case "int", "int8", "int16", "int32", "int64":
+		fmt.Fprintf(out, "\tif v := strconv.FormatInt(int64(e.%s), 10); !hasOld || old[%q] != v {\n\t\tbind[%q] = v\n\t}\n", f.Name, f.Name, f.Name)
+	case "string":
+		if _, has := schema.enums[f.Name]; has {
+			return errors.NotSupportedf("code generation for enum field %s in %s", f.Name, schema.t.String())
+		}
+		fmt.Fprintf(out, "\tif !hasOld || (old[%q] != e.%s && (old[%q] != nil || e.%s != \"\")) {\n", f.Name, f.Name, f.Name, f.Name)
+		nullable("e."+f.Name, "e."+f.Name+" == \"\"")
+		out.WriteString("\t}\n")
+	case "[]uint8":
+		fmt.Fprintf(out, "\tif v := string(e.%s); !hasOld || (old[%q] != v && (old[%q] != nil || v != \"\")) {\n", f.Name, f.Name, f.Name)
+		fmt.Fprintf(out, "\t\tif v == \"\" {\n\t\t\tbind[%q] = nil\n\t\t} else {\n\t\t\tbind[%q] = v\n\t\t}\n\t}\n", f.Name, f.Name)
+	case "bool":
+		value := "\"1\""
+		if f.Name == "FakeDelete" {
+			value = "strconv.FormatUint(id, 10)"
+		}
+		fmt.Fprintf(out, "\tv%s := \"0\"\n\tif e.%s {\n\t\tv%s = %s\n\t}\n", f.Name, f.Name, f.Name, value)
+		fmt.Fprintf(out, "\tif !hasOld || old[%q] != v%s {\n\t\tbind[%q] = v%s\n\t}\n", f.Name, f.Name, f.Name, f.Name)
+	case "float32", "float64":
+		precision := 8
+		bitSize := 32
+		if f.Type.String() == "float64" {
+			bitSize = 64
+			precision = 16
+		}
+		if userPrecision, has := attributes["precision"]; has {
+			_, _ = fmt.Sscanf(userPrecision, "%d", &precision)
+		}
+		value := fmt.Sprintf("strconv.FormatFloat(float64(e.%s), 'g', %d, %d)", f.Name, precision, bitSize)
+		if decimal, has := attributes["decimal"]; has {
+			value = fmt.Sprintf("fmt.Sprintf(\"%%.%sf\", e.%s)", strings.Split(decimal, ",")[1], f.Name)
+		}
+		fmt.Fprintf(out, "\tif v := %s; !hasOld || old[%q] != v {\n\t\tbind[%q] = v\n\t}\n", value, f.Name, f.Name)
+	case "*orm.CachedQuery":
+	default:
+		return errors.NotSupportedf("code generation for field %s in %s", f.Name, schema.t.String())
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package orm
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type codegenEntity struct {
+	ORM     `orm:"localCache"`
+	ID      uint
+	Name    string `orm:"required"`
+	Email   string
+	Age     uint8
+	Score   int32
+	Balance float64 `orm:"decimal=10,2"`
+	Ratio   float32
+	Active  bool
+	Avatar  []byte
+	Note    string `orm:"ignore"`
+	Counter int    `orm:"ignore"`
+}
+
+type codegenReflectEntity struct {
+	ORM     `orm:"localCache"`
+	ID      uint
+	Name    string `orm:"required"`
+	Email   string
+	Age     uint8
+	Score   int32
+	Balance float64 `orm:"decimal=10,2"`
+	Ratio   float32
+	Active  bool
+	Avatar  []byte
+	Note    string `orm:"ignore"`
+	Counter int    `orm:"ignore"`
+}
+
+type codegenOutdatedEntity struct {
+	codegenEntity
+}
+
+type codegenRefEntity struct {
+	ORM
+	ID     uint
+	Parent *codegenEntity
+}
+
+func TestGenerateEntityCode(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterMySQLPool("root:root@tcp(localhost:3310)/test")
+	registry.RegisterLocalCache(100)
+	source, err := registry.GenerateEntityCode("orm", &codegenEntity{})
+	assert.NoError(t, err)
+	expected, _ := ioutil.ReadFile("codegen_generated_test.go")
+	assert.Equal(t, string(expected), source)
+	_, err = registry.GenerateEntityCode("orm", &codegenRefEntity{})
+	assert.EqualError(t, err, "code generation for orm.codegenRefEntity fields not supported")
+
+	reflectSchema, _ := initTableSchema(registry, reflect.TypeOf(codegenReflectEntity{}))
+	data := []string{"18", "-3", "John", "", "abc", "1", "12.5", "0.25"}
+	generated := &codegenEntity{}
+	generated.OrmFill(data)
+	reflected := &codegenReflectEntity{}
+	fillStruct(nil, 0, data, reflectSchema.fields, reflect.ValueOf(reflected).Elem())
+	assert.Equal(t, codegenEntity(*reflected), *generated)
+
+	old := map[string]interface{}{"Name": "John", "Email": nil, "Age": "20", "Score": "-3", "Balance": "12.50", "Ratio": "1",
+		"Active": "0", "Avatar": "abc"}
+	expectedBind := createBind(2, reflectSchema, reflectSchema.t, reflect.ValueOf(reflected).Elem(), old, "")
+	assert.Equal(t, expectedBind, generated.OrmBind(2, old))
+	assert.Len(t, expectedBind, 3)
+	expectedBind = createBind(0, reflectSchema, reflectSchema.t, reflect.ValueOf(&codegenReflectEntity{}).Elem(), nil, "")
+	assert.Equal(t, expectedBind, (&codegenEntity{}).OrmBind(0, nil))
+
+	generated.Note = "note"
+	clone := &codegenEntity{}
+	generated.OrmCopy(clone)
+	assert.Equal(t, *generated, *clone)
+	clone.Avatar[0] = 'x'
+	assert.Equal(t, "abc", string(generated.Avatar))
+
+	assert.NoError(t, checkGeneratedCode(reflect.TypeOf(codegenEntity{})))
+	assert.NoError(t, checkGeneratedCode(reflect.TypeOf(codegenReflectEntity{})))
+	assert.EqualError(t, checkGeneratedCode(reflect.TypeOf(codegenOutdatedEntity{})),
+		"generated code for orm.codegenOutdatedEntity is outdated, run GenerateEntityCode again")
+}
+
+func TestGenerateReferenceGetters(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterMySQLPool("root:root@tcp(localhost:3310)/test")
+	registry.RegisterLocalCache(100)
+	registry.RegisterRedis("localhost:6380", 15)
+	registry.RegisterEntity(&loadByIDsOrder{}, &loadByIDsCustomer{}, &loadByIDsCountry{})
+	source, err := registry.GenerateReferenceGetters("orm", &loadByIDsOrder{}, &loadByIDsCustomer{})
+	assert.NoError(t, err)
+	expected, _ := ioutil.ReadFile("load_by_ids_generated_test.go")
+	assert.Equal(t, string(expected), source)
+
+	source, err = registry.GenerateReferenceGetters("entity", &loadByIDsCountry{})
+	assert.NoError(t, err)
+	assert.Equal(t, "// Code generated by orm. DO NOT EDIT.\n\npackage entity\n\nimport (\n\t\"github.com/summer-solutions/orm\"\n)\n", source)
+}
@@ -23,6 +23,9 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
 
+const spanKindTag = "span.kind"
+const spanKindServer = "server"
+
 type dataDog struct {
 	engine   *Engine
 	span     tracer.Span
@@ -34,6 +37,8 @@ type dataDog struct {
 type DataDog interface {
 	StartAPM(service string, environment string) APM
 	StartHTTPAPM(request *http.Request, service string, environment string) HTTPAPM
+	StartHTTPAPMWithOptions(request *http.Request, service string, environment string, options *HTTPAPMOptions) HTTPAPM
+	StartJobAPM(name string, service string, environment string) JobAPM
 	EnableORMAPMLog(level apexLog.Level, withAnalytics bool, source ...QueryLoggerSource)
 	RegisterAPMError(err interface{})
 	DropAPM()
@@ -41,6 +46,14 @@ type DataDog interface {
 	StartWorkSpan(name string) WorkSpan
 }
 
+type HTTPAPMOptions struct {
+	// OperationName replaces default "http.request" span name
+	OperationName string
+	// SpanOptions are added after default options, for example tracer.Tag(ext.Version, "1.2.0")
+	SpanOptions   []ddtrace.StartSpanOption
+	FinishOptions []ddtrace.FinishOption
+}
+
 type WorkSpan interface {
 	Finish()
 	SetTag(key string, value interface{})
@@ -87,16 +100,26 @@ type HTTPAPM interface {
 	SetResponseStatus(status int)
 }
 
+type JobAPM interface {
+	APM
+	FinishWithError(err interface{})
+}
+
 type apm struct {
 	engine *Engine
 }
 
+type jobAPM struct {
+	apm
+}
+
 type httpAPM struct {
 	apm
-	status int
+	status        int
+	finishOptions []ddtrace.FinishOption
 }
 
-func (s *apm) finish() {
+func (s *apm) finish(options ...ddtrace.FinishOption) {
 	dd := s.engine.dataDog
 	for k, v := range dd.counters {
 		if v > 0 {
@@ -104,7 +127,7 @@ func (s *apm) finish() {
 			dd.counters[k] = 0
 		}
 	}
-	s.engine.dataDog.span.Finish()
+	s.engine.dataDog.span.Finish(options...)
 }
 
 func (s *apm) Finish() {
@@ -119,14 +142,32 @@ func (s *httpAPM) Finish() {
 			dd.span.SetTag(ext.Error, fmt.Errorf("%d: %s", s.status, http.StatusText(s.status)))
 		}
 	}
-	s.finish()
+	s.finish(s.finishOptions...)
 }
 
 func (s *httpAPM) SetResponseStatus(status int) {
 	s.status = status
 }
 
+func (s *jobAPM) FinishWithError(err interface{}) {
+	if err != nil {
+		s.engine.dataDog.RegisterAPMError(err)
+	}
+	s.finish()
+}
+
 func (dd *dataDog) StartHTTPAPM(request *http.Request, service string, environment string) HTTPAPM {
+	return dd.StartHTTPAPMWithOptions(request, service, environment, nil)
+}
+
+func (dd *dataDog) StartHTTPAPMWithOptions(request *http.Request, service string, environment string, options *HTTPAPMOptions) HTTPAPM {
+	if options == nil {
+		options = &HTTPAPMOptions{}
+	}
+	operationName := options.OperationName
+	if operationName == "" {
+		operationName = "http.request"
+	}
 	resource := request.Method + " " + request.URL.Path
 	opts := []ddtrace.StartSpanOption{
 		tracer.ServiceName(service),
@@ -134,22 +175,40 @@ func (dd *dataDog) StartHTTPAPM(request *http.Request, service string, environme
 		tracer.SpanType(ext.SpanTypeWeb),
 		tracer.Tag(ext.HTTPMethod, request.Method),
 		tracer.Tag(ext.HTTPURL, request.URL.Path),
+		tracer.Tag(spanKindTag, spanKindServer),
+		tracer.Tag(ext.AnalyticsEvent, true),
+		tracer.Tag(ext.Environment, environment),
 		tracer.Measured(),
 	}
+	if len(request.URL.Query()) > 0 {
+		opts = append(opts, tracer.Tag("url.query", request.URL.RawQuery))
+	}
 	if spanCtx, err := tracer.Extract(tracer.HTTPHeadersCarrier(request.Header)); err == nil {
 		opts = append(opts, tracer.ChildOf(spanCtx))
 	}
-	span, ctx := tracer.StartSpanFromContext(request.Context(), "http.request", opts...)
-	span.SetTag(ext.AnalyticsEvent, true)
-	q := request.URL.Query()
-	if len(q) > 0 {
-		span.SetTag("url.query", request.URL.RawQuery)
+	opts = append(opts, options.SpanOptions...)
+	span, ctx := tracer.StartSpanFromContext(request.Context(), operationName, opts...)
+	dd.engine.Log().AddFields(apexLog.Fields{"dd.trace_id": span.Context().TraceID(), "dd.span_id": span.Context().SpanID()})
+	dd.span = span
+	dd.ctx = []context.Context{ctx}
+	return &httpAPM{apm{engine: dd.engine}, 0, options.FinishOptions}
+}
+
+func (dd *dataDog) StartJobAPM(name string, service string, environment string) JobAPM {
+	opts := []ddtrace.StartSpanOption{
+		tracer.ServiceName(service),
+		tracer.ResourceName(name),
+		tracer.SpanType("worker"),
+		tracer.Measured(),
 	}
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "job.run", opts...)
+	span.SetTag(ext.AnalyticsEvent, true)
 	span.SetTag(ext.Environment, environment)
 	dd.engine.Log().AddFields(apexLog.Fields{"dd.trace_id": span.Context().TraceID(), "dd.span_id": span.Context().SpanID()})
 	dd.span = span
 	dd.ctx = []context.Context{ctx}
-	return &httpAPM{apm{engine: dd.engine}, 0}
+	dd.hasError = false
+	return &jobAPM{apm{engine: dd.engine}}
 }
 
 func (dd *dataDog) StartWorkSpan(name string) WorkSpan {
@@ -162,7 +221,7 @@ func (dd *dataDog) StartWorkSpan(name string) WorkSpan {
 func (dd *dataDog) EnableORMAPMLog(level apexLog.Level, withAnalytics bool, source ...QueryLoggerSource) {
 	if len(source) == 0 {
 		source = []QueryLoggerSource{QueryLoggerSourceDB, QueryLoggerSourceRedis, QueryLoggerSourceRabbitMQ, QueryLoggerSourceElastic,
-			QueryLoggerSourceClickHouse}
+			QueryLoggerSourceClickHouse, QueryLoggerSourceLocalCache}
 	}
 	for _, s := range source {
 		if s == QueryLoggerSourceDB {
@@ -175,6 +234,8 @@ func (dd *dataDog) EnableORMAPMLog(level apexLog.Level, withAnalytics bool, sour
 			dd.engine.AddQueryLogger(newElasticDataDogHandler(withAnalytics, dd.engine), level, s)
 		} else if s == QueryLoggerSourceClickHouse {
 			dd.engine.AddQueryLogger(newClickHouseDataDogHandler(withAnalytics, dd.engine), level, s)
+		} else if s == QueryLoggerSourceLocalCache {
+			dd.engine.AddQueryLogger(newLocalCacheDataDogHandler(withAnalytics, dd.engine), level, s)
 		}
 	}
 }
@@ -8,6 +8,10 @@ import (
 	apexLog "github.com/apex/log"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
 
 func TestDataDog(t *testing.T) {
@@ -31,6 +35,12 @@ func TestDataDog(t *testing.T) {
 	assert.NotNil(t, httpAPM)
 	httpAPM.Finish()
 
+	jobAPM := engine.DataDog().StartJobAPM("test_job", "test_service", "test")
+	assert.NotNil(t, jobAPM)
+	assert.Len(t, engine.dataDog.ctx, 1)
+	jobAPM.FinishWithError(errors.Errorf("test job error"))
+	assert.True(t, engine.dataDog.hasError)
+
 	engine.DataDog().EnableORMAPMLog(apexLog.DebugLevel, true)
 	engine.DataDog().RegisterAPMError("test panic")
 	engine.DataDog().RegisterAPMError(errors.Errorf("test error"))
@@ -41,3 +51,34 @@ func TestDataDog(t *testing.T) {
 	f2()
 	apm.Finish()
 }
+
+func TestStartHTTPAPMWithOptions(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	engine := (&validatedRegistry{}).clone(&Registry{}, nil).CreateEngine()
+
+	request, _ := http.NewRequest(http.MethodGet, "/test/url", nil)
+	finishedAt := time.Unix(1600000000, 0)
+	httpAPM := engine.DataDog().StartHTTPAPMWithOptions(request, "test_service", "test", &HTTPAPMOptions{
+		OperationName: "web.request",
+		SpanOptions:   []ddtrace.StartSpanOption{tracer.Tag(ext.Version, "1.2.0"), tracer.Tag(ext.Environment, "staging")},
+		FinishOptions: []ddtrace.FinishOption{tracer.FinishTime(finishedAt)},
+	})
+	httpAPM.SetResponseStatus(http.StatusOK)
+	httpAPM.Finish()
+
+	spans := mt.FinishedSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "web.request", spans[0].OperationName())
+	assert.Equal(t, "1.2.0", spans[0].Tag(ext.Version))
+	assert.Equal(t, "staging", spans[0].Tag(ext.Environment))
+	assert.Equal(t, "server", spans[0].Tag("span.kind"))
+	assert.Equal(t, "200", spans[0].Tag(ext.HTTPCode))
+	assert.Equal(t, finishedAt, spans[0].FinishTime())
+
+	engine.DataDog().StartHTTPAPM(request, "test_service", "test").Finish()
+	spans = mt.FinishedSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "http.request", spans[1].OperationName())
+	assert.Equal(t, "test", spans[1].Tag(ext.Environment))
+}
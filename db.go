@@ -1,7 +1,10 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/juju/errors"
@@ -13,11 +16,15 @@ const counterDBQuery = "db.query"
 const counterDBExec = "db.exec"
 
 type DBConfig struct {
-	dataSourceName string
-	code           string
-	databaseName   string
-	db             *sql.DB
-	autoincrement  uint64
+	dataSourceName     string
+	code               string
+	databaseName       string
+	db                 *sql.DB
+	readDB             *sql.DB
+	readDataSourceName string
+	autoincrement      uint64
+	version            string
+	options            *MySQLPoolOptions
 }
 
 type ExecResult interface {
@@ -47,11 +54,15 @@ func (e *execResult) RowsAffected() uint64 {
 
 type sqlClient interface {
 	Begin() error
+	BeginContext(ctx context.Context) error
 	Commit() error
 	Rollback() (bool, error)
 	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	QueryRow(query string, args ...interface{}) SQLRow
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) SQLRow
 	Query(query string, args ...interface{}) (SQLRows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (SQLRows, error)
 }
 
 type dbClientQuery interface {
@@ -60,6 +71,16 @@ type dbClientQuery interface {
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 }
 
+type dbClientQueryContext interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type dbClientBeginContext interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
 type dbClient interface {
 	dbClientQuery
 	Begin() (*sql.Tx, error)
@@ -72,8 +93,9 @@ type dbClientTX interface {
 }
 
 type standardSQLClient struct {
-	db dbClient
-	tx dbClientTX
+	db   dbClient
+	tx   dbClientTX
+	read dbClientQuery
 }
 
 func (db *standardSQLClient) Begin() error {
@@ -88,6 +110,19 @@ func (db *standardSQLClient) Begin() error {
 	return nil
 }
 
+func (db *standardSQLClient) BeginContext(ctx context.Context) error {
+	withContext, is := db.db.(dbClientBeginContext)
+	if !is || db.tx != nil {
+		return db.Begin()
+	}
+	tx, err := withContext.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	db.tx = tx
+	return nil
+}
+
 func (db *standardSQLClient) Commit() error {
 	if db.tx == nil {
 		return errors.Errorf("transaction not started")
@@ -127,28 +162,67 @@ func (db *standardSQLClient) Exec(query string, args ...interface{}) (sql.Result
 	return res, nil
 }
 
-func (db *standardSQLClient) QueryRow(query string, args ...interface{}) SQLRow {
+func (db *standardSQLClient) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var client dbClientQuery = db.db
 	if db.tx != nil {
-		return db.tx.QueryRow(query, args...)
+		client = db.tx
 	}
-	return db.db.QueryRow(query, args...)
+	withContext, is := client.(dbClientQueryContext)
+	if !is {
+		return db.Exec(query, args...)
+	}
+	res, err := withContext.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return res, nil
+}
+
+func (db *standardSQLClient) QueryRow(query string, args ...interface{}) SQLRow {
+	return db.queryClient().QueryRow(query, args...)
+}
+
+func (db *standardSQLClient) QueryRowContext(ctx context.Context, query string, args ...interface{}) SQLRow {
+	client := db.queryClient()
+	withContext, is := client.(dbClientQueryContext)
+	if !is {
+		return client.QueryRow(query, args...)
+	}
+	return withContext.QueryRowContext(ctx, query, args...)
 }
 
 func (db *standardSQLClient) Query(query string, args ...interface{}) (SQLRows, error) {
-	if db.tx != nil {
-		rows, err := db.tx.Query(query, args...)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		return rows, nil
+	rows, err := db.queryClient().Query(query, args...)
+	if err != nil {
+		return nil, errors.Trace(err)
 	}
-	rows, err := db.db.Query(query, args...)
+	return rows, nil
+}
+
+func (db *standardSQLClient) QueryContext(ctx context.Context, query string, args ...interface{}) (SQLRows, error) {
+	client := db.queryClient()
+	withContext, is := client.(dbClientQueryContext)
+	if !is {
+		return db.Query(query, args...)
+	}
+	rows, err := withContext.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 	return rows, nil
 }
 
+// queryClient returns transaction when started, read replica or primary otherwise
+func (db *standardSQLClient) queryClient() dbClientQuery {
+	if db.tx != nil {
+		return db.tx
+	}
+	if db.read != nil {
+		return db.read
+	}
+	return db.db
+}
+
 type SQLRows interface {
 	Next() bool
 	Err() error
@@ -196,6 +270,12 @@ type DB struct {
 	code          string
 	databaseName  string
 	autoincrement uint64
+	version       string
+}
+
+func (db *DB) inTransaction() bool {
+	client, is := db.client.(*standardSQLClient)
+	return is && client.tx != nil
 }
 
 func (db *DB) GetDatabaseName() string {
@@ -206,9 +286,31 @@ func (db *DB) GetPoolCode() string {
 	return db.code
 }
 
+func (db *DB) GetServerVersion() string {
+	return db.version
+}
+
+func (db *DB) supportsRecursiveQueries() bool {
+	parts := strings.SplitN(db.version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	major, _ := strconv.Atoi(parts[0])
+	minor, _ := strconv.Atoi(parts[1])
+	if strings.Contains(strings.ToLower(db.version), "mariadb") {
+		return major > 10 || (major == 10 && minor >= 2)
+	}
+	return major >= 8
+}
+
 func (db *DB) Begin() {
 	start := time.Now()
-	err := db.client.Begin()
+	_, _, err := db.withMiddlewares("begin", "START TRANSACTION", nil, func(string, []interface{}) error {
+		if db.engine.flushContext != nil {
+			return db.client.BeginContext(db.engine.flushContext)
+		}
+		return db.client.Begin()
+	})
 	if db.engine.queryLoggers[QueryLoggerSourceDB] != nil {
 		db.fillLogFields("[ORM][MYSQL][BEGIN]", start, "transaction", "START TRANSACTION", nil, err)
 		db.engine.dataDog.incrementCounter(counterDBAll, 1)
@@ -221,7 +323,9 @@ func (db *DB) Begin() {
 
 func (db *DB) Commit() {
 	start := time.Now()
-	err := db.client.Commit()
+	_, _, err := db.withMiddlewares("commit", "COMMIT", nil, func(string, []interface{}) error {
+		return db.client.Commit()
+	})
 	if db.engine.queryLoggers[QueryLoggerSourceDB] != nil {
 		db.fillLogFields("[ORM][MYSQL][COMMIT]", start, "transaction", "COMMIT", nil, err)
 	}
@@ -240,15 +344,35 @@ func (db *DB) Commit() {
 	if db.engine.afterCommitRedisCacheDeletes != nil {
 		for cacheCode, keys := range db.engine.afterCommitRedisCacheDeletes {
 			cache := db.engine.GetRedis(cacheCode)
-			cache.Del(keys...)
+			cache.Del(mapKeys(keys)...)
 		}
 	}
 	db.engine.afterCommitRedisCacheDeletes = nil
+	invalidations := db.engine.afterCommitLocalCacheInvalidations
+	db.engine.afterCommitLocalCacheInvalidations = nil
+	publishLocalCacheInvalidation(db.engine, invalidations)
+	// changes are published once, when the last open transaction is committed
+	if !db.engine.hasOpenTransaction() {
+		publishEntityChanges(db.engine)
+	}
+}
+
+func (e *Engine) hasOpenTransaction() bool {
+	for _, db := range e.dbs {
+		if db.inTransaction() {
+			return true
+		}
+	}
+	return false
 }
 
 func (db *DB) Rollback() {
 	start := time.Now()
-	has, err := db.client.Rollback()
+	var has bool
+	_, _, err := db.withMiddlewares("rollback", "ROLLBACK", nil, func(string, []interface{}) (err error) {
+		has, err = db.client.Rollback()
+		return err
+	})
 	if has {
 		if db.engine.queryLoggers[QueryLoggerSourceDB] != nil {
 			db.fillLogFields("[ORM][MYSQL][ROLLBACK]", start, "transaction", "ROLLBACK", nil, err)
@@ -261,13 +385,28 @@ func (db *DB) Rollback() {
 	}
 	db.engine.afterCommitLocalCacheSets = nil
 	db.engine.afterCommitRedisCacheDeletes = nil
+	db.engine.afterCommitLocalCacheInvalidations = nil
+	db.engine.afterCommitChanges = nil
 }
 
 func (db *DB) Exec(query string, args ...interface{}) ExecResult {
+	return db.exec(query, nil, args)
+}
+
+// exec runs query with real arguments, values at redacted positions are masked only in logs
+func (db *DB) exec(query string, redacted []int, args []interface{}) ExecResult {
 	start := time.Now()
-	rows, err := db.client.Exec(query, args...)
+	var rows sql.Result
+	query, args, err := db.withMiddlewares("exec", query, args, func(query string, args []interface{}) (err error) {
+		if db.engine.flushContext != nil {
+			rows, err = db.client.ExecContext(db.engine.flushContext, query, args...)
+		} else {
+			rows, err = db.client.Exec(query, args...)
+		}
+		return err
+	})
 	if db.engine.queryLoggers[QueryLoggerSourceDB] != nil {
-		db.fillLogFields("[ORM][MYSQL][EXEC]", start, "exec", query, args, err)
+		db.fillLogFields("[ORM][MYSQL][EXEC]", start, "exec", query, redactArguments(args, redacted), err)
 	}
 	db.engine.dataDog.incrementCounter(counterDBAll, 1)
 	db.engine.dataDog.incrementCounter(counterDBExec, 1)
@@ -278,33 +417,51 @@ func (db *DB) Exec(query string, args ...interface{}) ExecResult {
 }
 
 func (db *DB) QueryRow(query *Where, toFill ...interface{}) (found bool) {
-	start := time.Now()
-	row := db.client.QueryRow(query.String(), query.GetParameters()...)
-
-	db.engine.dataDog.incrementCounter(counterDBAll, 1)
-	db.engine.dataDog.incrementCounter(counterDBQuery, 1)
-	err := row.Scan(toFill...)
+	found, err := db.queryRow(query, toFill...)
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
-			if db.engine.queryLoggers[QueryLoggerSourceDB] != nil {
-				db.fillLogFields("[ORM][MYSQL][SELECT]", start, "select", query.String(), query.GetParameters(), nil)
-			}
-			return false
-		}
-		if db.engine.queryLoggers[QueryLoggerSourceDB] != nil {
-			db.fillLogFields("[ORM][MYSQL][SELECT]", start, "select", query.String(), query.GetParameters(), err)
-		}
 		panic(err)
 	}
+	return found
+}
+
+func (db *DB) queryRow(query *Where, toFill ...interface{}) (found bool, err error) {
+	start := time.Now()
+	sqlQuery, args, err := db.withMiddlewares("select", query.String(), query.GetParameters(), func(query string, args []interface{}) error {
+		var row SQLRow
+		if db.engine.flushContext != nil {
+			row = db.client.QueryRowContext(db.engine.flushContext, query, args...)
+		} else {
+			row = db.client.QueryRow(query, args...)
+		}
+		err := row.Scan(toFill...)
+		if err != nil && err.Error() == "sql: no rows in result set" {
+			return nil
+		}
+		found = err == nil
+		return err
+	})
+	db.engine.dataDog.incrementCounter(counterDBAll, 1)
+	db.engine.dataDog.incrementCounter(counterDBQuery, 1)
 	if db.engine.queryLoggers[QueryLoggerSourceDB] != nil {
-		db.fillLogFields("[ORM][MYSQL][SELECT]", start, "select", query.String(), query.GetParameters(), nil)
+		db.fillLogFields("[ORM][MYSQL][SELECT]", start, "select", sqlQuery, args, err)
+	}
+	if err != nil {
+		return false, err
 	}
-	return true
+	return found, nil
 }
 
 func (db *DB) Query(query string, args ...interface{}) (rows Rows, deferF func()) {
 	start := time.Now()
-	result, err := db.client.Query(query, args...)
+	var result SQLRows
+	query, args, err := db.withMiddlewares("select", query, args, func(query string, args []interface{}) (err error) {
+		if db.engine.flushContext != nil {
+			result, err = db.client.QueryContext(db.engine.flushContext, query, args...)
+		} else {
+			result, err = db.client.Query(query, args...)
+		}
+		return err
+	})
 	if db.engine.queryLoggers[QueryLoggerSourceDB] != nil {
 		db.fillLogFields("[ORM][MYSQL][SELECT]", start, "select", query, args, err)
 	}
@@ -330,7 +487,7 @@ func (db *DB) Query(query string, args ...interface{}) (rows Rows, deferF func()
 func (db *DB) fillLogFields(message string, start time.Time, typeCode string, query string, args []interface{}, err error) {
 	now := time.Now()
 	stop := time.Since(start).Microseconds()
-	e := db.engine.queryLoggers[QueryLoggerSourceDB].log.
+	e := db.engine.queryLoggerEntry(QueryLoggerSourceDB).
 		WithField("pool", db.code).
 		WithField("db", db.databaseName).
 		WithField("Query", query).
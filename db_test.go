@@ -1,8 +1,13 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -11,6 +16,13 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+type shardedEntity struct {
+	ORM
+	ID     uint64
+	UserID uint64 `orm:"shardKey"`
+	Name   string
+}
+
 type dbEntity struct {
 	ORM
 	ID   uint
@@ -163,3 +175,356 @@ func TestDBErrors(t *testing.T) {
 		row.RowsAffected()
 	})
 }
+
+func TestTryGetters(t *testing.T) {
+	engine := &Engine{}
+	db, err := engine.TryGetMysql("missing")
+	assert.Nil(t, db)
+	assert.EqualError(t, err, "unregistered mysql pool 'missing'")
+	_, err = engine.TryGetRedis()
+	assert.EqualError(t, err, "unregistered redis cache pool 'default'")
+	_, err = engine.TryGetRabbitMQQueue("test")
+	assert.EqualError(t, err, "unregistered rabbitMQ queue 'test'")
+	assert.PanicsWithError(t, "unregistered locker pool 'default'", func() {
+		engine.GetLocker()
+	})
+	engine.DisableGetterPanics()
+	assert.Nil(t, engine.GetLocker())
+	assert.Nil(t, engine.GetLocalCache("missing"))
+}
+
+func TestPoolResolver(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterLocalCache(100)
+	registry.RegisterLocalCache(100, "tenant2")
+	validated := (&validatedRegistry{}).clone(registry, nil)
+	validated.localCacheContainers = registry.localCacheContainers
+	validated.sqlClients["default"] = &DBConfig{code: "default"}
+	validated.sqlClients["tenant1"] = &DBConfig{code: "tenant1"}
+	engine := validated.CreateEngine()
+	schema := &tableSchema{mysqlPoolName: "default", localCacheName: "default"}
+
+	tenant := ""
+	engine.SetPoolResolver(func(schema TableSchema) string {
+		return tenant
+	})
+	assert.Equal(t, "default", schema.GetMysql(engine).GetPoolCode())
+	localCache, _ := schema.GetLocalCache(engine)
+	assert.Equal(t, "default", localCache.code)
+
+	tenant = "tenant1"
+	assert.Equal(t, "tenant1", schema.GetMysql(engine).GetPoolCode())
+	localCache, _ = schema.GetLocalCache(engine)
+	assert.Equal(t, "default@tenant1", localCache.code)
+	localCache.Set("key", "value")
+	_, has := engine.GetLocalCache().Get("key")
+	assert.False(t, has)
+	value, has := engine.GetLocalCache().Get("tenant1:key")
+	assert.True(t, has)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, localCache, engine.GetLocalCache("default@tenant1"))
+
+	tenant = "tenant2"
+	localCache, _ = schema.GetLocalCache(engine)
+	assert.Equal(t, "tenant2@tenant2", localCache.code)
+	assert.PanicsWithError(t, "unregistered mysql pool 'tenant2'", func() {
+		schema.GetMysql(engine)
+	})
+}
+
+func TestMySQLPoolOptions(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterMySQLPoolWithOptions("root:root@tcp(localhost:3310)/test?charset=utf8&parseTime=true", MySQLPoolOptions{
+		Timeout: time.Second, Collation: "utf8mb4_unicode_ci", Params: map[string]string{"sql_mode": "'STRICT_ALL_TABLES'"}})
+	config := registry.sqlClients["default"]
+	assert.Equal(t, "test", config.databaseName)
+	assert.NoError(t, applyMySQLPoolOptions(config))
+	assert.Equal(t, "root:root@tcp(localhost:3310)/test?collation=utf8mb4_unicode_ci&timeout=1s"+
+		"&charset=utf8&sql_mode=%27STRICT_ALL_TABLES%27", config.dataSourceName)
+
+	registry.RegisterMySQLPoolWithOptions("root:root@tcp(localhost:3310)/test", MySQLPoolOptions{TLSConfig: "missing"}, "tls")
+	assert.EqualError(t, applyMySQLPoolOptions(registry.sqlClients["tls"]),
+		"invalid mysql pool 'tls' options: invalid value / unknown config name: missing")
+	registry.RegisterMySQLPoolWithOptions("root:root@tcp(localhost:3310)/test", MySQLPoolOptions{ReadTimeout: -time.Second}, "timeout")
+	assert.EqualError(t, applyMySQLPoolOptions(registry.sqlClients["timeout"]), "negative timeout in mysql pool 'timeout' options not valid")
+
+	registry.RegisterMySQLPoolWithOptions("root:root@tcp(localhost:3310)/test", MySQLPoolOptions{InterpolateReads: true}, "interpolated")
+	config = registry.sqlClients["interpolated"]
+	assert.NoError(t, applyMySQLPoolOptions(config))
+	assert.Equal(t, "root:root@tcp(localhost:3310)/test", config.dataSourceName)
+	assert.Equal(t, "root:root@tcp(localhost:3310)/test?interpolateParams=true", config.readDataSourceName)
+	registry.RegisterMySQLPoolWithOptions("root:root@tcp(localhost:3310)/test", MySQLPoolOptions{InterpolateReads: true,
+		Collation: "gbk_chinese_ci"}, "unsafe")
+	assert.EqualError(t, applyMySQLPoolOptions(registry.sqlClients["unsafe"]),
+		"invalid mysql pool 'unsafe' options: invalid DSN: interpolateParams can not be used with unsafe collations")
+}
+
+func TestSharding(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterMySQLPool("root:root@tcp(localhost:3310)/test", "shard1")
+	registry.RegisterMySQLPool("root:root@tcp(localhost:3310)/test2", "shard2")
+	registry.RegisterEntity(&shardedEntity{})
+	entityType := reflect.TypeOf(shardedEntity{})
+	_, err := initTableSchema(registry, entityType)
+	assert.EqualError(t, err, "shard resolver for orm.shardedEntity not found")
+
+	registry.RegisterShards(&shardedEntity{}, func(key interface{}) int {
+		return int(key.(uint64) % 2)
+	}, "shard1", "missing")
+	_, err = initTableSchema(registry, entityType)
+	assert.EqualError(t, err, "mysql pool 'missing' not found")
+
+	registry.RegisterShards(&shardedEntity{}, func(key interface{}) int {
+		return int(key.(uint64) % 2)
+	}, "shard1", "shard2")
+	schema, err := initTableSchema(registry, entityType)
+	assert.NoError(t, err)
+	assert.Equal(t, "shard1", schema.mysqlPoolName)
+	assert.Equal(t, []string{"shard1", "shard2"}, schema.getMysqlPools())
+
+	validated := (&validatedRegistry{}).clone(registry, nil)
+	validated.sqlClients = registry.sqlClients
+	validated.tableSchemas[entityType] = schema
+	engine := validated.CreateEngine()
+	assert.PanicsWithError(t, "query without shard key on sharded entity orm.shardedEntity not supported", func() {
+		schema.GetMysql(engine)
+	})
+
+	first := &shardedEntity{UserID: 2}
+	second := &shardedEntity{UserID: 3}
+	third := &shardedEntity{UserID: 5}
+	engine.Track(first, second, third)
+	groups := groupEntitiesByShard(engine, []Entity{first, second, third})
+	assert.Len(t, groups, 2)
+	assert.Equal(t, "shard1", groups[0].pool)
+	assert.Equal(t, []Entity{first}, groups[0].entities)
+	assert.Equal(t, "shard2", groups[1].pool)
+	assert.Equal(t, []Entity{second, third}, groups[1].entities)
+	engine.withShardPool(schema, "shard2", func() {
+		assert.Equal(t, "shard2", schema.GetMysql(engine).GetPoolCode())
+		assert.Nil(t, groupEntitiesByShard(engine, []Entity{second, third}))
+	})
+
+	id := uint64(1)<<shardIDBits + 10
+	assert.Equal(t, 1, GetShardFromID(id))
+	pool, valid := schema.shard.poolForID(id)
+	assert.True(t, valid)
+	assert.Equal(t, "shard2", pool)
+	_, valid = schema.shard.poolForID(uint64(2)<<shardIDBits + 1)
+	assert.False(t, valid)
+	second.ID = id
+	second.UserID = 4
+	assert.PanicsWithError(t, fmt.Sprintf("moving orm.shardedEntity [%d] to another shard not supported", id), func() {
+		schema.shard.poolForEntity(second)
+	})
+}
+
+func TestTableSuffix(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterLocalCache(100)
+	validated := (&validatedRegistry{}).clone(registry, nil)
+	validated.localCacheContainers = registry.localCacheContainers
+	validated.sqlClients["default"] = &DBConfig{code: "default"}
+	engine := validated.CreateEngine()
+	schema := &tableSchema{t: reflect.TypeOf(dbEntity{}), tableName: "orders", mysqlPoolName: "default", localCacheName: "default",
+		hasTableSuffix: true, tableSuffixPattern: defaultTableSuffixPattern}
+	other := &tableSchema{tableName: "users", mysqlPoolName: "default"}
+
+	suffix := ""
+	engine.SetTableSuffixResolver(func(schema TableSchema) string {
+		return suffix
+	})
+	assert.Equal(t, "orders", schema.getTableName(engine))
+	localCache, _ := schema.GetLocalCache(engine)
+	assert.Equal(t, "default", localCache.code)
+
+	suffix = "2024_05"
+	assert.Equal(t, "orders_2024_05", schema.getTableName(engine))
+	assert.Equal(t, "users", other.getTableName(engine))
+	localCache, _ = schema.GetLocalCache(engine)
+	assert.Equal(t, "default@/2024_05", localCache.code)
+	localCache.Set("key", "value")
+	_, has := engine.GetLocalCache().Get("key")
+	assert.False(t, has)
+
+	suffixSchema := schema.withTableName(schema.getTableName(engine))
+	assert.Equal(t, "orders_2024_05", suffixSchema.tableName)
+	assert.Equal(t, "orders_2024_05", suffixSchema.getTableName(engine))
+	assert.Equal(t, "orders", schema.tableName)
+
+	suffix = "items"
+	assert.PanicsWithError(t, "table suffix 'items' in orm.dbEntity not valid", func() {
+		schema.getTableName(engine)
+	})
+	pattern, err := getTableSuffixPattern("eu|us", true)
+	assert.NoError(t, err)
+	assert.True(t, pattern.MatchString("eu"))
+	assert.False(t, pattern.MatchString("eu_2024"))
+	_, err = getTableSuffixPattern("[a-", true)
+	assert.EqualError(t, err, "table suffix pattern '[a-' not valid")
+	pattern, _ = getTableSuffixPattern("", false)
+	assert.Nil(t, pattern)
+}
+
+func TestSearchAllShardsOrder(t *testing.T) {
+	schema := &tableSchema{t: reflect.TypeOf(shardedEntity{})}
+	assert.Equal(t, []cachedOrderField{{field: "ID"}}, parseSearchOrder(schema, "`UserID` = ?"))
+	assert.Equal(t, []cachedOrderField{{field: "Name", desc: true}, {field: "UserID"}, {field: "ID"}},
+		parseSearchOrder(schema, "`UserID` > ? ORDER BY `Name` DESC, UserID ASC"))
+	assert.PanicsWithError(t, "field RAND() in orm.shardedEntity not found", func() {
+		parseSearchOrder(schema, "1 ORDER BY RAND()")
+	})
+
+	rows := []reflect.Value{
+		reflect.ValueOf(&shardedEntity{ID: 3, Name: "b"}),
+		reflect.ValueOf(&shardedEntity{ID: 1<<shardIDBits + 1, Name: "a"}),
+		reflect.ValueOf(&shardedEntity{ID: 1, Name: "b"}),
+	}
+	sortShardRows(rows, parseSearchOrder(schema, "1 ORDER BY `Name` DESC"))
+	assert.Equal(t, uint64(1), rows[0].Interface().(*shardedEntity).ID)
+	assert.Equal(t, uint64(3), rows[1].Interface().(*shardedEntity).ID)
+	assert.Equal(t, "a", rows[2].Interface().(*shardedEntity).Name)
+
+	pager := getSearchAllShardsPager(nil)
+	assert.Equal(t, 1, pager.GetCurrentPage())
+	assert.Equal(t, maxSearchAllShardsRows, pager.GetPageSize())
+	assert.Equal(t, 2, getSearchAllShardsPager(NewPager(2, 10)).GetCurrentPage())
+
+	err := &ShardSearchError{Shards: map[string]error{"shard2": errors.New("timeout"), "shard1": errors.New("refused")}}
+	assert.EqualError(t, err, "search failed in shards: shard1: refused, shard2: timeout")
+}
+
+type middlewareSQLClient struct {
+	queries  []string
+	contexts int
+}
+
+type middlewareSQLRow struct {
+	err error
+}
+
+func (r *middlewareSQLRow) Scan(dest ...interface{}) error {
+	return r.err
+}
+
+func (c *middlewareSQLClient) Begin() error {
+	c.queries = append(c.queries, "BEGIN")
+	return nil
+}
+
+func (c *middlewareSQLClient) BeginContext(_ context.Context) error {
+	c.contexts++
+	return c.Begin()
+}
+
+func (c *middlewareSQLClient) Commit() error {
+	c.queries = append(c.queries, "COMMIT")
+	return nil
+}
+
+func (c *middlewareSQLClient) Rollback() (bool, error) {
+	return false, nil
+}
+
+func (c *middlewareSQLClient) Exec(query string, args ...interface{}) (sql.Result, error) {
+	c.queries = append(c.queries, fmt.Sprintf("%s %v", query, args))
+	return nil, nil
+}
+
+func (c *middlewareSQLClient) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.contexts++
+	return c.Exec(query, args...)
+}
+
+func (c *middlewareSQLClient) QueryRow(query string, args ...interface{}) SQLRow {
+	c.queries = append(c.queries, fmt.Sprintf("%s %v", query, args))
+	return &middlewareSQLRow{err: sql.ErrNoRows}
+}
+
+func (c *middlewareSQLClient) QueryRowContext(_ context.Context, query string, args ...interface{}) SQLRow {
+	c.contexts++
+	return c.QueryRow(query, args...)
+}
+
+func (c *middlewareSQLClient) Query(query string, args ...interface{}) (SQLRows, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *middlewareSQLClient) QueryContext(_ context.Context, query string, args ...interface{}) (SQLRows, error) {
+	c.contexts++
+	return c.Query(query, args...)
+}
+
+func TestDBFlushContext(t *testing.T) {
+	engine := (&validatedRegistry{}).clone(&Registry{}, nil).CreateEngine()
+	client := &middlewareSQLClient{}
+	db := &DB{engine: engine, client: client, code: "default"}
+	db.Begin()
+	db.QueryRow(NewWhere("SELECT 1"))
+	assert.Equal(t, 0, client.contexts)
+
+	engine.flushContext = context.Background()
+	db.Begin()
+	db.Exec("DELETE FROM `a`")
+	db.QueryRow(NewWhere("SELECT 1"))
+	assert.Panics(t, func() {
+		db.Query("SELECT 1")
+	})
+	assert.Equal(t, 4, client.contexts)
+}
+
+func TestSQLMiddleware(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterSQLMiddleware(func(query *SQLQuery, next func() error) error {
+		if query.Operation == "exec" {
+			query.Query += " AND `Tenant` = ?"
+			query.Args = append(query.Args, 7)
+		}
+		return next()
+	})
+	engine := (&validatedRegistry{}).clone(registry, nil).CreateEngine()
+	client := &middlewareSQLClient{}
+	db := &DB{engine: engine, client: client, code: "default"}
+	logger := memory.New()
+	engine.AddQueryLogger(logger, log2.InfoLevel, QueryLoggerSourceDB)
+
+	audit := make([]string, 0)
+	engine.AddSQLMiddleware(func(query *SQLQuery, next func() error) error {
+		if strings.HasPrefix(query.Query, "DELETE FROM `blocked`") {
+			return errors.New("circuit open")
+		}
+		if strings.HasPrefix(query.Query, "DELETE FROM `skipped`") {
+			return nil
+		}
+		err := next()
+		audit = append(audit, fmt.Sprintf("%s %s %s %v %t", query.Pool, query.Operation, query.Query, err, query.Duration > 0))
+		return err
+	})
+
+	db.Begin()
+	db.Exec("DELETE FROM `a` WHERE `ID` = ?", 1)
+	db.Commit()
+	var id uint64
+	assert.False(t, db.QueryRow(NewWhere("SELECT `ID` FROM `a` WHERE `ID` = ?", 2), &id))
+	assert.PanicsWithError(t, "circuit open", func() {
+		db.Exec("DELETE FROM `blocked`")
+	})
+	assert.PanicsWithError(t, "not implemented", func() {
+		db.Query("SELECT 1")
+	})
+	assert.PanicsWithError(t, "query not executed", func() {
+		db.Exec("DELETE FROM `skipped`")
+	})
+	assert.Equal(t, "DELETE FROM `a` WHERE `ID` = ? AND `Tenant` = ?", logger.Entries[1].Fields["Query"])
+	assert.Equal(t, []interface{}{1, 7}, logger.Entries[1].Fields["args"])
+
+	assert.Equal(t, []string{"BEGIN", "DELETE FROM `a` WHERE `ID` = ? AND `Tenant` = ? [1 7]", "COMMIT",
+		"SELECT `ID` FROM `a` WHERE `ID` = ? [2]"}, client.queries)
+	assert.Equal(t, []string{
+		"default begin START TRANSACTION <nil> true",
+		"default exec DELETE FROM `a` WHERE `ID` = ? AND `Tenant` = ? <nil> true",
+		"default commit COMMIT <nil> true",
+		"default select SELECT `ID` FROM `a` WHERE `ID` = ? <nil> true",
+		"default select SELECT 1 not implemented true",
+	}, audit)
+}
@@ -0,0 +1,89 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+func deleteByWhere(engine *Engine, entity Entity, where *Where) int64 {
+	schema := initIfNeeded(engine, entity).tableSchema
+	// all matching rows are deleted, so ORDER BY is ignored
+	condition, _, limit := where.splitTrailingClauses()
+	if limit != nil {
+		panic(errors.NotSupportedf("LIMIT in delete by where"))
+	}
+	entities := make([]Entity, 0)
+	searchInIDOrder(engine, schema.t, condition, 1000, func(rows reflect.Value) {
+		for i := 0; i < rows.Len(); i++ {
+			entities = append(entities, rows.Index(i).Interface().(Entity))
+		}
+	})
+	if len(entities) == 0 {
+		return 0
+	}
+	ids := make([]interface{}, len(entities))
+	for i, row := range entities {
+		ids[i] = row.GetID()
+	}
+	localCacheSets := make(map[string]map[string][]interface{})
+	localCacheDeletes := make(map[string]map[string]bool)
+	redisKeysToDelete := make(map[string]map[string]bool)
+	dirtyQueues := make(map[string][]*DirtyQueueValue)
+	logQueues := make([]*LogQueueValue, 0)
+	addReferencedQueriesKeys(engine, schema, ids, localCacheDeletes, redisKeysToDelete)
+
+	phase := startFlushPhase(engine, "delete")
+	defer func() {
+		phase.finish()
+	}()
+	db := schema.GetMysql(engine)
+	in := NewWhere("`ID` IN ?", ids)
+	/* #nosec */
+	sql := fmt.Sprintf("DELETE FROM `%s` WHERE %s", schema.getTableName(engine), in)
+	if schema.hasFakeDelete {
+		/* #nosec */
+		sql = fmt.Sprintf("UPDATE `%s` SET `FakeDelete` = `ID` WHERE %s", schema.getTableName(engine), in)
+	}
+	affected := db.Exec(sql, in.GetParameters()...).RowsAffected()
+
+	localCache, hasLocalCache := schema.GetLocalCache(engine)
+	redisCache, hasRedis := schema.GetRedisCache(engine)
+	for _, row := range entities {
+		id := row.GetID()
+		old := row.getORM().dBData
+		if hasRedis {
+			addCacheDeletes(redisKeysToDelete, redisCache.code, schema.getCacheKey(id))
+		}
+		if !schema.hasFakeDelete {
+			if hasLocalCache {
+				addLocalCacheSet(localCacheSets, db.GetPoolCode(), localCache.code, schema.getCacheKey(id), "nil")
+			}
+			addCacheQueriesDeletes(engine, schema, old, old, true, localCacheDeletes, redisKeysToDelete)
+			addDirtyQueues(dirtyQueues, old, schema, id, "d")
+			addEntityChange(engine, schema, id, old, nil)
+			logQueues = addToLogQueue(logQueues, schema, id, old, nil, nil)
+			continue
+		}
+		if hasLocalCache {
+			addCacheDeletes(localCacheDeletes, localCache.code, schema.getCacheKey(id))
+		}
+		bind := map[string]interface{}{"FakeDelete": strconv.FormatUint(id, 10)}
+		addCacheQueriesDeletes(engine, schema, bind, old, false, localCacheDeletes, redisKeysToDelete)
+		addDirtyQueues(dirtyQueues, bind, schema, id, "u")
+		if schema.hasChanges {
+			after := make(map[string]interface{}, len(old))
+			for k, v := range old {
+				after[k] = v
+			}
+			after["FakeDelete"] = bind["FakeDelete"]
+			addEntityChange(engine, schema, id, old, after)
+		}
+		logQueues = addToLogQueue(logQueues, schema, id, old, bind, row.getORM().attributes.logMeta)
+	}
+	phase = flushCacheAndQueues(engine, phase, false, false, make(map[string]interface{}), localCacheSets, localCacheDeletes,
+		redisKeysToDelete, dirtyQueues, logQueues)
+	return int64(affected)
+}
@@ -1,6 +1,8 @@
 package orm
 
 import (
+	"context"
+	"reflect"
 	"testing"
 	"time"
 
@@ -14,6 +16,14 @@ type dirtyReceiverEntity struct {
 	Age  uint64
 }
 
+type changeStreamEntity struct {
+	ORM  `orm:"changes"`
+	ID   uint
+	Name string
+	Age  uint
+	Meta interface{}
+}
+
 func TestDirtyReceiver(t *testing.T) {
 	var entity *dirtyReceiverEntity
 	registry := &Registry{}
@@ -141,3 +151,92 @@ func TestDirtyReceiver(t *testing.T) {
 	})
 	assert.True(t, valid)
 }
+
+func TestSubscribeChanges(t *testing.T) {
+	var entity *changeStreamEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+	schema := engine.registry.GetTableSchemaForEntity(entity).(*tableSchema)
+	for _, subscriber := range []string{"first", "second"} {
+		consumer := getChangesSubscriberQueue(engine, schema, subscriber).NewConsumer("test")
+		consumer.Purge()
+		consumer.Close()
+	}
+
+	e := &changeStreamEntity{Name: "John"}
+	engine.TrackAndFlush(e)
+	e.Name = "Tom"
+	engine.TrackAndFlush(e)
+	e.Name = "Adam"
+	engine.Track(e)
+	engine.FlushInTransaction()
+	assert.Len(t, engine.afterCommitChanges, 0)
+	engine.IncrementField(e, "Age", 2)
+	engine.SetJSONPaths(e, "Meta", map[string]interface{}{"$.a": 1})
+	engine.TrackAndFlush(e)
+	engine.UpdateByWhere(entity, map[string]interface{}{"Name": "Bob"}, NewWhere("ID = ?", e.ID))
+	engine.MarkToDelete(e)
+	engine.Flush()
+	e2 := &changeStreamEntity{Name: "Eva"}
+	engine.TrackAndFlush(e2)
+	_, err := engine.DeleteByWhere(entity, NewWhere("ID = ?", e2.ID))
+	assert.NoError(t, err)
+
+	for _, subscriber := range []string{"first", "second"} {
+		changes := make([]EntityChange, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		engine.SubscribeChanges(ctx, subscriber, func(change EntityChange) {
+			changes = append(changes, change)
+			if len(changes) == 9 {
+				cancel()
+			}
+		}, entity)
+		assert.Len(t, changes, 9)
+		assert.True(t, changes[0].Added)
+		assert.Equal(t, "John", changes[0].After["Name"])
+		assert.True(t, changes[1].Updated)
+		assert.Equal(t, "John", changes[1].Before["Name"])
+		assert.Equal(t, "Tom", changes[1].After["Name"])
+		assert.True(t, changes[2].Updated)
+		assert.Equal(t, "Adam", changes[2].After["Name"])
+		assert.True(t, changes[3].Updated)
+		assert.Equal(t, "2", changes[3].After["Age"])
+		assert.True(t, changes[4].Updated)
+		assert.Nil(t, changes[4].Before["Meta"])
+		assert.NotNil(t, changes[4].After["Meta"])
+		assert.True(t, changes[5].Updated)
+		assert.Equal(t, "Adam", changes[5].Before["Name"])
+		assert.Equal(t, "Bob", changes[5].After["Name"])
+		assert.True(t, changes[6].Deleted)
+		assert.Nil(t, changes[6].After)
+		assert.True(t, changes[7].Added)
+		assert.True(t, changes[8].Deleted)
+		assert.Equal(t, "Eva", changes[8].Before["Name"])
+	}
+
+	assert.PanicsWithError(t, "empty change stream subscriber name not valid", func() {
+		engine.SubscribeChanges(context.Background(), "", func(change EntityChange) {}, entity)
+	})
+}
+
+func TestEntityChange(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterMySQLPool("root:root@tcp(localhost:3310)/test")
+	schema, err := initTableSchema(registry, reflect.TypeOf(changeStreamEntity{}))
+	assert.NoError(t, err)
+	assert.True(t, schema.hasChanges)
+	assert.Equal(t, "orm_changes_orm.changeStreamEntity", schema.getChangesQueueName())
+
+	engine := &Engine{}
+	before := map[string]interface{}{"Name": "John"}
+	addEntityChange(engine, schema, 1, nil, before)
+	addEntityChange(engine, schema, 1, before, map[string]interface{}{"Name": "Tom"})
+	addEntityChange(engine, schema, 1, before, nil)
+	before["Name"] = "Adam"
+	assert.Len(t, engine.afterCommitChanges, 3)
+	assert.True(t, engine.afterCommitChanges[0].Added)
+	assert.Equal(t, "John", engine.afterCommitChanges[0].After["Name"])
+	assert.True(t, engine.afterCommitChanges[1].Updated)
+	assert.Equal(t, "Tom", engine.afterCommitChanges[1].After["Name"])
+	assert.True(t, engine.afterCommitChanges[2].Deleted)
+	assert.Equal(t, "John", engine.afterCommitChanges[2].Before["Name"])
+}
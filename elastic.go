@@ -82,7 +82,7 @@ func (e *Elastic) Search(index string, query elastic.Query, pager *Pager, sort *
 func (e *Elastic) fillLogFields(message string, start time.Time, operation string, fields log2.Fielder, err error) {
 	now := time.Now()
 	stop := time.Since(start).Microseconds()
-	entry := e.engine.queryLoggers[QueryLoggerSourceElastic].log.
+	entry := e.engine.queryLoggerEntry(QueryLoggerSourceElastic).
 		WithField("microseconds", stop).
 		WithField("operation", operation).
 		WithField("pool", e.code).
@@ -1,9 +1,14 @@
 package orm
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"reflect"
+	"sync"
 	"time"
 
 	logApex "github.com/apex/log"
@@ -15,24 +20,43 @@ import (
 )
 
 type Engine struct {
-	registry                     *validatedRegistry
-	dbs                          map[string]*DB
-	clickHouseDbs                map[string]*ClickHouse
-	localCache                   map[string]*LocalCache
-	redis                        map[string]*RedisCache
-	elastic                      map[string]*Elastic
-	locks                        map[string]*Locker
-	rabbitMQChannels             map[string]*rabbitMQChannel
-	rabbitMQQueues               map[string]*RabbitMQQueue
-	rabbitMQRouters              map[string]*RabbitMQRouter
-	logMetaData                  map[string]interface{}
-	trackedEntities              []Entity
-	trackedEntitiesCounter       int
-	queryLoggers                 map[QueryLoggerSource]*logger
-	log                          *log
-	afterCommitLocalCacheSets    map[string][]interface{}
-	afterCommitRedisCacheDeletes map[string][]string
-	dataDog                      *dataDog
+	registry                           *validatedRegistry
+	dbs                                map[string]*DB
+	clickHouseDbs                      map[string]*ClickHouse
+	localCache                         map[string]*LocalCache
+	redis                              map[string]*RedisCache
+	elastic                            map[string]*Elastic
+	locks                              map[string]*Locker
+	rabbitMQChannels                   map[string]*rabbitMQChannel
+	rabbitMQQueues                     map[string]*RabbitMQQueue
+	rabbitMQRouters                    map[string]*RabbitMQRouter
+	logMetaData                        map[string]interface{}
+	trackedEntities                    entityTracker
+	disableTrackDeduplication          bool
+	flushWorkers                       int
+	trackMutex                         sync.Mutex
+	flushMutex                         sync.Mutex
+	queryLoggers                       map[QueryLoggerSource]*logger
+	sqlMiddlewares                     []SQLMiddleware
+	log                                *log
+	afterCommitLocalCacheSets          map[string][]interface{}
+	afterCommitRedisCacheDeletes       map[string]map[string]bool
+	afterCommitLocalCacheInvalidations map[string]map[string]bool
+	dryRunQueries                      *[]PlannedQuery
+	afterCommitChanges                 []*EntityChange
+	dataDog                            *dataDog
+	tracerContext                      context.Context
+	tracerContextStack                 []context.Context
+	flushContext                       context.Context
+	lazyDelay                          time.Duration
+	trackLimit                         int
+	trackAutoFlush                     bool
+	disableGetterPanics                bool
+	lazyDelayKey                       string
+	poolResolver                       PoolResolver
+	shardPools                         map[*tableSchema]string
+	tableSuffixResolver                TableSuffixResolver
+	tenant                             string
 }
 
 func (e *Engine) DataDog() DataDog {
@@ -53,6 +77,16 @@ func (e *Engine) EnableLogger(level logApex.Level) {
 	e.log.logger.handler.Handlers = append(e.log.logger.handler.Handlers, levelHandler.New(&jsonHandler{}, level))
 }
 
+func (e *Engine) EnableProductionLogging(writer io.Writer) {
+	handler := NewJSONLogHandler(writer)
+	if e.log == nil {
+		e.log = newLog(e)
+	}
+	e.log.logger.handler.Handlers = append(e.log.logger.handler.Handlers, levelHandler.New(handler, logApex.InfoLevel))
+	e.AddQueryLogger(handler, logApex.WarnLevel, QueryLoggerSourceDB, QueryLoggerSourceRedis, QueryLoggerSourceRabbitMQ,
+		QueryLoggerSourceElastic, QueryLoggerSourceClickHouse, QueryLoggerSourceLocalCache)
+}
+
 func (e *Engine) EnableDebug() {
 	if e.log == nil {
 		e.log = newLog(e)
@@ -67,8 +101,12 @@ func (e *Engine) AddQueryLogger(handler logApex.Handler, level logApex.Level, so
 	if len(source) == 0 {
 		source = []QueryLoggerSource{QueryLoggerSourceDB, QueryLoggerSourceRedis, QueryLoggerSourceRabbitMQ, QueryLoggerSourceElastic, QueryLoggerSourceClickHouse}
 	}
-	newHandler := levelHandler.New(handler, level)
 	for _, source := range source {
+		sourceHandler := handler
+		if sampled, is := handler.(*samplingHandler); is {
+			sourceHandler = NewSampledLogHandler(sampled.handler, sampled.sampling)
+		}
+		newHandler := levelHandler.New(sourceHandler, level)
 		l, has := e.queryLoggers[source]
 		if has {
 			l.handler.Handlers = append(l.handler.Handlers, newHandler)
@@ -89,17 +127,76 @@ func (e *Engine) SetLogMetaData(key string, value interface{}) {
 	e.logMetaData[key] = value
 }
 
+func (e *Engine) SetLogActor(actor Actor) {
+	e.SetLogMetaData(logActorKey, actor)
+}
+
+type TrackLimitError struct {
+	Limit int
+}
+
+func (err *TrackLimitError) Error() string {
+	return fmt.Sprintf("track limit %d exceeded", err.Limit)
+}
+
 func (e *Engine) Track(entity ...Entity) {
+	limit := e.getTrackLimit()
 	for _, entity := range entity {
-		initIfNeeded(e, entity)
-		e.trackedEntities = append(e.trackedEntities, entity)
-		e.trackedEntitiesCounter++
-		if e.trackedEntitiesCounter == 10000 {
-			panic(errors.Errorf("track limit 10000 exceeded"))
+		toFlush := e.track(entity, limit)
+		if toFlush != nil {
+			e.flushTakenEntities(false, false, toFlush)
 		}
 	}
 }
 
+// track returns tracked entities when auto flush limit is reached, they are flushed outside of lock
+func (e *Engine) track(entity Entity, limit int) []Entity {
+	e.trackMutex.Lock()
+	defer e.trackMutex.Unlock()
+	initIfNeeded(e, entity)
+	assignGeneratedID(e, entity)
+	if e.trackedEntities.add(entity, !e.disableTrackDeduplication) && e.trackedEntities.len() == limit {
+		if !e.trackAutoFlush {
+			panic(&TrackLimitError{Limit: limit})
+		}
+		entities := e.trackedEntities.entities
+		e.trackedEntities.clear()
+		return entities
+	}
+	return nil
+}
+
+func (e *Engine) TrackWithCheck(entity ...Entity) (err error) {
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				asErr, is := r.(*TrackLimitError)
+				if !is {
+					panic(r)
+				}
+				err = asErr
+			}
+		}()
+		e.Track(entity...)
+	}()
+	return err
+}
+
+func (e *Engine) SetTrackLimit(limit int) {
+	e.trackLimit = limit
+}
+
+func (e *Engine) EnableTrackAutoFlush() {
+	e.trackAutoFlush = true
+}
+
+func (e *Engine) getTrackLimit() int {
+	if e.trackLimit <= 0 {
+		return 10000
+	}
+	return e.trackLimit
+}
+
 func (e *Engine) TrackAndFlush(entity ...Entity) {
 	e.Track(entity...)
 	e.Flush()
@@ -135,14 +232,51 @@ func (e *Engine) FlushWithFullCheck() error {
 	return err
 }
 
+func (e *Engine) FlushWithContext(ctx context.Context) {
+	e.flushWithContext(ctx)
+}
+
+func (e *Engine) ConnectAll(ctx context.Context) error {
+	return connectAll(ctx, e)
+}
+
+func (e *Engine) FlushDryRun() (queries []PlannedQuery, err error) {
+	e.trackMutex.Lock()
+	entities := e.trackedEntities.entities
+	e.trackMutex.Unlock()
+	e.flushMutex.Lock()
+	defer e.flushMutex.Unlock()
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				asErr, is := r.(error)
+				if !is {
+					panic(r)
+				}
+				err = asErr
+			}
+		}()
+		queries = flushDryRun(e, entities...)
+	}()
+	return queries, err
+}
+
 func (e *Engine) FlushLazy() {
 	e.flushTrackedEntities(true, false)
 }
 
+func (e *Engine) FlushLazyAfter(delay time.Duration) {
+	flushLazyAfter(e, delay)
+}
+
 func (e *Engine) FlushInTransaction() {
 	e.flushTrackedEntities(false, true)
 }
 
+func (e *Engine) FlushInChunks(chunkSize int, progress func(flushed int, total int)) {
+	e.flushInChunks(chunkSize, progress)
+}
+
 func (e *Engine) FlushWithLock(lockerPool string, lockName string, ttl time.Duration, waitTimeout time.Duration) {
 	e.flushWithLock(false, lockerPool, lockName, ttl, waitTimeout)
 }
@@ -152,13 +286,78 @@ func (e *Engine) FlushInTransactionWithLock(lockerPool string, lockName string,
 }
 
 func (e *Engine) ClearTrackedEntities() {
-	e.trackedEntities = make([]Entity, 0)
+	e.trackMutex.Lock()
+	defer e.trackMutex.Unlock()
+	e.trackedEntities.clear()
+}
+
+func (e *Engine) DisableTrackDeduplication() {
+	e.disableTrackDeduplication = true
 }
 
 func (e *Engine) SetOnDuplicateKeyUpdate(update *Where, entity Entity) {
 	initIfNeeded(e, entity).attributes.onDuplicateKeyUpdate = update
 }
 
+func (e *Engine) MarkForcedDirty(entity Entity, columns ...string) {
+	orm := initIfNeeded(e, entity)
+	if len(columns) == 0 {
+		for _, column := range orm.tableSchema.columnNames {
+			if column != "ID" {
+				columns = append(columns, column)
+			}
+		}
+	}
+	for _, column := range columns {
+		if column == "ID" || !hasColumn(orm.tableSchema, column) {
+			panic(errors.NotValidf("column '%s' in %s", column, orm.tableSchema.t.String()))
+		}
+	}
+	orm.attributes.forcedDirty = columns
+	e.Track(entity)
+}
+
+func (e *Engine) SetJSONPaths(entity Entity, field string, values map[string]interface{}) {
+	setJSONPaths(e, entity, field, values)
+}
+
+func (e *Engine) RemoveJSONPaths(entity Entity, field string, paths ...string) {
+	removeJSONPaths(e, entity, field, paths)
+}
+
+func (e *Engine) IncrementField(entity Entity, field string, delta int64) {
+	incrementField(e, entity, field, delta, false)
+}
+
+func (e *Engine) IncrementFieldLazy(entity Entity, field string, delta int64) {
+	incrementField(e, entity, field, delta, true)
+}
+
+func (e *Engine) SetFieldExpr(entity Entity, field string, expression Expr) {
+	setFieldExpr(e, entity, field, expression)
+}
+
+func (e *Engine) GetEntityLogs(entity Entity, id uint64, pager *Pager, filter ...*LogFilter) []*LogEntry {
+	checkPageSize(e, pager)
+	var logFilter *LogFilter
+	if len(filter) > 0 {
+		logFilter = filter[0]
+	}
+	return getEntityLogs(e, entity, id, pager, logFilter)
+}
+
+func (e *Engine) RevertEntity(entity Entity, logEntryID uint64) {
+	revertEntity(e, entity, logEntryID)
+}
+
+func (e *Engine) SubscribeChanges(ctx context.Context, subscriber string, handler func(EntityChange), entities ...Entity) {
+	subscribeChanges(ctx, e, subscriber, handler, entities)
+}
+
+func (e *Engine) PurgeEntityLogs(ctx context.Context) (deleted uint64) {
+	return purgeEntityLogs(ctx, e)
+}
+
 func (e *Engine) SetEntityLogMeta(key string, value interface{}, entity ...Entity) {
 	for _, row := range entity {
 		orm := initIfNeeded(e, row)
@@ -171,16 +370,54 @@ func (e *Engine) SetEntityLogMeta(key string, value interface{}, entity ...Entit
 
 func (e *Engine) MarkToDelete(entity ...Entity) {
 	for _, row := range entity {
+		markToDelete(e, row)
 		e.Track(row)
-		orm := initIfNeeded(e, row)
-		if orm.tableSchema.hasFakeDelete {
-			orm.attributes.elem.FieldByName("FakeDelete").SetBool(true)
-			continue
-		}
-		orm.attributes.delete = true
 	}
 }
 
+func markToDelete(engine *Engine, entity Entity) {
+	orm := initIfNeeded(engine, entity)
+	if orm.tableSchema.hasFakeDelete {
+		orm.attributes.elem.FieldByName("FakeDelete").SetBool(true)
+		return
+	}
+	orm.attributes.delete = true
+}
+
+func (e *Engine) MarkToRestore(entity ...Entity) {
+	for _, row := range entity {
+		markToRestore(e, row)
+	}
+}
+
+func (e *Engine) DeleteByWhere(entity Entity, where *Where) (deleted int64, err error) {
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				asErr, is := r.(error)
+				if !is {
+					panic(r)
+				}
+				err = asErr
+			}
+		}()
+		deleted = deleteByWhere(e, entity, where)
+	}()
+	return deleted, err
+}
+
+func (e *Engine) UpdateByWhere(entity Entity, set map[string]interface{}, where *Where) (updated int64) {
+	return updateByWhere(e, entity, set, where, 0)
+}
+
+func (e *Engine) UpdateByWhereInChunks(entity Entity, set map[string]interface{}, where *Where, chunkSize int) (updated int64) {
+	return updateByWhere(e, entity, set, where, chunkSize)
+}
+
+func (e *Engine) MarkToDeleteCascade(entity ...Entity) {
+	markToDeleteCascade(e, entity...)
+}
+
 func (e *Engine) ForceMarkToDelete(entity ...Entity) {
 	for _, row := range entity {
 		orm := initIfNeeded(e, row)
@@ -190,7 +427,7 @@ func (e *Engine) ForceMarkToDelete(entity ...Entity) {
 }
 
 func (e *Engine) MarkDirty(entity Entity, queueCode string, ids ...uint64) {
-	_, has := e.GetRegistry().GetDirtyQueues()[queueCode]
+	_, has := e.registry.dirtyQueues[queueCode]
 	if !has {
 		panic(errors.NotValidf("unknown dirty queue '%s'", queueCode))
 	}
@@ -222,132 +459,222 @@ func (e *Engine) GetRegistry() ValidatedRegistry {
 }
 
 func (e *Engine) GetMysql(code ...string) *DB {
+	db, err := e.TryGetMysql(code...)
+	e.checkGetterError(err)
+	return db
+}
+
+func (e *Engine) TryGetMysql(code ...string) (*DB, error) {
 	dbCode := "default"
 	if len(code) > 0 {
 		dbCode = code[0]
 	}
 	db, has := e.dbs[dbCode]
 	if !has {
-		panic(errors.Errorf("unregistered mysql pool '%s'", dbCode))
+		return nil, errors.Errorf("unregistered mysql pool '%s'", dbCode)
 	}
-	return db
+	return db, nil
 }
 
 func (e *Engine) GetLocalCache(code ...string) *LocalCache {
+	cache, err := e.TryGetLocalCache(code...)
+	e.checkGetterError(err)
+	return cache
+}
+
+func (e *Engine) TryGetLocalCache(code ...string) (*LocalCache, error) {
 	dbCode := "default"
 	if len(code) > 0 {
 		dbCode = code[0]
 	}
 	cache, has := e.localCache[dbCode]
 	if !has {
-		panic(errors.Errorf("unregistered local cache pool '%s'", dbCode))
+		cache, has = e.getNamespacedLocalCache(dbCode)
 	}
-	return cache
+	if !has {
+		return nil, errors.Errorf("unregistered local cache pool '%s'", dbCode)
+	}
+	return cache, nil
 }
 
 func (e *Engine) GetRedis(code ...string) *RedisCache {
+	cache, err := e.TryGetRedis(code...)
+	e.checkGetterError(err)
+	return cache
+}
+
+func (e *Engine) TryGetRedis(code ...string) (*RedisCache, error) {
 	dbCode := "default"
 	if len(code) > 0 {
 		dbCode = code[0]
 	}
 	cache, has := e.redis[dbCode]
 	if !has {
-		panic(errors.Errorf("unregistered redis cache pool '%s'", dbCode))
+		cache, has = e.getNamespacedRedis(dbCode)
 	}
-	return cache
+	if !has {
+		return nil, errors.Errorf("unregistered redis cache pool '%s'", dbCode)
+	}
+	return cache, nil
 }
 
 func (e *Engine) GetElastic(code ...string) *Elastic {
+	elastic, err := e.TryGetElastic(code...)
+	e.checkGetterError(err)
+	return elastic
+}
+
+func (e *Engine) TryGetElastic(code ...string) (*Elastic, error) {
 	dbCode := "default"
 	if len(code) > 0 {
 		dbCode = code[0]
 	}
 	elastic, has := e.elastic[dbCode]
 	if !has {
-		panic(errors.Errorf("unregistered elastic pool '%s'", dbCode))
+		return nil, errors.Errorf("unregistered elastic pool '%s'", dbCode)
 	}
-	return elastic
+	return elastic, nil
 }
 
 func (e *Engine) GetClickHouse(code ...string) *ClickHouse {
+	ch, err := e.TryGetClickHouse(code...)
+	e.checkGetterError(err)
+	return ch
+}
+
+func (e *Engine) TryGetClickHouse(code ...string) (*ClickHouse, error) {
 	dbCode := "default"
 	if len(code) > 0 {
 		dbCode = code[0]
 	}
 	ch, has := e.clickHouseDbs[dbCode]
 	if !has {
-		panic(errors.Errorf("unregistered clickhouse pool '%s'", dbCode))
+		return nil, errors.Errorf("unregistered clickhouse pool '%s'", dbCode)
 	}
-	return ch
+	return ch, nil
 }
 
 func (e *Engine) GetRabbitMQQueue(queueName string) *RabbitMQQueue {
+	queue, err := e.TryGetRabbitMQQueue(queueName)
+	e.checkGetterError(err)
+	return queue
+}
+
+func (e *Engine) TryGetRabbitMQQueue(queueName string) (*RabbitMQQueue, error) {
 	queue, has := e.rabbitMQQueues[queueName]
 	if has {
-		return queue
+		return queue, nil
 	}
 	channel, has := e.rabbitMQChannels[queueName]
 	if !has {
-		panic(errors.Errorf("unregistered rabbitMQ queue '%s'", queueName))
+		return nil, errors.Errorf("unregistered rabbitMQ queue '%s'", queueName)
 	}
 	if channel.config.Router != "" {
-		panic(errors.Errorf("rabbitMQ queue '%s' is declared as router", queueName))
+		return nil, errors.Errorf("rabbitMQ queue '%s' is declared as router", queueName)
 	}
 	if e.rabbitMQQueues == nil {
 		e.rabbitMQQueues = make(map[string]*RabbitMQQueue)
 	}
 	e.rabbitMQQueues[queueName] = &RabbitMQQueue{channel}
-	return e.rabbitMQQueues[queueName]
+	return e.rabbitMQQueues[queueName], nil
 }
 
 func (e *Engine) GetRabbitMQRouter(channelName string) *RabbitMQRouter {
+	router, err := e.TryGetRabbitMQRouter(channelName)
+	e.checkGetterError(err)
+	return router
+}
+
+func (e *Engine) TryGetRabbitMQRouter(channelName string) (*RabbitMQRouter, error) {
 	queue, has := e.rabbitMQRouters[channelName]
 	if has {
-		return queue
+		return queue, nil
 	}
 	channel, has := e.rabbitMQChannels[channelName]
 	if !has {
-		panic(errors.Errorf("unregistered rabbitMQ router '%s'", channelName))
+		return nil, errors.Errorf("unregistered rabbitMQ router '%s'", channelName)
 	}
 	if channel.config.Router == "" {
-		panic(errors.Errorf("rabbitMQ queue '%s' is not declared as router", channelName))
+		return nil, errors.Errorf("rabbitMQ queue '%s' is not declared as router", channelName)
 	}
 	if e.rabbitMQRouters == nil {
 		e.rabbitMQRouters = make(map[string]*RabbitMQRouter)
 	}
 	e.rabbitMQRouters[channelName] = &RabbitMQRouter{channel}
-	return e.rabbitMQRouters[channelName]
+	return e.rabbitMQRouters[channelName], nil
+}
+
+func (e *Engine) DisableGetterPanics() {
+	e.disableGetterPanics = true
+}
+
+func (e *Engine) checkGetterError(err error) {
+	if err == nil {
+		return
+	}
+	if !e.disableGetterPanics {
+		panic(err)
+	}
+	e.Log().Error(err, nil)
 }
 
 func (e *Engine) GetLocker(code ...string) *Locker {
+	locker, err := e.TryGetLocker(code...)
+	e.checkGetterError(err)
+	return locker
+}
+
+func (e *Engine) TryGetLocker(code ...string) (*Locker, error) {
 	dbCode := "default"
 	if len(code) > 0 {
 		dbCode = code[0]
 	}
 	locker, has := e.locks[dbCode]
 	if !has {
-		panic(errors.Errorf("unregistered locker pool '%s'", dbCode))
+		return nil, errors.Errorf("unregistered locker pool '%s'", dbCode)
 	}
-	return locker
+	return locker, nil
 }
 
 func (e *Engine) SearchWithCount(where *Where, pager *Pager, entities interface{}, references ...string) (totalRows int) {
+	pager = searchPager(e, pager)
 	return search(true, e, where, pager, true, reflect.ValueOf(entities).Elem(), references...)
 }
 
 func (e *Engine) Search(where *Where, pager *Pager, entities interface{}, references ...string) {
+	pager = searchPager(e, pager)
 	search(true, e, where, pager, false, reflect.ValueOf(entities).Elem(), references...)
 }
 
+func (e *Engine) SearchInShard(shardKey interface{}, where *Where, pager *Pager, entities interface{}, references ...string) {
+	pager = searchPager(e, pager)
+	searchInShard(e, shardKey, where, pager, reflect.ValueOf(entities).Elem(), references)
+}
+
+func (e *Engine) SearchOneInShard(shardKey interface{}, where *Where, entity Entity, references ...string) (found bool) {
+	return searchOneInShard(e, shardKey, where, entity, references)
+}
+
+func (e *Engine) SearchAllShards(where *Where, pager *Pager, entities interface{}, references ...string) error {
+	checkPageSize(e, pager)
+	return searchAllShards(e, where, pager, reflect.ValueOf(entities).Elem(), references)
+}
+
 func (e *Engine) SearchIDsWithCount(where *Where, pager *Pager, entity interface{}) (results []uint64, totalRows int) {
+	pager = searchPager(e, pager)
 	return searchIDsWithCount(true, e, where, pager, reflect.TypeOf(entity))
 }
 
 func (e *Engine) SearchIDs(where *Where, pager *Pager, entity Entity) []uint64 {
+	pager = searchPager(e, pager)
 	results, _ := searchIDs(true, e, where, pager, false, reflect.TypeOf(entity).Elem())
 	return results
 }
 
+func (e *Engine) SearchRandom(where *Where, limit int, entities interface{}, references ...string) {
+	searchRandom(e, where, limit, reflect.ValueOf(entities).Elem(), references)
+}
+
 func (e *Engine) SearchOne(where *Where, entity Entity, references ...string) (found bool) {
 	return searchOne(true, e, where, entity, references)
 }
@@ -361,20 +688,65 @@ func (e *Engine) CachedSearchOneWithReferences(entity Entity, indexName string,
 }
 
 func (e *Engine) CachedSearch(entities interface{}, indexName string, pager *Pager, arguments ...interface{}) (totalRows int) {
+	checkPageSize(e, pager)
 	total, _ := cachedSearch(e, entities, indexName, pager, arguments, nil)
 	return total
 }
 
 func (e *Engine) CachedSearchIDs(entity Entity, indexName string, pager *Pager, arguments ...interface{}) (totalRows int, ids []uint64) {
+	checkPageSize(e, pager)
 	return cachedSearch(e, entity, indexName, pager, arguments, nil)
 }
 
+func (e *Engine) CachedSearchIDsWithCheck(entity Entity, indexName string, pager *Pager, arguments ...interface{}) (ids []uint64, totalRows int, err error) {
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				asErr, is := r.(error)
+				if !is {
+					panic(r)
+				}
+				err = asErr
+			}
+		}()
+		checkPageSize(e, pager)
+		totalRows, ids = cachedSearch(e, entity, indexName, pager, arguments, nil)
+	}()
+	return ids, totalRows, err
+}
+
 func (e *Engine) CachedSearchWithReferences(entities interface{}, indexName string, pager *Pager,
 	arguments []interface{}, references []string) (totalRows int) {
+	checkPageSize(e, pager)
 	total, _ := cachedSearch(e, entities, indexName, pager, arguments, references)
 	return total
 }
 
+func (e *Engine) CachedChildren(parent Entity, children interface{}, pager *Pager) (totalRows int) {
+	checkPageSize(e, pager)
+	return cachedChildren(e, parent, children, pager)
+}
+
+func (e *Engine) CheckReferences(entity ...Entity) []*BrokenReference {
+	return checkReferences(e, ReferencesFixNone, entity...)
+}
+
+func (e *Engine) FixReferences(mode ReferencesFixMode, entity ...Entity) []*BrokenReference {
+	return checkReferences(e, mode, entity...)
+}
+
+func (e *Engine) CachedSearchComposite(entities interface{}, indexName string, pager *Pager, arguments map[string]interface{}) (totalRows int) {
+	checkPageSize(e, pager)
+	total, _ := cachedSearchComposite(e, entities, indexName, pager, arguments, nil)
+	return total
+}
+
+func (e *Engine) CachedSearchWithOptions(entities interface{}, indexName string, pager *Pager,
+	options *CachedSearchOptions, arguments ...interface{}) (totalRows int) {
+	checkPageSize(e, pager)
+	return cachedSearchWithOptions(e, entities, indexName, pager, arguments, options)
+}
+
 func (e *Engine) ClearByIDs(entity Entity, ids ...uint64) {
 	clearByIDs(e, entity, ids...)
 }
@@ -387,6 +759,42 @@ func (e *Engine) LoadByID(id uint64, entity Entity, references ...string) (found
 	return loadByID(e, id, entity, true, references...)
 }
 
+func (e *Engine) Exists(where *Where, entity Entity) (bool, error) {
+	return exists(e, where, entity)
+}
+
+func (e *Engine) ExistsByID(id uint64, entity Entity) (bool, error) {
+	return existsByID(e, id, entity)
+}
+
+func (e *Engine) LoadByUniqueKey(entity Entity, index string, values ...interface{}) (found bool) {
+	return loadByUniqueKey(e, entity, index, values)
+}
+
+func (e *Engine) CachedLoadByUniqueKey(entity Entity, index string, values ...interface{}) (found bool) {
+	return cachedLoadByUniqueKey(e, entity, index, values)
+}
+
+func (e *Engine) GetReference(entity Entity, field string) Entity {
+	return getReference(e, entity, field)
+}
+
+func (e *Engine) GetPolymorphicReference(reference *PolymorphicReference) Entity {
+	return getPolymorphicReference(e, reference)
+}
+
+func (e *Engine) LoadPolymorphicReferences(entities interface{}, field string) {
+	loadPolymorphicReferences(e, entities, field)
+}
+
+func (e *Engine) LoadAncestors(entity Entity, field string, ancestors interface{}) {
+	loadAncestors(e, entity, field, ancestors)
+}
+
+func (e *Engine) LoadDescendants(entity Entity, field string, descendants interface{}) {
+	loadDescendants(e, entity, field, descendants)
+}
+
 func (e *Engine) Load(entity Entity, references ...string) {
 	if e.Loaded(entity) {
 		if len(references) > 0 {
@@ -399,6 +807,8 @@ func (e *Engine) Load(entity Entity, references ...string) {
 	id := orm.GetID()
 	if id > 0 {
 		loadByID(e, id, entity, true, references...)
+	} else if orm.tableSchema.uuidKey != "" && orm.attributes.elem.FieldByName(orm.tableSchema.uuidKey).String() != "" {
+		loadByUUIDKey(e, entity, references)
 	}
 }
 
@@ -407,18 +817,144 @@ func (e *Engine) LoadByIDs(ids []uint64, entities interface{}, references ...str
 }
 
 func (e *Engine) GetAlters() (alters []Alter) {
+	if e.tenant != "" {
+		return getTenantAlters(e)
+	}
 	return getAlters(e)
 }
 
+func (e *Engine) CheckSchema() (ok bool, unsafe []Alter, safe []Alter, err error) {
+	return checkSchema(e, false)
+}
+
+func (e *Engine) CheckSchemaAndApplySafe() (ok bool, unsafe []Alter, safe []Alter, err error) {
+	return checkSchema(e, true)
+}
+
+func (e *Engine) GetClickHouseLogAlters() (alters []Alter) {
+	return getClickHouseLogAlters(e)
+}
+
+func (e *Engine) MigrateLogsToClickHouse(entity Entity, batchSize int) (migrated int) {
+	return migrateLogsToClickHouse(e, entity, batchSize)
+}
+
+func (e *Engine) TruncateTables(entity ...Entity) {
+	truncateEntities(e, entity)
+}
+
+func (e *Engine) TruncateAll() {
+	truncateAll(e)
+}
+
+func (e *Engine) Seed(seeds ...*Seed) {
+	seed(e, seeds...)
+}
+
+func (e *Engine) SnapshotTables(entity ...Entity) *Snapshot {
+	return snapshotTables(e, entity)
+}
+
+func (e *Engine) LoadField(entity Entity, field string) (found bool) {
+	return loadField(e, entity, field)
+}
+
+func (e *Engine) Clone(entity Entity) Entity {
+	return cloneEntity(e, entity)
+}
+
+func (e *Engine) LoadFixtures(fileSystem fs.FS, paths ...string) *Fixtures {
+	return loadFixtures(e, fileSystem, paths...)
+}
+
 func (e *Engine) flushTrackedEntities(lazy bool, transaction bool) {
-	if e.trackedEntitiesCounter == 0 {
+	entities := e.takeTrackedEntities()
+	if len(entities) == 0 {
 		return
 	}
+	e.flushTakenEntities(lazy, transaction, entities)
+}
+
+// takeTrackedEntities clears tracked entities, lock is not held in flush so entities can be tracked there
+func (e *Engine) takeTrackedEntities() []Entity {
+	e.trackMutex.Lock()
+	defer e.trackMutex.Unlock()
+	entities := e.trackedEntities.entities
+	e.trackedEntities.clear()
+	return entities
+}
+
+// restoreTrackedEntities tracks again not flushed entities, before entities tracked in the meantime
+func (e *Engine) restoreTrackedEntities(entities []Entity) {
+	e.trackMutex.Lock()
+	defer e.trackMutex.Unlock()
+	tracked := e.trackedEntities.entities
+	e.trackedEntities.clear()
+	for _, entity := range entities {
+		e.trackedEntities.add(entity, !e.disableTrackDeduplication)
+	}
+	for _, entity := range tracked {
+		e.trackedEntities.add(entity, !e.disableTrackDeduplication)
+	}
+}
+
+func (e *Engine) flushTakenEntities(lazy bool, transaction bool, entities []Entity) {
+	flushed := false
+	defer func() {
+		if !flushed {
+			e.restoreTrackedEntities(entities)
+		}
+	}()
+	e.flushEntities(lazy, transaction, entities...)
+	flushed = true
+}
+
+func (e *Engine) flushInChunks(chunkSize int, progress func(flushed int, total int)) {
+	entities := e.takeTrackedEntities()
+	total := len(entities)
+	if chunkSize <= 0 {
+		chunkSize = total
+	}
+	flushed := 0
+	defer func() {
+		if flushed < total {
+			e.restoreTrackedEntities(entities[flushed:])
+		}
+	}()
+	for flushed < total {
+		end := flushed + chunkSize
+		if end > total {
+			end = total
+		}
+		e.flushEntities(false, true, entities[flushed:end]...)
+		flushed = end
+		if progress != nil {
+			progress(flushed, total)
+		}
+	}
+}
+
+func (e *Engine) flushEntities(lazy bool, transaction bool, entities ...Entity) {
+	e.flushMutex.Lock()
+	defer e.flushMutex.Unlock()
+	span := e.startTracerSpan("orm.flush")
+	if span != nil {
+		span.SetAttribute("orm.entities", len(entities))
+		span.SetAttribute("orm.lazy", lazy)
+		span.SetAttribute("orm.transaction", transaction)
+		defer func() {
+			r := recover()
+			e.finishTracerSpan(span, r)
+			if r != nil {
+				panic(r)
+			}
+		}()
+	}
 	var dbPools map[string]*DB
 	if transaction {
 		dbPools = make(map[string]*DB)
-		for _, entity := range e.trackedEntities {
-			db := entity.getORM().tableSchema.GetMysql(e)
+		for _, entity := range entities {
+			db := entity.getORM().tableSchema.getEntityMysql(e, entity)
 			dbPools[db.code] = db
 		}
 		for _, db := range dbPools {
@@ -431,14 +967,24 @@ func (e *Engine) flushTrackedEntities(lazy bool, transaction bool) {
 		}
 	}()
 
-	flush(e, lazy, transaction, e.trackedEntities...)
+	var groups map[string][]Entity
+	if !lazy && e.flushWorkers > 1 {
+		groups = groupEntitiesByPool(e, entities)
+	}
+	if groups != nil {
+		e.flushParallel(transaction, groups)
+	} else {
+		flush(e, lazy, transaction, entities...)
+	}
 	if transaction {
+		phase := startFlushPhase(e, "commit")
+		defer phase.finish()
+		// cache is cleared after commit, it can't be aborted anymore
+		e.flushContext = nil
 		for _, db := range dbPools {
 			db.Commit()
 		}
 	}
-	e.trackedEntities = make([]Entity, 0)
-	e.trackedEntitiesCounter = 0
 }
 
 func (e *Engine) flushWithLock(transaction bool, lockerPool string, lockName string, ttl time.Duration, waitTimeout time.Duration) {
@@ -451,12 +997,32 @@ func (e *Engine) flushWithLock(transaction bool, lockerPool string, lockName str
 	e.flushTrackedEntities(false, transaction)
 }
 
+func (e *Engine) flushWithContext(ctx context.Context) {
+	e.flushContext = ctx
+	defer func() {
+		e.flushContext = nil
+	}()
+	e.flushTrackedEntities(false, true)
+}
+
+func (e *Engine) checkFlushContext() {
+	if e.flushContext != nil && e.flushContext.Err() != nil {
+		panic(errors.Trace(e.flushContext.Err()))
+	}
+}
+
 func (e *Engine) flushWithCheck(transaction bool) error {
+	return catchFlushError(e.ClearTrackedEntities, func() {
+		e.flushTrackedEntities(false, transaction)
+	})
+}
+
+func catchFlushError(clear func(), flush func()) error {
 	var err error
 	func() {
 		defer func() {
 			if r := recover(); r != nil {
-				e.ClearTrackedEntities()
+				clear()
 				asErr, is := r.(error)
 				if !is {
 					panic(r)
@@ -472,10 +1038,15 @@ func (e *Engine) flushWithCheck(transaction bool) error {
 					err = assErr2
 					return
 				}
+				assErr3, is := source.(*InvalidEnumValueError)
+				if is {
+					err = assErr3
+					return
+				}
 				panic(r)
 			}
 		}()
-		e.flushTrackedEntities(false, transaction)
+		flush()
 	}()
 	return err
 }
@@ -0,0 +1,81 @@
+package orm
+
+import (
+	"sync"
+
+	apexLog "github.com/apex/log"
+)
+
+type EnginePool struct {
+	registry *validatedRegistry
+	pool     sync.Pool
+}
+
+func (r *validatedRegistry) EnginePool() *EnginePool {
+	root := r
+	if r.root != nil {
+		root = r.root
+	}
+	root.enginePoolOnce.Do(func() {
+		root.enginePool = &EnginePool{registry: root}
+	})
+	return root.enginePool
+}
+
+func (p *EnginePool) Get() *Engine {
+	current := p.registry.current()
+	for {
+		engine, is := p.pool.Get().(*Engine)
+		if !is {
+			return current.createEngine()
+		}
+		if engine.registry == current {
+			return engine
+		}
+	}
+}
+
+func (p *EnginePool) Put(engine *Engine) {
+	if engine.tenant != "" {
+		return
+	}
+	engine.Reset()
+	p.pool.Put(engine)
+}
+
+func (e *Engine) Reset() {
+	for _, db := range e.dbs {
+		db.Rollback()
+	}
+	e.trackedEntities.clear()
+	e.disableTrackDeduplication = false
+	e.flushWorkers = 0
+	e.logMetaData = nil
+	e.queryLoggers = nil
+	e.sqlMiddlewares = nil
+	e.log = nil
+	e.afterCommitLocalCacheSets = nil
+	e.afterCommitRedisCacheDeletes = nil
+	e.afterCommitLocalCacheInvalidations = nil
+	e.afterCommitChanges = nil
+	e.dataDog = &dataDog{engine: e}
+	e.tracerContext = nil
+	e.tracerContextStack = nil
+	e.flushContext = nil
+	e.lazyDelay = 0
+	e.trackLimit = e.registry.registry.trackLimit
+	e.trackAutoFlush = false
+	e.disableGetterPanics = false
+	e.lazyDelayKey = ""
+	e.poolResolver = nil
+	e.shardPools = nil
+	e.tableSuffixResolver = nil
+	e.enableRegistryTracer()
+}
+
+func (e *Engine) enableRegistryTracer() {
+	if e.registry.tracer != nil {
+		e.AddQueryLogger(&tracerHandler{engine: e}, apexLog.DebugLevel, QueryLoggerSourceDB, QueryLoggerSourceRedis,
+			QueryLoggerSourceRabbitMQ, QueryLoggerSourceElastic, QueryLoggerSourceClickHouse, QueryLoggerSourceLocalCache)
+	}
+}
@@ -0,0 +1,269 @@
+package orm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/juju/errors"
+)
+
+const logActorKey = "actor"
+
+var logMetaKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+type LogEntry struct {
+	LogID    uint64
+	EntityID uint64
+	AddedAt  time.Time
+	Meta     map[string]interface{}
+	Before   map[string]interface{}
+	Changes  map[string]interface{}
+	After    map[string]interface{}
+}
+
+type Actor struct {
+	UserID uint64 `json:"user_id,omitempty"`
+	IP     string `json:"ip,omitempty"`
+	Source string `json:"source,omitempty"`
+}
+
+type Change struct {
+	Old interface{}
+	New interface{}
+}
+
+type LogFilter struct {
+	From time.Time
+	To   time.Time
+	Meta map[string]interface{}
+}
+
+func (l *LogEntry) fill(meta, before, changes sql.NullString) error {
+	return l.fillWith(jsoniter.ConfigFastest, meta, before, changes)
+}
+
+func (l *LogEntry) fillWith(api jsoniter.API, meta, before, changes sql.NullString) error {
+	value := &LogQueueValue{}
+	if err := value.unmarshalWith(api, meta, before, changes); err != nil {
+		return err
+	}
+	l.Meta = value.Meta
+	l.Before = value.Before
+	l.Changes = value.Changes
+	if l.Changes != nil {
+		l.After = make(map[string]interface{}, len(l.Before)+len(l.Changes))
+		for k, v := range l.Before {
+			l.After[k] = v
+		}
+		for k, v := range l.Changes {
+			l.After[k] = v
+		}
+	}
+	return nil
+}
+
+func (l *LogEntry) GetActor() *Actor {
+	switch value := l.Meta[logActorKey].(type) {
+	case Actor:
+		return &value
+	case map[string]interface{}:
+		actor := &Actor{}
+		userID, _ := value["user_id"].(float64)
+		actor.UserID = uint64(userID)
+		actor.IP, _ = value["ip"].(string)
+		actor.Source, _ = value["source"].(string)
+		return actor
+	}
+	return nil
+}
+
+func getEntityLogs(engine *Engine, entity Entity, id uint64, pager *Pager, filter *LogFilter) []*LogEntry {
+	schema := initIfNeeded(engine, entity).tableSchema
+	if !schema.hasLog {
+		panic(errors.Errorf("entity %s is not logged", schema.t.String()))
+	}
+	if schema.logExternal {
+		panic(errors.NotSupportedf("reading logs of entity %s written by log writer '%s'", schema.t.String(), schema.logWriter))
+	}
+	if pager == nil {
+		pager = NewPager(1, 100)
+	}
+	clickHouse := schema.logClickHouse != ""
+	conditions := []string{"`entity_id` = ?"}
+	arguments := []interface{}{id}
+	if filter != nil {
+		if !filter.From.IsZero() {
+			conditions = append(conditions, "`added_at` >= ?")
+			arguments = append(arguments, formatLogTime(filter.From, clickHouse))
+		}
+		if !filter.To.IsZero() {
+			conditions = append(conditions, "`added_at` <= ?")
+			arguments = append(arguments, formatLogTime(filter.To, clickHouse))
+		}
+		for key, value := range filter.Meta {
+			if !logMetaKeyPattern.MatchString(key) {
+				panic(errors.NotValidf("log meta key '%s'", key))
+			}
+			asJSON, _ := jsoniter.ConfigFastest.MarshalToString(value)
+			if clickHouse {
+				conditions = append(conditions, "JSONExtractRaw(ifNull(`meta`, ''), ?) = ?")
+				arguments = append(arguments, key, asJSON)
+			} else {
+				conditions = append(conditions, "JSON_EXTRACT(`meta`, ?) = CAST(? AS JSON)")
+				arguments = append(arguments, "$."+key, asJSON)
+			}
+		}
+	}
+	limit := fmt.Sprintf("LIMIT %d,%d", (pager.GetCurrentPage()-1)*pager.GetPageSize(), pager.GetPageSize())
+	where := strings.Join(conditions, " AND ")
+	entries := make([]*LogEntry, 0)
+	if clickHouse {
+		/* #nosec */
+		query := fmt.Sprintf("SELECT `entity_id`, `added_at`, `meta`, `before`, `changes` FROM `%s` WHERE %s ORDER BY `added_at` DESC %s",
+			schema.logTableName, where, limit)
+		rows, def := engine.GetClickHouse(schema.logClickHouse).QueryxCounted(query, arguments...)
+		defer def()
+		for rows.Next() {
+			entry := &LogEntry{}
+			var meta, before, changes sql.NullString
+			if err := rows.Scan(&entry.EntityID, &entry.AddedAt, &meta, &before, &changes); err != nil {
+				panic(errors.Trace(err))
+			}
+			if err := entry.fill(meta, before, changes); err != nil {
+				panic(errors.Annotatef(err, "log of entity %d added at %s", entry.EntityID, entry.AddedAt))
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+	/* #nosec */
+	query := fmt.Sprintf("SELECT `id`, `entity_id`, `added_at`, `meta`, `before`, `changes` FROM `%s` WHERE %s ORDER BY `id` DESC %s",
+		schema.logTableName, where, limit)
+	rows, def := engine.GetMysql(schema.logPoolName).Query(query, arguments...)
+	defer def()
+	for rows.Next() {
+		entry := &LogEntry{}
+		var addedAt string
+		var meta, before, changes sql.NullString
+		// Scan panics on error
+		rows.Scan(&entry.LogID, &entry.EntityID, &addedAt, &meta, &before, &changes)
+		var err error
+		entry.AddedAt, err = time.ParseInLocation("2006-01-02 15:04:05", addedAt, time.Local)
+		if err != nil {
+			panic(errors.Annotatef(err, "log %d added_at", entry.LogID))
+		}
+		if err := entry.fill(meta, before, changes); err != nil {
+			panic(errors.Annotatef(err, "log %d", entry.LogID))
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func formatLogTime(value time.Time, clickHouse bool) interface{} {
+	if clickHouse {
+		return value
+	}
+	return value.Local().Format("2006-01-02 15:04:05")
+}
+
+func revertEntity(engine *Engine, entity Entity, logEntryID uint64) {
+	orm := initIfNeeded(engine, entity)
+	schema := orm.tableSchema
+	if !schema.hasLog {
+		panic(errors.Errorf("entity %s is not logged", schema.t.String()))
+	}
+	if schema.logClickHouse != "" {
+		panic(errors.NotSupportedf("revert of entity %s logged in ClickHouse", schema.t.String()))
+	}
+	if schema.logExternal {
+		panic(errors.NotSupportedf("revert of entity %s logged by log writer '%s'", schema.t.String(), schema.logWriter))
+	}
+	entry := &LogEntry{LogID: logEntryID}
+	var addedAt string
+	var meta, before, changes sql.NullString
+	/* #nosec */
+	query := fmt.Sprintf("SELECT `entity_id`, `added_at`, `meta`, `before`, `changes` FROM `%s` WHERE `id` = ?", schema.logTableName)
+	found := engine.GetMysql(schema.logPoolName).QueryRow(NewWhere(query, logEntryID), &entry.EntityID, &addedAt, &meta, &before, &changes)
+	if !found {
+		panic(errors.NotFoundf("log entry %d for %s", logEntryID, schema.t.String()))
+	}
+	if err := entry.fillWith(logNumbersJSON, meta, before, changes); err != nil {
+		panic(errors.Annotatef(err, "log %d", logEntryID))
+	}
+	state := entry.After
+	if state == nil {
+		state = entry.Before
+	}
+	if !loadByID(engine, entry.EntityID, entity, false) {
+		orm.dBData = make(map[string]interface{}, len(schema.columnNames))
+		orm.attributes.loaded = false
+	}
+	data := make([]string, len(schema.columnNames)-1)
+	for i, column := range schema.columnNames[1:] {
+		value, has := state[column]
+		if !has {
+			value = orm.dBData[column]
+		}
+		data[i] = logValueToString(value)
+	}
+	target := reflect.New(schema.t).Interface().(Entity)
+	fillFromDBRow(entry.EntityID, engine, data, target)
+	source := target.getORM().attributes.elem
+	for i := 2; i < source.NumField(); i++ {
+		orm.attributes.elem.Field(i).Set(source.Field(i))
+	}
+	orm.attributes.idElem.SetUint(entry.EntityID)
+	engine.SetEntityLogMeta("reverted_log_id", logEntryID, entity)
+	engine.Track(entity)
+}
+
+func logValueToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func DiffLogEntries(older, newer *LogEntry) map[string]Change {
+	var before, after map[string]interface{}
+	if older != nil {
+		before = older.After
+	}
+	if newer != nil {
+		after = newer.After
+	}
+	diff := make(map[string]Change)
+	for key, value := range before {
+		newValue := after[key]
+		if !reflect.DeepEqual(value, newValue) {
+			diff[key] = Change{Old: value, New: newValue}
+		}
+	}
+	for key, value := range after {
+		_, has := before[key]
+		if !has && value != nil {
+			diff[key] = Change{New: value}
+		}
+	}
+	return diff
+}
@@ -0,0 +1,45 @@
+package orm
+
+func exists(engine *Engine, where *Where, entity Entity) (bool, error) {
+	schema := initIfNeeded(engine, entity).tableSchema
+	return existsRow(engine, schema, where, skipFakeDeleted(true, schema, where))
+}
+
+func existsByID(engine *Engine, id uint64, entity Entity) (bool, error) {
+	schema := initIfNeeded(engine, entity).tableSchema
+	cacheKey := schema.getCacheKey(id)
+	localCache, hasLocalCache := schema.GetLocalCache(engine)
+	if hasLocalCache {
+		value, has := localCache.Get(cacheKey)
+		if has {
+			return value != "nil", nil
+		}
+	}
+	redisCache, hasRedis := schema.GetRedisCache(engine)
+	if hasRedis {
+		value, has := redisCache.Get(cacheKey)
+		if has {
+			return value != "nil", nil
+		}
+	}
+	where := NewWhere("`ID` = ?", id)
+	if schema.shard == nil {
+		return existsRow(engine, schema, where, false)
+	}
+	pool, valid := schema.shard.poolForID(id)
+	if !valid {
+		return false, nil
+	}
+	var found bool
+	var err error
+	engine.withShardPool(schema, pool, func() {
+		found, err = existsRow(engine, schema, where, false)
+	})
+	return found, err
+}
+
+func existsRow(engine *Engine, schema *tableSchema, where *Where, skipFakeDelete bool) (bool, error) {
+	query := buildSearchQuery("1", schema.getTableName(engine), where.String(), skipFakeDelete, nil)
+	var one int
+	return schema.GetMysql(engine).queryRow(&Where{query: query, parameters: where.GetParameters()}, &one)
+}
@@ -0,0 +1,49 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type existsEntity struct {
+	ORM        `orm:"localCache"`
+	ID         uint
+	Name       string
+	FakeDelete bool
+}
+
+func TestExists(t *testing.T) {
+	var entity *existsEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	engine.TrackAndFlush(&existsEntity{Name: "a"}, &existsEntity{Name: "b"})
+	found, err := engine.Exists(NewWhere("`Name` = ?", "a"), entity)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	found, err = engine.Exists(NewWhere("`Name` IN ?", []string{"c", "d"}), entity)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	_, err = engine.Exists(NewWhere("`Missing` = ?", "a"), entity)
+	assert.Error(t, err)
+
+	found, err = engine.ExistsByID(1, entity)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	found, err = engine.ExistsByID(3, entity)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	entity = &existsEntity{}
+	engine.LoadByID(2, entity)
+	engine.MarkToDelete(entity)
+	engine.Flush()
+	found, _ = engine.Exists(NewWhere("`Name` = ?", "b"), entity)
+	assert.False(t, found)
+	found, _ = engine.Exists(NewWhere("`Name` = ?", "b").WithFakeDeleted(), entity)
+	assert.True(t, found)
+
+	engine.GetLocalCache().Set(entity.getORM().tableSchema.getCacheKey(10), "nil")
+	found, _ = engine.ExistsByID(10, entity)
+	assert.False(t, found)
+}
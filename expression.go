@@ -0,0 +1,96 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+type Expr string
+
+func ExprNow() Expr {
+	return "NOW()"
+}
+
+func setFieldExpr(engine *Engine, entity Entity, field string, expression Expr) {
+	orm := initIfNeeded(engine, entity)
+	schema := orm.tableSchema
+	_, isField := schema.t.FieldByName(field)
+	if field == "ID" || !isField || !hasColumn(schema, field) {
+		panic(errors.NotValidf("field '%s' in %s", field, schema.t.String()))
+	}
+	if orm.attributes.expressions == nil {
+		orm.attributes.expressions = make(map[string]Expr)
+	}
+	orm.attributes.expressions[field] = expression
+	engine.Track(entity)
+}
+
+func hasColumn(schema *tableSchema, column string) bool {
+	for _, name := range schema.columnNames {
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+func applyExpressions(bind map[string]interface{}, expressions map[string]Expr) (keys []string, sql []string) {
+	keys = make([]string, 0, len(expressions))
+	sql = make([]string, 0, len(expressions))
+	for field, expression := range expressions {
+		delete(bind, field)
+		keys = append(keys, field)
+		sql = append(sql, string(expression))
+	}
+	return keys, sql
+}
+
+func insertWithExpressions(engine *Engine, entity Entity, bind map[string]interface{}) uint64 {
+	orm := entity.getORM()
+	schema := orm.tableSchema
+	expressionKeys, expressionValues := applyExpressions(bind, orm.attributes.expressions)
+	keys := make([]string, 0, len(bind))
+	columns := make([]string, 0, len(bind)+len(expressionKeys))
+	values := make([]string, 0, len(bind)+len(expressionKeys))
+	arguments := make([]interface{}, 0, len(bind))
+	for key, value := range bind {
+		keys = append(keys, key)
+		columns = append(columns, fmt.Sprintf("`%s`", key))
+		values = append(values, schema.placeholder(key))
+		arguments = append(arguments, value)
+	}
+	for i, key := range expressionKeys {
+		columns = append(columns, fmt.Sprintf("`%s`", key))
+		values = append(values, expressionValues[i])
+	}
+	/* #nosec */
+	sql := fmt.Sprintf("INSERT INTO %s(%s) VALUES (%s)", schema.getTableName(engine), strings.Join(columns, ","), strings.Join(values, ","))
+	db := schema.GetMysql(engine)
+	if planQuery(engine, db, sql, schema.redactedPositions(keys), arguments) {
+		return 0
+	}
+	result := db.exec(sql, schema.redactedPositions(keys), arguments)
+	id := entity.GetID()
+	if id == 0 {
+		id = result.LastInsertId()
+		orm.attributes.idElem.SetUint(id)
+	}
+	reconcileExpressions(engine, entity, id, expressionKeys, bind)
+	return id
+}
+
+func reconcileExpressions(engine *Engine, entity Entity, id uint64, fields []string, bind map[string]interface{}) {
+	orm := entity.getORM()
+	fresh := reflect.New(orm.tableSchema.t).Interface().(Entity)
+	if searchRow(false, engine, NewWhere("`ID` = ?", id), fresh, nil) {
+		freshORM := fresh.getORM()
+		for _, field := range fields {
+			orm.attributes.elem.FieldByName(field).Set(freshORM.attributes.elem.FieldByName(field))
+			bind[field] = freshORM.dBData[field]
+		}
+	}
+	orm.attributes.expressions = nil
+}
@@ -0,0 +1,207 @@
+package orm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"gopkg.in/yaml.v2"
+)
+
+type Fixtures struct {
+	engine   *Engine
+	entities map[string]Entity
+	groups   [][]Entity
+}
+
+type fixtureRow struct {
+	label  string
+	fields map[string]interface{}
+	entity Entity
+}
+
+func (f *Fixtures) Get(label string) Entity {
+	return f.entities[label]
+}
+
+func (f *Fixtures) Cleanup() {
+	for i := len(f.groups) - 1; i >= 0; i-- {
+		f.engine.ForceMarkToDelete(f.groups[i]...)
+		f.engine.Flush()
+	}
+}
+
+func loadFixtures(engine *Engine, fileSystem fs.FS, paths ...string) *Fixtures {
+	rows := make(map[reflect.Type][]*fixtureRow)
+	labels := make(map[string]*fixtureRow)
+	for _, filePath := range paths {
+		content, err := fs.ReadFile(fileSystem, filePath)
+		if err != nil {
+			panic(errors.Trace(err))
+		}
+		data := make(map[string]map[string]map[string]interface{})
+		if path.Ext(filePath) == ".json" {
+			err = json.Unmarshal(content, &data)
+		} else {
+			err = yaml.Unmarshal(content, &data)
+		}
+		if err != nil {
+			panic(errors.Annotatef(err, "invalid fixture file '%s'", filePath))
+		}
+		for entityName, entities := range data {
+			t := getFixtureEntityType(engine.registry, entityName)
+			for label, fields := range entities {
+				_, has := labels[label]
+				if has {
+					panic(errors.AlreadyExistsf("fixture label '%s'", label))
+				}
+				row := &fixtureRow{label: label, fields: fields, entity: reflect.New(t).Interface().(Entity)}
+				initIfNeeded(engine, row.entity)
+				labels[label] = row
+				rows[t] = append(rows[t], row)
+			}
+		}
+	}
+	fixtures := &Fixtures{engine: engine, entities: make(map[string]Entity, len(labels))}
+	for _, typeRows := range rows {
+		sort.Slice(typeRows, func(i, j int) bool {
+			return typeRows[i].label < typeRows[j].label
+		})
+		for _, row := range typeRows {
+			for field, value := range row.fields {
+				asString, is := value.(string)
+				if is && strings.HasPrefix(asString, "@") {
+					ref, has := labels[asString[1:]]
+					if !has {
+						panic(errors.NotFoundf("fixture label '%s'", asString[1:]))
+					}
+					value = ref.entity
+				}
+				err := row.entity.SetField(field, convertFixtureValue(row.entity, field, value))
+				if err != nil {
+					panic(errors.Annotatef(err, "invalid fixture '%s'", row.label))
+				}
+			}
+			fixtures.entities[row.label] = row.entity
+		}
+	}
+	for _, t := range sortFixtureTypes(engine.registry, rows) {
+		group := make([]Entity, len(rows[t]))
+		for i, row := range rows[t] {
+			group[i] = row.entity
+		}
+		engine.Track(group...)
+		engine.Flush()
+		fixtures.groups = append(fixtures.groups, group)
+	}
+	return fixtures
+}
+
+func getFixtureEntityType(registry *validatedRegistry, name string) reflect.Type {
+	t, has := registry.entities[name]
+	if has {
+		return t
+	}
+	var found reflect.Type
+	for entityName, entityType := range registry.entities {
+		if strings.HasSuffix(entityName, "."+name) {
+			if found != nil {
+				panic(errors.NotValidf("ambiguous fixture entity '%s'", name))
+			}
+			found = entityType
+		}
+	}
+	if found == nil {
+		panic(EntityNotRegisteredError{Name: name})
+	}
+	return found
+}
+
+func sortFixtureTypes(registry *validatedRegistry, rows map[reflect.Type][]*fixtureRow) []reflect.Type {
+	sorted := make([]reflect.Type, 0, len(rows))
+	visited := make(map[reflect.Type]bool)
+	var visit func(t reflect.Type)
+	visit = func(t reflect.Type) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		schema := getTableSchema(registry, t)
+		for _, refName := range schema.refOne {
+			refType := registry.entities[schema.tags[refName]["ref"]]
+			_, has := rows[refType]
+			if has {
+				visit(refType)
+			}
+		}
+		sorted = append(sorted, t)
+	}
+	types := make([]reflect.Type, 0, len(rows))
+	for t := range rows {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].String() < types[j].String()
+	})
+	for _, t := range types {
+		visit(t)
+	}
+	return sorted
+}
+
+func convertFixtureValue(entity Entity, field string, value interface{}) interface{} {
+	f := entity.getORM().attributes.elem.FieldByName(field)
+	if !f.IsValid() {
+		return value
+	}
+	switch f.Type().String() {
+	case "time.Time", "*time.Time":
+		asString, is := value.(string)
+		if !is {
+			return value
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+			parsed, err := time.Parse(layout, asString)
+			if err == nil {
+				if f.Kind() == reflect.Ptr {
+					return &parsed
+				}
+				return parsed
+			}
+		}
+	case "[]string":
+		asSlice, is := value.([]interface{})
+		if is {
+			values := make([]string, len(asSlice))
+			for i, v := range asSlice {
+				values[i] = fmt.Sprintf("%v", v)
+			}
+			return values
+		}
+	case "interface {}":
+		return normalizeFixtureValue(value)
+	}
+	return value
+}
+
+func normalizeFixtureValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			result[fmt.Sprintf("%v", k)] = normalizeFixtureValue(val)
+		}
+		return result
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeFixtureValue(val)
+		}
+	}
+	return value
+}
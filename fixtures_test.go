@@ -0,0 +1,59 @@
+package orm
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixtureEntity struct {
+	ORM
+	ID        uint
+	Name      string
+	Age       int
+	Reference *fixtureEntityReference
+}
+
+type fixtureEntityReference struct {
+	ORM
+	ID   uint
+	Name string
+}
+
+func TestLoadFixtures(t *testing.T) {
+	var entity *fixtureEntity
+	var reference *fixtureEntityReference
+	engine := PrepareTables(t, &Registry{}, entity, reference)
+
+	fileSystem := fstest.MapFS{
+		"users.yaml": {Data: []byte(`
+fixtureEntity:
+  john:
+    Name: John
+    Age: 30
+    Reference: "@acme"
+  tom:
+    Name: Tom
+`)},
+		"references.json": {Data: []byte(`{"orm.fixtureEntityReference": {"acme": {"Name": "Acme"}}}`)},
+	}
+	fixtures := engine.LoadFixtures(fileSystem, "users.yaml", "references.json")
+	john := fixtures.Get("john").(*fixtureEntity)
+	assert.True(t, john.ID > 0)
+	assert.True(t, fixtures.Get("acme").GetID() > 0)
+
+	entity = &fixtureEntity{}
+	assert.True(t, engine.LoadByID(uint64(john.ID), entity, "Reference"))
+	assert.Equal(t, "John", entity.Name)
+	assert.Equal(t, 30, entity.Age)
+	assert.Equal(t, "Acme", entity.Reference.Name)
+
+	fixtures.Cleanup()
+	assert.False(t, engine.LoadByID(uint64(john.ID), entity))
+	assert.False(t, engine.LoadByID(fixtures.Get("acme").GetID(), &fixtureEntityReference{}))
+
+	assert.Panics(t, func() {
+		engine.LoadFixtures(fstest.MapFS{"a.yaml": {Data: []byte("fixtureEntity:\n  a:\n    Reference: \"@missing\"")}}, "a.yaml")
+	})
+}
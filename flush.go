@@ -1,14 +1,18 @@
 package orm
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/juju/errors"
+	"github.com/shopspring/decimal"
 
 	jsoniter "github.com/json-iterator/go"
 
@@ -33,13 +37,30 @@ func (err *ForeignKeyError) Error() string {
 	return err.Message
 }
 
+type InvalidEnumValueError struct {
+	Entity  string
+	Field   string
+	Value   string
+	Allowed []string
+}
+
+func (err *InvalidEnumValueError) Error() string {
+	return fmt.Sprintf("invalid value '%s' for %s.%s, allowed values: %s", err.Value, err.Entity, err.Field,
+		strings.Join(err.Allowed, ","))
+}
+
 func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
+	if groups := groupEntitiesByShard(engine, entities); groups != nil {
+		flushShards(engine, lazy, transaction, groups)
+		return
+	}
 	insertKeys := make(map[reflect.Type][]string)
 	insertValues := make(map[reflect.Type]string)
 	insertArguments := make(map[reflect.Type][]interface{})
 	insertBinds := make(map[reflect.Type][]map[string]interface{})
 	insertReflectValues := make(map[reflect.Type][]Entity)
 	deleteBinds := make(map[reflect.Type]map[uint64]map[string]interface{})
+	deleteTypes := make([]reflect.Type, 0)
 	totalInsert := make(map[reflect.Type]int)
 	localCacheSets := make(map[string]map[string][]interface{})
 	localCacheDeletes := make(map[string]map[string]bool)
@@ -50,6 +71,10 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 
 	var referencesToFlash map[Entity]Entity
 
+	phase := startFlushPhase(engine, "bind")
+	defer func() {
+		phase.finish()
+	}()
 	for _, entity := range entities {
 		schema := entity.getORM().tableSchema
 		for _, refName := range schema.refOne {
@@ -57,7 +82,12 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 			if !refValue.IsNil() {
 				refEntity := refValue.Interface().(Entity)
 				initIfNeeded(engine, refEntity)
-				if refEntity.GetID() == 0 {
+				// uuidBinary reference doesn't need ID of referenced entity
+				_, isUUID := schema.uuidReferences[refName]
+				if !isUUID && hasPendingID(refEntity) {
+					if engine.dryRunQueries != nil {
+						panic(errors.NotSupportedf("dry run with not flushed reference %s in %s", refName, schema.t.String()))
+					}
 					if referencesToFlash == nil {
 						referencesToFlash = make(map[Entity]Entity)
 					}
@@ -72,9 +102,15 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 		orm := entity.getORM()
 		dbData := orm.dBData
 		isDirty, bind := getDirtyBind(entity)
+		if engine.dryRunQueries == nil {
+			orm.attributes.forcedDirty = nil
+		}
 		if !isDirty {
 			continue
 		}
+		if lazy && len(orm.attributes.expressions) > 0 {
+			panic(errors.NotSupportedf("field expressions in lazy flush"))
+		}
 		bindLength := len(bind)
 
 		t := orm.tableSchema.t
@@ -82,23 +118,29 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 		if orm.attributes.delete {
 			if deleteBinds[t] == nil {
 				deleteBinds[t] = make(map[uint64]map[string]interface{})
+				deleteTypes = append(deleteTypes, t)
 			}
 			deleteBinds[t][currentID] = dbData
 		} else if len(dbData) == 0 {
 			onUpdate := entity.getORM().attributes.onDuplicateKeyUpdate
 			if onUpdate != nil {
+				if len(orm.attributes.expressions) > 0 {
+					panic(errors.NotSupportedf("field expressions with on duplicate key update"))
+				}
 				values := make([]string, bindLength)
 				columns := make([]string, bindLength)
+				keys := make([]string, bindLength)
 				bindRow := make([]interface{}, bindLength)
 				i := 0
 				for key, val := range bind {
+					keys[i] = key
 					columns[i] = fmt.Sprintf("`%s`", key)
-					values[i] = "?"
+					values[i] = schema.placeholder(key)
 					bindRow[i] = val
 					i++
 				}
 				/* #nosec */
-				sql := fmt.Sprintf("INSERT INTO %s(%s) VALUES (%s)", schema.tableName, strings.Join(columns, ","), strings.Join(values, ","))
+				sql := fmt.Sprintf("INSERT INTO %s(%s) VALUES (%s)", schema.getTableName(engine), strings.Join(columns, ","), strings.Join(values, ","))
 				sql += " ON DUPLICATE KEY UPDATE "
 				subSQL := onUpdate.String()
 				if subSQL == "" {
@@ -107,10 +149,11 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 				sql += subSQL
 				bindRow = append(bindRow, onUpdate.GetParameters()...)
 				db := schema.GetMysql(engine)
+				redacted := schema.redactedPositions(keys)
 				if lazy {
-					fillLazyQuery(lazyMap, db.GetPoolCode(), sql, bindRow)
-				} else {
-					result := db.Exec(sql, bindRow...)
+					fillLazyQuery(lazyMap, db.GetPoolCode(), sql, bindRow, redacted)
+				} else if !planQuery(engine, db, sql, redacted, bindRow) {
+					result := db.exec(sql, redacted, bindRow)
 					affected := result.RowsAffected()
 					if affected > 0 {
 						lastID := result.LastInsertId()
@@ -134,7 +177,7 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 									allNotNil = false
 									break
 								}
-								fields = append(fields, fmt.Sprintf("`%s` = ?", column))
+								fields = append(fields, fmt.Sprintf("`%s` = %s", column, schema.placeholder(column)))
 								binds = append(binds, bind[column])
 							}
 							if allNotNil {
@@ -147,10 +190,29 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 				}
 				continue
 			}
+			if engine.dryRunQueries == nil {
+				assignGeneratedID(engine, entity)
+			}
+			currentID = entity.GetID()
 			if currentID > 0 {
 				bind["ID"] = currentID
 				bindLength++
 			}
+			if len(orm.attributes.expressions) > 0 {
+				insertedID := insertWithExpressions(engine, entity, bind)
+				if engine.dryRunQueries != nil {
+					continue
+				}
+				injectBind(entity, bind)
+				logQueues = updateCacheForInserted(entity, lazy, insertedID, bind, localCacheSets, localCacheDeletes,
+					redisKeysToDelete, dirtyQueues, logQueues)
+				localCache, hasLocalCache := schema.GetLocalCache(engine)
+				if hasLocalCache {
+					addLocalCacheSet(localCacheSets, schema.GetMysql(engine).GetPoolCode(), localCache.code,
+						schema.getCacheKey(insertedID), buildLocalCacheValue(entity))
+				}
+				continue
+			}
 
 			values := make([]interface{}, bindLength)
 			valuesKeys := make([]string, bindLength)
@@ -161,12 +223,13 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 					fields[i] = key
 					i++
 				}
+				sort.Strings(fields)
 				insertKeys[t] = fields
 			}
 			for index, key := range insertKeys[t] {
 				value := bind[key]
 				values[index] = value
-				valuesKeys[index] = "?"
+				valuesKeys[index] = schema.placeholder(key)
 			}
 			_, has := insertArguments[t]
 			if !has {
@@ -180,25 +243,40 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 			insertBinds[t] = append(insertBinds[t], bind)
 			totalInsert[t]++
 		} else {
-			values := make([]interface{}, bindLength+1)
 			if !engine.Loaded(entity) {
 				panic(errors.Errorf("entity is not loaded and can't be updated: %v [%d]", entity.getORM().attributes.elem.Type().String(), currentID))
 			}
-			fields := make([]string, bindLength)
+			expressionKeys, expressionValues := applyExpressions(bind, orm.attributes.expressions)
+			bindLength = len(bind)
+			values := make([]interface{}, bindLength+1)
+			fields := make([]string, bindLength, bindLength+len(expressionKeys))
+			keys := make([]string, bindLength)
 			i := 0
 			for key, value := range bind {
-				fields[i] = fmt.Sprintf("`%s` = ?", key)
+				keys[i] = key
+				fields[i] = fmt.Sprintf("`%s` = %s", key, schema.placeholder(key))
 				values[i] = value
 				i++
 			}
+			for k, key := range expressionKeys {
+				fields = append(fields, fmt.Sprintf("`%s` = %s", key, expressionValues[k]))
+			}
 			/* #nosec */
-			sql := fmt.Sprintf("UPDATE %s SET %s WHERE `ID` = ?", schema.GetTableName(), strings.Join(fields, ","))
+			sql := fmt.Sprintf("UPDATE %s SET %s WHERE `ID` = ?", schema.getTableName(engine), strings.Join(fields, ","))
 			db := schema.GetMysql(engine)
 			values[i] = currentID
-			if lazy {
-				fillLazyQuery(lazyMap, db.GetPoolCode(), sql, values)
+			redacted := schema.redactedPositions(keys)
+			if lazy && engine.lazyDelayKey != "" {
+				lazyMap["u"] = newDelayedLazyUpdate(schema, engine, db.GetPoolCode(), currentID, bind, expressionKeys, expressionValues)
+			} else if lazy {
+				fillLazyQuery(lazyMap, db.GetPoolCode(), sql, values, redacted)
+			} else if planQuery(engine, db, sql, redacted, values) {
+				continue
 			} else {
-				_ = db.Exec(sql, values...)
+				_ = db.exec(sql, redacted, values)
+			}
+			if len(expressionKeys) > 0 {
+				reconcileExpressions(engine, entity, currentID, expressionKeys, bind)
 			}
 			logQueues = updateCacheAfterUpdate(dbData, engine, entity, bind, schema, localCacheSets, localCacheDeletes, db, currentID,
 				redisKeysToDelete, dirtyQueues, logQueues)
@@ -225,6 +303,7 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 		}
 		flush(engine, transaction, transaction, rest...)
 	}
+	phase = phase.next("insert")
 	for typeOf, values := range insertKeys {
 		schema := getTableSchema(engine.registry, typeOf)
 		finalValues := make([]string, len(values))
@@ -232,16 +311,19 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 			finalValues[key] = fmt.Sprintf("`%s`", val)
 		}
 		/* #nosec */
-		sql := fmt.Sprintf("INSERT INTO %s(%s) VALUES %s", schema.tableName, strings.Join(finalValues, ","), insertValues[typeOf])
+		sql := fmt.Sprintf("INSERT INTO %s(%s) VALUES %s", schema.getTableName(engine), strings.Join(finalValues, ","), insertValues[typeOf])
 		for i := 1; i < totalInsert[typeOf]; i++ {
 			sql += "," + insertValues[typeOf]
 		}
 		id := uint64(0)
 		db := schema.GetMysql(engine)
+		redacted := expandRedactedPositions(schema.redactedPositions(values), len(values), len(insertArguments[typeOf]))
 		if lazy {
-			fillLazyQuery(lazyMap, db.GetPoolCode(), sql, insertArguments[typeOf])
+			fillLazyQuery(lazyMap, db.GetPoolCode(), sql, insertArguments[typeOf], redacted)
+		} else if planQuery(engine, db, sql, redacted, insertArguments[typeOf]) {
+			continue
 		} else {
-			res := db.Exec(sql, insertArguments[typeOf]...)
+			res := db.exec(sql, redacted, insertArguments[typeOf])
 			id = res.LastInsertId()
 		}
 		for key, entity := range insertReflectValues[typeOf] {
@@ -262,7 +344,9 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 			}
 		}
 	}
-	for typeOf, deleteBinds := range deleteBinds {
+	phase = phase.next("delete")
+	for _, typeOf := range deleteTypes {
+		deleteBinds := deleteBinds[typeOf]
 		schema := getTableSchema(engine.registry, typeOf)
 		ids := make([]interface{}, len(deleteBinds))
 		i := 0
@@ -271,10 +355,14 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 			i++
 		}
 		/* #nosec */
-		sql := fmt.Sprintf("DELETE FROM `%s` WHERE %s", schema.tableName, NewWhere("`ID` IN ?", ids))
+		sql := fmt.Sprintf("DELETE FROM `%s` WHERE %s", schema.getTableName(engine), NewWhere("`ID` IN ?", ids))
 		db := schema.GetMysql(engine)
+		if planQuery(engine, db, sql, nil, ids) {
+			continue
+		}
+		addReferencedQueriesKeys(engine, schema, ids, localCacheDeletes, redisKeysToDelete)
 		if lazy {
-			fillLazyQuery(lazyMap, db.GetPoolCode(), sql, ids)
+			fillLazyQuery(lazyMap, db.GetPoolCode(), sql, ids, nil)
 		} else {
 			usage := schema.GetUsage(engine.registry)
 			if len(usage) > 0 {
@@ -297,7 +385,7 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 								toDeleteAll := make([]Entity, total)
 								for i := 0; i < total; i++ {
 									toDeleteValue := subElem.Index(i).Interface().(Entity)
-									engine.MarkToDelete(toDeleteValue)
+									markToDelete(engine, toDeleteValue)
 									toDeleteAll[i] = toDeleteValue
 								}
 								flush(engine, transaction, lazy, toDeleteAll...)
@@ -312,27 +400,44 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 		localCache, hasLocalCache := schema.GetLocalCache(engine)
 		redisCache, hasRedis := schema.GetRedisCache(engine)
 		if hasLocalCache {
-			for id, bind := range deleteBinds {
+			for id := range deleteBinds {
 				addLocalCacheSet(localCacheSets, db.GetPoolCode(), localCache.code, schema.getCacheKey(id), "nil")
-				keys := getCacheQueriesKeys(schema, bind, bind, true)
-				addCacheDeletes(localCacheDeletes, localCache.code, keys...)
 			}
 		}
 		if hasRedis {
-			for id, bind := range deleteBinds {
+			for id := range deleteBinds {
 				addCacheDeletes(redisKeysToDelete, redisCache.code, schema.getCacheKey(id))
-				keys := getCacheQueriesKeys(schema, bind, bind, true)
-				addCacheDeletes(redisKeysToDelete, redisCache.code, keys...)
 			}
 		}
 		for id, bind := range deleteBinds {
+			addCacheQueriesDeletes(engine, schema, bind, bind, true, localCacheDeletes, redisKeysToDelete)
 			addDirtyQueues(dirtyQueues, bind, schema, id, "d")
+			addEntityChange(engine, schema, id, bind, nil)
 			logQueues = addToLogQueue(logQueues, schema, id, bind, nil, nil)
 		}
 	}
+	if engine.dryRunQueries != nil {
+		return
+	}
+	phase = flushCacheAndQueues(engine, phase, lazy, transaction, lazyMap, localCacheSets, localCacheDeletes, redisKeysToDelete,
+		dirtyQueues, logQueues)
+}
+
+func flushCacheAndQueues(engine *Engine, phase *flushPhase, lazy bool, transaction bool, lazyMap map[string]interface{},
+	localCacheSets map[string]map[string][]interface{}, localCacheDeletes map[string]map[string]bool,
+	redisKeysToDelete map[string]map[string]bool, dirtyQueues map[string][]*DirtyQueueValue, logQueues []*LogQueueValue) *flushPhase {
+	if lazy && len(engine.afterCommitChanges) > 0 {
+		engine.afterCommitChanges = nil
+		panic(errors.NotSupportedf("lazy flush of entity with change stream"))
+	}
+	phase = phase.next("cache")
+	invalidations := make(map[string]map[string]bool)
 	for _, values := range localCacheSets {
 		for cacheCode, keys := range values {
 			cache := engine.GetLocalCache(cacheCode)
+			for i := 0; i < len(keys); i += 2 {
+				addCacheDeletes(invalidations, cacheCode, keys[i].(string))
+			}
 			if !transaction {
 				cache.MSet(keys...)
 			} else {
@@ -360,6 +465,17 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 			deletesLocalCache.(map[string][]string)[cacheCode] = keys
 		} else {
 			cache.Remove(keys...)
+			addCacheDeletes(invalidations, cacheCode, keys...)
+		}
+	}
+	if !transaction {
+		publishLocalCacheInvalidation(engine, invalidations)
+	} else if len(invalidations) > 0 {
+		if engine.afterCommitLocalCacheInvalidations == nil {
+			engine.afterCommitLocalCacheInvalidations = make(map[string]map[string]bool)
+		}
+		for cacheCode, keys := range invalidations {
+			addCacheDeletes(engine.afterCommitLocalCacheInvalidations, cacheCode, mapKeys(keys)...)
 		}
 	}
 	for cacheCode, allKeys := range redisKeysToDelete {
@@ -382,15 +498,16 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 				cache.Del(keys...)
 			} else {
 				if engine.afterCommitRedisCacheDeletes == nil {
-					engine.afterCommitRedisCacheDeletes = make(map[string][]string)
+					engine.afterCommitRedisCacheDeletes = make(map[string]map[string]bool)
 				}
-				engine.afterCommitRedisCacheDeletes[cacheCode] = append(engine.afterCommitRedisCacheDeletes[cacheCode], keys...)
+				addCacheDeletes(engine.afterCommitRedisCacheDeletes, cacheCode, keys...)
 			}
 		}
 	}
+	// flush context is checked once when queue phase starts, started publishing is never interrupted
+	phase = phase.next("queue")
 	if len(lazyMap) > 0 {
-		channel := engine.GetRabbitMQQueue(lazyQueueName)
-		channel.Publish(serializeForLazyQueue(lazyMap))
+		publishLazy(engine, lazyMap)
 	}
 	for k, v := range dirtyQueues {
 		channel := engine.GetRabbitMQQueue("dirty_queue_" + k)
@@ -399,6 +516,9 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 			channel.Publish(asJSON)
 		}
 	}
+	if !transaction {
+		publishEntityChanges(engine)
+	}
 	for _, val := range logQueues {
 		if val.Meta == nil {
 			val.Meta = engine.logMetaData
@@ -411,6 +531,7 @@ func flush(engine *Engine, lazy bool, transaction bool, entities ...Entity) {
 		channel := engine.GetRabbitMQQueue(logQueueName)
 		channel.Publish(asJSON)
 	}
+	return phase
 }
 
 func updateCacheAfterUpdate(dbData map[string]interface{}, engine *Engine, entity Entity, bind map[string]interface{},
@@ -426,22 +547,30 @@ func updateCacheAfterUpdate(dbData map[string]interface{}, engine *Engine, entit
 	redisCache, hasRedis := schema.GetRedisCache(engine)
 	if hasLocalCache {
 		addLocalCacheSet(localCacheSets, db.GetPoolCode(), localCache.code, schema.getCacheKey(currentID), buildLocalCacheValue(entity))
-		keys := getCacheQueriesKeys(schema, bind, dbData, false)
-		addCacheDeletes(localCacheDeletes, localCache.code, keys...)
-		keys = getCacheQueriesKeys(schema, bind, old, false)
-		addCacheDeletes(localCacheDeletes, localCache.code, keys...)
 	}
 	if hasRedis {
 		addCacheDeletes(redisKeysToDelete, redisCache.code, schema.getCacheKey(currentID))
-		keys := getCacheQueriesKeys(schema, bind, dbData, false)
-		addCacheDeletes(redisKeysToDelete, redisCache.code, keys...)
-		keys = getCacheQueriesKeys(schema, bind, old, false)
-		addCacheDeletes(redisKeysToDelete, redisCache.code, keys...)
+	}
+	addCacheQueriesDeletes(engine, schema, bind, dbData, false, localCacheDeletes, redisKeysToDelete)
+	addCacheQueriesDeletes(engine, schema, bind, old, false, localCacheDeletes, redisKeysToDelete)
+	_, hasFakeDelete := bind["FakeDelete"]
+	if schema.hasFakeDelete && hasFakeDelete {
+		addReferencedQueriesKeys(engine, schema, []interface{}{currentID}, localCacheDeletes, redisKeysToDelete)
 	}
 	addDirtyQueues(dirtyQueues, bind, schema, currentID, "u")
+	addEntityChange(engine, schema, currentID, old, entity.getORM().dBData)
 	return addToLogQueue(logQueues, schema, currentID, old, bind, entity.getORM().attributes.logMeta)
 }
 
+func publishLazy(engine *Engine, lazyMap map[string]interface{}) {
+	if engine.lazyDelay > 0 {
+		publishDelayedLazy(engine, lazyMap)
+		return
+	}
+	channel := engine.GetRabbitMQQueue(lazyQueueName)
+	channel.Publish(serializeForLazyQueue(lazyMap))
+}
+
 func serializeForLazyQueue(lazyMap map[string]interface{}) []byte {
 	encoded, _ := jsoniter.ConfigFastest.Marshal(lazyMap)
 	return encoded
@@ -453,9 +582,79 @@ func injectBind(entity Entity, bind map[string]interface{}) map[string]interface
 		orm.dBData[key] = value
 	}
 	orm.attributes.loaded = true
+	orm.attributes.generatedID = false
 	return orm.dBData
 }
 
+func valuerToString(field reflect.Value) (valString string, isNull bool) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return "", true
+		}
+		field = field.Elem()
+	}
+	valuer, is := field.Interface().(driver.Valuer)
+	if !is {
+		ptr := reflect.New(field.Type())
+		ptr.Elem().Set(field)
+		valuer = ptr.Interface().(driver.Valuer)
+	}
+	value, err := valuer.Value()
+	if err != nil {
+		panic(errors.Trace(err))
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", true
+	case []byte:
+		return string(v), false
+	case string:
+		return v, false
+	case int64:
+		return strconv.FormatInt(v, 10), false
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), false
+	case bool:
+		if v {
+			return "1", false
+		}
+		return "0", false
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999"), false
+	}
+	return fmt.Sprintf("%v", value), false
+}
+
+func formatDecimal(attributes map[string]string, value decimal.Decimal) string {
+	scale := 0
+	decimalAttribute, has := attributes["decimal"]
+	if has {
+		scale, _ = strconv.Atoi(strings.Split(decimalAttribute, ",")[1])
+	}
+	return value.StringFixed(int32(scale))
+}
+
+func formatTime(tableSchema *tableSchema, attributes map[string]string, value time.Time) string {
+	if attributes["time"] != "true" {
+		if value.Year() == 1 {
+			return "0001-01-01"
+		}
+		return value.Format("2006-01-02")
+	}
+	layout := "2006-01-02 15:04:05"
+	precision, _ := strconv.Atoi(attributes["precision"])
+	if precision > 0 {
+		layout += "." + strings.Repeat("0", precision)
+	}
+	if value.Year() == 1 {
+		return time.Time{}.Format(layout)
+	}
+	if tableSchema.timeLocation != nil {
+		value = value.In(tableSchema.timeLocation)
+	}
+	return value.Format(layout)
+}
+
 func createBind(id uint64, tableSchema *tableSchema, t reflect.Type, value reflect.Value,
 	oldData map[string]interface{}, prefix string) (bind map[string]interface{}) {
 	bind = make(map[string]interface{})
@@ -463,7 +662,7 @@ func createBind(id uint64, tableSchema *tableSchema, t reflect.Type, value refle
 	for i := 0; i < t.NumField(); i++ {
 		fieldType := t.Field(i)
 		name := prefix + fieldType.Name
-		if prefix == "" && i <= 1 {
+		if t == tableSchema.t && i <= 1 {
 			continue
 		}
 		old := oldData[name]
@@ -503,9 +702,16 @@ func createBind(id uint64, tableSchema *tableSchema, t reflect.Type, value refle
 			bind[name] = val
 		case "string":
 			value := field.String()
+			uuid, isUUID := attributes["uuidBinary"]
+			if isUUID && value != "" {
+				value = uuidToHex(value, uuid == "ordered")
+			}
 			if hasOld && (old == value || (old == nil && value == "")) {
 				continue
 			}
+			if !isUUID && value != "" {
+				tableSchema.checkEnumValues(name, value)
+			}
 			if value == "" {
 				if isRequired {
 					bind[name] = ""
@@ -570,24 +776,30 @@ func createBind(id uint64, tableSchema *tableSchema, t reflect.Type, value refle
 				continue
 			}
 			bind[name] = valString
+		case "decimal.Decimal":
+			valString := formatDecimal(attributes, field.Interface().(decimal.Decimal))
+			if hasOld && old == valString {
+				continue
+			}
+			bind[name] = valString
+		case "*decimal.Decimal":
+			value := field.Interface().(*decimal.Decimal)
+			var valString string
+			if value != nil {
+				valString = formatDecimal(attributes, *value)
+			}
+			if hasOld && (old == valString || (valString == "" && (old == nil || old == ""))) {
+				continue
+			}
+			if valString == "" {
+				bind[name] = nil
+			} else {
+				bind[name] = valString
+			}
 		case "*orm.CachedQuery":
 			continue
 		case "time.Time":
-			value := field.Interface().(time.Time)
-			layout := "2006-01-02"
-			var valueAsString string
-			if tableSchema.tags[name]["time"] == "true" {
-				if value.Year() == 1 {
-					valueAsString = "0001-01-01 00:00:00"
-				} else {
-					layout += " 15:04:05"
-				}
-			} else if value.Year() == 1 {
-				valueAsString = "0001-01-01"
-			}
-			if valueAsString == "" {
-				valueAsString = value.Format(layout)
-			}
+			valueAsString := formatTime(tableSchema, attributes, field.Interface().(time.Time))
 			if hasOld && old == valueAsString {
 				continue
 			}
@@ -595,15 +807,9 @@ func createBind(id uint64, tableSchema *tableSchema, t reflect.Type, value refle
 			continue
 		case "*time.Time":
 			value := field.Interface().(*time.Time)
-			layout := "2006-01-02"
 			var valueAsString string
-			if tableSchema.tags[name]["time"] == "true" {
-				if value != nil {
-					layout += " 15:04:05"
-				}
-			}
 			if value != nil {
-				valueAsString = value.Format(layout)
+				valueAsString = formatTime(tableSchema, attributes, *value)
 			}
 			if hasOld && (old == valueAsString || (valueAsString == "" && (old == nil || old == ""))) {
 				continue
@@ -622,6 +828,9 @@ func createBind(id uint64, tableSchema *tableSchema, t reflect.Type, value refle
 			if hasOld && old == valueAsString {
 				continue
 			}
+			if value != nil {
+				tableSchema.checkEnumValues(name, value...)
+			}
 			bind[name] = valueAsString
 		case "interface {}":
 			value := field.Interface()
@@ -639,15 +848,29 @@ func createBind(id uint64, tableSchema *tableSchema, t reflect.Type, value refle
 			bind[name] = valString
 		default:
 			k := field.Kind().String()
-			if k == "struct" {
-				subBind := createBind(0, tableSchema, field.Type(), reflect.ValueOf(field.Interface()), oldData, fieldType.Name)
+			if isValuerType(field.Type()) {
+				valString, isNull := valuerToString(field)
+				if hasOld && (old == valString || (isNull && (old == nil || old == ""))) {
+					continue
+				}
+				if isNull {
+					bind[name] = nil
+				} else {
+					bind[name] = valString
+				}
+				continue
+			} else if k == "struct" {
+				subBind := createBind(0, tableSchema, field.Type(), reflect.ValueOf(field.Interface()), oldData, embeddedPrefix(fieldType.Name, attributes))
 				for key, value := range subBind {
 					bind[key] = value
 				}
 				continue
 			} else if k == "ptr" {
 				valueAsString := ""
-				if !field.IsNil() {
+				_, isUUID := tableSchema.uuidReferences[name]
+				if !field.IsNil() && isUUID {
+					valueAsString = getUUIDReferenceValue(tableSchema, name, field)
+				} else if !field.IsNil() {
 					valueAsString = strconv.FormatUint(field.Elem().Field(1).Uint(), 10)
 				}
 				if hasOld && (old == valueAsString || ((old == nil || old == "0") && valueAsString == "")) {
@@ -665,13 +888,16 @@ func createBind(id uint64, tableSchema *tableSchema, t reflect.Type, value refle
 	return
 }
 
-func getCacheQueriesKeys(schema *tableSchema, bind map[string]interface{}, data map[string]interface{}, addedDeleted bool) (keys []string) {
-	keys = make([]string, 0)
-
+func addCacheQueriesDeletes(engine *Engine, schema *tableSchema, bind map[string]interface{}, data map[string]interface{},
+	addedDeleted bool, localCacheDeletes map[string]map[string]bool, redisKeysToDelete map[string]map[string]bool) {
 	for indexName, definition := range schema.cachedIndexesAll {
 		if !addedDeleted && schema.hasFakeDelete {
 			_, addedDeleted = bind["FakeDelete"]
 		}
+		keys := make([]string, 0)
+		if definition.Composite {
+			keys = append(keys, getCompositeKeys(schema, indexName, definition, bind, data, addedDeleted)...)
+		}
 		if addedDeleted && len(definition.TrackedFields) == 0 {
 			keys = append(keys, getCacheKeySearch(schema, indexName))
 		}
@@ -689,8 +915,76 @@ func getCacheQueriesKeys(schema *tableSchema, bind map[string]interface{}, data
 				break
 			}
 		}
+		if len(keys) == 0 {
+			continue
+		}
+		localCache, hasLocalCache := schema.getQueryLocalCache(engine, definition)
+		if hasLocalCache {
+			addCacheDeletes(localCacheDeletes, localCache.code, keys...)
+		}
+		redisCache, hasRedis := schema.getQueryRedisCache(engine, definition)
+		if hasRedis {
+			addCacheDeletes(redisKeysToDelete, redisCache.code, keys...)
+		}
+	}
+}
+
+func addReferencedQueriesKeys(engine *Engine, schema *tableSchema, ids []interface{},
+	localCacheDeletes map[string]map[string]bool, redisKeysToDelete map[string]map[string]bool) {
+	for _, reference := range schema.queryReferences {
+		refSchema := reference.schema
+		definition := refSchema.cachedIndexesAll[reference.indexName]
+		localCache, hasLocalCache := refSchema.getQueryLocalCache(engine, definition)
+		redisCache, hasRedis := refSchema.getQueryRedisCache(engine, definition)
+		if !hasLocalCache && !hasRedis {
+			continue
+		}
+		fields := make([]string, 0)
+		for _, field := range definition.QueryFields {
+			if !refSchema.hasFakeDelete || field != "FakeDelete" {
+				fields = append(fields, field)
+			}
+		}
+		keys := make([]string, 0)
+		if definition.Composite {
+			keys = append(keys, getCacheKeySearch(refSchema, getCompositeIndexName(reference.indexName, "")))
+			for _, id := range ids {
+				keys = append(keys, getCacheKeySearch(refSchema, getCompositeIndexName(reference.indexName, reference.column), id))
+			}
+		}
+		if len(fields) == 1 {
+			for _, id := range ids {
+				keys = append(keys, getCacheKeySearch(refSchema, reference.indexName, id))
+			}
+		} else {
+			where := NewWhere(fmt.Sprintf("`%s` IN ?", reference.column), ids)
+			/* #nosec */
+			query := fmt.Sprintf("SELECT DISTINCT `%s` FROM `%s` WHERE %s", strings.Join(fields, "`,`"), refSchema.getTableName(engine), where)
+			results, def := refSchema.GetMysql(engine).Query(query, where.GetParameters()...)
+			for results.Next() {
+				values := make([]sql.NullString, len(fields))
+				pointers := make([]interface{}, len(fields))
+				for i := range values {
+					pointers[i] = &values[i]
+				}
+				results.Scan(pointers...)
+				attributes := make([]interface{}, len(fields))
+				for i, value := range values {
+					if value.Valid {
+						attributes[i] = value.String
+					}
+				}
+				keys = append(keys, getCacheKeySearch(refSchema, reference.indexName, attributes...))
+			}
+			def()
+		}
+		if hasLocalCache {
+			addCacheDeletes(localCacheDeletes, localCache.code, keys...)
+		}
+		if hasRedis {
+			addCacheDeletes(redisKeysToDelete, redisCache.code, keys...)
+		}
 	}
-	return
 }
 
 func addLocalCacheSet(localCacheSets map[string]map[string][]interface{}, dbCode string, cacheCode string, keys ...interface{}) {
@@ -700,6 +994,14 @@ func addLocalCacheSet(localCacheSets map[string]map[string][]interface{}, dbCode
 	localCacheSets[dbCode][cacheCode] = append(localCacheSets[dbCode][cacheCode], keys...)
 }
 
+func mapKeys(values map[string]bool) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
 func addCacheDeletes(cacheDeletes map[string]map[string]bool, cacheCode string, keys ...string) {
 	if len(keys) == 0 {
 		return
@@ -759,13 +1061,13 @@ func addToLogQueue(keys []*LogQueueValue, tableSchema *tableSchema, id uint64,
 		}
 	}
 	val := &LogQueueValue{TableName: tableSchema.logTableName, ID: id,
-		PoolName: tableSchema.logPoolName, Before: before,
+		PoolName: tableSchema.logPoolName, ClickHousePoolName: tableSchema.logClickHouse, Writer: tableSchema.logWriter, Before: before,
 		Changes: changes, Updated: time.Now(), Meta: entityMeta}
 	keys = append(keys, val)
 	return keys
 }
 
-func fillLazyQuery(lazyMap map[string]interface{}, dbCode string, sql string, values []interface{}) {
+func fillLazyQuery(lazyMap map[string]interface{}, dbCode string, sql string, values []interface{}, redacted []int) {
 	updatesMap := lazyMap["q"]
 	if updatesMap == nil {
 		updatesMap = make([]interface{}, 0)
@@ -775,6 +1077,9 @@ func fillLazyQuery(lazyMap map[string]interface{}, dbCode string, sql string, va
 	lazyValue[0] = dbCode
 	lazyValue[1] = sql
 	lazyValue[2] = values
+	if len(redacted) > 0 {
+		lazyValue = append(lazyValue, redacted)
+	}
 	lazyMap["q"] = append(updatesMap.([]interface{}), lazyValue)
 }
 
@@ -812,15 +1117,13 @@ func updateCacheForInserted(entity Entity, lazy bool, id uint64,
 		} else {
 			addCacheDeletes(localCacheDeletes, localCache.code, schema.getCacheKey(id))
 		}
-		keys := getCacheQueriesKeys(schema, bind, bind, true)
-		addCacheDeletes(localCacheDeletes, localCache.code, keys...)
 	}
 	if hasRedis {
 		addCacheDeletes(redisKeysToDelete, redisCache.code, schema.getCacheKey(id))
-		keys := getCacheQueriesKeys(schema, bind, bind, true)
-		addCacheDeletes(redisKeysToDelete, redisCache.code, keys...)
 	}
+	addCacheQueriesDeletes(engine, schema, bind, bind, true, localCacheDeletes, redisKeysToDelete)
 	addDirtyQueues(dirtyQueues, bind, schema, id, "i")
+	addEntityChange(engine, schema, id, nil, bind)
 	logQueues = addToLogQueue(logQueues, schema, id, nil, bind, entity.getORM().attributes.logMeta)
 	return logQueues
 }
@@ -832,7 +1135,19 @@ func getDirtyBind(entity Entity) (is bool, bind map[string]interface{}) {
 	}
 	id := orm.GetID()
 	t := orm.attributes.elem.Type()
-	bind = createBind(id, orm.tableSchema, t, orm.attributes.elem, orm.dBData, "")
-	is = id == 0 || len(bind) > 0
+	if generated, is := entity.(GeneratedEntity); is {
+		bind = generated.OrmBind(id, orm.dBData)
+	} else {
+		bind = createBind(id, orm.tableSchema, t, orm.attributes.elem, orm.dBData, "")
+	}
+	if id > 0 {
+		for _, column := range orm.attributes.forcedDirty {
+			_, has := bind[column]
+			if !has {
+				bind[column] = orm.dBData[column]
+			}
+		}
+	}
+	is = id == 0 || len(bind) > 0 || len(orm.attributes.expressions) > 0
 	return is, bind
 }
@@ -0,0 +1,27 @@
+package orm
+
+type PlannedQuery struct {
+	Pool      string
+	Query     string
+	Arguments []interface{}
+}
+
+// flushDryRun runs flush with queries collected instead of executed, entities and cache are not changed
+func flushDryRun(engine *Engine, entities ...Entity) []PlannedQuery {
+	planned := make([]PlannedQuery, 0)
+	engine.dryRunQueries = &planned
+	defer func() {
+		engine.dryRunQueries = nil
+	}()
+	flush(engine, false, false, entities...)
+	return planned
+}
+
+func planQuery(engine *Engine, db *DB, query string, redacted []int, args []interface{}) bool {
+	if engine.dryRunQueries == nil {
+		return false
+	}
+	*engine.dryRunQueries = append(*engine.dryRunQueries, PlannedQuery{Pool: db.GetPoolCode(), Query: query,
+		Arguments: redactArguments(args, redacted)})
+	return true
+}
@@ -80,7 +80,7 @@ func (r *FlushFromCacheReceiver) Digest() {
 			attributes := make([]interface{}, bindLength+1)
 			i := 0
 			for key, value := range bind {
-				fields[i] = fmt.Sprintf("`%s` = ?", key)
+				fields[i] = fmt.Sprintf("`%s` = %s", key, schema.placeholder(key))
 				attributes[i] = value
 				i++
 			}
@@ -88,14 +88,17 @@ func (r *FlushFromCacheReceiver) Digest() {
 			db := schema.GetMysql(r.engine)
 
 			/* #nosec */
-			sql := fmt.Sprintf("UPDATE %s SET %s WHERE `ID` = ?", schema.tableName, strings.Join(fields, ","))
+			sql := fmt.Sprintf("UPDATE %s SET %s WHERE `ID` = ?", schema.getTableName(r.engine), strings.Join(fields, ","))
 			_ = db.Exec(sql, attributes...)
-			cacheKeys := getCacheQueriesKeys(schema, bind, entity.getORM().dBData, false)
-
-			keys := getCacheQueriesKeys(schema, bind, newData, false)
-			cacheKeys = append(cacheKeys, keys...)
-			if len(cacheKeys) > 0 {
-				cacheEntity.Del(cacheKeys...)
+			localCacheDeletes := make(map[string]map[string]bool)
+			redisKeysToDelete := make(map[string]map[string]bool)
+			addCacheQueriesDeletes(r.engine, schema, bind, entity.getORM().dBData, false, localCacheDeletes, redisKeysToDelete)
+			addCacheQueriesDeletes(r.engine, schema, bind, newData, false, localCacheDeletes, redisKeysToDelete)
+			for cacheCode, keys := range localCacheDeletes {
+				r.engine.GetLocalCache(cacheCode).Remove(mapKeys(keys)...)
+			}
+			for cacheCode, keys := range redisKeysToDelete {
+				r.engine.GetRedis(cacheCode).Del(mapKeys(keys)...)
 			}
 		}
 	})
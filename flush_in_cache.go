@@ -7,7 +7,7 @@ import (
 func flushInCache(engine *Engine, entities ...Entity) {
 	invalidEntities := make([]Entity, 0)
 	validEntities := make([][]byte, 0)
-	redisValues := make(map[string][]interface{})
+	redisValues := make(map[string]map[int][]interface{})
 
 	for _, entity := range entities {
 		orm := initIfNeeded(engine, entity)
@@ -31,9 +31,10 @@ func flushInCache(engine *Engine, entities ...Entity) {
 			entityCacheKey := schema.getCacheKey(id)
 			entityCacheValue := buildRedisValue(entity.(Entity))
 			if redisValues[cache.code] == nil {
-				redisValues[cache.code] = make([]interface{}, 0)
+				redisValues[cache.code] = make(map[int][]interface{})
 			}
-			redisValues[cache.code] = append(redisValues[cache.code], entityCacheKey, entityCacheValue)
+			ttl := schema.redisCacheTTL
+			redisValues[cache.code][ttl] = append(redisValues[cache.code][ttl], entityCacheKey, entityCacheValue)
 
 			validEntities = append(validEntities, createDirtyQueueMember(entityName, id))
 		}
@@ -46,8 +47,14 @@ func flushInCache(engine *Engine, entities ...Entity) {
 		for _, v := range validEntities {
 			channel.Publish(v)
 		}
-		for cacheCode, keys := range redisValues {
-			engine.GetRedis(cacheCode).MSet(keys...)
+		for cacheCode, values := range redisValues {
+			for ttl, keys := range values {
+				if ttl > 0 {
+					engine.GetRedis(cacheCode).MSetWithTTL(ttl, keys...)
+				} else {
+					engine.GetRedis(cacheCode).MSet(keys...)
+				}
+			}
 		}
 	}
 }
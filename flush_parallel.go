@@ -0,0 +1,135 @@
+package orm
+
+import (
+	"context"
+	"sync"
+)
+
+func (e *Engine) SetFlushWorkers(workers int) {
+	e.flushWorkers = workers
+}
+
+func groupEntitiesByPool(engine *Engine, entities []Entity) map[string][]Entity {
+	groups := make(map[string][]Entity)
+	for _, entity := range entities {
+		orm := entity.getORM()
+		schema := orm.tableSchema
+		if schema.shard != nil {
+			return nil
+		}
+		for _, refName := range schema.refOne {
+			refSchema := getTableSchema(engine.registry, engine.registry.entities[schema.tags[refName]["ref"]])
+			if refSchema.getMysqlPoolName(engine) != schema.getMysqlPoolName(engine) {
+				return nil
+			}
+			refValue := orm.attributes.elem.FieldByName(refName)
+			if !refValue.IsNil() && hasPendingID(refValue.Interface().(Entity)) {
+				return nil
+			}
+		}
+		if orm.attributes.delete {
+			for refType := range schema.GetUsage(engine.registry) {
+				if getTableSchema(engine.registry, refType).getMysqlPoolName(engine) != schema.getMysqlPoolName(engine) {
+					return nil
+				}
+			}
+		}
+		pool := schema.getMysqlPoolName(engine)
+		groups[pool] = append(groups[pool], entity)
+	}
+	if len(groups) < 2 {
+		return nil
+	}
+	return groups
+}
+
+func (e *Engine) flushParallel(transaction bool, groups map[string][]Entity) {
+	workers := make([]*Engine, 0, len(groups))
+	releases := make([]func(), 0, len(groups))
+	semaphore := make(chan struct{}, e.flushWorkers)
+	var wg sync.WaitGroup
+	var recovered interface{}
+	var mutex sync.Mutex
+	for pool, entities := range groups {
+		worker, release := e.newFlushWorker(pool)
+		workers = append(workers, worker)
+		releases = append(releases, release)
+		wg.Add(1)
+		go func(entities []Entity) {
+			semaphore <- struct{}{}
+			defer func() {
+				if r := recover(); r != nil {
+					mutex.Lock()
+					if recovered == nil {
+						recovered = r
+					}
+					mutex.Unlock()
+				}
+				<-semaphore
+				wg.Done()
+			}()
+			flush(worker, false, transaction, entities...)
+		}(entities)
+	}
+	wg.Wait()
+	for _, worker := range workers {
+		for k, v := range worker.dataDog.counters {
+			e.dataDog.incrementCounter(k, v)
+		}
+		for cacheCode, pairs := range worker.afterCommitLocalCacheSets {
+			if e.afterCommitLocalCacheSets == nil {
+				e.afterCommitLocalCacheSets = make(map[string][]interface{})
+			}
+			e.afterCommitLocalCacheSets[cacheCode] = append(e.afterCommitLocalCacheSets[cacheCode], pairs...)
+		}
+		for cacheCode, keys := range worker.afterCommitRedisCacheDeletes {
+			if e.afterCommitRedisCacheDeletes == nil {
+				e.afterCommitRedisCacheDeletes = make(map[string]map[string]bool)
+			}
+			addCacheDeletes(e.afterCommitRedisCacheDeletes, cacheCode, mapKeys(keys)...)
+		}
+		for cacheCode, keys := range worker.afterCommitLocalCacheInvalidations {
+			if e.afterCommitLocalCacheInvalidations == nil {
+				e.afterCommitLocalCacheInvalidations = make(map[string]map[string]bool)
+			}
+			addCacheDeletes(e.afterCommitLocalCacheInvalidations, cacheCode, mapKeys(keys)...)
+		}
+		e.afterCommitChanges = append(e.afterCommitChanges, worker.afterCommitChanges...)
+	}
+	for _, release := range releases {
+		release()
+	}
+	if recovered != nil {
+		panic(recovered)
+	}
+}
+
+// newFlushWorker takes engine from EnginePool, release returns it there
+func (e *Engine) newFlushWorker(pool string) (worker *Engine, release func()) {
+	enginePool := e.registry.EnginePool()
+	worker = enginePool.Get()
+	if worker.registry != e.registry {
+		worker = e.registry.createEngine()
+	}
+	worker.queryLoggers = e.queryLoggers
+	worker.sqlMiddlewares = e.sqlMiddlewares
+	worker.log = e.log
+	worker.logMetaData = e.logMetaData
+	worker.tracerContext = e.tracerContext
+	worker.flushContext = e.flushContext
+	worker.poolResolver = e.poolResolver
+	worker.shardPools = e.shardPools
+	worker.tableSuffixResolver = e.tableSuffixResolver
+	if len(e.dataDog.ctx) > 0 {
+		worker.dataDog.ctx = []context.Context{e.dataDog.ctx[len(e.dataDog.ctx)-1]}
+	}
+	db := e.GetMysql(pool)
+	own := worker.dbs[pool]
+	worker.dbs[pool] = &DB{engine: worker, client: db.client, code: db.code, databaseName: db.databaseName, autoincrement: db.autoincrement,
+		version: db.version}
+	return worker, func() {
+		// client is shared with this engine, it can't be rolled back in Reset
+		worker.dbs[pool] = own
+		enginePool.Put(worker)
+	}
+}
@@ -0,0 +1,47 @@
+package orm
+
+import (
+	"strings"
+	"time"
+
+	apexLog "github.com/apex/log"
+)
+
+type flushPhase struct {
+	engine   *Engine
+	name     string
+	start    time.Time
+	span     TracerSpan
+	ddSpan   WorkSpan
+	finished bool
+}
+
+func startFlushPhase(engine *Engine, name string) *flushPhase {
+	engine.checkFlushContext()
+	phase := &flushPhase{engine: engine, name: name, start: time.Now()}
+	phase.span = engine.startTracerSpan("orm.flush." + name)
+	if len(engine.dataDog.ctx) > 0 {
+		phase.ddSpan = engine.dataDog.StartWorkSpan("orm.flush." + name)
+	}
+	return phase
+}
+
+func (p *flushPhase) next(name string) *flushPhase {
+	p.finish()
+	return startFlushPhase(p.engine, name)
+}
+
+func (p *flushPhase) finish() {
+	if p.finished {
+		return
+	}
+	p.finished = true
+	if p.ddSpan != nil {
+		p.ddSpan.Finish()
+	}
+	p.engine.finishTracerSpan(p.span, nil)
+	if p.engine.log != nil {
+		p.engine.log.Debug("[ORM][FLUSH]["+strings.ToUpper(p.name)+"]",
+			apexLog.Fields{"phase": p.name, "microseconds": time.Since(p.start).Microseconds()})
+	}
+}
@@ -1,8 +1,18 @@
 package orm
 
 import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
+	apexLog "github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -150,3 +160,917 @@ func TestFlush(t *testing.T) {
 	found = engine.LoadByID(1, referenceCascade)
 	assert.False(t, found)
 }
+
+func TestFlushConcurrentTrack(t *testing.T) {
+	var entity *flushEntityReference
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				engine.Track(&flushEntityReference{Name: fmt.Sprintf("Name %d %d", i, j)})
+			}
+			engine.Flush()
+		}(i)
+	}
+	wg.Wait()
+	engine.Flush()
+
+	var rows []*flushEntityReference
+	engine.Search(NewWhere("1"), NewPager(1, 1000), &rows)
+	assert.Len(t, rows, 100)
+}
+
+func TestFlusher(t *testing.T) {
+	var entity *flushEntityReference
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	flusher := engine.NewFlusher(3, true)
+	flusher.Track(&flushEntityReference{Name: "A"}, &flushEntityReference{Name: "B"})
+	assert.Equal(t, 2, flusher.Len())
+	flusher.Track(&flushEntityReference{Name: "C"})
+	assert.Equal(t, 0, flusher.Len())
+	entity = &flushEntityReference{}
+	assert.True(t, engine.LoadByID(3, entity))
+	assert.Equal(t, "C", entity.Name)
+
+	entity.Name = "C2"
+	flusher.Track(entity)
+	flusher.FlushInTransaction()
+	entity = &flushEntityReference{}
+	engine.LoadByID(3, entity)
+	assert.Equal(t, "C2", entity.Name)
+
+	flusher.MarkToDelete(entity)
+	assert.NoError(t, flusher.FlushWithCheck())
+	assert.False(t, engine.LoadByID(3, entity))
+
+	flusher = engine.NewFlusher(2, false)
+	flusher.Track(&flushEntityReference{Name: "D"})
+	assert.PanicsWithError(t, "flusher limit 2 exceeded", func() {
+		flusher.Track(&flushEntityReference{Name: "E"})
+	})
+	flusher.Clear()
+	assert.Equal(t, 0, flusher.Len())
+}
+
+func TestFlushTrackDeduplication(t *testing.T) {
+	var entity *flushEntityReference
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	entity = &flushEntityReference{Name: "A"}
+	engine.Track(entity, entity)
+	assert.Equal(t, 1, engine.trackedEntities.len())
+	engine.Flush()
+
+	entity2 := &flushEntityReference{}
+	engine.LoadByID(1, entity2)
+	entity.Name = "B"
+	entity2.Name = "C"
+	engine.Track(entity, entity2)
+	assert.Equal(t, 1, engine.trackedEntities.len())
+	engine.Flush()
+	engine.LoadByID(1, entity)
+	assert.Equal(t, "C", entity.Name)
+
+	engine.DisableTrackDeduplication()
+	engine.Track(entity, entity)
+	assert.Equal(t, 2, engine.trackedEntities.len())
+	engine.ClearTrackedEntities()
+
+	flusher := engine.NewFlusher(10, false)
+	assert.True(t, flusher.disableDeduplication)
+	flusher = engine.registry.CreateEngine().NewFlusher(10, false)
+	flusher.Track(entity, entity)
+	assert.Equal(t, 1, flusher.Len())
+}
+
+func TestFlushInChunks(t *testing.T) {
+	var entity *flushEntityReference
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	for i := 0; i < 5; i++ {
+		engine.Track(&flushEntityReference{Name: fmt.Sprintf("Name %d", i)})
+	}
+	progress := make([]int, 0)
+	engine.FlushInChunks(2, func(flushed int, total int) {
+		assert.Equal(t, 5, total)
+		progress = append(progress, flushed)
+	})
+	assert.Equal(t, []int{2, 4, 5}, progress)
+	assert.Equal(t, 0, engine.trackedEntities.len())
+	var rows []*flushEntityReference
+	engine.Search(NewWhere("1"), NewPager(1, 100), &rows)
+	assert.Len(t, rows, 5)
+
+	engine.Track(&flushEntityReference{Name: "Name 5"}, &flushEntityReference{Name: "Name 6"})
+	engine.Track(&flushEntityReference{Name: "Name 7", ID: 1})
+	assert.Panics(t, func() {
+		engine.FlushInChunks(2, nil)
+	})
+	assert.Equal(t, 1, engine.trackedEntities.len())
+	engine.ClearTrackedEntities()
+	engine.Search(NewWhere("1"), NewPager(1, 100), &rows)
+	assert.Len(t, rows, 7)
+}
+
+type flushEntityLogPool struct {
+	ORM  `orm:"mysql=log;redisCache"`
+	ID   uint
+	Name string
+}
+
+func TestFlushParallel(t *testing.T) {
+	var entity *flushEntityReference
+	var entityLogPool *flushEntityLogPool
+	engine := PrepareTables(t, &Registry{}, entity, entityLogPool)
+	engine.SetFlushWorkers(2)
+
+	engine.Track(&flushEntityReference{Name: "A"}, &flushEntityLogPool{Name: "B"}, &flushEntityReference{Name: "C"})
+	groups := groupEntitiesByPool(engine, engine.trackedEntities.entities)
+	assert.Len(t, groups, 2)
+	assert.Len(t, groups["default"], 2)
+	engine.FlushInTransaction()
+
+	entity = &flushEntityReference{}
+	assert.True(t, engine.LoadByID(2, entity))
+	assert.Equal(t, "C", entity.Name)
+	entityLogPool = &flushEntityLogPool{}
+	assert.True(t, engine.LoadByID(1, entityLogPool))
+	assert.Equal(t, "B", entityLogPool.Name)
+
+	entityLogPool.Name = "B2"
+	engine.Track(entityLogPool, &flushEntityReference{Name: "A", ID: 1})
+	assert.Panics(t, func() {
+		engine.FlushInTransaction()
+	})
+	engine.ClearTrackedEntities()
+	entityLogPool = &flushEntityLogPool{}
+	engine.LoadByID(1, entityLogPool)
+	assert.Equal(t, "B", entityLogPool.Name)
+}
+
+func TestFlushWorkerRelease(t *testing.T) {
+	registry := &validatedRegistry{sqlClients: map[string]*DBConfig{"default": {code: "default", databaseName: "test"}}}
+	engine := registry.clone(&Registry{}, nil).CreateEngine()
+	worker, release := engine.newFlushWorker("default")
+	assert.Equal(t, engine.registry, worker.registry)
+	assert.Same(t, engine.dbs["default"].client, worker.dbs["default"].client)
+	release()
+	assert.NotSame(t, engine.dbs["default"].client, worker.dbs["default"].client)
+	assert.Same(t, worker, worker.dbs["default"].engine)
+}
+
+func TestTruncateTables(t *testing.T) {
+	var entity *flushEntity
+	var reference *flushEntityReference
+	engine := PrepareTables(t, &Registry{}, entity, reference)
+
+	entity = &flushEntity{Name: "Tom", NameRequired: "required", ReferenceOne: &flushEntityReference{Name: "John"}}
+	engine.TrackAndFlush(entity)
+	assert.True(t, engine.LoadByID(1, entity))
+	redisCache := engine.GetRedis()
+	assert.NotEmpty(t, redisCache.Keys("*"))
+
+	engine.TruncateTables(entity)
+	assert.Empty(t, redisCache.Keys("*"))
+	assert.False(t, engine.LoadByID(1, &flushEntity{}))
+	assert.True(t, engine.LoadByID(1, &flushEntityReference{}))
+
+	engine.TruncateAll()
+	assert.False(t, engine.LoadByID(1, &flushEntityReference{}))
+}
+
+type flushEntityAddress struct {
+	Street string
+	City   string
+}
+
+type flushEntityEmbedded struct {
+	ORM
+	ID         uint
+	Home       flushEntityAddress
+	Work       flushEntityAddress `orm:"prefix=work_"`
+	Correspond flushEntityAddress `orm:"prefix=-"`
+}
+
+func TestFlushEmbeddedPrefix(t *testing.T) {
+	var entity *flushEntityEmbedded
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	schema := engine.GetRegistry().GetTableSchemaForEntity(entity)
+	assert.Equal(t, []string{"ID", "HomeStreet", "HomeCity", "work_Street", "work_City", "Street", "City"}, schema.GetColumns())
+
+	entity = &flushEntityEmbedded{}
+	entity.Home.City = "Berlin"
+	entity.Work.Street = "Main"
+	entity.Correspond.City = "Warsaw"
+	engine.TrackAndFlush(entity)
+
+	entity = &flushEntityEmbedded{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, "Berlin", entity.Home.City)
+	assert.Equal(t, "Main", entity.Work.Street)
+	assert.Equal(t, "Warsaw", entity.Correspond.City)
+
+	entity.Work.City = "Paris"
+	assert.True(t, engine.IsDirty(entity))
+	engine.TrackAndFlush(entity)
+	entity = &flushEntityEmbedded{}
+	engine.LoadByID(1, entity)
+	assert.Equal(t, "Paris", entity.Work.City)
+}
+
+type flushEntityTime struct {
+	ORM
+	ID      uint
+	Date    time.Time
+	Created time.Time  `orm:"time"`
+	Updated *time.Time `orm:"time;precision=6"`
+}
+
+func TestFlushTimeLocation(t *testing.T) {
+	var entity *flushEntityTime
+	registry := &Registry{}
+	registry.SetTimeLocation(time.UTC)
+	engine := PrepareTables(t, registry, entity)
+
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	assert.NoError(t, err)
+	created := time.Date(2020, 6, 1, 12, 30, 15, 0, warsaw)
+	updated := time.Date(2020, 6, 1, 12, 30, 15, 123456000, warsaw)
+	entity = &flushEntityTime{Date: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), Created: created, Updated: &updated}
+	engine.TrackAndFlush(entity)
+
+	var stored string
+	engine.GetMysql().QueryRow(NewWhere("SELECT `Created` FROM `flushEntityTime` WHERE `ID` = 1"), &stored)
+	assert.Equal(t, "2020-06-01 10:30:15", stored)
+
+	entity = &flushEntityTime{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.True(t, created.Equal(entity.Created))
+	assert.Equal(t, time.UTC, entity.Created.Location())
+	assert.True(t, updated.Equal(*entity.Updated))
+	assert.Equal(t, "2020-06-01", entity.Date.Format("2006-01-02"))
+	assert.False(t, engine.IsDirty(entity))
+}
+
+type flushEntityDecimal struct {
+	ORM      `orm:"localCache"`
+	ID       uint
+	Price    decimal.Decimal  `orm:"decimal=12,2;unsigned=false"`
+	Discount *decimal.Decimal `orm:"decimal=5,3"`
+}
+
+type flushEntityDecimalNoTag struct {
+	ORM
+	ID    uint
+	Price decimal.Decimal
+}
+
+func TestDecimalTagRequired(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterMySQLPool("root:root@tcp(localhost:3310)/test")
+	schema, err := initTableSchema(registry, reflect.TypeOf(flushEntityDecimalNoTag{}))
+	assert.NoError(t, err)
+	engine := (&validatedRegistry{}).clone(&Registry{}, nil).CreateEngine()
+	_, err = checkStruct(schema, engine, schema.t, make(map[string]*index), make(map[string]*foreignIndex), "")
+	assert.EqualError(t, err, "decimal field Price without decimal=precision,scale tag not valid")
+
+	assert.True(t, isValidDecimalTag("12,2"))
+	assert.True(t, isValidDecimalTag("10,0"))
+	assert.False(t, isValidDecimalTag(""))
+	assert.False(t, isValidDecimalTag("12"))
+	assert.False(t, isValidDecimalTag("2,3"))
+	assert.False(t, isValidDecimalTag("a,b"))
+}
+
+func TestFlushDecimal(t *testing.T) {
+	var entity *flushEntityDecimal
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	entity = &flushEntityDecimal{Price: decimal.RequireFromString("-1234567890.1")}
+	engine.TrackAndFlush(entity)
+	entity = &flushEntityDecimal{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, "-1234567890.10", entity.Price.StringFixed(2))
+	assert.Nil(t, entity.Discount)
+	assert.False(t, engine.IsDirty(entity))
+
+	discount := decimal.RequireFromString("0.125")
+	entity.Discount = &discount
+	entity.Price = decimal.RequireFromString("-1234567890.10")
+	assert.True(t, engine.IsDirty(entity))
+	engine.TrackAndFlush(entity)
+	entity = &flushEntityDecimal{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.True(t, discount.Equal(*entity.Discount))
+
+	assert.NoError(t, entity.SetField("Price", "10.5"))
+	assert.Equal(t, "10.50", entity.Price.StringFixed(2))
+	assert.NoError(t, entity.SetField("Discount", nil))
+	assert.Nil(t, entity.Discount)
+}
+
+type flushIP net.IP
+
+func (ip flushIP) Value() (driver.Value, error) {
+	return net.IP(ip).String(), nil
+}
+
+func (ip *flushIP) Scan(value interface{}) error {
+	parsed := net.ParseIP(string(value.([]byte)))
+	if parsed == nil {
+		return fmt.Errorf("invalid IP %s", value)
+	}
+	*ip = flushIP(parsed)
+	return nil
+}
+
+type flushEntityValuer struct {
+	ORM      `orm:"redisCache"`
+	ID       uint
+	IP       flushIP  `orm:"column=varchar(39);required"`
+	ClientIP *flushIP `orm:"column=varchar(39)"`
+}
+
+func TestFlushValuer(t *testing.T) {
+	var entity *flushEntityValuer
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	entity = &flushEntityValuer{IP: flushIP(net.ParseIP("10.0.0.1"))}
+	engine.TrackAndFlush(entity)
+	entity = &flushEntityValuer{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, "10.0.0.1", net.IP(entity.IP).String())
+	assert.Nil(t, entity.ClientIP)
+	assert.False(t, engine.IsDirty(entity))
+
+	clientIP := flushIP(net.ParseIP("::1"))
+	entity.ClientIP = &clientIP
+	assert.True(t, engine.IsDirty(entity))
+	engine.TrackAndFlush(entity)
+	entity = &flushEntityValuer{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, "::1", net.IP(*entity.ClientIP).String())
+	assert.NoError(t, entity.SetField("IP", []byte("10.0.0.2")))
+	assert.Equal(t, "10.0.0.2", net.IP(entity.IP).String())
+
+	engine.GetMysql().Exec("UPDATE `flushEntityValuer` SET `IP` = 'invalid' WHERE `ID` = 1")
+	engine.GetRedis().FlushDB()
+	assert.PanicsWithError(t, "scan of field IP in orm.flushEntityValuer: invalid IP invalid", func() {
+		engine.LoadByID(1, &flushEntityValuer{})
+	})
+}
+
+type flushEntityEnum struct {
+	ORM
+	ID     uint
+	Color  string   `orm:"enum=orm.flushColor"`
+	Colors []string `orm:"set=orm.flushColor"`
+}
+
+func TestFlushEnumValidation(t *testing.T) {
+	var entity *flushEntityEnum
+	registry := &Registry{}
+	registry.RegisterEnumSlice("orm.flushColor", []string{"Red", "Blue"})
+	engine := PrepareTables(t, registry, entity)
+
+	entity = &flushEntityEnum{Color: "Red", Colors: []string{"Red", "Blue"}}
+	engine.TrackAndFlush(entity)
+
+	entity.Colors = []string{"Red", "Green"}
+	engine.Track(entity)
+	err := engine.FlushWithCheck()
+	assert.EqualError(t, err, "invalid value 'Green' for orm.flushEntityEnum.Colors, allowed values: Red,Blue")
+	enumErr, is := err.(*InvalidEnumValueError)
+	assert.True(t, is)
+	assert.Equal(t, []string{"Red", "Blue"}, enumErr.Allowed)
+
+	err = entity.SetField("Color", "Pink")
+	assert.EqualError(t, err, "invalid value 'Pink' for orm.flushEntityEnum.Color, allowed values: Red,Blue")
+	assert.Equal(t, "Red", entity.Color)
+	assert.NoError(t, entity.SetField("Color", "Blue"))
+	assert.NoError(t, entity.SetField("Colors", []string{"Blue"}))
+	engine.TrackAndFlush(entity)
+
+	// value removed from enum, still stored in row
+	entity.Color = "Green"
+	entity.getORM().dBData["Color"] = "Green"
+	assert.False(t, engine.IsDirty(entity))
+	entity.Colors = []string{"Red"}
+	engine.Track(entity)
+	assert.NoError(t, engine.FlushWithCheck())
+}
+
+type cascadeParentEntity struct {
+	ORM
+	ID   uint
+	Name string
+}
+
+type cascadeChildEntity struct {
+	ORM    `orm:"localCache"`
+	ID     uint
+	Parent *cascadeParentEntity
+}
+
+type cascadeGrandChildEntity struct {
+	ORM    `orm:"redisCache"`
+	ID     uint
+	Child  *cascadeChildEntity `orm:"required"`
+	Parent *cascadeParentEntity
+}
+
+func TestMarkToDeleteCascade(t *testing.T) {
+	var parent *cascadeParentEntity
+	var child *cascadeChildEntity
+	var grandChild *cascadeGrandChildEntity
+	engine := PrepareTables(t, &Registry{}, grandChild, parent, child)
+
+	parent = &cascadeParentEntity{Name: "A"}
+	other := &cascadeParentEntity{Name: "B"}
+	engine.TrackAndFlush(parent, other)
+	child = &cascadeChildEntity{Parent: parent}
+	otherChild := &cascadeChildEntity{Parent: other}
+	engine.TrackAndFlush(child, otherChild)
+	engine.TrackAndFlush(&cascadeGrandChildEntity{Child: child}, &cascadeGrandChildEntity{Child: otherChild, Parent: parent},
+		&cascadeGrandChildEntity{Child: otherChild})
+
+	engine.MarkToDeleteCascade(parent)
+	engine.Flush()
+
+	assert.False(t, engine.LoadByID(1, &cascadeParentEntity{}))
+	assert.True(t, engine.LoadByID(2, &cascadeParentEntity{}))
+	assert.False(t, engine.LoadByID(1, &cascadeChildEntity{}))
+	assert.True(t, engine.LoadByID(2, &cascadeChildEntity{}))
+	var rows []*cascadeGrandChildEntity
+	total := engine.SearchWithCount(NewWhere("1"), nil, &rows)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, uint(3), rows[0].ID)
+	assert.False(t, engine.LoadByID(1, &cascadeGrandChildEntity{}))
+}
+
+type flushCascadeParent struct {
+	ORM
+	ID   uint
+	Name string
+}
+
+type flushCascadeChild struct {
+	ORM
+	ID     uint
+	Parent *flushCascadeParent `orm:"cascade"`
+}
+
+func TestFlushCascadeDelete(t *testing.T) {
+	var parent *flushCascadeParent
+	var child *flushCascadeChild
+	engine := PrepareTables(t, &Registry{}, parent, child)
+
+	parent = &flushCascadeParent{Name: "A"}
+	other := &flushCascadeParent{Name: "B"}
+	engine.TrackAndFlush(parent, other)
+	engine.TrackAndFlush(&flushCascadeChild{Parent: parent}, &flushCascadeChild{Parent: parent}, &flushCascadeChild{Parent: other})
+
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		engine.MarkToDelete(parent)
+		engine.Flush()
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "flush with cascade delete blocked")
+	}
+	assert.False(t, engine.LoadByID(1, &flushCascadeParent{}))
+	assert.False(t, engine.LoadByID(1, &flushCascadeChild{}))
+	assert.False(t, engine.LoadByID(2, &flushCascadeChild{}))
+	assert.True(t, engine.LoadByID(3, &flushCascadeChild{}))
+	assert.Equal(t, 0, engine.trackedEntities.len())
+
+	engine.SetTrackLimit(1)
+	engine.EnableTrackAutoFlush()
+	flushed = make(chan struct{})
+	go func() {
+		defer close(flushed)
+		engine.MarkToDelete(other)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "track auto flush with cascade delete blocked")
+	}
+	assert.False(t, engine.LoadByID(2, &flushCascadeParent{}))
+	assert.False(t, engine.LoadByID(3, &flushCascadeChild{}))
+}
+
+type flushEntityExpression struct {
+	ORM       `orm:"localCache"`
+	ID        uint
+	Counter   uint
+	UpdatedAt *time.Time `orm:"time"`
+}
+
+func TestFlushFieldExpressions(t *testing.T) {
+	var entity *flushEntityExpression
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	entity = &flushEntityExpression{Counter: 5}
+	engine.SetFieldExpr(entity, "UpdatedAt", ExprNow())
+	engine.Flush()
+	assert.Equal(t, uint(1), entity.ID)
+	assert.NotNil(t, entity.UpdatedAt)
+
+	engine.SetFieldExpr(entity, "Counter", "`Counter` + 2")
+	engine.Flush()
+	assert.Equal(t, uint(7), entity.Counter)
+	assert.False(t, engine.IsDirty(entity))
+
+	entity = &flushEntityExpression{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, uint(7), entity.Counter)
+	assert.NotNil(t, entity.UpdatedAt)
+
+	assert.PanicsWithError(t, "field 'Missing' in orm.flushEntityExpression not valid", func() {
+		engine.SetFieldExpr(entity, "Missing", "1")
+	})
+	engine.SetFieldExpr(entity, "Counter", "`Counter` + 1")
+	assert.PanicsWithError(t, "field expressions in lazy flush not supported", func() {
+		engine.FlushLazy()
+	})
+}
+
+type flushEntityJSON struct {
+	ORM
+	ID   uint
+	Meta interface{}
+	Name string
+}
+
+func TestJSONPathsExpression(t *testing.T) {
+	registry := &Registry{}
+	registry.sqlClients = map[string]*DBConfig{"default": {code: "default", databaseName: "test"}}
+	entityType := reflect.TypeOf(flushEntityJSON{})
+	schema, err := initTableSchema(registry, entityType)
+	assert.NoError(t, err)
+	validated := (&validatedRegistry{}).clone(registry, nil)
+	validated.sqlClients = registry.sqlClients
+	validated.tableSchemas[entityType] = schema
+	engine := validated.CreateEngine()
+
+	entity := &flushEntityJSON{ID: 1}
+	engine.SetJSONPaths(entity, "Meta", map[string]interface{}{"$.b": "x'y", "$.a[0]": 1})
+	engine.RemoveJSONPaths(entity, "Meta", "$.c")
+	assert.Equal(t, Expr("JSON_REMOVE(JSON_SET(COALESCE(`Meta`, JSON_OBJECT()), "+
+		"'$.a[0]', CAST(CONVERT(X'31' USING utf8mb4) AS JSON), '$.b', CAST(CONVERT(X'2278277922' USING utf8mb4) AS JSON)), '$.c')"),
+		entity.getORM().attributes.expressions["Meta"])
+
+	assert.PanicsWithError(t, "JSON path '$.a' OR 1' not valid", func() {
+		engine.RemoveJSONPaths(entity, "Meta", "$.a' OR 1")
+	})
+	assert.PanicsWithError(t, "JSON field 'Name' in orm.flushEntityJSON not valid", func() {
+		engine.RemoveJSONPaths(entity, "Name", "$.a")
+	})
+	assert.PanicsWithError(t, "partial JSON update of field 'Meta' in not saved orm.flushEntityJSON not valid", func() {
+		engine.RemoveJSONPaths(&flushEntityJSON{}, "Meta", "$.a")
+	})
+}
+
+func TestJSONPaths(t *testing.T) {
+	var entity *flushEntityJSON
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	entity = &flushEntityJSON{Meta: []interface{}{1}}
+	engine.TrackAndFlush(entity)
+	engine.SetJSONPaths(entity, "Meta", map[string]interface{}{"$[1]": "a"})
+	assert.True(t, engine.IsDirty(entity))
+	engine.Flush()
+	assert.Equal(t, []interface{}{float64(1), "a"}, entity.Meta)
+	assert.False(t, engine.IsDirty(entity))
+
+	engine.RemoveJSONPaths(entity, "Meta", "$[0]")
+	engine.Flush()
+	entity = &flushEntityJSON{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, []interface{}{"a"}, entity.Meta)
+}
+
+func TestIncrementField(t *testing.T) {
+	var entity *flushEntityExpression
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	entity = &flushEntityExpression{Counter: 5}
+	engine.TrackAndFlush(entity)
+	other := &flushEntityExpression{}
+	assert.True(t, engine.LoadByID(1, other))
+
+	engine.IncrementField(entity, "Counter", 2)
+	engine.IncrementField(other, "Counter", 3)
+	assert.Equal(t, uint(7), entity.Counter)
+	assert.Equal(t, uint(10), other.Counter)
+	assert.False(t, engine.IsDirty(other))
+	engine.IncrementField(other, "Counter", -4)
+	assert.Equal(t, uint(6), other.Counter)
+
+	entity = &flushEntityExpression{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, uint(6), entity.Counter)
+
+	engine.IncrementFieldLazy(entity, "Counter", 1)
+	assert.Equal(t, uint(7), entity.Counter)
+	assert.False(t, engine.IsDirty(entity))
+	receiver := NewLazyReceiver(engine)
+	receiver.DisableLoop()
+	receiver.Digest()
+	entity = &flushEntityExpression{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, uint(7), entity.Counter)
+
+	assert.PanicsWithError(t, "numeric field 'UpdatedAt' in orm.flushEntityExpression not valid", func() {
+		engine.IncrementField(entity, "UpdatedAt", 1)
+	})
+	assert.PanicsWithError(t, "increment of field 'Counter' in not saved orm.flushEntityExpression not valid", func() {
+		engine.IncrementField(&flushEntityExpression{}, "Counter", 1)
+	})
+	assert.PanicsWithError(t, "negative value of unsigned field 'Counter' in orm.flushEntityExpression not valid", func() {
+		engine.IncrementField(entity, "Counter", -8)
+	})
+	assert.PanicsWithError(t, "negative value of unsigned field 'Counter' in orm.flushEntityExpression not valid", func() {
+		engine.IncrementFieldLazy(entity, "Counter", -8)
+	})
+	assert.Equal(t, uint(7), entity.Counter)
+
+	notLoaded := &flushEntityExpression{}
+	notLoaded.ID = 1
+	engine.IncrementField(notLoaded, "Counter", -7)
+	assert.Equal(t, uint(0), notLoaded.Counter)
+	assert.True(t, engine.Loaded(notLoaded))
+}
+
+func TestFlushForcedDirty(t *testing.T) {
+	var entity *flushEntityExpression
+	engine := PrepareTables(t, &Registry{}, entity)
+	entity = &flushEntityExpression{Counter: 5}
+	engine.TrackAndFlush(entity)
+
+	DBLogger := memory.New()
+	engine.AddQueryLogger(DBLogger, apexLog.InfoLevel, QueryLoggerSourceDB)
+	engine.TrackAndFlush(entity)
+	assert.Len(t, DBLogger.Entries, 0)
+
+	engine.MarkForcedDirty(entity, "Counter")
+	assert.True(t, engine.IsDirty(entity))
+	engine.Flush()
+	assert.Len(t, DBLogger.Entries, 1)
+	assert.Contains(t, DBLogger.Entries[0].Fields["Query"], "UPDATE flushEntityExpression SET `Counter` = ? WHERE `ID` = ?")
+	assert.False(t, engine.IsDirty(entity))
+
+	engine.MarkForcedDirty(entity)
+	engine.Flush()
+	assert.Len(t, DBLogger.Entries, 2)
+	assert.PanicsWithError(t, "column 'Missing' in orm.flushEntityExpression not valid", func() {
+		engine.MarkForcedDirty(entity, "Missing")
+	})
+}
+
+func TestFlushWithContext(t *testing.T) {
+	var entity *flushEntityExpression
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	engine.Track(&flushEntityExpression{Counter: 1})
+	engine.FlushWithContext(context.Background())
+	assert.True(t, engine.LoadByID(1, &flushEntityExpression{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	engine.Track(&flushEntityExpression{Counter: 2})
+	assert.PanicsWithError(t, "context canceled", func() {
+		engine.FlushWithContext(ctx)
+	})
+	engine.ClearTrackedEntities()
+	assert.False(t, engine.LoadByID(2, &flushEntityExpression{}))
+}
+
+func TestFlushDryRun(t *testing.T) {
+	var entity *flushEntityExpression
+	engine := PrepareTables(t, &Registry{}, entity)
+	entity = &flushEntityExpression{Counter: 1}
+	engine.TrackAndFlush(entity)
+
+	DBLogger := memory.New()
+	engine.AddQueryLogger(DBLogger, apexLog.InfoLevel, QueryLoggerSourceDB)
+	entity.Counter = 2
+	newEntity := &flushEntityExpression{Counter: 3}
+	engine.Track(entity, newEntity, &flushEntityExpression{Counter: 4})
+	queries, err := engine.FlushDryRun()
+	assert.NoError(t, err)
+	assert.Len(t, DBLogger.Entries, 0)
+	assert.Len(t, queries, 2)
+	assert.Equal(t, "default", queries[0].Pool)
+	assert.Equal(t, "UPDATE flushEntityExpression SET `Counter` = ? WHERE `ID` = ?", queries[0].Query)
+	assert.Len(t, queries[0].Arguments, 2)
+	assert.Equal(t, uint64(1), queries[0].Arguments[1])
+	assert.Equal(t, "INSERT INTO flushEntityExpression(`Counter`,`UpdatedAt`) VALUES (?,?),(?,?)", queries[1].Query)
+	assert.True(t, engine.IsDirty(entity))
+	assert.Equal(t, uint64(0), newEntity.GetID())
+
+	engine.ClearTrackedEntities()
+	engine.MarkToDelete(entity)
+	queries, err = engine.FlushDryRun()
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM `flushEntityExpression` WHERE `ID` IN (?)", queries[0].Query)
+}
+
+type flushEntityDeleteByWhere struct {
+	ORM      `orm:"localCache"`
+	ID       uint
+	Age      uint         `orm:"index=Age"`
+	IndexAge *CachedQuery `query:":Age = ?"`
+}
+
+type flushEntityFakeDeleteByWhere struct {
+	ORM        `orm:"localCache"`
+	ID         uint
+	Age        uint `orm:"index=Age"`
+	FakeDelete bool
+	IndexAge   *CachedQuery `query:":Age = ?"`
+}
+
+func TestDeleteByWhere(t *testing.T) {
+	var entity *flushEntityDeleteByWhere
+	var fakeEntity *flushEntityFakeDeleteByWhere
+	engine := PrepareTables(t, &Registry{}, entity, fakeEntity)
+	engine.TrackAndFlush(&flushEntityDeleteByWhere{Age: 10}, &flushEntityDeleteByWhere{Age: 10}, &flushEntityDeleteByWhere{Age: 20},
+		&flushEntityFakeDeleteByWhere{Age: 10}, &flushEntityFakeDeleteByWhere{Age: 20})
+
+	var rows []*flushEntityDeleteByWhere
+	assert.Equal(t, 2, engine.CachedSearch(&rows, "IndexAge", nil, 10))
+	assert.True(t, engine.LoadByID(1, &flushEntityDeleteByWhere{}))
+	deleted, err := engine.DeleteByWhere(&flushEntityDeleteByWhere{}, NewWhere("`Age` = ?", 10))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.Equal(t, 0, engine.CachedSearch(&rows, "IndexAge", nil, 10))
+	assert.False(t, engine.LoadByID(1, &flushEntityDeleteByWhere{}))
+	assert.True(t, engine.LoadByID(3, &flushEntityDeleteByWhere{}))
+
+	var fakeRows []*flushEntityFakeDeleteByWhere
+	assert.Equal(t, 1, engine.CachedSearch(&fakeRows, "IndexAge", nil, 10))
+	deleted, err = engine.DeleteByWhere(&flushEntityFakeDeleteByWhere{}, NewWhere("`Age` = ?", 10))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Equal(t, 0, engine.CachedSearch(&fakeRows, "IndexAge", nil, 10))
+	fakeEntity = &flushEntityFakeDeleteByWhere{}
+	assert.True(t, engine.LoadByID(1, fakeEntity))
+	assert.True(t, fakeEntity.FakeDelete)
+
+	_, err = engine.DeleteByWhere(&flushEntityDeleteByWhere{}, NewWhere("`Missing` = ?", 10))
+	assert.Error(t, err)
+}
+
+func TestUpdateByWhere(t *testing.T) {
+	var entity *flushEntityDeleteByWhere
+	engine := PrepareTables(t, &Registry{}, entity)
+	engine.TrackAndFlush(&flushEntityDeleteByWhere{Age: 10}, &flushEntityDeleteByWhere{Age: 10}, &flushEntityDeleteByWhere{Age: 10},
+		&flushEntityDeleteByWhere{Age: 20})
+
+	var rows []*flushEntityDeleteByWhere
+	assert.Equal(t, 3, engine.CachedSearch(&rows, "IndexAge", nil, 10))
+	assert.True(t, engine.LoadByID(1, &flushEntityDeleteByWhere{}))
+	updated := engine.UpdateByWhere(&flushEntityDeleteByWhere{}, map[string]interface{}{"Age": 30}, NewWhere("`Age` = ? AND `ID` < ?", 10, 3))
+	assert.Equal(t, int64(2), updated)
+	assert.Equal(t, 1, engine.CachedSearch(&rows, "IndexAge", nil, 10))
+	assert.Equal(t, 2, engine.CachedSearch(&rows, "IndexAge", nil, 30))
+	entity = &flushEntityDeleteByWhere{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, uint(30), entity.Age)
+
+	updated = engine.UpdateByWhereInChunks(&flushEntityDeleteByWhere{}, map[string]interface{}{"Age": 40}, NewWhere("1"), 3)
+	assert.Equal(t, int64(4), updated)
+	assert.Equal(t, 4, engine.CachedSearch(&rows, "IndexAge", nil, 40))
+	assert.PanicsWithError(t, "column 'ID' in orm.flushEntityDeleteByWhere not valid", func() {
+		engine.UpdateByWhere(&flushEntityDeleteByWhere{}, map[string]interface{}{"ID": 40}, NewWhere("1"))
+	})
+
+	updated = engine.UpdateByWhereInChunks(&flushEntityDeleteByWhere{}, map[string]interface{}{"Age": 50},
+		NewWhere("`Age` = ? ORDER BY `Age` DESC LIMIT ?", 40, 3), 2)
+	assert.Equal(t, int64(3), updated)
+	assert.Equal(t, 3, engine.CachedSearch(&rows, "IndexAge", nil, 50))
+	assert.PanicsWithError(t, "ORDER BY with LIMIT in update by where not supported", func() {
+		engine.UpdateByWhere(&flushEntityDeleteByWhere{}, map[string]interface{}{"Age": 60}, NewWhere("1 ORDER BY `ID` DESC LIMIT 1"))
+	})
+}
+
+func TestFlushLazyAfter(t *testing.T) {
+	var entity *flushEntityExpression
+	engine := PrepareTables(t, &Registry{}, entity)
+	entity = &flushEntityExpression{Counter: 1}
+	engine.TrackAndFlush(entity)
+
+	entity.Counter = 2
+	engine.Track(entity)
+	engine.FlushLazyAfter(time.Millisecond * 100)
+	entity.Counter = 3
+	engine.Track(entity)
+	engine.FlushLazyAfter(time.Millisecond * 100)
+	assert.False(t, engine.IsDirty(entity))
+
+	receiver := NewLazyReceiver(engine)
+	receiver.DisableLoop()
+	receiver.Digest()
+	entity = &flushEntityExpression{}
+	engine.GetLocalCache().Clear()
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, uint(1), entity.Counter)
+
+	time.Sleep(time.Millisecond * 200)
+	receiver.Digest()
+	engine.GetLocalCache().Clear()
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, uint(3), entity.Counter)
+}
+
+func TestFlushLazyAfterMixedDelays(t *testing.T) {
+	var entity *flushEntityExpression
+	engine := PrepareTables(t, &Registry{}, entity)
+	slow := &flushEntityExpression{Counter: 1}
+	fast := &flushEntityExpression{Counter: 1}
+	engine.TrackAndFlush(slow, fast)
+
+	slow.Counter = 2
+	engine.Track(slow)
+	engine.FlushLazyAfter(time.Hour)
+	fast.Counter = 2
+	engine.Track(fast)
+	engine.FlushLazyAfter(time.Millisecond * 100)
+
+	time.Sleep(time.Millisecond * 300)
+	receiver := NewLazyReceiver(engine)
+	receiver.DisableLoop()
+	receiver.Digest()
+	engine.GetLocalCache().Clear()
+	fast = &flushEntityExpression{}
+	assert.True(t, engine.LoadByID(2, fast))
+	assert.Equal(t, uint(2), fast.Counter)
+	slow = &flushEntityExpression{}
+	assert.True(t, engine.LoadByID(1, slow))
+	assert.Equal(t, uint(1), slow.Counter)
+}
+
+func TestLazyDelayBucket(t *testing.T) {
+	assert.Equal(t, 100*time.Millisecond, getLazyDelayBucket(0))
+	assert.Equal(t, 100*time.Millisecond, getLazyDelayBucket(time.Millisecond))
+	assert.Equal(t, 200*time.Millisecond, getLazyDelayBucket(101*time.Millisecond))
+	assert.Equal(t, time.Second, getLazyDelayBucket(950*time.Millisecond))
+	assert.Equal(t, 2*time.Second, getLazyDelayBucket(1500*time.Millisecond))
+	assert.Equal(t, 5*time.Minute, getLazyDelayBucket(5*time.Minute))
+	assert.Equal(t, 2*time.Hour, getLazyDelayBucket(61*time.Minute))
+}
+
+func TestFlushLazyAfterMergeFields(t *testing.T) {
+	var entity *flushEntityReference
+	engine := PrepareTables(t, &Registry{}, entity)
+	entity = &flushEntityReference{Name: "a", Age: 1}
+	engine.TrackAndFlush(entity)
+
+	entity.Name = "b"
+	engine.Track(entity)
+	engine.FlushLazyAfter(time.Millisecond * 100)
+	entity.Age = 2
+	engine.Track(entity)
+	engine.FlushLazyAfter(time.Millisecond * 100)
+
+	time.Sleep(time.Millisecond * 200)
+	receiver := NewLazyReceiver(engine)
+	receiver.DisableLoop()
+	receiver.Digest()
+	entity = &flushEntityReference{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, "b", entity.Name)
+	assert.Equal(t, 2, entity.Age)
+}
+
+func TestTrackLimit(t *testing.T) {
+	var entity *flushEntityExpression
+	registry := &Registry{}
+	registry.SetTrackLimit(3)
+	engine := PrepareTables(t, registry, entity)
+
+	engine.Track(&flushEntityExpression{}, &flushEntityExpression{})
+	err := engine.TrackWithCheck(&flushEntityExpression{})
+	assert.EqualError(t, err, "track limit 3 exceeded")
+	assert.Equal(t, 3, err.(*TrackLimitError).Limit)
+	engine.ClearTrackedEntities()
+
+	engine.SetTrackLimit(2)
+	engine.EnableTrackAutoFlush()
+	engine.Track(&flushEntityExpression{}, &flushEntityExpression{}, &flushEntityExpression{})
+	assert.True(t, engine.LoadByID(2, &flushEntityExpression{}))
+	assert.False(t, engine.LoadByID(3, &flushEntityExpression{}))
+	engine.Flush()
+	assert.True(t, engine.LoadByID(3, &flushEntityExpression{}))
+}
@@ -0,0 +1,96 @@
+package orm
+
+import (
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+type Flusher struct {
+	engine               *Engine
+	limit                int
+	autoFlush            bool
+	disableDeduplication bool
+	entities             entityTracker
+	mutex                sync.Mutex
+}
+
+func (e *Engine) NewFlusher(limit int, autoFlush bool) *Flusher {
+	return &Flusher{engine: e, limit: limit, autoFlush: autoFlush, disableDeduplication: e.disableTrackDeduplication}
+}
+
+func (f *Flusher) DisableDeduplication() {
+	f.disableDeduplication = true
+}
+
+func (f *Flusher) Track(entity ...Entity) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, entity := range entity {
+		initIfNeeded(f.engine, entity)
+		if f.entities.add(entity, !f.disableDeduplication) && f.limit > 0 && f.entities.len() >= f.limit {
+			if !f.autoFlush {
+				panic(errors.Errorf("flusher limit %d exceeded", f.limit))
+			}
+			f.flush(false, false)
+		}
+	}
+}
+
+func (f *Flusher) MarkToDelete(entity ...Entity) {
+	for _, row := range entity {
+		orm := initIfNeeded(f.engine, row)
+		if orm.tableSchema.hasFakeDelete {
+			orm.attributes.elem.FieldByName("FakeDelete").SetBool(true)
+		} else {
+			orm.attributes.delete = true
+		}
+		f.Track(row)
+	}
+}
+
+func (f *Flusher) Flush() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.flush(false, false)
+}
+
+func (f *Flusher) FlushWithCheck() error {
+	return catchFlushError(f.Clear, f.Flush)
+}
+
+func (f *Flusher) FlushInTransaction() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.flush(false, true)
+}
+
+func (f *Flusher) FlushInTransactionWithCheck() error {
+	return catchFlushError(f.Clear, f.FlushInTransaction)
+}
+
+func (f *Flusher) FlushLazy() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.flush(true, false)
+}
+
+func (f *Flusher) Clear() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.entities.clear()
+}
+
+func (f *Flusher) Len() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.entities.len()
+}
+
+func (f *Flusher) flush(lazy bool, transaction bool) {
+	if f.entities.len() == 0 {
+		return
+	}
+	f.engine.flushEntities(lazy, transaction, f.entities.entities...)
+	f.entities.clear()
+}
@@ -1,6 +1,6 @@
 module github.com/summer-solutions/orm
 
-go 1.14
+go 1.16
 
 require (
 	github.com/ClickHouse/clickhouse-go v1.4.0
@@ -21,11 +21,12 @@ require (
 	github.com/philhofer/fwd v1.0.0 // indirect
 	github.com/pkg/errors v0.9.1
 	github.com/segmentio/fasthash v1.0.2
+	github.com/shopspring/decimal v1.2.0
 	github.com/streadway/amqp v0.0.0-20200108173154-1c71cc93ed71
 	github.com/stretchr/testify v1.5.1
 	github.com/tinylib/msgp v1.1.2 // indirect
 	golang.org/x/time v0.0.0-20200416051211-89c76fbcd5d1 // indirect
 	golang.org/x/tools v0.0.0-20200713011307-fd294ab11aed // indirect
 	gopkg.in/DataDog/dd-trace-go.v1 v1.24.1
-	gopkg.in/yaml.v2 v2.3.0 // indirect
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,183 @@
+package orm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const snowflakeEpoch = int64(1577836800000)
+const shardIDMask = uint64(1)<<shardIDBits - 1
+
+type IDGenerator interface {
+	NextID(engine *Engine, schema TableSchema) uint64
+}
+
+// sharded entities keep shard in top 16 bits of ID, so snowflake uses shorter layout for them:
+// 30 bits of seconds, 10 bits of node and 8 bits of sequence
+const snowflakeShardedSequenceBits = 8
+
+type snowflakeIDGenerator struct {
+	mutex   sync.Mutex
+	node    uint64
+	clock   snowflakeClock
+	sharded snowflakeClock
+}
+
+type snowflakeClock struct {
+	last     int64
+	sequence uint64
+}
+
+func (c *snowflakeClock) next(unit time.Duration, sequenceMask uint64) (int64, uint64) {
+	now := time.Now().UnixNano() / int64(unit)
+	if now < c.last {
+		now = c.last
+	}
+	if now == c.last {
+		c.sequence = (c.sequence + 1) & sequenceMask
+		if c.sequence == 0 {
+			for now <= c.last {
+				time.Sleep(time.Millisecond)
+				now = time.Now().UnixNano() / int64(unit)
+			}
+		}
+	} else {
+		c.sequence = 0
+	}
+	c.last = now
+	return now, c.sequence
+}
+
+func NewSnowflakeIDGenerator(node uint16) IDGenerator {
+	if node > 1023 {
+		panic(errors.NotValidf("snowflake node %d", node))
+	}
+	return &snowflakeIDGenerator{node: uint64(node)}
+}
+
+func (g *snowflakeIDGenerator) NextID(_ *Engine, schema TableSchema) uint64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if isShardedSchema(schema) {
+		now, sequence := g.sharded.next(time.Second, 1<<snowflakeShardedSequenceBits-1)
+		id := uint64(now-snowflakeEpoch/1000)<<(10+snowflakeShardedSequenceBits) | g.node<<snowflakeShardedSequenceBits | sequence
+		return id & shardIDMask
+	}
+	now, sequence := g.clock.next(time.Millisecond, 4095)
+	return uint64(now-snowflakeEpoch)<<22 | g.node<<12 | sequence
+}
+
+func isShardedSchema(schema TableSchema) bool {
+	asTableSchema, is := schema.(*tableSchema)
+	return is && asTableSchema.shard != nil
+}
+
+// creates sequence starting from MAX(ID) when it is missing in redis
+const idSequenceSeedScript = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return redis.call('INCRBY', KEYS[1], ARGV[2])
+`
+
+type redisIDGenerator struct {
+	mutex     sync.Mutex
+	redisPool string
+	blockSize uint64
+	next      map[string]uint64
+	max       map[string]uint64
+}
+
+func NewRedisIDGenerator(redisPool string) IDGenerator {
+	return NewBlockIDGenerator(redisPool, 1)
+}
+
+func NewBlockIDGenerator(redisPool string, blockSize uint64) IDGenerator {
+	if blockSize == 0 {
+		panic(errors.NotValidf("block size 0"))
+	}
+	return &redisIDGenerator{redisPool: redisPool, blockSize: blockSize, next: make(map[string]uint64), max: make(map[string]uint64)}
+}
+
+func (g *redisIDGenerator) NextID(engine *Engine, schema TableSchema) uint64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	name := schema.GetTableName()
+	if g.next[name] == 0 || g.next[name] > g.max[name] {
+		redis := engine.GetRedis(g.redisPool)
+		key := "_orm_id:" + name
+		var max uint64
+		if _, has := g.max[name]; has {
+			max = uint64(redis.IncrBy(key, int64(g.blockSize)))
+		} else {
+			max = uint64(redis.eval(idSequenceSeedScript, []string{key}, getMaxGeneratedID(engine, schema), g.blockSize).(int64))
+		}
+		g.max[name] = max
+		g.next[name] = max - g.blockSize + 1
+	}
+	id := g.next[name]
+	g.next[name]++
+	return id
+}
+
+func getMaxGeneratedID(engine *Engine, schema TableSchema) uint64 {
+	asTableSchema, is := schema.(*tableSchema)
+	if !is {
+		return 0
+	}
+	var max uint64
+	if asTableSchema.shard == nil {
+		/* #nosec */
+		asTableSchema.GetMysql(engine).QueryRow(NewWhere("SELECT IFNULL(MAX(`ID`), 0) FROM "+asTableSchema.getTableName(engine)), &max)
+		return max
+	}
+	for _, pool := range asTableSchema.shard.pools {
+		engine.withShardPool(asTableSchema, pool, func() {
+			var poolMax uint64
+			/* #nosec */
+			query := NewWhere("SELECT IFNULL(MAX(`ID` & ?), 0) FROM "+asTableSchema.getTableName(engine), shardIDMask)
+			asTableSchema.GetMysql(engine).QueryRow(query, &poolMax)
+			if poolMax > max {
+				max = poolMax
+			}
+		})
+	}
+	return max
+}
+
+func (r *Registry) RegisterIDGenerator(code string, generator IDGenerator) {
+	if r.idGenerators == nil {
+		r.idGenerators = make(map[string]IDGenerator)
+	}
+	r.idGenerators[code] = generator
+}
+
+func hasPendingID(entity Entity) bool {
+	orm := entity.getORM()
+	return entity.GetID() == 0 || (orm.attributes != nil && orm.attributes.generatedID)
+}
+
+func assignGeneratedID(engine *Engine, entity Entity) {
+	orm := entity.getORM()
+	schema := orm.tableSchema
+	if schema.idGenerator == nil || orm.attributes.idElem.Uint() > 0 || orm.attributes.delete {
+		return
+	}
+	id := schema.idGenerator.NextID(engine, schema)
+	if schema.shard != nil {
+		if id > shardIDMask {
+			panic(errors.NotValidf("generated ID %d for sharded entity %s", id, schema.t.String()))
+		}
+		pool := schema.shard.poolForEntity(entity)
+		for shard, name := range schema.shard.pools {
+			if name == pool {
+				id = uint64(shard)<<shardIDBits | id
+				break
+			}
+		}
+	}
+	orm.attributes.idElem.SetUint(id)
+	orm.attributes.generatedID = true
+}
@@ -0,0 +1,130 @@
+package orm
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+func incrementField(engine *Engine, entity Entity, field string, delta int64, lazy bool) {
+	orm := initIfNeeded(engine, entity)
+	schema := orm.tableSchema
+	id := entity.GetID()
+	if id == 0 {
+		panic(errors.NotValidf("increment of field '%s' in not saved %s", field, schema.t.String()))
+	}
+	structField, isField := schema.t.FieldByName(field)
+	if field == "ID" || !isField || !hasColumn(schema, field) || !isNumericKind(structField.Type.Kind()) {
+		panic(errors.NotValidf("numeric field '%s' in %s", field, schema.t.String()))
+	}
+	if schema.shard != nil {
+		engine.withShardPool(schema, schema.shard.poolForEntity(entity), func() {
+			incrementFieldInPool(engine, entity, field, delta, lazy)
+		})
+		return
+	}
+	incrementFieldInPool(engine, entity, field, delta, lazy)
+}
+
+func incrementFieldInPool(engine *Engine, entity Entity, field string, delta int64, lazy bool) {
+	orm := entity.getORM()
+	schema := orm.tableSchema
+	id := entity.GetID()
+	if !engine.Loaded(entity) && !engine.LoadByID(id, entity) {
+		panic(errors.NotFoundf("%s with ID %d", schema.t.String(), id))
+	}
+	value := orm.attributes.elem.FieldByName(field)
+	unsigned := isUnsignedKind(value.Kind())
+	tableName := schema.getTableName(engine)
+	/* #nosec */
+	sql := "UPDATE `" + tableName + "` SET `" + field + "` = `" + field + "` + ? WHERE `ID` = ?"
+	arguments := []interface{}{delta, id}
+	if unsigned && delta < 0 {
+		sql += " AND `" + field + "` >= ?"
+		arguments = append(arguments, -delta)
+	}
+	db := schema.GetMysql(engine)
+	phase := startFlushPhase(engine, "update")
+	lazyMap := make(map[string]interface{})
+	var fresh string
+	if lazy {
+		if unsigned && delta < 0 && value.Uint() < uint64(-delta) {
+			panic(errors.NotValidf("negative value of unsigned field '%s' in %s", field, schema.t.String()))
+		}
+		fillLazyQuery(lazyMap, db.GetPoolCode(), sql, arguments, nil)
+		fresh = incrementedValue(value, delta)
+	} else {
+		affected := db.Exec(sql, arguments...).RowsAffected()
+		if !db.QueryRow(NewWhere("SELECT `"+field+"` FROM `"+tableName+"` WHERE `ID` = ?", id), &fresh) {
+			panic(errors.NotFoundf("%s with ID %d", schema.t.String(), id))
+		}
+		if affected == 0 && unsigned && delta < 0 {
+			panic(errors.NotValidf("negative value of unsigned field '%s' in %s", field, schema.t.String()))
+		}
+	}
+	old := orm.dBData[field]
+	setFieldFromString(orm, field, fresh)
+	orm.dBData[field] = old
+
+	bind := map[string]interface{}{field: fresh}
+	localCacheDeletes := make(map[string]map[string]bool)
+	redisKeysToDelete := make(map[string]map[string]bool)
+	dirtyQueues := make(map[string][]*DirtyQueueValue)
+	// entity can have other not flushed changes, so cached entity is removed instead of replaced
+	logQueues := updateCacheAfterUpdate(orm.dBData, engine, entity, bind, schema, make(map[string]map[string][]interface{}),
+		localCacheDeletes, db, id, redisKeysToDelete, dirtyQueues, make([]*LogQueueValue, 0))
+	if localCache, has := schema.GetLocalCache(engine); has {
+		addCacheDeletes(localCacheDeletes, localCache.code, schema.getCacheKey(id))
+	}
+	phase = flushCacheAndQueues(engine, phase, lazy, db.inTransaction(), lazyMap, nil, localCacheDeletes,
+		redisKeysToDelete, dirtyQueues, logQueues)
+	phase.finish()
+}
+
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func isUnsignedKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func incrementedValue(value reflect.Value, delta int64) string {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int()+delta, 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(uint64(int64(value.Uint())+delta), 10)
+	default:
+		return strconv.FormatFloat(value.Float()+float64(delta), 'f', -1, 64)
+	}
+}
+
+func setFieldFromString(orm *ORM, field string, value string) {
+	target := orm.attributes.elem.FieldByName(field)
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, _ := strconv.ParseInt(value, 10, 64)
+		target.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, _ := strconv.ParseUint(value, 10, 64)
+		target.SetUint(parsed)
+	default:
+		parsed, _ := strconv.ParseFloat(value, 64)
+		target.SetFloat(parsed)
+	}
+	if orm.dBData != nil {
+		orm.dBData[field] = value
+	}
+}
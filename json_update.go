@@ -0,0 +1,73 @@
+package orm
+
+import (
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/juju/errors"
+)
+
+var jsonPathPattern = regexp.MustCompile(`^\$(\.[A-Za-z0-9_]+|\[[0-9]+\])+$`)
+
+func setJSONPaths(engine *Engine, entity Entity, field string, values map[string]interface{}) {
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	arguments := make([]string, 0, len(paths)*2)
+	for _, path := range paths {
+		asJSON, err := jsoniter.ConfigFastest.Marshal(values[path])
+		if err != nil {
+			panic(errors.Annotatef(err, "invalid value for JSON path '%s'", path))
+		}
+		arguments = append(arguments, jsonPathLiteral(path), "CAST(CONVERT(X'"+hex.EncodeToString(asJSON)+"' USING utf8mb4) AS JSON)")
+	}
+	addJSONExpression(engine, entity, field, "JSON_SET", arguments)
+}
+
+func removeJSONPaths(engine *Engine, entity Entity, field string, paths []string) {
+	arguments := make([]string, len(paths))
+	for i, path := range paths {
+		arguments[i] = jsonPathLiteral(path)
+	}
+	addJSONExpression(engine, entity, field, "JSON_REMOVE", arguments)
+}
+
+func addJSONExpression(engine *Engine, entity Entity, field string, function string, arguments []string) {
+	orm := initIfNeeded(engine, entity)
+	schema := orm.tableSchema
+	if !isJSONField(schema, field) {
+		panic(errors.NotValidf("JSON field '%s' in %s", field, schema.t.String()))
+	}
+	if entity.GetID() == 0 {
+		panic(errors.NotValidf("partial JSON update of field '%s' in not saved %s", field, schema.t.String()))
+	}
+	if len(arguments) == 0 {
+		return
+	}
+	document := "COALESCE(`" + field + "`, JSON_OBJECT())"
+	if previous, has := orm.attributes.expressions[field]; has {
+		document = string(previous)
+	}
+	setFieldExpr(engine, entity, field, Expr(function+"("+document+", "+strings.Join(arguments, ", ")+")"))
+}
+
+func isJSONField(schema *tableSchema, field string) bool {
+	for _, i := range schema.fields.jsons {
+		if schema.fields.fields[i].Name == field {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonPathLiteral(path string) string {
+	if !jsonPathPattern.MatchString(path) {
+		panic(errors.NotValidf("JSON path '%s'", path))
+	}
+	return "'" + path + "'"
+}
@@ -0,0 +1,252 @@
+package orm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const lazyDelayedQueueName = "lazy_queue_delayed"
+const lazyDelayedKeyPrefix = "_lazy_delayed:"
+
+// ARGV: ttl, number of fields to remove, number of queries, fields to remove, queries, field value pairs
+// returns 1 when delayed message must be published
+const lazyDelayedMergeScript = `
+local removed = tonumber(ARGV[2])
+local queries = tonumber(ARGV[3])
+local i = 4
+for j = 1, removed do
+	redis.call('HDEL', KEYS[1], ARGV[i])
+	i = i + 1
+end
+for j = 1, queries do
+	local n = redis.call('HINCRBY', KEYS[1], 'n', 1)
+	redis.call('HSET', KEYS[1], 'q:' .. n, ARGV[i])
+	i = i + 1
+end
+while i < #ARGV do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	i = i + 2
+end
+redis.call('EXPIRE', KEYS[1], ARGV[1])
+return redis.call('HSETNX', KEYS[1], 's', 1)
+`
+
+const lazyDelayedPopScript = `
+local data = redis.call('HGETALL', KEYS[1])
+redis.call('DEL', KEYS[1])
+return data
+`
+
+type delayedLazyUpdate struct {
+	pool        string
+	table       string
+	id          uint64
+	columns     []string
+	holders     []string
+	values      []interface{}
+	redacted    map[string]bool
+	expressions map[string]string
+}
+
+func newDelayedLazyUpdate(schema *tableSchema, engine *Engine, pool string, id uint64, bind map[string]interface{},
+	expressionKeys []string, expressionValues []string) *delayedLazyUpdate {
+	update := &delayedLazyUpdate{pool: pool, table: schema.getTableName(engine), id: id, redacted: schema.redactedColumns,
+		expressions: make(map[string]string, len(expressionKeys))}
+	for column, value := range bind {
+		update.columns = append(update.columns, column)
+		update.holders = append(update.holders, schema.placeholder(column))
+		update.values = append(update.values, value)
+	}
+	for i, column := range expressionKeys {
+		update.expressions[column] = expressionValues[i]
+	}
+	return update
+}
+
+func flushLazyAfter(engine *Engine, delay time.Duration) {
+	entities := engine.takeTrackedEntities()
+	engine.flushMutex.Lock()
+	defer engine.flushMutex.Unlock()
+	flushed := 0
+	defer func() {
+		engine.lazyDelay = 0
+		engine.lazyDelayKey = ""
+		if flushed < len(entities) {
+			engine.restoreTrackedEntities(entities[flushed:])
+		}
+	}()
+	engine.lazyDelay = delay
+	for _, entity := range entities {
+		engine.lazyDelayKey = ""
+		id := entity.GetID()
+		if id > 0 {
+			engine.lazyDelayKey = entity.getORM().tableSchema.getCacheKey(id)
+		}
+		flush(engine, true, false, entity)
+		flushed++
+	}
+}
+
+// getLazyDelayBucket rounds delay up, so number of delayed queues stays small
+func getLazyDelayBucket(delay time.Duration) time.Duration {
+	unit := time.Hour
+	switch {
+	case delay < time.Second:
+		unit = 100 * time.Millisecond
+	case delay < time.Minute:
+		unit = time.Second
+	case delay < time.Hour:
+		unit = time.Minute
+	}
+	if delay < unit {
+		return unit
+	}
+	return (delay + unit - 1) / unit * unit
+}
+
+func publishDelayedLazy(engine *Engine, lazyMap map[string]interface{}) {
+	if engine.lazyDelayKey == "" {
+		engine.GetRabbitMQQueue(lazyDelayedQueueName).publishDelayed(serializeForLazyQueue(lazyMap), engine.lazyDelay)
+		return
+	}
+	ttl := int(engine.lazyDelay.Seconds()) + 3600
+	args := buildDelayedLazyArgs(ttl, lazyMap)
+	scheduled := engine.GetRedis().eval(lazyDelayedMergeScript, []string{lazyDelayedKeyPrefix + engine.lazyDelayKey}, args...)
+	if scheduled.(int64) == 0 {
+		return
+	}
+	body, _ := jsoniter.ConfigFastest.Marshal(map[string]interface{}{"d": engine.lazyDelayKey})
+	engine.GetRabbitMQQueue(lazyDelayedQueueName).publishDelayed(body, engine.lazyDelay)
+}
+
+// every changed column is stored as separate hash field so flushes in the same delay window are merged
+func buildDelayedLazyArgs(ttl int, lazyMap map[string]interface{}) []interface{} {
+	removed := make([]interface{}, 0)
+	queries := make([]interface{}, 0)
+	pairs := make([]interface{}, 0)
+	update, hasUpdate := lazyMap["u"].(*delayedLazyUpdate)
+	if hasUpdate {
+		pairs = append(pairs, "p", update.pool, "t", update.table, "i", strconv.FormatUint(update.id, 10))
+		for i, column := range update.columns {
+			value, _ := jsoniter.ConfigFastest.MarshalToString([]interface{}{update.holders[i], update.values[i]})
+			pairs = append(pairs, "f:"+column, value)
+			removed = append(removed, "e:"+column)
+			if update.redacted[column] {
+				pairs = append(pairs, "r:"+column, "1")
+			} else {
+				removed = append(removed, "r:"+column)
+			}
+		}
+		for column, expression := range update.expressions {
+			pairs = append(pairs, "e:"+column, expression)
+			removed = append(removed, "f:"+column, "r:"+column)
+		}
+	}
+	if lazyQueries, has := lazyMap["q"]; has {
+		for _, query := range lazyQueries.([]interface{}) {
+			asJSON, _ := jsoniter.ConfigFastest.MarshalToString(query)
+			queries = append(queries, asJSON)
+		}
+	}
+	for _, cacheType := range []string{"cl", "cr"} {
+		if deletes, has := lazyMap[cacheType]; has {
+			for code, keys := range deletes.(map[string][]string) {
+				for _, key := range keys {
+					pairs = append(pairs, cacheType+":"+code+":"+key, "1")
+				}
+			}
+		}
+	}
+	args := make([]interface{}, 0, 3+len(removed)+len(queries)+len(pairs))
+	args = append(args, ttl, len(removed), len(queries))
+	args = append(args, removed...)
+	args = append(args, queries...)
+	return append(args, pairs...)
+}
+
+func getDelayedLazyData(engine *Engine, key string) map[string]interface{} {
+	result := engine.GetRedis().eval(lazyDelayedPopScript, []string{lazyDelayedKeyPrefix + key})
+	values, _ := result.([]interface{})
+	if len(values) == 0 {
+		return nil
+	}
+	fields := make(map[string]string, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		fields[values[i].(string)] = values[i+1].(string)
+	}
+	return buildDelayedLazyData(fields)
+}
+
+func buildDelayedLazyData(fields map[string]string) map[string]interface{} {
+	data := make(map[string]interface{})
+	queryNumbers := make([]int, 0)
+	columns := make([]string, 0)
+	for field := range fields {
+		switch {
+		case strings.HasPrefix(field, "q:"):
+			number, _ := strconv.Atoi(field[2:])
+			queryNumbers = append(queryNumbers, number)
+		case strings.HasPrefix(field, "f:"), strings.HasPrefix(field, "e:"):
+			columns = append(columns, field)
+		case strings.HasPrefix(field, "cl:"), strings.HasPrefix(field, "cr:"):
+			parts := strings.SplitN(field, ":", 3)
+			if len(parts) < 3 {
+				continue
+			}
+			deletes, has := data[parts[0]].(map[string]interface{})
+			if !has {
+				deletes = make(map[string]interface{})
+				data[parts[0]] = deletes
+			}
+			keys, _ := deletes[parts[1]].([]interface{})
+			deletes[parts[1]] = append(keys, parts[2])
+		}
+	}
+	sort.Ints(queryNumbers)
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i][2:] < columns[j][2:]
+	})
+	queries := make([]interface{}, 0, len(queryNumbers)+1)
+	for _, number := range queryNumbers {
+		var query interface{}
+		_ = jsoniter.ConfigFastest.UnmarshalFromString(fields["q:"+strconv.Itoa(number)], &query)
+		queries = append(queries, query)
+	}
+	if len(columns) > 0 {
+		sets := make([]string, len(columns))
+		args := make([]interface{}, 0, len(columns)+1)
+		redacted := make([]interface{}, 0)
+		for i, field := range columns {
+			column := field[2:]
+			if field[0] == 'e' {
+				sets[i] = fmt.Sprintf("`%s` = %s", column, fields[field])
+				continue
+			}
+			var value []interface{}
+			_ = jsoniter.ConfigFastest.UnmarshalFromString(fields[field], &value)
+			sets[i] = fmt.Sprintf("`%s` = %s", column, value[0])
+			if fields["r:"+column] != "" {
+				redacted = append(redacted, float64(len(args)))
+			}
+			args = append(args, value[1])
+		}
+		id, _ := strconv.ParseUint(fields["i"], 10, 64)
+		args = append(args, id)
+		/* #nosec */
+		sql := fmt.Sprintf("UPDATE %s SET %s WHERE `ID` = ?", fields["t"], strings.Join(sets, ","))
+		query := []interface{}{fields["p"], sql, args}
+		if len(redacted) > 0 {
+			query = append(query, redacted)
+		}
+		queries = append(queries, query)
+	}
+	if len(queries) > 0 {
+		data["q"] = queries
+	}
+	return data
+}
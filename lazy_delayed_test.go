@@ -0,0 +1,52 @@
+package orm
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mergeDelayedLazyArgs(hash map[string]string, args []interface{}) {
+	removed := args[1].(int)
+	queries := args[2].(int)
+	i := 3
+	for j := 0; j < removed; j++ {
+		delete(hash, args[i].(string))
+		i++
+	}
+	for j := 0; j < queries; j++ {
+		n, _ := strconv.Atoi(hash["n"])
+		hash["n"] = strconv.Itoa(n + 1)
+		hash["q:"+hash["n"]] = args[i].(string)
+		i++
+	}
+	for ; i+1 < len(args); i += 2 {
+		hash[args[i].(string)] = args[i+1].(string)
+	}
+}
+
+func TestDelayedLazyMerge(t *testing.T) {
+	schema := &tableSchema{redactedColumns: map[string]bool{"Password": true}}
+	hash := make(map[string]string)
+	first := &delayedLazyUpdate{pool: "default", table: "`user`", id: 7, columns: []string{"Name", "Password"},
+		holders: []string{"?", "?"}, values: []interface{}{"John", "secret"}, redacted: schema.redactedColumns}
+	mergeDelayedLazyArgs(hash, buildDelayedLazyArgs(10, map[string]interface{}{"u": first,
+		"cl": map[string][]string{"default": {"user:7"}}}))
+	second := &delayedLazyUpdate{pool: "default", table: "`user`", id: 7, columns: []string{"Age"},
+		holders: []string{"?"}, values: []interface{}{33}, redacted: schema.redactedColumns,
+		expressions: map[string]string{"Counter": "`Counter` + 1"}}
+	mergeDelayedLazyArgs(hash, buildDelayedLazyArgs(10, map[string]interface{}{"u": second,
+		"q": []interface{}{[]interface{}{"default", "DELETE FROM `log` WHERE `ID` = ?", []interface{}{1}}}}))
+
+	data := buildDelayedLazyData(hash)
+	queries := data["q"].([]interface{})
+	assert.Len(t, queries, 2)
+	assert.Equal(t, "DELETE FROM `log` WHERE `ID` = ?", queries[0].([]interface{})[1])
+	update := queries[1].([]interface{})
+	assert.Equal(t, "default", update[0])
+	assert.Equal(t, "UPDATE `user` SET `Age` = ?,`Counter` = `Counter` + 1,`Name` = ?,`Password` = ? WHERE `ID` = ?", update[1])
+	assert.Equal(t, []interface{}{float64(33), "John", "secret", uint64(7)}, update[2])
+	assert.Equal(t, []interface{}{float64(2)}, update[3])
+	assert.Equal(t, map[string]interface{}{"default": []interface{}{"user:7"}}, data["cl"])
+}
@@ -39,6 +39,13 @@ func (r *LazyReceiver) Digest() {
 			var data interface{}
 			_ = jsoniter.ConfigFastest.Unmarshal(item, &data)
 			validMap := data.(map[string]interface{})
+			delayedKey, isDelayed := validMap["d"]
+			if isDelayed {
+				validMap = getDelayedLazyData(r.engine, delayedKey.(string))
+				if validMap == nil {
+					continue
+				}
+			}
 			r.handleQueries(r.engine, validMap)
 			r.handleClearCache(validMap, "cl")
 			r.handleClearCache(validMap, "cr")
@@ -56,6 +63,14 @@ func (r *LazyReceiver) handleQueries(engine *Engine, validMap map[string]interfa
 			db := engine.GetMysql(code)
 			sql := validInsert[1].(string)
 			attributes := validInsert[2].([]interface{})
+			var positions []int
+			if len(validInsert) > 3 {
+				redacted := validInsert[3].([]interface{})
+				positions = make([]int, len(redacted))
+				for i, position := range redacted {
+					positions[i] = int(position.(float64))
+				}
+			}
 			func() {
 				defer func() {
 					if r := recover(); r != nil {
@@ -72,7 +87,7 @@ func (r *LazyReceiver) handleQueries(engine *Engine, validMap map[string]interfa
 						panic(r)
 					}
 				}()
-				_ = db.Exec(sql, attributes...)
+				_ = db.exec(sql, positions, attributes)
 			}()
 		}
 	}
@@ -91,6 +106,9 @@ func (r *LazyReceiver) handleClearCache(validMap map[string]interface{}, key str
 			if key == "cl" {
 				cache := r.engine.localCache[cacheCode]
 				cache.Remove(stringKeys...)
+				invalidations := make(map[string]map[string]bool)
+				addCacheDeletes(invalidations, cacheCode, stringKeys...)
+				publishLocalCacheInvalidation(r.engine, invalidations)
 			} else {
 				cache := r.engine.redis[cacheCode]
 				cache.Del(stringKeys...)
@@ -31,6 +31,10 @@ func loadByID(engine *Engine, id uint64, entity Entity, useCache bool, reference
 		cacheKey = schema.getCacheKey(id)
 		row, has := redisCache.Get(cacheKey)
 		if has {
+			// missing rows are not refreshed, so they are checked again in database when ttl expires
+			if schema.redisCacheRefresh && row != "nil" {
+				redisCache.Expire(schema.redisCacheTTL, cacheKey)
+			}
 			if row == "nil" {
 				return false
 			}
@@ -43,7 +47,17 @@ func loadByID(engine *Engine, id uint64, entity Entity, useCache bool, reference
 			return true
 		}
 	}
-	found = searchRow(false, engine, NewWhere("`ID` = ?", id), entity, nil)
+	if schema.shard != nil {
+		pool, valid := schema.shard.poolForID(id)
+		if !valid {
+			return false
+		}
+		engine.withShardPool(schema, pool, func() {
+			found = searchRow(false, engine, NewWhere("`ID` = ?", id), entity, nil)
+		})
+	} else {
+		found = searchRow(false, engine, NewWhere("`ID` = ?", id), entity, nil)
+	}
 	if !found {
 		if localCache != nil {
 			localCache.Set(cacheKey, "nil")
@@ -57,7 +71,7 @@ func loadByID(engine *Engine, id uint64, entity Entity, useCache bool, reference
 		localCache.Set(cacheKey, buildLocalCacheValue(entity))
 	}
 	if redisCache != nil && useCache {
-		redisCache.Set(cacheKey, buildRedisValue(entity), 0)
+		redisCache.Set(cacheKey, buildRedisValue(entity), schema.redisCacheTTL)
 	}
 	if len(references) > 0 {
 		warmUpReferences(engine, schema, orm.attributes.elem, references, false)
@@ -100,7 +114,7 @@ func initIfNeeded(engine *Engine, entity Entity) *ORM {
 		orm.engine = engine
 		orm.tableSchema = tableSchema
 		orm.dBData = make(map[string]interface{}, len(tableSchema.columnNames))
-		orm.attributes = &entityAttributes{nil, false, false, value, elem, elem.Field(1), nil}
+		orm.attributes = &entityAttributes{nil, false, false, value, elem, elem.Field(1), nil, nil, nil, false}
 		defaultInterface, is := entity.(DefaultValuesInterface)
 		if is {
 			defaultInterface.SetDefaults()
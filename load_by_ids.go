@@ -3,15 +3,48 @@ package orm
 import (
 	"encoding/json"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/juju/errors"
 )
 
+const maxWarmUpWorkers = 4
+
+type WarmUpError struct {
+	Entities map[string]error
+}
+
+func (e *WarmUpError) Error() string {
+	names := make([]string, 0, len(e.Entities))
+	for name := range e.Entities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = name + ": " + e.Entities[name].Error()
+	}
+	return "warm up of references failed: " + strings.Join(lines, ", ")
+}
+
+type idsLoad struct {
+	schema         *tableSchema
+	ids            []uint64
+	entities       reflect.Value
+	references     []string
+	results        map[string]Entity
+	keysMapping    map[string]uint64
+	keysReversed   map[uint64]string
+	cacheKeys      []string
+	localCacheKeys []string
+	redisCacheKeys []string
+	missing        []uint64
+}
+
 func tryByIDs(engine *Engine, ids []uint64, entities reflect.Value, references []string) (missing []uint64) {
-	originalIDs := ids
-	lenIDs := len(ids)
-	if lenIDs == 0 {
+	if len(ids) == 0 {
 		entities.SetLen(0)
 		return make([]uint64, 0)
 	}
@@ -19,108 +52,212 @@ func tryByIDs(engine *Engine, ids []uint64, entities reflect.Value, references [
 	if !has {
 		panic(EntityNotRegisteredError{Name: entities.Type().String()})
 	}
+	load := &idsLoad{schema: getTableSchema(engine.registry, t), ids: ids, entities: entities, references: references}
+	tryByIDsBatch(engine, []*idsLoad{load})
+	return load.missing
+}
 
-	schema := getTableSchema(engine.registry, t)
-	localCache, hasLocalCache := schema.GetLocalCache(engine)
-	redisCache, hasRedis := schema.GetRedisCache(engine)
-	var localCacheKeys []string
-	var redisCacheKeys []string
-	results := make(map[string]Entity, lenIDs)
-	keysMapping := make(map[string]uint64, lenIDs)
-	keysReversed := make(map[uint64]string, lenIDs)
-	cacheKeys := make([]string, lenIDs)
-	for index, id := range ids {
-		cacheKey := schema.getCacheKey(id)
-		cacheKeys[index] = cacheKey
-		keysMapping[cacheKey] = id
-		keysReversed[id] = cacheKey
-		results[cacheKey] = nil
-	}
-
-	if hasLocalCache || hasRedis {
+func tryByIDsBatch(engine *Engine, loads []*idsLoad) {
+	redisKeys := make(map[string][]string)
+	redisCaches := make(map[string]*RedisCache)
+	for _, load := range loads {
+		schema := load.schema
+		lenIDs := len(load.ids)
+		load.results = make(map[string]Entity, lenIDs)
+		load.keysMapping = make(map[string]uint64, lenIDs)
+		load.keysReversed = make(map[uint64]string, lenIDs)
+		load.cacheKeys = make([]string, lenIDs)
+		for index, id := range load.ids {
+			cacheKey := schema.getCacheKey(id)
+			load.cacheKeys[index] = cacheKey
+			load.keysMapping[cacheKey] = id
+			load.keysReversed[id] = cacheKey
+			load.results[cacheKey] = nil
+		}
+		localCache, hasLocalCache := schema.GetLocalCache(engine)
 		if hasLocalCache {
-			resultsLocalCache := localCache.MGet(cacheKeys...)
-			cacheKeys = getKeysForNils(engine, schema.t, resultsLocalCache, keysMapping, results, false)
-			localCacheKeys = cacheKeys
-		}
-		if hasRedis && len(cacheKeys) > 0 {
-			resultsRedis := redisCache.MGet(cacheKeys...)
-			cacheKeys = getKeysForNils(engine, schema.t, resultsRedis, keysMapping, results, true)
-			redisCacheKeys = cacheKeys
-		}
-		ids = make([]uint64, len(cacheKeys))
-		for k, v := range cacheKeys {
-			ids[k] = keysMapping[v]
-		}
-	}
-	l := len(ids)
-	if l > 0 {
-		_ = search(false, engine, NewWhere("`ID` IN ?", ids), NewPager(1, l), false, entities)
-		for i := 0; i < entities.Len(); i++ {
-			e := entities.Index(i).Interface().(Entity)
-			results[schema.getCacheKey(e.GetID())] = e
-		}
-	}
-	if hasLocalCache {
-		l = len(localCacheKeys)
-		if l > 0 {
-			pairs := make([]interface{}, l*2)
-			i := 0
-			for _, key := range localCacheKeys {
-				pairs[i] = key
-				val := results[key]
-				var toSet interface{}
-				if val == nil {
-					toSet = "nil"
-				} else {
+			resultsLocalCache := localCache.MGet(load.cacheKeys...)
+			load.cacheKeys = getKeysForNils(engine, schema.t, resultsLocalCache, load.keysMapping, load.results, false)
+			load.localCacheKeys = load.cacheKeys
+		}
+		redisCache, hasRedis := schema.GetRedisCache(engine)
+		if hasRedis && len(load.cacheKeys) > 0 {
+			redisCaches[redisCache.code] = redisCache
+			redisKeys[redisCache.code] = append(redisKeys[redisCache.code], load.cacheKeys...)
+		}
+	}
+	redisResults := make(map[string]map[string]interface{}, len(redisKeys))
+	for code, keys := range redisKeys {
+		redisResults[code] = redisCaches[code].MGet(keys...)
+	}
+	dbLoads := make([]*idsLoad, 0, len(loads))
+	for _, load := range loads {
+		schema := load.schema
+		redisCache, hasRedis := schema.GetRedisCache(engine)
+		if hasRedis && len(load.cacheKeys) > 0 {
+			resultsRedis := make(map[string]interface{}, len(load.cacheKeys))
+			for _, key := range load.cacheKeys {
+				resultsRedis[key] = redisResults[redisCache.code][key]
+			}
+			if schema.redisCacheRefresh {
+				refreshRedisTTL(redisCache, schema.redisCacheTTL, resultsRedis)
+			}
+			load.cacheKeys = getKeysForNils(engine, schema.t, resultsRedis, load.keysMapping, load.results, true)
+			load.redisCacheKeys = load.cacheKeys
+		}
+		if len(load.cacheKeys) > 0 {
+			dbLoads = append(dbLoads, load)
+		}
+	}
+	if len(dbLoads) > 1 && canWarmUpConcurrently(engine) {
+		searchByIDsConcurrently(engine, dbLoads)
+	} else {
+		for _, load := range dbLoads {
+			searchByIDs(engine, load)
+		}
+	}
+	redisSets := make(map[string]map[int][]interface{})
+	for _, load := range loads {
+		schema := load.schema
+		localCache, hasLocalCache := schema.GetLocalCache(engine)
+		if hasLocalCache && len(load.localCacheKeys) > 0 {
+			pairs := make([]interface{}, 0, len(load.localCacheKeys)*2)
+			for _, key := range load.localCacheKeys {
+				var toSet interface{} = "nil"
+				if val := load.results[key]; val != nil {
 					toSet = buildLocalCacheValue(val)
 				}
-				pairs[i+1] = toSet
-				i += 2
+				pairs = append(pairs, key, toSet)
 			}
 			localCache.MSet(pairs...)
 		}
-	}
-
-	if hasRedis {
-		l = len(redisCacheKeys)
-		if l > 0 {
-			pairs := make([]interface{}, l*2)
-			i := 0
-			for _, key := range redisCacheKeys {
-				pairs[i] = key
-				val := results[key]
-				var toSet interface{}
-				if val == nil {
-					toSet = "nil"
-				} else {
+		redisCache, hasRedis := schema.GetRedisCache(engine)
+		if hasRedis && len(load.redisCacheKeys) > 0 {
+			if redisSets[redisCache.code] == nil {
+				redisSets[redisCache.code] = make(map[int][]interface{})
+				redisCaches[redisCache.code] = redisCache
+			}
+			for _, key := range load.redisCacheKeys {
+				var toSet interface{} = "nil"
+				if val := load.results[key]; val != nil {
 					toSet = buildRedisValue(val)
 				}
-				pairs[i+1] = toSet
-				i += 2
+				redisSets[redisCache.code][schema.redisCacheTTL] = append(redisSets[redisCache.code][schema.redisCacheTTL], key, toSet)
+			}
+		}
+	}
+	for code, ttls := range redisSets {
+		for ttl, pairs := range ttls {
+			if ttl > 0 {
+				redisCaches[code].MSetWithTTL(ttl, pairs...)
+			} else {
+				redisCaches[code].MSet(pairs...)
+			}
+		}
+	}
+	for _, load := range loads {
+		load.missing = make([]uint64, 0)
+		valOrigin := load.entities
+		valOrigin.SetLen(0)
+		valOrigin.SetCap(0)
+		v := valOrigin
+		for _, id := range load.ids {
+			val := load.results[load.keysReversed[id]]
+			if val == nil {
+				load.missing = append(load.missing, id)
+			} else {
+				v = reflect.Append(v, reflect.ValueOf(val))
 			}
-			redisCache.MSet(pairs...)
+		}
+		valOrigin.Set(v)
+		if len(load.references) > 0 && v.Len() > 0 {
+			warmUpReferences(engine, load.schema, load.entities, load.references, true)
 		}
 	}
+}
 
-	missing = make([]uint64, 0)
-	valOrigin := entities
-	valOrigin.SetLen(0)
-	valOrigin.SetCap(0)
-	v := valOrigin
-	for _, id := range originalIDs {
-		val := results[keysReversed[id]]
-		if val == nil {
-			missing = append(missing, id)
-		} else {
-			v = reflect.Append(v, reflect.ValueOf(val))
+func searchByIDs(engine *Engine, load *idsLoad) {
+	schema := load.schema
+	ids := make([]uint64, len(load.cacheKeys))
+	for k, v := range load.cacheKeys {
+		ids[k] = load.keysMapping[v]
+	}
+	if schema.shard != nil {
+		searchShardedIDs(engine, schema, ids, load.entities)
+	} else {
+		_ = search(false, engine, NewWhere("`ID` IN ?", ids), NewPager(1, len(ids)), false, load.entities)
+	}
+	for i := 0; i < load.entities.Len(); i++ {
+		e := load.entities.Index(i).Interface().(Entity)
+		load.results[schema.getCacheKey(e.GetID())] = e
+	}
+}
+
+func searchByIDsConcurrently(engine *Engine, loads []*idsLoad) {
+	workers := make([]*Engine, len(loads))
+	releases := make([]func(), len(loads))
+	failed := make(map[string]interface{})
+	semaphore := make(chan struct{}, maxWarmUpWorkers)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for i, load := range loads {
+		pool := load.schema.mysqlPoolName
+		if load.schema.shard == nil {
+			pool = load.schema.getMysqlPoolName(engine)
+		}
+		workers[i], releases[i] = engine.newFlushWorker(pool)
+		wg.Add(1)
+		go func(worker *Engine, load *idsLoad) {
+			semaphore <- struct{}{}
+			defer func() {
+				if r := recover(); r != nil {
+					mutex.Lock()
+					failed[load.schema.t.String()] = r
+					mutex.Unlock()
+				}
+				<-semaphore
+				wg.Done()
+			}()
+			searchByIDs(worker, load)
+		}(workers[i], load)
+	}
+	wg.Wait()
+	for i, load := range loads {
+		for k, v := range workers[i].dataDog.counters {
+			engine.dataDog.incrementCounter(k, v)
+		}
+		releases[i]()
+		for _, e := range load.results {
+			if e != nil {
+				e.getORM().engine = engine
+			}
+		}
+	}
+	if len(failed) == 1 {
+		for _, r := range failed {
+			panic(r)
 		}
 	}
-	valOrigin.Set(v)
-	if len(references) > 0 && v.Len() > 0 {
-		warmUpReferences(engine, schema, entities, references, true)
+	if len(failed) > 1 {
+		asErrors := make(map[string]error, len(failed))
+		for name, r := range failed {
+			asErr, is := r.(error)
+			if !is {
+				asErr = errors.Errorf("%v", r)
+			}
+			asErrors[name] = asErr
+		}
+		panic(&WarmUpError{Entities: asErrors})
+	}
+}
+
+func canWarmUpConcurrently(engine *Engine) bool {
+	for _, db := range engine.dbs {
+		if db.inTransaction() {
+			return false
+		}
 	}
-	return
+	return true
 }
 
 func getKeysForNils(engine *Engine, entityType reflect.Type, rows map[string]interface{}, keysMapping map[string]uint64,
@@ -148,49 +285,60 @@ func getKeysForNils(engine *Engine, entityType reflect.Type, rows map[string]int
 	return keys
 }
 
+func refreshRedisTTL(redisCache *RedisCache, ttl int, rows map[string]interface{}) {
+	keys := make([]string, 0, len(rows))
+	for key, value := range rows {
+		if value != nil && value != "nil" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		redisCache.Expire(ttl, keys...)
+	}
+}
+
 func warmUpReferences(engine *Engine, tableSchema *tableSchema, rows reflect.Value, references []string, many bool) {
 	warmUpRows := make(map[reflect.Type]map[uint64]bool)
 	warmUpRefs := make(map[reflect.Type]map[uint64][]reflect.Value)
 	warmUpRowsIDs := make(map[reflect.Type][]uint64)
 	warmUpSubRefs := make(map[reflect.Type][]string)
+	warmUpUUIDRefs := make(map[reflect.Type]map[string][]reflect.Value)
 	l := 1
 	if many {
 		l = rows.Len()
 	}
-	if references[0] == "*" {
-		references = tableSchema.refOne
-	}
-	for _, ref := range references {
-		parts := strings.Split(ref, "/")
-		_, has := tableSchema.tags[parts[0]]
+	paths, order := groupReferencePaths(tableSchema, references)
+	for _, refName := range order {
+		parentRef, has := tableSchema.tags[refName]["ref"]
 		if !has {
-			panic(errors.NotValidf("reference %s in %s", ref, tableSchema.tableName))
-		}
-		parentRef, has := tableSchema.tags[parts[0]]["ref"]
-		if !has {
-			panic(errors.NotValidf("reference tag %s", ref))
+			panic(errors.NotValidf("reference tag %s", refName))
 		}
 		parentType, has := engine.registry.entities[parentRef]
 		if !has {
-			panic(EntityNotRegisteredError{Name: tableSchema.tags[parts[0]]["ref"]})
-		}
-		newSub := parts[1:]
-		if len(newSub) > 0 {
-			warmUpSubRefs[parentType] = append(warmUpSubRefs[parentType], strings.Join(newSub, "/"))
+			panic(EntityNotRegisteredError{Name: parentRef})
 		}
-
+		warmUpSubRefs[parentType] = append(warmUpSubRefs[parentType], paths[refName]...)
 		for i := 0; i < l; i++ {
 			var ref reflect.Value
 			if many {
-				ref = rows.Index(i).Elem().FieldByName(parts[0])
+				ref = rows.Index(i).Elem().FieldByName(refName)
 			} else {
-				ref = rows.FieldByName(parts[0])
+				ref = rows.FieldByName(refName)
 			}
 			if ref.IsZero() {
 				continue
 			}
 			refEntity := ref.Interface().(Entity)
 			refID := refEntity.GetID()
+			_, isUUID := tableSchema.uuidReferences[refName]
+			if isUUID && refID == 0 {
+				if warmUpUUIDRefs[parentType] == nil {
+					warmUpUUIDRefs[parentType] = make(map[string][]reflect.Value)
+				}
+				uuid := getUUIDKeyValue(getTableSchema(engine.registry, parentType), ref)
+				warmUpUUIDRefs[parentType][uuid] = append(warmUpUUIDRefs[parentType][uuid], ref)
+				continue
+			}
 			ids := make([]uint64, 0)
 			if refID != 0 {
 				ids = append(ids, refID)
@@ -218,9 +366,20 @@ func warmUpReferences(engine *Engine, tableSchema *tableSchema, rows reflect.Val
 			}
 		}
 	}
+	loads := make([]*idsLoad, 0, len(warmUpRowsIDs))
 	for t, ids := range warmUpRowsIDs {
 		sub := reflect.New(reflect.SliceOf(reflect.PtrTo(t))).Elem()
-		_ = tryByIDs(engine, ids, sub, warmUpSubRefs[t])
+		loads = append(loads, &idsLoad{schema: getTableSchema(engine.registry, t), ids: ids, entities: sub, references: warmUpSubRefs[t]})
+	}
+	if len(loads) > 0 {
+		tryByIDsBatch(engine, loads)
+	}
+	for t, refs := range warmUpUUIDRefs {
+		warmUpUUIDReferences(engine, getTableSchema(engine.registry, t), refs, warmUpSubRefs[t])
+	}
+	for _, load := range loads {
+		t := load.schema.t
+		sub := load.entities
 		subLen := sub.Len()
 		for i := 0; i < subLen; i++ {
 			v := sub.Index(i).Interface().(Entity)
@@ -234,3 +393,31 @@ func warmUpReferences(engine *Engine, tableSchema *tableSchema, rows reflect.Val
 		}
 	}
 }
+
+func groupReferencePaths(tableSchema *tableSchema, references []string) (paths map[string][]string, order []string) {
+	paths = make(map[string][]string)
+	added := make(map[string]bool)
+	for _, reference := range references {
+		parts := strings.SplitN(strings.ReplaceAll(reference, ".", "/"), "/", 2)
+		names := []string{parts[0]}
+		if parts[0] == "*" {
+			names = tableSchema.refOne
+		}
+		for _, name := range names {
+			_, has := tableSchema.tags[name]
+			if !has {
+				panic(errors.NotValidf("reference %s in %s", reference, tableSchema.tableName))
+			}
+			_, has = paths[name]
+			if !has {
+				paths[name] = make([]string, 0)
+				order = append(order, name)
+			}
+			if len(parts) > 1 && !added[name+"/"+parts[1]] {
+				added[name+"/"+parts[1]] = true
+				paths[name] = append(paths[name], parts[1])
+			}
+		}
+	}
+	return paths, order
+}
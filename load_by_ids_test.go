@@ -0,0 +1,274 @@
+package orm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type loadByIDsOrder struct {
+	ORM      `orm:"localCache"`
+	ID       uint
+	Name     string
+	Customer *loadByIDsCustomer
+}
+
+type loadByIDsCustomer struct {
+	ORM     `orm:"redisCache"`
+	ID      uint
+	Name    string
+	Country *loadByIDsCountry
+	Partner *loadByIDsCustomer
+}
+
+type loadByIDsCountry struct {
+	ORM
+	ID   uint
+	Name string
+}
+
+type loadByIDsRedisTTL struct {
+	ORM  `orm:"redisCache=default:30:refresh"`
+	ID   uint
+	Name string
+}
+
+type loadByIDsRedisTTLInvalid struct {
+	ORM `orm:"redisCache=default:abc"`
+	ID  uint
+}
+
+func TestGroupReferencePaths(t *testing.T) {
+	schema := &tableSchema{tableName: "test", refOne: []string{"A", "B"},
+		tags: map[string]map[string]string{"A": {"ref": "a"}, "B": {"ref": "b"}}}
+	paths, order := groupReferencePaths(schema, []string{"A/X/Y", "A.X.Y", "B", "A/Z", "*"})
+	assert.Equal(t, []string{"A", "B"}, order)
+	assert.Equal(t, []string{"X/Y", "Z"}, paths["A"])
+	assert.Len(t, paths["B"], 0)
+
+	paths, order = groupReferencePaths(schema, []string{"*/X"})
+	assert.Equal(t, []string{"A", "B"}, order)
+	assert.Equal(t, []string{"X"}, paths["B"])
+
+	assert.Panics(t, func() {
+		groupReferencePaths(schema, []string{"C"})
+	})
+}
+
+func TestLoadByIDsNestedReferences(t *testing.T) {
+	var order *loadByIDsOrder
+	var customer *loadByIDsCustomer
+	var country *loadByIDsCountry
+	engine := PrepareTables(t, &Registry{}, order, customer, country)
+
+	poland := &loadByIDsCountry{Name: "Poland"}
+	partner := &loadByIDsCustomer{Name: "Partner", Country: poland}
+	tom := &loadByIDsCustomer{Name: "Tom", Country: poland, Partner: partner}
+	engine.TrackAndFlush(poland)
+	engine.TrackAndFlush(partner)
+	engine.TrackAndFlush(tom)
+	engine.TrackAndFlush(&loadByIDsOrder{Name: "a", Customer: tom}, &loadByIDsOrder{Name: "b", Customer: partner})
+
+	var orders []*loadByIDsOrder
+	engine.LoadByIDs([]uint64{1, 2}, &orders, "Customer.Partner.Country", "Customer/Country")
+	assert.Len(t, orders, 2)
+	assert.Equal(t, "Tom", orders[0].Customer.Name)
+	assert.Equal(t, "Poland", orders[0].Customer.Country.Name)
+	assert.Equal(t, "Partner", orders[0].Customer.Partner.Name)
+	assert.Equal(t, "Poland", orders[0].Customer.Partner.Country.Name)
+	assert.Equal(t, "Poland", orders[1].Customer.Country.Name)
+	assert.Nil(t, orders[1].Customer.Partner)
+
+	orders = nil
+	engine.Search(NewWhere("1"), nil, &orders, "*/*")
+	assert.Equal(t, "Poland", orders[0].Customer.Country.Name)
+	assert.True(t, engine.Loaded(orders[0].Customer.Partner))
+}
+
+func TestGetReference(t *testing.T) {
+	var customer *loadByIDsCustomer
+	var country *loadByIDsCountry
+	engine := PrepareTables(t, &Registry{}, customer, country)
+
+	customer = &loadByIDsCustomer{Name: "Tom", Country: &loadByIDsCountry{Name: "Poland"}}
+	engine.TrackAndFlush(customer)
+
+	customer = &loadByIDsCustomer{}
+	engine.LoadByID(1, customer)
+	assert.False(t, engine.Loaded(customer.Country))
+	assert.Equal(t, "Poland", customer.GetCountry(engine).Name)
+	assert.True(t, engine.Loaded(customer.Country))
+	assert.Same(t, customer.Country, customer.GetCountry(engine))
+
+	customer.Country = nil
+	assert.Nil(t, customer.GetCountry(engine))
+	assert.Panics(t, func() {
+		engine.GetReference(customer, "Name")
+	})
+}
+
+func TestCheckReferences(t *testing.T) {
+	var customer *loadByIDsCustomer
+	var country *loadByIDsCountry
+	engine := PrepareTables(t, &Registry{}, customer, country)
+	country = &loadByIDsCountry{Name: "Poland"}
+	engine.TrackAndFlush(country)
+	engine.TrackAndFlush(&loadByIDsCustomer{Name: "A", Country: country}, &loadByIDsCustomer{Name: "B", Country: country},
+		&loadByIDsCustomer{Name: "C", Country: country})
+	assert.Len(t, engine.CheckReferences(customer), 0)
+
+	db := engine.GetMysql()
+	db.Begin()
+	db.Exec("SET FOREIGN_KEY_CHECKS = 0")
+	db.Exec("UPDATE `loadByIDsCustomer` SET `Country` = 7 WHERE `ID` IN (1, 3)")
+	db.Exec("SET FOREIGN_KEY_CHECKS = 1")
+	db.Commit()
+
+	report := engine.CheckReferences(customer)
+	assert.Len(t, report, 1)
+	assert.Equal(t, "orm.loadByIDsCustomer", report[0].Entity)
+	assert.Equal(t, "Country", report[0].Field)
+	assert.Equal(t, "orm.loadByIDsCountry", report[0].Reference)
+	assert.Equal(t, []uint64{1, 3}, report[0].IDs)
+	assert.Equal(t, []uint64{7}, report[0].MissingIDs)
+	assert.False(t, report[0].Fixed)
+
+	pending := &loadByIDsCountry{Name: "Germany"}
+	engine.Track(pending)
+	report = engine.FixReferences(ReferencesFixSetNull, customer)
+	assert.Len(t, report, 1)
+	assert.True(t, report[0].Fixed)
+	assert.Equal(t, uint64(0), pending.GetID())
+	assert.True(t, engine.IsDirty(pending))
+	engine.ClearTrackedEntities()
+	assert.Len(t, engine.CheckReferences(customer), 0)
+	customer = &loadByIDsCustomer{}
+	engine.LoadByID(1, customer)
+	assert.Nil(t, customer.Country)
+}
+
+func TestLoadByIDsRedisTTL(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterRedis("localhost:6380", 15)
+	registry.sqlClients = map[string]*DBConfig{"default": {}}
+	_, err := initTableSchema(registry, reflect.TypeOf(loadByIDsRedisTTLInvalid{}))
+	assert.EqualError(t, err, "redis cache definition 'default:abc' not valid")
+
+	var entity *loadByIDsRedisTTL
+	engine := PrepareTables(t, &Registry{}, entity)
+	schema := engine.GetRegistry().GetTableSchemaForEntity(entity).(*tableSchema)
+	assert.Equal(t, 30, schema.redisCacheTTL)
+	assert.True(t, schema.redisCacheRefresh)
+
+	engine.TrackAndFlush(&loadByIDsRedisTTL{Name: "a"}, &loadByIDsRedisTTL{Name: "b"})
+	client := engine.GetRedis().client.(*standardRedisClient).client
+	var rows []*loadByIDsRedisTTL
+	engine.LoadByIDs([]uint64{1, 2}, &rows)
+	assert.Len(t, rows, 2)
+	ttl := client.TTL(schema.getCacheKey(1)).Val()
+	assert.True(t, ttl > 0 && ttl <= 30*time.Second)
+
+	client.Expire(schema.getCacheKey(1), 5*time.Second)
+	row := &loadByIDsRedisTTL{}
+	assert.True(t, engine.LoadByID(1, row))
+	assert.True(t, client.TTL(schema.getCacheKey(1)).Val() > 5*time.Second)
+}
+
+type loadByIDsBatchParent struct {
+	ORM  `orm:"localCache"`
+	ID   uint
+	Name string
+}
+
+type loadByIDsBatchTag struct {
+	ORM  `orm:"localCache"`
+	ID   uint
+	Name string
+}
+
+type loadByIDsBatchChild struct {
+	ORM    `orm:"localCache"`
+	ID     uint
+	Name   string
+	Parent *loadByIDsBatchParent
+	Tag    *loadByIDsBatchTag
+}
+
+func TestLoadByIDsBatchFromCache(t *testing.T) {
+	registry := &Registry{}
+	registry.sqlClients = map[string]*DBConfig{"default": {code: "default", databaseName: "test"}}
+	validated := (&validatedRegistry{}).clone(registry, nil)
+	validated.sqlClients["default"] = registry.sqlClients["default"]
+	err := validated.Extend(func(r *Registry) {
+		r.RegisterLocalCache(100)
+		r.RegisterEntity(&loadByIDsBatchParent{}, &loadByIDsBatchTag{}, &loadByIDsBatchChild{})
+	})
+	assert.NoError(t, err)
+	engine := validated.CreateEngine()
+	cache := engine.GetLocalCache()
+	schema := validated.GetTableSchemaForEntity(&loadByIDsBatchChild{}).(*tableSchema)
+	parentSchema := validated.GetTableSchemaForEntity(&loadByIDsBatchParent{}).(*tableSchema)
+	tagSchema := validated.GetTableSchemaForEntity(&loadByIDsBatchTag{}).(*tableSchema)
+	cache.Set(schema.getCacheKey(1), []string{"a", "10", "20"})
+	cache.Set(schema.getCacheKey(2), []string{"b", "10", ""})
+	cache.Set(schema.getCacheKey(3), "nil")
+	cache.Set(parentSchema.getCacheKey(10), []string{"parent"})
+	cache.Set(tagSchema.getCacheKey(20), []string{"tag"})
+
+	var rows []*loadByIDsBatchChild
+	missing := engine.LoadByIDs([]uint64{2, 3, 1}, &rows, "Parent", "Tag")
+	assert.Equal(t, []uint64{3}, missing)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "b", rows[0].Name)
+	assert.Equal(t, "a", rows[1].Name)
+	assert.Same(t, rows[0].Parent, rows[1].Parent)
+	assert.True(t, rows[0].Parent.getORM().attributes.loaded)
+	assert.Equal(t, "parent", rows[0].Parent.Name)
+	assert.Nil(t, rows[0].Tag)
+	assert.Equal(t, "tag", rows[1].Tag.Name)
+}
+
+type loadByIDsShipment struct {
+	ORM
+	ID       uint
+	Customer *loadByIDsCustomer
+	Country  *loadByIDsCountry
+}
+
+func TestLoadByIDsConcurrentReferences(t *testing.T) {
+	var shipment *loadByIDsShipment
+	var customer *loadByIDsCustomer
+	var country *loadByIDsCountry
+	engine := PrepareTables(t, &Registry{}, shipment, customer, country)
+
+	poland := &loadByIDsCountry{Name: "Poland"}
+	germany := &loadByIDsCountry{Name: "Germany"}
+	engine.TrackAndFlush(poland, germany)
+	tom := &loadByIDsCustomer{Name: "Tom", Country: poland}
+	engine.TrackAndFlush(tom)
+	engine.TrackAndFlush(&loadByIDsShipment{Customer: tom, Country: germany})
+
+	var shipments []*loadByIDsShipment
+	engine.LoadByIDs([]uint64{1}, &shipments, "Customer/Country", "Country")
+	assert.Len(t, shipments, 1)
+	assert.Equal(t, "Tom", shipments[0].Customer.Name)
+	assert.Equal(t, "Poland", shipments[0].Customer.Country.Name)
+	assert.Equal(t, "Germany", shipments[0].Country.Name)
+	assert.Equal(t, engine, shipments[0].Country.getORM().engine)
+	assert.True(t, canWarmUpConcurrently(engine))
+
+	engine.GetMysql().Begin()
+	assert.False(t, canWarmUpConcurrently(engine))
+	shipments = nil
+	engine.LoadByIDs([]uint64{1}, &shipments, "Customer", "Country")
+	assert.Equal(t, "Germany", shipments[0].Country.Name)
+	engine.GetMysql().Rollback()
+}
+
+func TestWarmUpError(t *testing.T) {
+	err := &WarmUpError{Entities: map[string]error{"b": errors.New("second"), "a": errors.New("first")}}
+	assert.Equal(t, "warm up of references failed: a: first, b: second", err.Error())
+}
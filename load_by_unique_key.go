@@ -0,0 +1,68 @@
+package orm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+func loadByUniqueKey(engine *Engine, entity Entity, index string, values []interface{}) (found bool) {
+	schema := initIfNeeded(engine, entity).tableSchema
+	return searchRow(true, engine, getUniqueKeyWhere(schema, index, values), entity, nil)
+}
+
+func cachedLoadByUniqueKey(engine *Engine, entity Entity, index string, values []interface{}) (found bool) {
+	orm := initIfNeeded(engine, entity)
+	schema := orm.tableSchema
+	redisCache, has := schema.GetRedisCache(engine)
+	if !has {
+		panic(errors.Errorf("cached unique key search not allowed for entity without redis cache: '%s'", schema.t.String()))
+	}
+	where := getUniqueKeyWhere(schema, index, values)
+	cacheKey := getCacheKeySearch(schema, "_uk_"+index, values...)
+	fromCache, has := redisCache.Get(cacheKey)
+	if has {
+		id, _ := strconv.ParseUint(fromCache, 10, 64)
+		if id > 0 && loadByID(engine, id, entity, true) && uniqueKeyMatches(schema, orm, index, values) {
+			return true
+		}
+		redisCache.Del(cacheKey)
+	}
+	if !searchRow(true, engine, where, entity, nil) {
+		return false
+	}
+	redisCache.Set(cacheKey, strconv.FormatUint(entity.GetID(), 10), schema.redisCacheTTL)
+	return true
+}
+
+func getUniqueKeyWhere(schema *tableSchema, index string, values []interface{}) *Where {
+	columns, has := schema.uniqueIndices[index]
+	if !has {
+		panic(errors.NotFoundf("unique index '%s' in %s", index, schema.t.String()))
+	}
+	if len(values) != len(columns) {
+		panic(errors.NotValidf("%d values for unique index '%s' with %d columns in %s", len(values), index, len(columns), schema.t.String()))
+	}
+	conditions := make([]string, len(columns))
+	for i, column := range columns {
+		if values[i] == nil {
+			panic(errors.NotValidf("nil value for column %s in unique index '%s'", column, index))
+		}
+		conditions[i] = "`" + column + "` = " + schema.placeholder(column)
+	}
+	return NewWhere(strings.Join(conditions, " AND "), values...)
+}
+
+func uniqueKeyMatches(schema *tableSchema, orm *ORM, index string, values []interface{}) bool {
+	if schema.hasFakeDelete && fmt.Sprintf("%v", orm.dBData["FakeDelete"]) != "0" {
+		return false
+	}
+	for i, column := range schema.uniqueIndices[index] {
+		if fmt.Sprintf("%v", orm.dBData[column]) != fmt.Sprintf("%v", values[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type uniqueKeyEntity struct {
+	ORM        `orm:"redisCache"`
+	ID         uint
+	Email      string `orm:"unique=Email;required"`
+	Name       string `orm:"unique=NameCountry:2"`
+	Country    string `orm:"unique=NameCountry"`
+	FakeDelete bool
+}
+
+func TestUniqueKeyWhere(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterRedis("localhost:6380", 15)
+	registry.sqlClients = map[string]*DBConfig{"default": {}}
+	schema, err := initTableSchema(registry, reflect.TypeOf(uniqueKeyEntity{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Country", "Name"}, schema.uniqueIndices["NameCountry"])
+
+	where := getUniqueKeyWhere(schema, "NameCountry", []interface{}{"PL", "Tom"})
+	assert.Equal(t, "`Country` = ? AND `Name` = ?", where.String())
+	assert.Equal(t, []interface{}{"PL", "Tom"}, where.GetParameters())
+	assert.PanicsWithError(t, "unique index 'Missing' in orm.uniqueKeyEntity not found", func() {
+		getUniqueKeyWhere(schema, "Missing", []interface{}{"a"})
+	})
+	assert.PanicsWithError(t, "1 values for unique index 'NameCountry' with 2 columns in orm.uniqueKeyEntity not valid", func() {
+		getUniqueKeyWhere(schema, "NameCountry", []interface{}{"a"})
+	})
+	assert.Panics(t, func() {
+		getUniqueKeyWhere(schema, "Email", []interface{}{nil})
+	})
+}
+
+func TestLoadByUniqueKey(t *testing.T) {
+	var entity *uniqueKeyEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	engine.TrackAndFlush(&uniqueKeyEntity{Email: "tom@example.com", Name: "Tom", Country: "PL"})
+	entity = &uniqueKeyEntity{}
+	assert.True(t, engine.LoadByUniqueKey(entity, "Email", "tom@example.com"))
+	assert.Equal(t, uint(1), entity.ID)
+	entity = &uniqueKeyEntity{}
+	assert.True(t, engine.LoadByUniqueKey(entity, "NameCountry", "PL", "Tom"))
+	assert.Equal(t, "tom@example.com", entity.Email)
+	assert.False(t, engine.LoadByUniqueKey(&uniqueKeyEntity{}, "Email", "missing@example.com"))
+
+	entity = &uniqueKeyEntity{}
+	assert.True(t, engine.CachedLoadByUniqueKey(entity, "Email", "tom@example.com"))
+	cacheKey := getCacheKeySearch(entity.getORM().tableSchema, "_uk_Email", "tom@example.com")
+	id, has := engine.GetRedis().Get(cacheKey)
+	assert.True(t, has)
+	assert.Equal(t, "1", id)
+	assert.True(t, engine.CachedLoadByUniqueKey(&uniqueKeyEntity{}, "Email", "tom@example.com"))
+
+	entity.Email = "tom2@example.com"
+	engine.TrackAndFlush(entity)
+	assert.False(t, engine.CachedLoadByUniqueKey(&uniqueKeyEntity{}, "Email", "tom@example.com"))
+	_, has = engine.GetRedis().Get(cacheKey)
+	assert.False(t, has)
+	assert.True(t, engine.CachedLoadByUniqueKey(&uniqueKeyEntity{}, "Email", "tom2@example.com"))
+
+	engine.MarkToDelete(entity)
+	engine.Flush()
+	assert.False(t, engine.LoadByUniqueKey(&uniqueKeyEntity{}, "Email", "tom2@example.com"))
+	assert.False(t, engine.CachedLoadByUniqueKey(&uniqueKeyEntity{}, "Email", "tom2@example.com"))
+}
@@ -0,0 +1,38 @@
+package orm
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/juju/errors"
+)
+
+func loadField(engine *Engine, entity Entity, field string) bool {
+	orm := initIfNeeded(engine, entity)
+	schema := orm.tableSchema
+	_, isLazy := schema.tags[field]["lazy"]
+	if !isLazy {
+		panic(errors.NotValidf("field '%s' in %s is not lazy", field, schema.t.String()))
+	}
+	id := entity.GetID()
+	if id == 0 {
+		panic(errors.NotValidf("entity %s without ID", schema.t.String()))
+	}
+	/* #nosec */
+	query := fmt.Sprintf("SELECT `%s` FROM `%s` WHERE `ID` = ?", field, schema.getTableName(engine))
+	var value sql.NullString
+	if !schema.GetMysql(engine).QueryRow(NewWhere(query, id), &value) {
+		return false
+	}
+	f := orm.attributes.elem.FieldByName(field)
+	if f.Kind() == reflect.String {
+		f.SetString(value.String)
+	} else if value.Valid {
+		f.SetBytes([]byte(value.String))
+	} else {
+		f.SetBytes(nil)
+	}
+	orm.dBData[field] = value.String
+	return true
+}
@@ -0,0 +1,51 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type loadFieldEntity struct {
+	ORM   `orm:"localCache"`
+	ID    uint
+	Name  string
+	Body  string `orm:"length=max;lazy"`
+	Image []byte `orm:"lazy"`
+}
+
+func TestLoadField(t *testing.T) {
+	var entity *loadFieldEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	schema := engine.GetRegistry().GetTableSchemaForEntity(entity)
+	assert.Equal(t, []string{"ID", "Name"}, schema.GetColumns())
+
+	entity = &loadFieldEntity{Name: "Tom", Body: "long text", Image: []byte("image")}
+	engine.TrackAndFlush(entity)
+
+	entity = &loadFieldEntity{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, "Tom", entity.Name)
+	assert.Equal(t, "", entity.Body)
+	assert.Nil(t, entity.Image)
+	assert.False(t, engine.IsDirty(entity))
+
+	assert.True(t, engine.LoadField(entity, "Body"))
+	assert.True(t, engine.LoadField(entity, "Image"))
+	assert.Equal(t, "long text", entity.Body)
+	assert.Equal(t, []byte("image"), entity.Image)
+	assert.False(t, engine.IsDirty(entity))
+
+	entity.Body = ""
+	assert.True(t, engine.IsDirty(entity))
+	engine.TrackAndFlush(entity)
+	entity = &loadFieldEntity{}
+	engine.LoadByID(1, entity)
+	engine.LoadField(entity, "Body")
+	assert.Equal(t, "", entity.Body)
+
+	assert.Panics(t, func() {
+		engine.LoadField(entity, "Name")
+	})
+}
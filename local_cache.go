@@ -1,23 +1,114 @@
 package orm
 
 import (
+	"hash/fnv"
 	"sync"
 	"time"
 
 	"github.com/golang/groupcache/lru"
 )
 
+const maxLocalCacheShards = 16
+const minLocalCacheShardSize = 64
+
 type LocalCacheConfig struct {
 	code string
-	lru  *lru.Cache
+	lru  *shardedLRU
 	ttl  int64
 }
 
 type LocalCache struct {
 	engine *Engine
 	code   string
-	lru    *lru.Cache
+	lru    *shardedLRU
 	ttl    int64
+	prefix string
+}
+
+type lruShard struct {
+	mutex sync.Mutex
+	cache *lru.Cache
+}
+
+type shardedLRU struct {
+	shards []*lruShard
+}
+
+func newShardedLRU(size int) *shardedLRU {
+	count := maxLocalCacheShards
+	for count > 1 && size/count < minLocalCacheShardSize {
+		count /= 2
+	}
+	shardSize := (size + count - 1) / count
+	cache := &shardedLRU{shards: make([]*lruShard, count)}
+	for i := range cache.shards {
+		cache.shards[i] = &lruShard{cache: lru.New(shardSize)}
+	}
+	return cache
+}
+
+func (c *shardedLRU) shard(key string) *lruShard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return c.shards[hash.Sum32()%uint32(len(c.shards))]
+}
+
+func (c *shardedLRU) Get(key string) (value interface{}, ok bool) {
+	shard := c.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	return shard.cache.Get(key)
+}
+
+func (c *shardedLRU) Add(key string, value interface{}) {
+	shard := c.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	shard.cache.Add(key, value)
+}
+
+func (c *shardedLRU) Remove(key string) {
+	shard := c.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	shard.cache.Remove(key)
+}
+
+func (c *shardedLRU) Update(key string, callback func(value interface{}, has bool) interface{}) {
+	shard := c.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	value, has := shard.cache.Get(key)
+	shard.cache.Add(key, callback(value, has))
+}
+
+func (c *shardedLRU) View(key string, callback func(value interface{}, has bool)) {
+	shard := c.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	value, has := shard.cache.Get(key)
+	callback(value, has)
+}
+
+func (c *shardedLRU) Clear() {
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		shard.cache.Clear()
+		shard.mutex.Unlock()
+	}
+}
+
+func (c *shardedLRU) Len() int {
+	total := 0
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		total += shard.cache.Len()
+		shard.mutex.Unlock()
+	}
+	return total
 }
 
 type ttlValue struct {
@@ -41,7 +132,7 @@ func (c *LocalCache) GetSet(key string, ttlSeconds int, provider GetSetProvider)
 
 func (c *LocalCache) Get(key string) (value interface{}, ok bool) {
 	start := time.Now()
-	value, ok = c.lru.Get(key)
+	value, ok = c.lru.Get(c.prefix + key)
 	misses := 0
 	if !ok {
 		misses = 1
@@ -57,7 +148,7 @@ func (c *LocalCache) MGet(keys ...string) map[string]interface{} {
 	results := make(map[string]interface{}, len(keys))
 	misses := 0
 	for _, key := range keys {
-		value, ok := c.lru.Get(key)
+		value, ok := c.lru.Get(c.prefix + key)
 		if !ok {
 			misses++
 			value = nil
@@ -72,10 +163,7 @@ func (c *LocalCache) MGet(keys ...string) map[string]interface{} {
 
 func (c *LocalCache) Set(key string, value interface{}) {
 	start := time.Now()
-	m := &sync.Mutex{}
-	m.Lock()
-	defer m.Unlock()
-	c.lru.Add(key, value)
+	c.lru.Add(c.prefix+key, value)
 	if c.engine.queryLoggers[QueryLoggerSourceLocalCache] != nil {
 		c.fillLogFields("[ORM][LOCAL][MGET]", start, "set", -1, map[string]interface{}{"Key": key, "value": value})
 	}
@@ -84,11 +172,8 @@ func (c *LocalCache) Set(key string, value interface{}) {
 func (c *LocalCache) MSet(pairs ...interface{}) {
 	start := time.Now()
 	max := len(pairs)
-	m := &sync.Mutex{}
-	m.Lock()
-	defer m.Unlock()
 	for i := 0; i < max; i += 2 {
-		c.lru.Add(pairs[i], pairs[i+1])
+		c.lru.Add(c.prefix+pairs[i].(string), pairs[i+1])
 	}
 	if c.engine.queryLoggers[QueryLoggerSourceLocalCache] != nil {
 		c.fillLogFields("[ORM][LOCAL][MSET]", start, "mset", -1, map[string]interface{}{"Keys": pairs})
@@ -99,22 +184,23 @@ func (c *LocalCache) HMget(key string, fields ...string) map[string]interface{}
 	start := time.Now()
 	l := len(fields)
 	results := make(map[string]interface{}, l)
-	value, ok := c.lru.Get(key)
 	misses := 0
-	for _, field := range fields {
-		if !ok {
-			results[field] = nil
-			misses++
-		} else {
-			val, has := value.(map[string]interface{})[field]
-			if !has {
+	c.lru.View(c.prefix+key, func(value interface{}, ok bool) {
+		for _, field := range fields {
+			if !ok {
 				results[field] = nil
 				misses++
 			} else {
-				results[field] = val
+				val, has := value.(map[string]interface{})[field]
+				if !has {
+					results[field] = nil
+					misses++
+				} else {
+					results[field] = val
+				}
 			}
 		}
-	}
+	})
 	if c.engine.queryLoggers[QueryLoggerSourceLocalCache] != nil {
 		c.fillLogFields("[ORM][LOCAL][HMGET]", start, "hmget", misses, map[string]interface{}{"Key": key, "fields": fields})
 	}
@@ -123,17 +209,15 @@ func (c *LocalCache) HMget(key string, fields ...string) map[string]interface{}
 
 func (c *LocalCache) HMset(key string, fields map[string]interface{}) {
 	start := time.Now()
-	m, has := c.lru.Get(key)
-	if !has {
-		m = make(map[string]interface{})
-		mutex := &sync.Mutex{}
-		mutex.Lock()
-		defer mutex.Unlock()
-		c.lru.Add(key, m)
-	}
-	for k, v := range fields {
-		m.(map[string]interface{})[k] = v
-	}
+	c.lru.Update(c.prefix+key, func(m interface{}, has bool) interface{} {
+		if !has {
+			m = make(map[string]interface{})
+		}
+		for k, v := range fields {
+			m.(map[string]interface{})[k] = v
+		}
+		return m
+	})
 	if c.engine.queryLoggers[QueryLoggerSourceLocalCache] != nil {
 		c.fillLogFields("[ORM][LOCAL][HMSET]", start, "hmset", -1, map[string]interface{}{"Key": key, "fields": fields})
 	}
@@ -141,11 +225,8 @@ func (c *LocalCache) HMset(key string, fields map[string]interface{}) {
 
 func (c *LocalCache) Remove(keys ...string) {
 	start := time.Now()
-	m := &sync.Mutex{}
-	m.Lock()
-	defer m.Unlock()
 	for _, v := range keys {
-		c.lru.Remove(v)
+		c.lru.Remove(c.prefix + v)
 	}
 	if c.engine.queryLoggers[QueryLoggerSourceLocalCache] != nil {
 		c.fillLogFields("[ORM][LOCAL][REMOVE]", start, "remove", -1, map[string]interface{}{"Keys": keys})
@@ -154,9 +235,6 @@ func (c *LocalCache) Remove(keys ...string) {
 
 func (c *LocalCache) Clear() {
 	start := time.Now()
-	m := &sync.Mutex{}
-	m.Lock()
-	defer m.Unlock()
 	c.lru.Clear()
 	if c.engine.queryLoggers[QueryLoggerSourceLocalCache] != nil {
 		c.fillLogFields("[ORM][LOCAL][CLEAR]", start, "clear", -1, nil)
@@ -164,13 +242,16 @@ func (c *LocalCache) Clear() {
 }
 
 func (c *LocalCache) fillLogFields(message string, start time.Time, operation string, misses int, fields map[string]interface{}) {
+	now := time.Now()
 	stop := time.Since(start).Microseconds()
-	e := c.engine.queryLoggers[QueryLoggerSourceLocalCache].log.
+	e := c.engine.queryLoggerEntry(QueryLoggerSourceLocalCache).
 		WithField("microseconds", stop).
 		WithField("operation", operation).
 		WithField("pool", c.code).
 		WithField("target", "local_cache").
-		WithField("time", start.Unix())
+		WithField("time", start.Unix()).
+		WithField("started", start.UnixNano()).
+		WithField("finished", now.UnixNano())
 	if misses >= 0 {
 		e = e.WithField("misses", misses)
 	}
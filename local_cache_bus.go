@@ -0,0 +1,86 @@
+package orm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/juju/errors"
+)
+
+const localCacheInvalidationChannel = "orm.local_cache.invalidation"
+
+type localCacheBus struct {
+	pool     string
+	instance string
+}
+
+type localCacheInvalidation struct {
+	Instance string              `json:"i"`
+	Keys     map[string][]string `json:"k"`
+}
+
+// EnableLocalCacheInvalidation publishes local cache keys changed in flush to redis channel,
+// other instances remove them from own local cache in ReceiveLocalCacheInvalidations
+func (r *Registry) EnableLocalCacheInvalidation(redisPool ...string) {
+	pool := "default"
+	if len(redisPool) > 0 {
+		pool = redisPool[0]
+	}
+	instance := make([]byte, 8)
+	_, _ = rand.Read(instance)
+	r.localCacheBus = &localCacheBus{pool: pool, instance: hex.EncodeToString(instance)}
+}
+
+// ReceiveLocalCacheInvalidations removes local cache keys changed by other instances, blocks until ctx is done
+func (e *Engine) ReceiveLocalCacheInvalidations(ctx context.Context) {
+	bus := e.registry.registry.localCacheBus
+	if bus == nil {
+		panic(errors.New("local cache invalidation not enabled"))
+	}
+	pubSub := e.GetRedis(bus.pool).client.Subscribe(localCacheInvalidationChannel)
+	defer pubSub.Close()
+	messages := pubSub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			handleLocalCacheInvalidation(e, message.Payload)
+		}
+	}
+}
+
+func publishLocalCacheInvalidation(engine *Engine, keys map[string]map[string]bool) {
+	bus := engine.registry.registry.localCacheBus
+	if bus == nil || len(keys) == 0 {
+		return
+	}
+	invalidation := localCacheInvalidation{Instance: bus.instance, Keys: make(map[string][]string, len(keys))}
+	for cacheCode, cacheKeys := range keys {
+		invalidation.Keys[cacheCode] = mapKeys(cacheKeys)
+	}
+	asJSON, _ := json.Marshal(invalidation)
+	engine.GetRedis(bus.pool).publish(localCacheInvalidationChannel, string(asJSON))
+}
+
+func handleLocalCacheInvalidation(engine *Engine, payload string) {
+	invalidation := &localCacheInvalidation{}
+	if err := json.Unmarshal([]byte(payload), invalidation); err != nil {
+		engine.Log().Error(errors.Annotate(err, "local cache invalidation"), nil)
+		return
+	}
+	if invalidation.Instance == engine.registry.registry.localCacheBus.instance {
+		return
+	}
+	for cacheCode, keys := range invalidation.Keys {
+		cache, err := engine.TryGetLocalCache(cacheCode)
+		if err == nil {
+			cache.Remove(keys...)
+		}
+	}
+}
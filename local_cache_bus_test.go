@@ -0,0 +1,27 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalCacheInvalidation(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterLocalCache(100)
+	registry.EnableLocalCacheInvalidation()
+	validated := &validatedRegistry{localCacheContainers: registry.localCacheContainers}
+	engine := validated.clone(registry, nil).CreateEngine()
+	cache := engine.GetLocalCache()
+	cache.MSet("a", 1, "b", 2, "c", 3)
+
+	handleLocalCacheInvalidation(engine, `{"i":"`+registry.localCacheBus.instance+`","k":{"default":["a"]}}`)
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2, "c": 3}, cache.MGet("a", "b", "c"))
+	handleLocalCacheInvalidation(engine, `{"i":"other","k":{"default":["a","b"],"missing":["c"]}}`)
+	assert.Equal(t, map[string]interface{}{"a": nil, "b": nil, "c": 3}, cache.MGet("a", "b", "c"))
+
+	registry = &Registry{}
+	registry.EnableLocalCacheInvalidation("bus")
+	_, err := registry.Validate()
+	assert.EqualError(t, err, "local cache invalidation redis pool 'bus' not found")
+}
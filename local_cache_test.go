@@ -0,0 +1,43 @@
+package orm
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedLRU(t *testing.T) {
+	assert.Len(t, newShardedLRU(100).shards, 1)
+	assert.Len(t, newShardedLRU(300).shards, 4)
+	assert.Len(t, newShardedLRU(100000).shards, maxLocalCacheShards)
+
+	cache := &LocalCache{engine: &Engine{}, code: "default", lru: newShardedLRU(10000)}
+	cache.MSet("a", 1, "b", 2)
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2, "c": nil}, cache.MGet("a", "b", "c"))
+	cache.HMset("h", map[string]interface{}{"x": 1})
+	cache.HMset("h", map[string]interface{}{"y": 2})
+	assert.Equal(t, map[string]interface{}{"x": 1, "y": 2, "z": nil}, cache.HMget("h", "x", "y", "z"))
+	cache.Remove("a")
+	_, has := cache.Get("a")
+	assert.False(t, has)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				key := strconv.Itoa(i*500 + j)
+				cache.Set(key, j)
+				cache.Get(key)
+				cache.HMset("shared", map[string]interface{}{key: j})
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 4000+3, cache.lru.Len())
+	cache.Clear()
+	assert.Equal(t, 0, cache.lru.Len())
+}
@@ -1,6 +1,9 @@
 package orm
 
 import (
+	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/juju/errors"
@@ -11,17 +14,147 @@ import (
 const counterRedisLockObtain = "redis.lockObtain"
 const counterRedisLockRelease = "redis.lockRelease"
 const counterRedisLockTTL = "redis.lockTTL"
+const counterRedisLockRefresh = "redis.lockRefresh"
+const counterRedisLockAcquired = "redis.lockAcquired"
+const counterRedisLockContention = "redis.lockContention"
+const counterRedisLockHoldMicroseconds = "redis.lockHoldMicroseconds"
+
+type LockRenewalError struct {
+	Key string
+	Err error
+}
+
+func (e *LockRenewalError) Error() string {
+	return fmt.Sprintf("lock '%s' renewal failed: %s", e.Key, e.Err.Error())
+}
 
 type lockerClient interface {
-	Obtain(key string, ttl time.Duration, opt *redislock.Options) (*redislock.Lock, error)
+	Obtain(key string, ttl time.Duration, opt *redislock.Options) (lockClient, error)
+}
+
+type lockClient interface {
+	Release() error
+	TTL() (time.Duration, error)
+	Refresh(ttl time.Duration, opt *redislock.Options) error
 }
 
 type standardLockerClient struct {
 	client *redislock.Client
+	prefix string
+}
+
+func (l *standardLockerClient) Obtain(key string, ttl time.Duration, opt *redislock.Options) (lockClient, error) {
+	return l.client.Obtain(l.prefix+key, ttl, opt)
+}
+
+type redLockerClient struct {
+	clients  []*redislock.Client
+	prefixes []string
+}
+
+func (l *redLockerClient) quorum() int {
+	return len(l.clients)/2 + 1
+}
+
+func (l *redLockerClient) Obtain(key string, ttl time.Duration, opt *redislock.Options) (lockClient, error) {
+	retry := opt.RetryStrategy
+	if retry == nil {
+		retry = redislock.NoRetry()
+	}
+	drift := ttl/100 + 2*time.Millisecond
+	for {
+		start := time.Now()
+		locks := make([]*redislock.Lock, 0, len(l.clients))
+		var lastErr error
+		for i, client := range l.clients {
+			lock, err := client.Obtain(l.prefixes[i]+key, ttl, nil)
+			if err == nil {
+				locks = append(locks, lock)
+			} else if err != redislock.ErrNotObtained {
+				lastErr = err
+			}
+		}
+		redLock := &redLock{locks: locks, quorum: l.quorum()}
+		if len(locks) >= l.quorum() && ttl-time.Since(start)-drift > 0 {
+			return redLock, nil
+		}
+		_ = redLock.Release()
+		backoff := retry.NextBackoff()
+		if backoff <= 0 {
+			if len(locks) == 0 && lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, redislock.ErrNotObtained
+		}
+		time.Sleep(backoff)
+	}
+}
+
+type redLock struct {
+	locks  []*redislock.Lock
+	quorum int
+}
+
+func (l *redLock) Release() error {
+	released := 0
+	var lastErr error
+	for _, lock := range l.locks {
+		err := lock.Release()
+		if err == nil {
+			released++
+		} else if err != redislock.ErrLockNotHeld {
+			lastErr = err
+		}
+	}
+	if released == 0 && lastErr != nil {
+		return lastErr
+	}
+	return nil
+}
+
+func (l *redLock) TTL() (time.Duration, error) {
+	ttls := make([]time.Duration, 0, len(l.locks))
+	var lastErr error
+	for _, lock := range l.locks {
+		ttl, err := lock.TTL()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if ttl > 0 {
+			ttls = append(ttls, ttl)
+		}
+	}
+	if len(ttls) < l.quorum {
+		if lastErr != nil {
+			return 0, lastErr
+		}
+		return 0, nil
+	}
+	sort.Slice(ttls, func(i, j int) bool {
+		return ttls[i] > ttls[j]
+	})
+	return ttls[l.quorum-1], nil
 }
 
-func (l *standardLockerClient) Obtain(key string, ttl time.Duration, opt *redislock.Options) (*redislock.Lock, error) {
-	return l.client.Obtain(key, ttl, opt)
+func (l *redLock) Refresh(ttl time.Duration, opt *redislock.Options) error {
+	refreshed := 0
+	var lastErr error
+	for _, lock := range l.locks {
+		err := lock.Refresh(ttl, opt)
+		if err == nil {
+			refreshed++
+		} else {
+			lastErr = err
+		}
+	}
+	if refreshed < l.quorum {
+		if lastErr != nil && lastErr != redislock.ErrNotObtained {
+			return lastErr
+		}
+		return redislock.ErrNotObtained
+	}
+	return nil
 }
 
 type Locker struct {
@@ -37,12 +170,16 @@ func (l *Locker) Obtain(key string, ttl time.Duration, waitTimeout time.Duration
 	if waitTimeout == 0 {
 		waitTimeout = ttl
 	}
-	minInterval := 16 * time.Millisecond
-	maxInterval := 256 * time.Millisecond
-	max := int(waitTimeout / maxInterval)
-	options := &redislock.Options{RetryStrategy: redislock.LimitRetry(redislock.ExponentialBackoff(minInterval, maxInterval), max)}
 	start := time.Now()
-	redisLock, err := l.locker.Obtain(key, ttl, options)
+	redisLock, err := l.locker.Obtain(key, ttl, &redislock.Options{RetryStrategy: redislock.NoRetry()})
+	if err == redislock.ErrNotObtained {
+		l.engine.dataDog.incrementCounter(counterRedisLockContention, 1)
+		minInterval := 16 * time.Millisecond
+		maxInterval := 256 * time.Millisecond
+		max := int(waitTimeout / maxInterval)
+		options := &redislock.Options{RetryStrategy: redislock.LimitRetry(redislock.ExponentialBackoff(minInterval, maxInterval), max)}
+		redisLock, err = l.locker.Obtain(key, ttl, options)
+	}
 	if err != nil {
 		if err == redislock.ErrNotObtained {
 			return nil, false
@@ -59,21 +196,93 @@ func (l *Locker) Obtain(key string, ttl time.Duration, waitTimeout time.Duration
 	}
 	l.engine.dataDog.incrementCounter(counterRedisAll, 1)
 	l.engine.dataDog.incrementCounter(counterRedisLockObtain, 1)
-	return &Lock{lock: redisLock, locker: l, key: key, has: true, engine: l.engine}, true
+	l.engine.dataDog.incrementCounter(counterRedisLockAcquired, 1)
+	return &Lock{lock: redisLock, locker: l, key: key, has: true, engine: l.engine, obtained: time.Now()}, true
+}
+
+func (l *Locker) WithLock(key string, ttl time.Duration, waitTimeout time.Duration, fn func() error) error {
+	lock, has := l.Obtain(key, ttl, waitTimeout)
+	if !has {
+		return errors.Timeoutf("lock '%s' wait timeout", key)
+	}
+	defer lock.Release()
+	return fn()
 }
 
 type Lock struct {
-	lock   *redislock.Lock
-	key    string
-	locker *Locker
-	has    bool
-	engine *Engine
+	lock       lockClient
+	key        string
+	locker     *Locker
+	has        bool
+	engine     *Engine
+	stop       chan struct{}
+	stopped    chan struct{}
+	mutex      sync.Mutex
+	renewalErr error
+	obtained   time.Time
+}
+
+func (l *Lock) AutoRenew(ttl time.Duration, interval time.Duration) {
+	if ttl <= 0 || interval <= 0 {
+		panic(errors.NotValidf("ttl and interval must be greater than zero"))
+	}
+	if interval >= ttl {
+		panic(errors.NotValidf("interval must be lower than ttl"))
+	}
+	if !l.has || l.stop != nil {
+		return
+	}
+	l.stop = make(chan struct{})
+	l.stopped = make(chan struct{})
+	go func() {
+		defer close(l.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				err := l.lock.Refresh(ttl, nil)
+				if err != nil {
+					l.mutex.Lock()
+					l.renewalErr = &LockRenewalError{Key: l.key, Err: err}
+					l.mutex.Unlock()
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (l *Lock) RenewalError() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.renewalErr
+}
+
+func (l *Lock) Refresh(ttl time.Duration) {
+	start := time.Now()
+	err := l.lock.Refresh(ttl, nil)
+	if l.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
+		l.locker.fillLogFields("[ORM][LOCKER][REFRESH]", start, l.key, "refresh lock", err)
+	}
+	l.engine.dataDog.incrementCounter(counterRedisAll, 1)
+	l.engine.dataDog.incrementCounter(counterRedisLockRefresh, 1)
+	if err != nil {
+		panic(&LockRenewalError{Key: l.key, Err: err})
+	}
 }
 
 func (l *Lock) Release() {
 	if !l.has {
 		return
 	}
+	if l.stop != nil {
+		close(l.stop)
+		<-l.stopped
+		l.stop = nil
+	}
 	start := time.Now()
 	err := l.lock.Release()
 	if l.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
@@ -81,6 +290,7 @@ func (l *Lock) Release() {
 	}
 	l.engine.dataDog.incrementCounter(counterRedisAll, 1)
 	l.engine.dataDog.incrementCounter(counterRedisLockRelease, 1)
+	l.engine.dataDog.incrementCounter(counterRedisLockHoldMicroseconds, uint(time.Since(l.obtained).Microseconds()))
 	l.has = false
 }
 
@@ -101,7 +311,7 @@ func (l *Lock) TTL() time.Duration {
 func (l *Locker) fillLogFields(message string, start time.Time, key string, operation string, err error) {
 	now := time.Now()
 	stop := time.Since(start).Microseconds()
-	e := l.engine.queryLoggers[QueryLoggerSourceRedis].log.
+	e := l.engine.queryLoggerEntry(QueryLoggerSourceRedis).
 		WithField("Key", key).
 		WithField("microseconds", stop).
 		WithField("operation", operation).
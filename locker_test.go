@@ -0,0 +1,137 @@
+package orm
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bsm/redislock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocker(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterLocker("default", "default")
+	engine := PrepareTables(t, registry)
+
+	locker := engine.GetLocker()
+	lock, has := locker.Obtain("test_lock", time.Second, 0)
+	assert.True(t, has)
+	_, has = locker.Obtain("test_lock", time.Second, 100*time.Millisecond)
+	assert.False(t, has)
+
+	lock.AutoRenew(time.Second, 300*time.Millisecond)
+	time.Sleep(1200 * time.Millisecond)
+	assert.Nil(t, lock.RenewalError())
+	assert.True(t, lock.TTL() > 0)
+	lock.Release()
+	_, has = locker.Obtain("test_lock", time.Second, 0)
+	assert.True(t, has)
+
+	run := false
+	err := locker.WithLock("test_lock_2", time.Second, 0, func() error {
+		run = true
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.True(t, run)
+	err = locker.WithLock("test_lock_2", time.Second, 0, func() error {
+		return errors.New("test error")
+	})
+	assert.EqualError(t, err, "test error")
+	assert.Equal(t, uint(4), engine.dataDog.counters[counterRedisLockAcquired])
+	assert.Equal(t, uint(1), engine.dataDog.counters[counterRedisLockContention])
+}
+
+type autoRenewLockClient struct {
+	refreshed  int32
+	refreshErr error
+	released   bool
+}
+
+func (c *autoRenewLockClient) Release() error {
+	c.released = true
+	return nil
+}
+
+func (c *autoRenewLockClient) TTL() (time.Duration, error) {
+	return time.Second, nil
+}
+
+func (c *autoRenewLockClient) Refresh(_ time.Duration, _ *redislock.Options) error {
+	atomic.AddInt32(&c.refreshed, 1)
+	return c.refreshErr
+}
+
+func TestLockAutoRenew(t *testing.T) {
+	engine := (&validatedRegistry{}).clone(&Registry{}, nil).CreateEngine()
+	client := &autoRenewLockClient{}
+	lock := &Lock{lock: client, key: "test", has: true, engine: engine, obtained: time.Now()}
+	lock.AutoRenew(time.Second, 10*time.Millisecond)
+	stop := lock.stop
+	lock.AutoRenew(time.Second, 10*time.Millisecond)
+	assert.Equal(t, stop, lock.stop)
+	time.Sleep(100 * time.Millisecond)
+	lock.Release()
+	refreshed := atomic.LoadInt32(&client.refreshed)
+	assert.True(t, refreshed >= 2)
+	assert.True(t, client.released)
+	assert.Nil(t, lock.stop)
+	assert.Nil(t, lock.RenewalError())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, refreshed, atomic.LoadInt32(&client.refreshed))
+
+	client = &autoRenewLockClient{refreshErr: errors.New("lock lost")}
+	lock = &Lock{lock: client, key: "test", has: true, engine: engine, obtained: time.Now()}
+	lock.AutoRenew(time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualError(t, lock.RenewalError(), "lock 'test' renewal failed: lock lost")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&client.refreshed))
+	lock.Release()
+
+	lock = &Lock{lock: &autoRenewLockClient{}, has: false, engine: engine}
+	lock.AutoRenew(time.Second, 10*time.Millisecond)
+	assert.Nil(t, lock.stop)
+}
+
+func TestLockAutoRenewInvalidInterval(t *testing.T) {
+	lock := &Lock{has: true}
+	assert.Panics(t, func() {
+		lock.AutoRenew(time.Second, 0)
+	})
+	assert.Panics(t, func() {
+		lock.AutoRenew(time.Second, -time.Millisecond)
+	})
+	assert.Panics(t, func() {
+		lock.AutoRenew(-time.Second, -2*time.Second)
+	})
+	assert.Nil(t, lock.stop)
+}
+
+func TestRedLock(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterRedis("localhost:6380", 13, "lock_1")
+	registry.RegisterRedis("localhost:6380", 12, "lock_2")
+	registry.RegisterRedis("localhost:6380", 11, "lock_3")
+	registry.RegisterRedLock("red_lock", "lock_1", "lock_2", "lock_3")
+	engine := PrepareTables(t, registry)
+
+	locker := engine.GetLocker("red_lock")
+	lock, has := locker.Obtain("test_lock", time.Second, 0)
+	assert.True(t, has)
+	_, has = locker.Obtain("test_lock", time.Second, 100*time.Millisecond)
+	assert.False(t, has)
+	assert.True(t, lock.TTL() > 0)
+	lock.Refresh(2 * time.Second)
+	assert.True(t, lock.TTL() > time.Second)
+	lock.Release()
+	_, has = locker.Obtain("test_lock", time.Second, 0)
+	assert.True(t, has)
+
+	registry = &Registry{}
+	registry.RegisterRedis("localhost:6380", 13, "lock_1")
+	registry.RegisterRedLock("red_lock", "lock_1")
+	_, err := registry.Validate()
+	assert.EqualError(t, err, "redlock 'red_lock' with 1 redis pools, at least 3 are required not valid")
+}
@@ -0,0 +1,102 @@
+package orm
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+func getClickHouseLogAlters(engine *Engine) (alters []Alter) {
+	alters = make([]Alter, 0)
+	for _, t := range engine.registry.entities {
+		schema := getTableSchema(engine.registry, t)
+		if schema.logClickHouse == "" {
+			continue
+		}
+		ch := engine.GetClickHouse(schema.logClickHouse)
+		rows, def := ch.Queryx("SELECT count() FROM system.tables WHERE database = currentDatabase() AND name = ?", schema.logTableName)
+		total := 0
+		if rows.Next() {
+			err := rows.Scan(&total)
+			if err != nil {
+				def()
+				panic(err)
+			}
+		}
+		def()
+		if total > 0 {
+			continue
+		}
+		/* #nosec */
+		query := fmt.Sprintf("CREATE TABLE `%s` (\n  `entity_id` UInt64,\n  `added_at` DateTime,\n  `meta` Nullable(String),\n  "+
+			"`before` Nullable(String),\n  `changes` Nullable(String)\n) ENGINE = MergeTree() PARTITION BY toYYYYMM(`added_at`) ORDER BY (`entity_id`, `added_at`);",
+			schema.logTableName)
+		alters = append(alters, Alter{SQL: query, Safe: true, Pool: schema.logClickHouse})
+	}
+	return alters
+}
+
+func insertClickHouseLogs(engine *Engine, pool string, tableName string, values []*LogQueueValue) {
+	/* #nosec */
+	query := fmt.Sprintf("INSERT INTO `%s` (`entity_id`, `added_at`, `meta`, `before`, `changes`) VALUES (?, ?, ?, ?, ?)", tableName)
+	batch := engine.GetClickHouse(pool).PrepareBatch(query)
+	defer batch.Abort()
+	for _, value := range values {
+		meta, before, changes := value.marshal()
+		batch.Append(value.ID, value.Updated, meta, before, changes)
+	}
+	batch.Send()
+}
+
+func migrateLogsToClickHouse(engine *Engine, entity Entity, batchSize int) int {
+	schema := initIfNeeded(engine, entity).tableSchema
+	if schema.logClickHouse == "" {
+		panic(errors.Errorf("entity %s is not logged in clickhouse", schema.t.String()))
+	}
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	db := engine.GetMysql(schema.logPoolName)
+	/* #nosec */
+	query := fmt.Sprintf("SELECT `id`, `entity_id`, `added_at`, `meta`, `before`, `changes` FROM `%s` WHERE `id` > ? ORDER BY `id` LIMIT %d",
+		schema.logTableName, batchSize)
+	lastID := uint64(0)
+	total := 0
+	for {
+		values := make([]*LogQueueValue, 0)
+		err := func() error {
+			rows, def := db.Query(query, lastID)
+			defer def()
+			for rows.Next() {
+				var addedAt string
+				var meta, before, changes sql.NullString
+				value := &LogQueueValue{}
+				// Scan panics on error
+				rows.Scan(&lastID, &value.ID, &addedAt, &meta, &before, &changes)
+				updated, err := time.ParseInLocation("2006-01-02 15:04:05", addedAt, time.Local)
+				if err != nil {
+					return errors.Annotatef(err, "log %d added_at", lastID)
+				}
+				value.Updated = updated
+				if err = value.unmarshal(meta, before, changes); err != nil {
+					return errors.Annotatef(err, "log %d", lastID)
+				}
+				values = append(values, value)
+			}
+			return nil
+		}()
+		if err != nil {
+			panic(errors.Trace(err))
+		}
+		if len(values) == 0 {
+			return total
+		}
+		insertClickHouseLogs(engine, schema.logClickHouse, schema.logTableName, values)
+		total += len(values)
+		if len(values) < batchSize {
+			return total
+		}
+	}
+}
@@ -1,23 +1,69 @@
 package orm
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/juju/errors"
 )
 
 const logQueueName = "orm_log"
 
 type LogQueueValue struct {
-	PoolName  string
-	TableName string
-	ID        uint64
-	LogID     uint64
-	Meta      map[string]interface{}
-	Before    map[string]interface{}
-	Changes   map[string]interface{}
-	Updated   time.Time
+	PoolName           string
+	ClickHousePoolName string
+	Writer             string
+	TableName          string
+	ID                 uint64
+	LogID              uint64
+	Meta               map[string]interface{}
+	Before             map[string]interface{}
+	Changes            map[string]interface{}
+	Updated            time.Time
+}
+
+func (v *LogQueueValue) marshal() (meta, before, changes interface{}) {
+	if v.Meta != nil {
+		asJSON, _ := jsoniter.ConfigFastest.MarshalToString(v.Meta)
+		meta = asJSON
+	}
+	if v.Before != nil {
+		asJSON, _ := jsoniter.ConfigFastest.MarshalToString(v.Before)
+		before = asJSON
+	}
+	if v.Changes != nil {
+		asJSON, _ := jsoniter.ConfigFastest.MarshalToString(v.Changes)
+		changes = asJSON
+	}
+	return meta, before, changes
+}
+
+// logNumbersJSON keeps numbers as json.Number, float64 can't hold every uint64 value
+var logNumbersJSON = jsoniter.Config{UseNumber: true}.Froze()
+
+func (v *LogQueueValue) unmarshal(meta, before, changes sql.NullString) error {
+	return v.unmarshalWith(jsoniter.ConfigFastest, meta, before, changes)
+}
+
+func (v *LogQueueValue) unmarshalWith(api jsoniter.API, meta, before, changes sql.NullString) error {
+	if meta.Valid {
+		if err := api.UnmarshalFromString(meta.String, &v.Meta); err != nil {
+			return errors.Annotate(err, "meta")
+		}
+	}
+	if before.Valid {
+		if err := api.UnmarshalFromString(before.String, &v.Before); err != nil {
+			return errors.Annotate(err, "before")
+		}
+	}
+	if changes.Valid {
+		if err := api.UnmarshalFromString(changes.String, &v.Changes); err != nil {
+			return errors.Annotate(err, "changes")
+		}
+	}
+	return nil
 }
 
 type LogReceiver struct {
@@ -57,22 +103,26 @@ func (r *LogReceiver) Digest() {
 		consumer.SetHeartBeat(r.heartBeat)
 	}
 	consumer.Consume(func(items [][]byte) {
+		clickHouseValues := make(map[string]map[string][]*LogQueueValue)
+		customValues := make([]*LogQueueValue, 0)
 		for _, item := range items {
-			var value LogQueueValue
-			_ = jsoniter.ConfigFastest.Unmarshal(item, &value)
+			value := &LogQueueValue{}
+			_ = jsoniter.ConfigFastest.Unmarshal(item, value)
+			if value.Writer != "" {
+				customValues = append(customValues, value)
+				continue
+			}
+			if value.ClickHousePoolName != "" {
+				if clickHouseValues[value.ClickHousePoolName] == nil {
+					clickHouseValues[value.ClickHousePoolName] = make(map[string][]*LogQueueValue)
+				}
+				clickHouseValues[value.ClickHousePoolName][value.TableName] = append(clickHouseValues[value.ClickHousePoolName][value.TableName], value)
+				continue
+			}
 			poolDB := r.engine.GetMysql(value.PoolName)
 			/* #nosec */
 			query := fmt.Sprintf("INSERT INTO `%s`(`entity_id`, `added_at`, `meta`, `before`, `changes`) VALUES(?, ?, ?, ?, ?)", value.TableName)
-			var meta, before, changes interface{}
-			if value.Meta != nil {
-				meta, _ = jsoniter.ConfigFastest.Marshal(value.Meta)
-			}
-			if value.Before != nil {
-				before, _ = jsoniter.ConfigFastest.Marshal(value.Before)
-			}
-			if value.Changes != nil {
-				changes, _ = jsoniter.ConfigFastest.Marshal(value.Changes)
-			}
+			meta, before, changes := value.marshal()
 			func() {
 				if r.Logger != nil {
 					poolDB.Begin()
@@ -81,10 +131,21 @@ func (r *LogReceiver) Digest() {
 				res := poolDB.Exec(query, value.ID, value.Updated.Format("2006-01-02 15:04:05"), meta, before, changes)
 				if r.Logger != nil {
 					value.LogID = res.LastInsertId()
-					r.Logger(&value)
+					r.Logger(value)
 					poolDB.Commit()
 				}
 			}()
 		}
+		writeCustomLogs(r.engine, customValues, r.Logger)
+		for pool, tables := range clickHouseValues {
+			for tableName, values := range tables {
+				insertClickHouseLogs(r.engine, pool, tableName, values)
+				if r.Logger != nil {
+					for _, value := range values {
+						r.Logger(value)
+					}
+				}
+			}
+		}
 	})
 }
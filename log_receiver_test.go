@@ -2,7 +2,9 @@ package orm
 
 import (
 	"database/sql"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -22,6 +24,26 @@ type logReceiverEntity2 struct {
 	Age  uint64
 }
 
+type logRetentionEntity struct {
+	ORM  `orm:"log;logRetentionDays=30;logRetentionVersions=10"`
+	ID   uint
+	Name string
+}
+
+type logWriterEntity struct {
+	ORM  `orm:"logWriter=memory"`
+	ID   uint
+	Name string
+}
+
+type memoryLogWriter struct {
+	tables map[string][]*LogQueueValue
+}
+
+func (w *memoryLogWriter) WriteLogs(_ *Engine, tableName string, values []*LogQueueValue) {
+	w.tables[tableName] = append(w.tables[tableName], values...)
+}
+
 func TestLogReceiver(t *testing.T) {
 	var entity1 *logReceiverEntity1
 	var entity2 *logReceiverEntity2
@@ -126,4 +148,157 @@ func TestLogReceiver(t *testing.T) {
 	assert.False(t, changesNullable.Valid)
 	assert.Equal(t, "{\"Name\": \"John2\", \"Country\": \"Germany\", \"LastName\": \"Summer\"}", before.String)
 	assert.Equal(t, "{\"user_id\": 12}", meta.String)
+
+	logs := engine.GetEntityLogs(e1, 2, nil)
+	assert.Len(t, logs, 3)
+	assert.Equal(t, uint64(4), logs[0].LogID)
+	assert.Nil(t, logs[0].After)
+	assert.Equal(t, "Summer", logs[1].After["LastName"])
+	assert.Equal(t, "Germany", logs[1].Before["Country"])
+	assert.Equal(t, float64(12), logs[2].Meta["user_id"])
+	logs = engine.GetEntityLogs(e1, 2, NewPager(1, 10), &LogFilter{From: time.Now().Add(-time.Hour),
+		Meta: map[string]interface{}{"user_id": 12}})
+	assert.Len(t, logs, 3)
+	logs = engine.GetEntityLogs(e1, 2, NewPager(1, 10), &LogFilter{Meta: map[string]interface{}{"user_id": 13}})
+	assert.Len(t, logs, 0)
+
+	reverted := &logReceiverEntity1{}
+	engine.RevertEntity(reverted, 3)
+	engine.Flush()
+	receiver.Digest()
+	assert.True(t, engine.LoadByID(2, reverted))
+	assert.Equal(t, "John2", reverted.Name)
+	assert.Equal(t, "Summer", reverted.LastName)
+	logs = engine.GetEntityLogs(e1, 2, NewPager(1, 1))
+	assert.Len(t, logs, 1)
+	assert.Equal(t, float64(3), logs[0].Meta["reverted_log_id"])
+	assert.Equal(t, float64(12), logs[0].Meta["user_id"])
+	assert.Panics(t, func() {
+		engine.RevertEntity(&logReceiverEntity1{}, 100)
+	})
+}
+
+func TestLogEntryFill(t *testing.T) {
+	entry := &LogEntry{}
+	err := entry.fill(sql.NullString{String: `{"user_id": 12}`, Valid: true}, sql.NullString{String: `{"Name": "John", "Age": "18"}`, Valid: true},
+		sql.NullString{String: `{"Name": "Tom"}`, Valid: true})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"user_id": float64(12)}, entry.Meta)
+	assert.Equal(t, map[string]interface{}{"Name": "Tom", "Age": "18"}, entry.After)
+	assert.Equal(t, "John", entry.Before["Name"])
+
+	assert.Equal(t, "", logValueToString(nil))
+	assert.Equal(t, "12345678901", logValueToString(float64(12345678901)))
+	assert.Equal(t, "1", logValueToString(true))
+
+	entry = &LogEntry{}
+	err = entry.fillWith(logNumbersJSON, sql.NullString{}, sql.NullString{String: `{"Age": 18446744073709551615}`, Valid: true},
+		sql.NullString{String: `{"Age": 9007199254740993}`, Valid: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "18446744073709551615", logValueToString(entry.Before["Age"]))
+	assert.Equal(t, "9007199254740993", logValueToString(entry.After["Age"]))
+
+	entry = &LogEntry{}
+	err = entry.fill(sql.NullString{}, sql.NullString{String: `{"Name": "John"}`, Valid: true}, sql.NullString{})
+	assert.NoError(t, err)
+	assert.Nil(t, entry.After)
+	assert.Nil(t, entry.Meta)
+
+	err = (&LogEntry{}).fill(sql.NullString{String: `{"user_id"`, Valid: true}, sql.NullString{}, sql.NullString{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "meta")
+
+	value := &LogQueueValue{}
+	err = value.unmarshal(sql.NullString{}, sql.NullString{String: `{"Name": "John"}`, Valid: true}, sql.NullString{String: `{"Name"`, Valid: true})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "changes")
+}
+
+func TestLogActor(t *testing.T) {
+	engine := &Engine{}
+	engine.SetLogActor(Actor{UserID: 12, IP: "127.0.0.1", Source: "api"})
+	assert.Equal(t, Actor{UserID: 12, IP: "127.0.0.1", Source: "api"}, engine.logMetaData["actor"])
+
+	entry := &LogEntry{}
+	err := entry.fill(sql.NullString{String: `{"actor": {"user_id": 12, "ip": "127.0.0.1", "source": "api"}}`, Valid: true},
+		sql.NullString{}, sql.NullString{})
+	assert.NoError(t, err)
+	assert.Equal(t, &Actor{UserID: 12, IP: "127.0.0.1", Source: "api"}, entry.GetActor())
+	assert.Nil(t, (&LogEntry{}).GetActor())
+}
+
+func TestDiffLogEntries(t *testing.T) {
+	older := &LogEntry{After: map[string]interface{}{"Name": "John", "Age": "18", "Country": nil}}
+	newer := &LogEntry{After: map[string]interface{}{"Name": "Tom", "Age": "18", "Country": "Poland", "City": nil}}
+	diff := DiffLogEntries(older, newer)
+	assert.Len(t, diff, 2)
+	assert.Equal(t, Change{Old: "John", New: "Tom"}, diff["Name"])
+	assert.Equal(t, Change{New: "Poland"}, diff["Country"])
+
+	deleted := &LogEntry{Before: newer.After}
+	diff = DiffLogEntries(newer, deleted)
+	assert.Len(t, diff, 3)
+	assert.Equal(t, Change{Old: "18"}, diff["Age"])
+	diff = DiffLogEntries(nil, older)
+	assert.Equal(t, Change{New: "John"}, diff["Name"])
+	assert.Len(t, diff, 2)
+}
+
+func TestLogRetention(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterMySQLPool("root:root@tcp(localhost:3310)/test")
+	schema, err := initTableSchema(registry, reflect.TypeOf(logRetentionEntity{}))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(30), schema.logRetentionDays)
+	assert.Equal(t, uint64(10), schema.logRetentionVersions)
+
+	_, _, err = getLogRetention(map[string]string{"logRetentionDays": "30"}, false, false)
+	assert.EqualError(t, err, "logRetentionDays without log not valid")
+	_, _, err = getLogRetention(map[string]string{"logRetentionDays": "0"}, true, false)
+	assert.EqualError(t, err, "logRetentionDays '0' not valid")
+	_, _, err = getLogRetention(map[string]string{"logRetentionVersions": "5"}, true, true)
+	assert.EqualError(t, err, "logRetentionVersions with clickhouseLog not supported")
+	days, versions, err := getLogRetention(map[string]string{"logRetentionDays": "7"}, true, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), days)
+	assert.Equal(t, uint64(0), versions)
+}
+
+func TestLogWriter(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterMySQLPool("root:root@tcp(localhost:3310)/test")
+	entityType := reflect.TypeOf(logWriterEntity{})
+	_, err := initTableSchema(registry, entityType)
+	assert.EqualError(t, err, "log writer 'memory' not found")
+
+	writer := &memoryLogWriter{tables: make(map[string][]*LogQueueValue)}
+	registry.RegisterLogWriter("memory", writer)
+	schema, err := initTableSchema(registry, entityType)
+	assert.NoError(t, err)
+	assert.True(t, schema.hasLog)
+	assert.True(t, schema.logExternal)
+	values := addToLogQueue(nil, schema, 1, nil, map[string]interface{}{"Name": "John"}, nil)
+	assert.Equal(t, "memory", values[0].Writer)
+
+	validated := (&validatedRegistry{}).clone(registry, nil)
+	engine := validated.CreateEngine()
+	logged := make([]*LogQueueValue, 0)
+	writeCustomLogs(engine, values, func(log *LogQueueValue) {
+		logged = append(logged, log)
+	})
+	assert.Len(t, writer.tables["_log_default_logWriterEntity"], 1)
+	assert.Len(t, logged, 1)
+	values[0].Writer = "missing"
+	assert.PanicsWithError(t, "log writer 'missing' not found", func() {
+		writeCustomLogs(engine, values, nil)
+	})
+
+	registry.RegisterLogWriter("memory", NewMySQLLogWriter("log"))
+	_, err = initTableSchema(registry, entityType)
+	assert.EqualError(t, err, "mysql pool 'log' not found")
+	registry.RegisterMySQLPool("root:root@tcp(localhost:3310)/test_log", "log")
+	schema, err = initTableSchema(registry, entityType)
+	assert.NoError(t, err)
+	assert.False(t, schema.logExternal)
+	assert.Equal(t, "log", schema.logPoolName)
 }
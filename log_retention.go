@@ -0,0 +1,120 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const logPurgeBatchSize = 1000
+const logPurgeInterval = time.Millisecond * 100
+
+func purgeEntityLogs(ctx context.Context, engine *Engine) (deleted uint64) {
+	for _, t := range engine.registry.entities {
+		schema := getTableSchema(engine.registry, t)
+		if !schema.hasLog || schema.logExternal {
+			continue
+		}
+		if schema.logRetentionDays > 0 {
+			deleted += purgeLogsByDays(ctx, engine, schema)
+		}
+		if schema.logRetentionVersions > 0 {
+			deleted += purgeLogsByVersions(ctx, engine, schema)
+		}
+		if ctx.Err() != nil {
+			return deleted
+		}
+	}
+	return deleted
+}
+
+func purgeLogsByDays(ctx context.Context, engine *Engine, schema *tableSchema) uint64 {
+	limit := time.Now().Add(-time.Duration(schema.logRetentionDays) * time.Hour * 24)
+	if schema.logClickHouse != "" {
+		/* #nosec */
+		query := fmt.Sprintf("ALTER TABLE `%s` DELETE WHERE `added_at` < ?", schema.logTableName)
+		engine.GetClickHouse(schema.logClickHouse).Exec(query, limit)
+		return 0
+	}
+	/* #nosec */
+	query := fmt.Sprintf("DELETE FROM `%s` WHERE `added_at` < ? ORDER BY `id` LIMIT %d", schema.logTableName, logPurgeBatchSize)
+	return purgeLogsInBatches(ctx, engine.GetMysql(schema.logPoolName), query, formatLogTime(limit, false))
+}
+
+func purgeLogsByVersions(ctx context.Context, engine *Engine, schema *tableSchema) (deleted uint64) {
+	db := engine.GetMysql(schema.logPoolName)
+	/* #nosec */
+	query := fmt.Sprintf("DELETE FROM `%s` WHERE `entity_id` = ? AND `id` <= ? ORDER BY `id` LIMIT %d",
+		schema.logTableName, logPurgeBatchSize)
+	for _, id := range getLogEntitiesOverRetention(db, schema) {
+		var lastID uint64
+		/* #nosec */
+		where := NewWhere(fmt.Sprintf("SELECT `id` FROM `%s` WHERE `entity_id` = ? ORDER BY `id` DESC LIMIT %d,1",
+			schema.logTableName, schema.logRetentionVersions), id)
+		if !db.QueryRow(where, &lastID) {
+			continue
+		}
+		deleted += purgeLogsInBatches(ctx, db, query, id, lastID)
+		if ctx.Err() != nil {
+			return deleted
+		}
+	}
+	return deleted
+}
+
+func getLogEntitiesOverRetention(db *DB, schema *tableSchema) []uint64 {
+	/* #nosec */
+	query := fmt.Sprintf("SELECT `entity_id` FROM `%s` GROUP BY `entity_id` HAVING COUNT(*) > ?", schema.logTableName)
+	rows, def := db.Query(query, schema.logRetentionVersions)
+	defer def()
+	ids := make([]uint64, 0)
+	for rows.Next() {
+		var id uint64
+		// Scan panics on error, rows are closed in def
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func purgeLogsInBatches(ctx context.Context, db *DB, query string, args ...interface{}) (deleted uint64) {
+	for {
+		affected := db.Exec(query, args...).RowsAffected()
+		deleted += affected
+		if affected < logPurgeBatchSize {
+			return deleted
+		}
+		select {
+		case <-ctx.Done():
+			return deleted
+		case <-time.After(logPurgeInterval):
+		}
+	}
+}
+
+func getLogRetention(tags map[string]string, hasLog bool, clickHouse bool) (days uint64, versions uint64, err error) {
+	for _, name := range []string{"logRetentionDays", "logRetentionVersions"} {
+		value, has := tags[name]
+		if !has {
+			continue
+		}
+		if !hasLog {
+			return 0, 0, errors.NotValidf("%s without log", name)
+		}
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || parsed == 0 {
+			return 0, 0, errors.NotValidf("%s '%s'", name, value)
+		}
+		if name == "logRetentionDays" {
+			days = parsed
+		} else if clickHouse {
+			return 0, 0, errors.NotSupportedf("logRetentionVersions with clickhouseLog")
+		} else {
+			versions = parsed
+		}
+	}
+	return days, versions, nil
+}
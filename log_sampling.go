@@ -0,0 +1,54 @@
+package orm
+
+import (
+	"sync"
+	"time"
+
+	apexLog "github.com/apex/log"
+)
+
+type LogSampling struct {
+	Every        uint64
+	MaxPerSecond int
+}
+
+type samplingHandler struct {
+	handler  apexLog.Handler
+	sampling LogSampling
+	counter  uint64
+	second   int64
+	inSecond int
+	mutex    sync.Mutex
+}
+
+// NewSampledLogHandler drops entries below error level which are not sampled,
+// AddQueryLogger samples every source separately
+func NewSampledLogHandler(handler apexLog.Handler, sampling LogSampling) apexLog.Handler {
+	return &samplingHandler{handler: handler, sampling: sampling}
+}
+
+func (h *samplingHandler) HandleLog(e *apexLog.Entry) error {
+	if e.Level >= apexLog.ErrorLevel {
+		return h.handler.HandleLog(e)
+	}
+	h.mutex.Lock()
+	h.counter++
+	if h.sampling.Every > 1 && (h.counter-1)%h.sampling.Every != 0 {
+		h.mutex.Unlock()
+		return nil
+	}
+	if h.sampling.MaxPerSecond > 0 {
+		now := time.Now().Unix()
+		if now != h.second {
+			h.second = now
+			h.inSecond = 0
+		}
+		if h.inSecond >= h.sampling.MaxPerSecond {
+			h.mutex.Unlock()
+			return nil
+		}
+		h.inSecond++
+	}
+	h.mutex.Unlock()
+	return h.handler.HandleLog(e)
+}
@@ -0,0 +1,42 @@
+package orm
+
+import (
+	"testing"
+
+	apexLog "github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampledLogHandler(t *testing.T) {
+	handler := memory.New()
+	logger := &apexLog.Logger{Handler: NewSampledLogHandler(handler, LogSampling{Every: 3}), Level: apexLog.DebugLevel}
+	for i := 0; i < 10; i++ {
+		logger.Info("test")
+	}
+	assert.Len(t, handler.Entries, 4)
+	logger.Error("test error")
+	assert.Len(t, handler.Entries, 5)
+
+	handler = memory.New()
+	logger = &apexLog.Logger{Handler: NewSampledLogHandler(handler, LogSampling{MaxPerSecond: 5}), Level: apexLog.DebugLevel}
+	for i := 0; i < 100; i++ {
+		logger.Info("test")
+	}
+	assert.LessOrEqual(t, len(handler.Entries), 10)
+	assert.GreaterOrEqual(t, len(handler.Entries), 5)
+}
+
+func TestAddQueryLoggerSampledPerSource(t *testing.T) {
+	engine := (&validatedRegistry{}).clone(&Registry{}, nil).CreateEngine()
+	handler := memory.New()
+	engine.AddQueryLogger(NewSampledLogHandler(handler, LogSampling{Every: 3}), apexLog.DebugLevel,
+		QueryLoggerSourceDB, QueryLoggerSourceRedis)
+	for i := 0; i < 3; i++ {
+		engine.queryLoggers[QueryLoggerSourceDB].log.Info("db")
+		engine.queryLoggers[QueryLoggerSourceRedis].log.Info("redis")
+	}
+	assert.Len(t, handler.Entries, 2)
+	assert.Equal(t, "db", handler.Entries[0].Message)
+	assert.Equal(t, "redis", handler.Entries[1].Message)
+}
@@ -0,0 +1,94 @@
+package orm
+
+import (
+	"fmt"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/juju/errors"
+)
+
+type LogWriter interface {
+	WriteLogs(engine *Engine, tableName string, values []*LogQueueValue)
+}
+
+type mySQLLogWriter struct {
+	pool string
+}
+
+func NewMySQLLogWriter(pool string) LogWriter {
+	return &mySQLLogWriter{pool: pool}
+}
+
+func (w *mySQLLogWriter) WriteLogs(engine *Engine, tableName string, values []*LogQueueValue) {
+	placeholders := make([]string, len(values))
+	args := make([]interface{}, 0, len(values)*5)
+	for i, value := range values {
+		placeholders[i] = "(?, ?, ?, ?, ?)"
+		meta, before, changes := value.marshal()
+		args = append(args, value.ID, value.Updated.Format("2006-01-02 15:04:05"), meta, before, changes)
+	}
+	/* #nosec */
+	query := fmt.Sprintf("INSERT INTO `%s`(`entity_id`, `added_at`, `meta`, `before`, `changes`) VALUES %s", tableName,
+		strings.Join(placeholders, ", "))
+	engine.GetMysql(w.pool).Exec(query, args...)
+}
+
+type clickHouseLogWriter struct {
+	pool string
+}
+
+func NewClickHouseLogWriter(pool string) LogWriter {
+	return &clickHouseLogWriter{pool: pool}
+}
+
+func (w *clickHouseLogWriter) WriteLogs(engine *Engine, tableName string, values []*LogQueueValue) {
+	insertClickHouseLogs(engine, w.pool, tableName, values)
+}
+
+type queueLogWriter struct {
+	queue string
+}
+
+func NewQueueLogWriter(queue string) LogWriter {
+	return &queueLogWriter{queue: queue}
+}
+
+func (w *queueLogWriter) WriteLogs(engine *Engine, _ string, values []*LogQueueValue) {
+	channel := engine.GetRabbitMQQueue(w.queue)
+	for _, value := range values {
+		asJSON, _ := jsoniter.ConfigFastest.Marshal(value)
+		channel.Publish(asJSON)
+	}
+}
+
+func (r *Registry) RegisterLogWriter(code string, writer LogWriter) {
+	if r.logWriters == nil {
+		r.logWriters = make(map[string]LogWriter)
+	}
+	r.logWriters[code] = writer
+}
+
+func writeCustomLogs(engine *Engine, values []*LogQueueValue, logger func(log *LogQueueValue)) {
+	grouped := make(map[string]map[string][]*LogQueueValue)
+	for _, value := range values {
+		if grouped[value.Writer] == nil {
+			grouped[value.Writer] = make(map[string][]*LogQueueValue)
+		}
+		grouped[value.Writer][value.TableName] = append(grouped[value.Writer][value.TableName], value)
+	}
+	for code, tables := range grouped {
+		writer, has := engine.registry.registry.logWriters[code]
+		if !has {
+			panic(errors.NotFoundf("log writer '%s'", code))
+		}
+		for tableName, rows := range tables {
+			writer.WriteLogs(engine, tableName, rows)
+			if logger != nil {
+				for _, value := range rows {
+					logger(value)
+				}
+			}
+		}
+	}
+}
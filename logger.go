@@ -1,12 +1,15 @@
 package orm
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"reflect"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/segmentio/fasthash/fnv1a"
@@ -15,7 +18,6 @@ import (
 
 	apexLog "github.com/apex/log"
 	"github.com/apex/log/handlers/multi"
-	jsoniter "github.com/json-iterator/go"
 )
 
 type log struct {
@@ -124,7 +126,20 @@ func (l *log) Error(err interface{}, fields apexLog.Fielder) {
 	log.WithFields(errorFields).Error(message)
 }
 
-type jsonHandler struct{}
+type jsonHandler struct {
+	writer io.Writer
+	mutex  sync.Mutex
+	// stable field names and UTC timestamps are used only in handler from NewJSONLogHandler,
+	// EnableLogger keeps original field names
+	stable bool
+}
+
+var jsonLogFieldNames = map[string]string{"Query": "query", "Key": "key", "Keys": "keys", "Queue": "queue", "Router": "router",
+	"Index": "index", "microseconds": "duration_us", "target": "source"}
+
+func NewJSONLogHandler(writer io.Writer) apexLog.Handler {
+	return &jsonHandler{writer: writer, stable: true}
+}
 
 func clearStack(stack []string) []string {
 	cleared := make([]string, len(stack))
@@ -144,14 +159,42 @@ func clearStack(stack []string) []string {
 }
 
 func (h *jsonHandler) HandleLog(e *apexLog.Entry) error {
-	fields := e.Fields
-	fields["level"] = e.Level
-	fields["timestamp"] = e.Timestamp
+	fields := make(map[string]interface{}, len(e.Fields)+3)
+	for k, v := range e.Fields {
+		if !h.stable {
+			fields[k] = v
+			continue
+		}
+		name, has := jsonLogFieldNames[k]
+		if !has {
+			name = k
+		}
+		if k == "error" {
+			asErr, is := v.(error)
+			if is {
+				v = asErr.Error()
+			}
+		}
+		fields[name] = v
+	}
+	if h.stable {
+		fields["level"] = e.Level.String()
+		fields["timestamp"] = e.Timestamp.UTC().Format(time.RFC3339Nano)
+	} else {
+		fields["level"] = e.Level
+		fields["timestamp"] = e.Timestamp
+	}
 	fields["message"] = e.Message
-	b, err := jsoniter.ConfigFastest.MarshalToString(fields)
+	b, err := json.Marshal(fields)
 	if err != nil {
 		return err
 	}
-	_, _ = os.Stderr.WriteString(b + "\n")
-	return nil
+	writer := h.writer
+	if writer == nil {
+		writer = os.Stderr
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, err = writer.Write(append(b, '\n'))
+	return err
 }
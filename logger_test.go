@@ -0,0 +1,44 @@
+package orm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	apexLog "github.com/apex/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLogHandler(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := &apexLog.Logger{Handler: NewJSONLogHandler(buffer), Level: apexLog.DebugLevel}
+	logger.WithField("Query", "SELECT 1").WithField("microseconds", 12).WithField("target", "mysql").Info("[ORM][MYSQL][SELECT]")
+	logger.WithError(errors.New("test error")).Error("failed")
+
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+	var data map[string]interface{}
+	assert.Nil(t, json.Unmarshal(lines[0], &data))
+	assert.Equal(t, "SELECT 1", data["query"])
+	assert.Equal(t, float64(12), data["duration_us"])
+	assert.Equal(t, "mysql", data["source"])
+	assert.Equal(t, "info", data["level"])
+	assert.Equal(t, "[ORM][MYSQL][SELECT]", data["message"])
+	assert.NotEmpty(t, data["timestamp"])
+
+	data = nil
+	assert.Nil(t, json.Unmarshal(lines[1], &data))
+	assert.Equal(t, "test error", data["error"])
+	assert.Equal(t, "error", data["level"])
+
+	buffer.Reset()
+	logger = &apexLog.Logger{Handler: &jsonHandler{writer: buffer}, Level: apexLog.DebugLevel}
+	logger.WithField("Query", "SELECT 1").WithField("microseconds", 12).Info("[ORM][MYSQL][SELECT]")
+	data = nil
+	assert.Nil(t, json.Unmarshal(bytes.TrimSpace(buffer.Bytes()), &data))
+	assert.Equal(t, "SELECT 1", data["Query"])
+	assert.Equal(t, float64(12), data["microseconds"])
+	assert.NotContains(t, data, "query")
+	assert.Equal(t, "info", data["level"])
+}
@@ -0,0 +1,174 @@
+package orm
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+type MarshalOptions struct {
+	WithID          bool
+	ReferencesAsIDs bool
+	OmitZero        bool
+}
+
+var defaultMarshalOptions = &MarshalOptions{WithID: true}
+
+var entityInterfaceType = reflect.TypeOf((*Entity)(nil)).Elem()
+
+func MarshalEntity(entity Entity, options *MarshalOptions) ([]byte, error) {
+	if options == nil {
+		options = defaultMarshalOptions
+	}
+	return marshalEntity(reflect.ValueOf(entity).Elem(), entity.getORM().tableSchema, "", options)
+}
+
+func marshalEntity(elem reflect.Value, schema *tableSchema, tagName string, options *MarshalOptions) ([]byte, error) {
+	if schema != nil {
+		tagName = schema.tagName
+	}
+	t := elem.Type()
+	buffer := &bytes.Buffer{}
+	buffer.WriteString("{")
+	first := true
+	for i := 1; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || (i == 1 && !options.WithID) || field.Type.String() == "*orm.CachedQuery" {
+			continue
+		}
+		if isIgnoredField(schema, tagName, field) {
+			continue
+		}
+		name := field.Name
+		jsonTag := strings.Split(field.Tag.Get("json"), ",")
+		if jsonTag[0] == "-" {
+			continue
+		}
+		if jsonTag[0] != "" {
+			name = jsonTag[0]
+		}
+		value := elem.Field(i)
+		if options.OmitZero && value.IsZero() {
+			continue
+		}
+		encoded, err := marshalValue(value, tagName, options)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid field %s", field.Name)
+		}
+		if !first {
+			buffer.WriteString(",")
+		}
+		first = false
+		encodedName, _ := json.Marshal(name)
+		buffer.Write(encodedName)
+		buffer.WriteString(":")
+		buffer.Write(encoded)
+	}
+	buffer.WriteString("}")
+	return buffer.Bytes(), nil
+}
+
+// marshalValue encodes entities found in field value with entity rules, also entities not initialized by engine
+func marshalValue(value reflect.Value, tagName string, options *MarshalOptions) ([]byte, error) {
+	if !mayContainEntity(value.Type()) {
+		return json.Marshal(value.Interface())
+	}
+	switch value.Kind() {
+	case reflect.Interface:
+		if value.IsNil() {
+			return []byte("null"), nil
+		}
+		return marshalValue(value.Elem(), tagName, options)
+	case reflect.Ptr:
+		if value.IsNil() {
+			return []byte("null"), nil
+		}
+		ref, isReference := value.Interface().(Entity)
+		if !isReference {
+			return json.Marshal(value.Interface())
+		}
+		if options.ReferencesAsIDs {
+			return json.Marshal(value.Elem().Field(1).Uint())
+		}
+		return marshalEntity(value.Elem(), ref.getORM().tableSchema, tagName, options)
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return []byte("null"), nil
+		}
+		buffer := &bytes.Buffer{}
+		buffer.WriteString("[")
+		for i := 0; i < value.Len(); i++ {
+			encoded, err := marshalValue(value.Index(i), tagName, options)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.Write(encoded)
+		}
+		buffer.WriteString("]")
+		return buffer.Bytes(), nil
+	case reflect.Map:
+		if value.IsNil() {
+			return []byte("null"), nil
+		}
+		keys := make([]string, 0, value.Len())
+		for _, key := range value.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		buffer := &bytes.Buffer{}
+		buffer.WriteString("{")
+		for i, key := range keys {
+			encoded, err := marshalValue(value.MapIndex(reflect.ValueOf(key).Convert(value.Type().Key())), tagName, options)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			encodedKey, _ := json.Marshal(key)
+			buffer.Write(encodedKey)
+			buffer.WriteString(":")
+			buffer.Write(encoded)
+		}
+		buffer.WriteString("}")
+		return buffer.Bytes(), nil
+	}
+	return json.Marshal(value.Interface())
+}
+
+func mayContainEntity(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr:
+		return t.Implements(entityInterfaceType)
+	case reflect.Slice, reflect.Array:
+		return mayContainEntity(t.Elem())
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && mayContainEntity(t.Elem())
+	}
+	return false
+}
+
+func isIgnoredField(schema *tableSchema, tagName string, field reflect.StructField) bool {
+	if schema != nil {
+		_, has := schema.tags[field.Name]["ignore"]
+		return has
+	}
+	if tagName == "" {
+		tagName = "orm"
+	}
+	for _, arg := range strings.Split(field.Tag.Get(tagName), ";") {
+		if arg == "ignore" {
+			return true
+		}
+	}
+	return false
+}
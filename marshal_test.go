@@ -0,0 +1,75 @@
+package orm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type marshalEntityAddress struct {
+	City string
+}
+
+type marshalEntityRef struct {
+	ORM
+	ID   uint
+	Name string
+}
+
+type marshalEntityTest struct {
+	ORM
+	ID       uint
+	Name     string
+	Age      int    `json:"age"`
+	Password string `json:"-"`
+	Cache    string `orm:"ignore"`
+	Address  marshalEntityAddress
+	Ref      *marshalEntityRef
+	Items    interface{}
+	secret   string
+}
+
+func TestMarshalEntity(t *testing.T) {
+	entity := &marshalEntityTest{ID: 2, Name: "Tom", Password: "secret", Cache: "a", secret: "b"}
+	entity.Address.City = "Berlin"
+	entity.Ref = &marshalEntityRef{ID: 7, Name: "John"}
+
+	encoded, err := MarshalEntity(entity, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ID":2,"Name":"Tom","age":0,"Address":{"City":"Berlin"},"Ref":{"ID":7,"Name":"John"},"Items":null}`, string(encoded))
+
+	encoded, err = MarshalEntity(entity, &MarshalOptions{ReferencesAsIDs: true, OmitZero: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Name":"Tom","Address":{"City":"Berlin"},"Ref":7}`, string(encoded))
+
+	entity.Ref = nil
+	encoded, err = MarshalEntity(entity, &MarshalOptions{WithID: true, ReferencesAsIDs: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ID":2,"Name":"Tom","age":0,"Address":{"City":"Berlin"},"Ref":null,"Items":null}`, string(encoded))
+
+	entity.Items = []interface{}{&marshalEntityRef{ID: 8, Name: "Adam"}, "x", map[string]*marshalEntityRef{"b": nil, "a": {ID: 9}}}
+	encoded, err = MarshalEntity(entity, &MarshalOptions{OmitZero: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Name":"Tom","Address":{"City":"Berlin"},"Items":[{"Name":"Adam"},"x",{"a":{},"b":null}]}`, string(encoded))
+	encoded, err = MarshalEntity(entity, &MarshalOptions{ReferencesAsIDs: true, OmitZero: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Name":"Tom","Address":{"City":"Berlin"},"Items":[8,"x",{"a":9,"b":null}]}`, string(encoded))
+
+	entity.Items = nil
+	encoded, err = json.Marshal(entity)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ID":2,"Name":"Tom","age":0,"Cache":"a","Address":{"City":"Berlin"},"Ref":null,"Items":null}`, string(encoded))
+}
+
+func TestMarshalEntityLoaded(t *testing.T) {
+	var entity *marshalEntityTest
+	var ref *marshalEntityRef
+	engine := PrepareTables(t, &Registry{}, entity, ref)
+
+	entity = &marshalEntityTest{Name: "Tom", Ref: &marshalEntityRef{Name: "John"}}
+	engine.TrackAndFlush(entity)
+	encoded, err := MarshalEntity(entity, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ID":1,"Name":"Tom","age":0,"Address":{"City":""},"Ref":{"ID":1,"Name":"John"},"Items":null}`, string(encoded))
+}
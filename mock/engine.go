@@ -0,0 +1,289 @@
+package mock
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/juju/errors"
+
+	"github.com/summer-solutions/orm"
+)
+
+type Engine interface {
+	Track(entity ...orm.Entity)
+	TrackAndFlush(entity ...orm.Entity)
+	Flush()
+	FlushWithCheck() error
+	MarkToDelete(entity ...orm.Entity)
+	ClearTrackedEntities()
+	LoadByID(id uint64, entity orm.Entity, references ...string) (found bool)
+	LoadByIDs(ids []uint64, entities interface{}, references ...string) (missing []uint64)
+	Search(where *orm.Where, pager *orm.Pager, entities interface{}, references ...string)
+	SearchWithCount(where *orm.Where, pager *orm.Pager, entities interface{}, references ...string) (totalRows int)
+	SearchOne(where *orm.Where, entity orm.Entity, references ...string) (found bool)
+	SearchIDs(where *orm.Where, pager *orm.Pager, entity orm.Entity) []uint64
+}
+
+var _ Engine = &orm.Engine{}
+var _ Engine = &MockEngine{}
+
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+type MockEngine struct {
+	mutex        sync.Mutex
+	data         map[reflect.Type]map[uint64]reflect.Value
+	ids          map[reflect.Type]uint64
+	tracked      []orm.Entity
+	deleted      map[orm.Entity]bool
+	expectations []*searchExpectation
+}
+
+type searchExpectation struct {
+	t          reflect.Type
+	query      string
+	parameters []interface{}
+	results    []orm.Entity
+	used       bool
+}
+
+func NewMockEngine() *MockEngine {
+	return &MockEngine{}
+}
+
+func (m *MockEngine) ExpectSearch(where *orm.Where, results ...orm.Entity) {
+	m.expectSearch(nil, where, results)
+}
+
+func (m *MockEngine) ExpectSearchForEntity(entity orm.Entity, where *orm.Where, results ...orm.Entity) {
+	m.expectSearch(entityType(entity), where, results)
+}
+
+func (m *MockEngine) AssertExpectations(t TestingT) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	valid := true
+	for _, expectation := range m.expectations {
+		if !expectation.used {
+			t.Errorf("expected search was not executed: %s %v", expectation.query, expectation.parameters)
+			valid = false
+		}
+	}
+	return valid
+}
+
+func (m *MockEngine) Add(entity ...orm.Entity) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, row := range entity {
+		m.save(row)
+	}
+}
+
+func (m *MockEngine) Count(entity orm.Entity) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.data[entityType(entity)])
+}
+
+func (m *MockEngine) Track(entity ...orm.Entity) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tracked = append(m.tracked, entity...)
+}
+
+func (m *MockEngine) TrackAndFlush(entity ...orm.Entity) {
+	m.Track(entity...)
+	m.Flush()
+}
+
+func (m *MockEngine) Flush() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, entity := range m.tracked {
+		if m.deleted[entity] {
+			t := entityType(entity)
+			delete(m.data[t], getID(entity))
+			continue
+		}
+		m.save(entity)
+	}
+	m.tracked = nil
+	m.deleted = nil
+}
+
+func (m *MockEngine) FlushWithCheck() error {
+	m.Flush()
+	return nil
+}
+
+func (m *MockEngine) MarkToDelete(entity ...orm.Entity) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.deleted == nil {
+		m.deleted = make(map[orm.Entity]bool)
+	}
+	for _, row := range entity {
+		m.deleted[row] = true
+		m.tracked = append(m.tracked, row)
+	}
+}
+
+func (m *MockEngine) ClearTrackedEntities() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tracked = nil
+	m.deleted = nil
+}
+
+func (m *MockEngine) LoadByID(id uint64, entity orm.Entity, references ...string) (found bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	value, has := m.data[entityType(entity)][id]
+	if !has {
+		return false
+	}
+	copyEntity(value, reflect.ValueOf(entity).Elem())
+	return true
+}
+
+func (m *MockEngine) LoadByIDs(ids []uint64, entities interface{}, references ...string) (missing []uint64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	slice := reflect.ValueOf(entities).Elem()
+	t := slice.Type().Elem().Elem()
+	slice.SetLen(0)
+	for _, id := range ids {
+		value, has := m.data[t][id]
+		if !has {
+			missing = append(missing, id)
+			continue
+		}
+		slice.Set(reflect.Append(slice, newCopy(value)))
+	}
+	return missing
+}
+
+func (m *MockEngine) Search(where *orm.Where, pager *orm.Pager, entities interface{}, references ...string) {
+	m.SearchWithCount(where, pager, entities, references...)
+}
+
+func (m *MockEngine) SearchWithCount(where *orm.Where, pager *orm.Pager, entities interface{}, references ...string) (totalRows int) {
+	slice := reflect.ValueOf(entities).Elem()
+	results := m.search(slice.Type().Elem().Elem(), where)
+	slice.SetLen(0)
+	for _, row := range page(results, pager) {
+		slice.Set(reflect.Append(slice, newCopy(reflect.ValueOf(row).Elem())))
+	}
+	return len(results)
+}
+
+func (m *MockEngine) SearchOne(where *orm.Where, entity orm.Entity, references ...string) (found bool) {
+	results := m.search(entityType(entity), where)
+	if len(results) == 0 {
+		return false
+	}
+	copyEntity(reflect.ValueOf(results[0]).Elem(), reflect.ValueOf(entity).Elem())
+	return true
+}
+
+func (m *MockEngine) SearchIDs(where *orm.Where, pager *orm.Pager, entity orm.Entity) []uint64 {
+	results := page(m.search(entityType(entity), where), pager)
+	ids := make([]uint64, len(results))
+	for i, row := range results {
+		ids[i] = getID(row)
+	}
+	return ids
+}
+
+func (m *MockEngine) expectSearch(t reflect.Type, where *orm.Where, results []orm.Entity) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if t == nil && len(results) > 0 {
+		t = entityType(results[0])
+	}
+	if t == nil {
+		panic(errors.NotValidf("empty search expectation without entity, use ExpectSearchForEntity"))
+	}
+	m.expectations = append(m.expectations, &searchExpectation{t: t, query: where.String(), parameters: where.GetParameters(), results: results})
+}
+
+func (m *MockEngine) search(t reflect.Type, where *orm.Where) []orm.Entity {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, expectation := range m.expectations {
+		if expectation.t == t && expectation.query == where.String() &&
+			fmt.Sprintf("%v", expectation.parameters) == fmt.Sprintf("%v", where.GetParameters()) {
+			expectation.used = true
+			return expectation.results
+		}
+	}
+	panic(errors.NotFoundf("search expectation for %s: %s %v", t.String(), where.String(), where.GetParameters()))
+}
+
+func (m *MockEngine) save(entity orm.Entity) {
+	t := entityType(entity)
+	elem := reflect.ValueOf(entity).Elem()
+	id := getID(entity)
+	if id == 0 {
+		if m.ids == nil {
+			m.ids = make(map[reflect.Type]uint64)
+		}
+		m.ids[t]++
+		id = m.ids[t]
+		elem.FieldByName("ID").SetUint(id)
+	} else if id > m.ids[t] {
+		if m.ids == nil {
+			m.ids = make(map[reflect.Type]uint64)
+		}
+		m.ids[t] = id
+	}
+	if m.data == nil {
+		m.data = make(map[reflect.Type]map[uint64]reflect.Value)
+	}
+	if m.data[t] == nil {
+		m.data[t] = make(map[uint64]reflect.Value)
+	}
+	stored := reflect.New(t).Elem()
+	copyEntity(elem, stored)
+	m.data[t][id] = stored
+}
+
+func page(results []orm.Entity, pager *orm.Pager) []orm.Entity {
+	if pager == nil {
+		return results
+	}
+	start := (pager.GetCurrentPage() - 1) * pager.GetPageSize()
+	if start >= len(results) {
+		return nil
+	}
+	end := start + pager.GetPageSize()
+	if end > len(results) {
+		end = len(results)
+	}
+	return results[start:end]
+}
+
+func entityType(entity orm.Entity) reflect.Type {
+	return reflect.TypeOf(entity).Elem()
+}
+
+func getID(entity orm.Entity) uint64 {
+	return reflect.ValueOf(entity).Elem().FieldByName("ID").Uint()
+}
+
+func newCopy(source reflect.Value) reflect.Value {
+	target := reflect.New(source.Type())
+	copyEntity(source, target.Elem())
+	return target
+}
+
+func copyEntity(source reflect.Value, target reflect.Value) {
+	for i := 1; i < source.NumField(); i++ {
+		if target.Field(i).CanSet() {
+			target.Field(i).Set(source.Field(i))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/summer-solutions/orm"
+)
+
+type mockEntity struct {
+	orm.ORM
+	ID   uint
+	Name string
+}
+
+type testingT struct {
+	errors int
+}
+
+func (t *testingT) Errorf(format string, args ...interface{}) {
+	t.errors++
+}
+
+func TestMockEngine(t *testing.T) {
+	engine := NewMockEngine()
+	entity := &mockEntity{Name: "Tom"}
+	engine.TrackAndFlush(entity, &mockEntity{Name: "John"})
+	assert.Equal(t, uint(1), entity.ID)
+	assert.Equal(t, 2, engine.Count(entity))
+
+	entity.Name = "Tom 2"
+	loaded := &mockEntity{}
+	assert.True(t, engine.LoadByID(1, loaded))
+	assert.Equal(t, "Tom", loaded.Name)
+	assert.False(t, engine.LoadByID(3, loaded))
+
+	var rows []*mockEntity
+	missing := engine.LoadByIDs([]uint64{1, 2, 3}, &rows)
+	assert.Equal(t, []uint64{3}, missing)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "John", rows[1].Name)
+
+	engine.MarkToDelete(loaded)
+	engine.Flush()
+	assert.Equal(t, 1, engine.Count(entity))
+
+	where := orm.NewWhere("`Name` = ?", "John")
+	engine.ExpectSearch(where, rows[1])
+	engine.ExpectSearchForEntity(entity, orm.NewWhere("`Name` = ?", "Adam"))
+	total := engine.SearchWithCount(orm.NewWhere("`Name` = ?", "John"), orm.NewPager(1, 10), &rows)
+	assert.Equal(t, 1, total)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, []uint64{2}, engine.SearchIDs(where, orm.NewPager(1, 10), entity))
+	assert.Len(t, engine.SearchIDs(where, orm.NewPager(2, 10), entity), 0)
+	assert.False(t, engine.SearchOne(orm.NewWhere("`Name` = ?", "Adam"), entity))
+	assert.Panics(t, func() {
+		engine.SearchOne(orm.NewWhere("`Name` = ?", "Bob"), entity)
+	})
+	assert.True(t, engine.AssertExpectations(t))
+
+	engine.ExpectSearch(orm.NewWhere("`Name` = ?", "Bob"), rows[0])
+	mockT := &testingT{}
+	assert.False(t, engine.AssertExpectations(mockT))
+	assert.Equal(t, 1, mockT.errors)
+}
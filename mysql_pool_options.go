@@ -0,0 +1,85 @@
+package orm
+
+import (
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/juju/errors"
+)
+
+// MySQLPoolOptions are applied to data source name, parseTime is always disabled
+// because orm scans date and time columns as strings
+type MySQLPoolOptions struct {
+	Timeout      time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	TLSConfig    string
+	Collation    string
+	Params       map[string]string
+	// InterpolateReads runs read queries outside transactions through a second pool
+	// which interpolates parameters client-side instead of preparing statements
+	InterpolateReads bool
+}
+
+func (r *Registry) RegisterMySQLPoolWithOptions(dataSourceName string, options MySQLPoolOptions, code ...string) {
+	r.registerSQLPool(dataSourceName, code...)
+	dbCode := "default"
+	if len(code) > 0 {
+		dbCode = code[0]
+	}
+	r.sqlClients[dbCode].options = &options
+}
+
+func applyMySQLPoolOptions(v *DBConfig) error {
+	if v.options == nil {
+		return nil
+	}
+	config, err := mysql.ParseDSN(v.dataSourceName)
+	if err != nil {
+		return errors.Annotatef(err, "invalid mysql pool '%s' data source name", v.code)
+	}
+	options := v.options
+	if options.Timeout < 0 || options.ReadTimeout < 0 || options.WriteTimeout < 0 {
+		return errors.NotValidf("negative timeout in mysql pool '%s' options", v.code)
+	}
+	config.ParseTime = false
+	if options.Timeout > 0 {
+		config.Timeout = options.Timeout
+	}
+	if options.ReadTimeout > 0 {
+		config.ReadTimeout = options.ReadTimeout
+	}
+	if options.WriteTimeout > 0 {
+		config.WriteTimeout = options.WriteTimeout
+	}
+	if options.TLSConfig != "" {
+		config.TLSConfig = options.TLSConfig
+	}
+	if options.Collation != "" {
+		config.Collation = options.Collation
+	}
+	for key, value := range options.Params {
+		if config.Params == nil {
+			config.Params = make(map[string]string)
+		}
+		config.Params[key] = value
+	}
+	dataSourceName := config.FormatDSN()
+	_, err = mysql.ParseDSN(dataSourceName)
+	if err != nil {
+		return errors.Annotatef(err, "invalid mysql pool '%s' options", v.code)
+	}
+	if options.InterpolateReads {
+		readConfig := config.Clone()
+		readConfig.InterpolateParams = true
+		readDataSourceName := readConfig.FormatDSN()
+		_, err = mysql.ParseDSN(readDataSourceName)
+		if err != nil {
+			return errors.Annotatef(err, "invalid mysql pool '%s' options", v.code)
+		}
+		v.readDataSourceName = readDataSourceName
+	}
+	v.dataSourceName = dataSourceName
+	v.options = nil
+	return nil
+}
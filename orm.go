@@ -1,6 +1,7 @@
 package orm
 
 import (
+	"database/sql"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/juju/errors"
+	"github.com/shopspring/decimal"
 )
 
 type Entity interface {
@@ -24,6 +26,9 @@ type entityAttributes struct {
 	elem                 reflect.Value
 	idElem               reflect.Value
 	logMeta              map[string]interface{}
+	expressions          map[string]Expr
+	forcedDirty          []string
+	generatedID          bool
 }
 
 type ORM struct {
@@ -96,13 +101,24 @@ func (orm *ORM) SetField(field string, value interface{}) error {
 		if value == nil {
 			f.Set(reflect.Zero(f.Type()))
 		} else {
-			f.SetString(fmt.Sprintf("%v", value))
+			asString := fmt.Sprintf("%v", value)
+			if asString != "" {
+				err := orm.tableSchema.validateEnumValues(field, asString)
+				if err != nil {
+					return err
+				}
+			}
+			f.SetString(asString)
 		}
 	case "[]string":
-		_, ok := value.([]string)
+		values, ok := value.([]string)
 		if !ok {
 			return errors.NotValidf("%s value %v", field, value)
 		}
+		err := orm.tableSchema.validateEnumValues(field, values...)
+		if err != nil {
+			return err
+		}
 		f.Set(reflect.ValueOf(value))
 	case "[]uint8":
 		_, ok := value.([]uint8)
@@ -130,6 +146,28 @@ func (orm *ORM) SetField(field string, value interface{}) error {
 			val = parsed
 		}
 		f.SetFloat(val)
+	case "decimal.Decimal", "*decimal.Decimal":
+		var val *decimal.Decimal
+		switch v := value.(type) {
+		case nil:
+		case decimal.Decimal:
+			val = &v
+		case *decimal.Decimal:
+			val = v
+		default:
+			parsed, err := decimal.NewFromString(strings.ReplaceAll(fmt.Sprintf("%v", value), ",", "."))
+			if err != nil {
+				return errors.NotValidf("%s value %v", field, value)
+			}
+			val = &parsed
+		}
+		if typeName == "*decimal.Decimal" {
+			f.Set(reflect.ValueOf(val))
+		} else if val == nil {
+			f.Set(reflect.Zero(f.Type()))
+		} else {
+			f.Set(reflect.ValueOf(*val))
+		}
 	case "*time.Time":
 		_, ok := value.(*time.Time)
 		if !ok {
@@ -146,6 +184,31 @@ func (orm *ORM) SetField(field string, value interface{}) error {
 		f.Set(reflect.ValueOf(value))
 	default:
 		k := f.Type().Kind().String()
+		if isValuerType(f.Type()) {
+			if value == nil {
+				f.Set(reflect.Zero(f.Type()))
+				return nil
+			}
+			if reflect.TypeOf(value) == f.Type() {
+				f.Set(reflect.ValueOf(value))
+				return nil
+			}
+			t := f.Type()
+			if k == "ptr" {
+				t = t.Elem()
+			}
+			val := reflect.New(t)
+			err := val.Interface().(sql.Scanner).Scan(value)
+			if err != nil {
+				return errors.NotValidf("%s value %v", field, value)
+			}
+			if k == "ptr" {
+				f.Set(val)
+			} else {
+				f.Set(val.Elem())
+			}
+			return nil
+		}
 		if k == "struct" {
 			return errors.NotSupportedf("%s", field)
 		} else if k == "ptr" {
@@ -0,0 +1,27 @@
+package ormtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/summer-solutions/orm"
+)
+
+type ormTestEntity struct {
+	orm.ORM `orm:"localCache;redisCache"`
+	ID      uint
+	Name    string
+}
+
+func TestNewEngine(t *testing.T) {
+	engine, cleanup := NewEngine(t, &Config{Entities: []orm.Entity{&ormTestEntity{}}})
+	defer cleanup()
+
+	entity := &ormTestEntity{Name: "Test"}
+	engine.TrackAndFlush(entity)
+	assert.Equal(t, uint(1), entity.ID)
+	assert.True(t, engine.LoadByID(1, &ormTestEntity{}))
+	cleanup()
+	assert.False(t, engine.LoadByID(1, &ormTestEntity{}))
+}
@@ -1,5 +1,11 @@
 package orm
 
+import (
+	"reflect"
+
+	"github.com/juju/errors"
+)
+
 type Pager struct {
 	CurrentPage int
 	PageSize    int
@@ -23,3 +29,55 @@ func (pager *Pager) GetCurrentPage() int {
 func (pager *Pager) IncrementPage() {
 	pager.CurrentPage++
 }
+
+func (pager *Pager) GetTotalPages(total int) int {
+	if pager.PageSize <= 0 || total <= 0 {
+		return 0
+	}
+	return (total + pager.PageSize - 1) / pager.PageSize
+}
+
+func (pager *Pager) Iterate(engine *Engine, where *Where, entities interface{}, fn func() bool) {
+	checkPageSize(engine, pager)
+	value := reflect.ValueOf(entities).Elem()
+	for {
+		search(true, engine, where, pager, false, value)
+		if value.Len() == 0 || !fn() || value.Len() < pager.PageSize {
+			return
+		}
+		pager.IncrementPage()
+	}
+}
+
+func (r *Registry) SetMaxPageSize(size int) {
+	r.maxPageSize = size
+}
+
+const defaultPageSize = 50000
+
+const maxInt = int(^uint(0) >> 1)
+
+// searchPager returns default pager when pager is nil, so limits are checked also for default page size
+func searchPager(engine *Engine, pager *Pager) *Pager {
+	if pager == nil {
+		pager = NewPager(1, defaultPageSize)
+	}
+	checkPageSize(engine, pager)
+	return pager
+}
+
+func checkPageSize(engine *Engine, pager *Pager) {
+	if pager == nil {
+		return
+	}
+	if pager.PageSize <= 0 {
+		panic(errors.NotValidf("page size %d", pager.PageSize))
+	}
+	if pager.CurrentPage <= 0 || pager.CurrentPage-1 > (maxInt-pager.PageSize)/pager.PageSize {
+		panic(errors.NotValidf("page %d", pager.CurrentPage))
+	}
+	max := engine.registry.registry.maxPageSize
+	if max > 0 && pager.PageSize > max {
+		panic(errors.NotValidf("page size %d above limit %d", pager.PageSize, max))
+	}
+}
@@ -0,0 +1,78 @@
+package orm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pagerEntity struct {
+	ORM
+	ID   uint
+	Name string
+}
+
+func TestPagerTotalPages(t *testing.T) {
+	pager := NewPager(1, 10)
+	assert.Equal(t, 0, pager.GetTotalPages(0))
+	assert.Equal(t, 1, pager.GetTotalPages(10))
+	assert.Equal(t, 2, pager.GetTotalPages(11))
+	assert.Equal(t, 0, NewPager(1, 0).GetTotalPages(11))
+
+	registry := &Registry{}
+	registry.SetMaxPageSize(100)
+	validated := (&validatedRegistry{}).clone(registry, nil)
+	engine := validated.CreateEngine()
+	checkPageSize(engine, nil)
+	checkPageSize(engine, NewPager(1, 100))
+	assert.PanicsWithError(t, "page size 101 above limit 100 not valid", func() {
+		engine.Search(NewWhere("1"), NewPager(1, 101), &[]*pagerEntity{})
+	})
+	assert.PanicsWithError(t, "page size 50000 above limit 100 not valid", func() {
+		engine.Search(NewWhere("1"), nil, &[]*pagerEntity{})
+	})
+	assert.PanicsWithError(t, "page size 0 not valid", func() {
+		checkPageSize(engine, NewPager(1, 0))
+	})
+	assert.PanicsWithError(t, "page size -1 not valid", func() {
+		checkPageSize(engine, NewPager(1, -1))
+	})
+	assert.PanicsWithError(t, "page 0 not valid", func() {
+		checkPageSize(engine, NewPager(0, 10))
+	})
+	assert.PanicsWithError(t, fmt.Sprintf("page %d not valid", maxInt), func() {
+		checkPageSize(engine, NewPager(maxInt, 10))
+	})
+	checkPageSize(engine, NewPager(maxInt/10, 10))
+}
+
+func TestPagerIterate(t *testing.T) {
+	var entity *pagerEntity
+	registry := &Registry{}
+	registry.SetMaxPageSize(2)
+	engine := PrepareTables(t, registry, entity)
+	engine.TrackAndFlush(&pagerEntity{Name: "a"}, &pagerEntity{Name: "b"}, &pagerEntity{Name: "c"})
+
+	var rows []*pagerEntity
+	names := make([]string, 0)
+	NewPager(1, 2).Iterate(engine, NewWhere("1 ORDER BY `ID`"), &rows, func() bool {
+		for _, row := range rows {
+			names = append(names, row.Name)
+		}
+		return true
+	})
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	pages := 0
+	NewPager(1, 1).Iterate(engine, NewWhere("1"), &rows, func() bool {
+		pages++
+		return false
+	})
+	assert.Equal(t, 1, pages)
+	assert.Panics(t, func() {
+		NewPager(1, 3).Iterate(engine, NewWhere("1"), &rows, func() bool {
+			return true
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package orm
+
+import (
+	"reflect"
+
+	"github.com/juju/errors"
+)
+
+type PolymorphicReference struct {
+	EntityType string
+	EntityID   uint64
+	entity     Entity
+}
+
+func NewPolymorphicReference(entity Entity) PolymorphicReference {
+	reference := PolymorphicReference{}
+	reference.Set(entity)
+	return reference
+}
+
+func (r *PolymorphicReference) Set(entity Entity) {
+	if entity == nil {
+		r.EntityType = ""
+		r.EntityID = 0
+		r.entity = nil
+		return
+	}
+	id := reflect.ValueOf(entity).Elem().Field(1).Uint()
+	if id == 0 {
+		panic(errors.NotValidf("polymorphic reference to not saved %s", reflect.TypeOf(entity).Elem().String()))
+	}
+	r.EntityType = reflect.TypeOf(entity).Elem().String()
+	r.EntityID = id
+	r.entity = entity
+}
+
+func (r *PolymorphicReference) Get() Entity {
+	if r.entity == nil || reflect.ValueOf(r.entity).Elem().Field(1).Uint() != r.EntityID || reflect.TypeOf(r.entity).Elem().String() != r.EntityType {
+		return nil
+	}
+	return r.entity
+}
+
+func (r *PolymorphicReference) IsEmpty() bool {
+	return r.EntityType == "" || r.EntityID == 0
+}
+
+func getPolymorphicReference(engine *Engine, reference *PolymorphicReference) Entity {
+	if reference.IsEmpty() {
+		return nil
+	}
+	entity := reference.Get()
+	if entity != nil {
+		return entity
+	}
+	t, has := engine.registry.entities[reference.EntityType]
+	if !has {
+		panic(EntityNotRegisteredError{Name: reference.EntityType})
+	}
+	entity = reflect.New(t).Interface().(Entity)
+	if !engine.LoadByID(reference.EntityID, entity) {
+		return nil
+	}
+	reference.entity = entity
+	return entity
+}
+
+func loadPolymorphicReferences(engine *Engine, entities interface{}, field string) {
+	value := reflect.ValueOf(entities)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Slice {
+		panic(errors.NotValidf("entities %s", value.Type().String()))
+	}
+	references := make([]*PolymorphicReference, 0, value.Len())
+	ids := make(map[string][]uint64)
+	for i := 0; i < value.Len(); i++ {
+		elem := reflect.Indirect(value.Index(i))
+		fieldValue := elem.FieldByName(field)
+		if !fieldValue.IsValid() || fieldValue.Type() != reflect.TypeOf(PolymorphicReference{}) {
+			panic(errors.NotValidf("polymorphic reference %s in %s", field, elem.Type().String()))
+		}
+		reference := fieldValue.Addr().Interface().(*PolymorphicReference)
+		if reference.IsEmpty() || reference.Get() != nil {
+			continue
+		}
+		references = append(references, reference)
+		ids[reference.EntityType] = append(ids[reference.EntityType], reference.EntityID)
+	}
+	loaded := make(map[string]map[uint64]Entity)
+	for entityType, typeIDs := range ids {
+		t, has := engine.registry.entities[entityType]
+		if !has {
+			panic(EntityNotRegisteredError{Name: entityType})
+		}
+		rows := reflect.New(reflect.SliceOf(reflect.PtrTo(t)))
+		engine.LoadByIDs(typeIDs, rows.Interface())
+		loaded[entityType] = make(map[uint64]Entity)
+		for i := 0; i < rows.Elem().Len(); i++ {
+			row := rows.Elem().Index(i)
+			if !row.IsNil() {
+				entity := row.Interface().(Entity)
+				loaded[entityType][entity.GetID()] = entity
+			}
+		}
+	}
+	for _, reference := range references {
+		reference.entity = loaded[reference.EntityType][reference.EntityID]
+	}
+}
@@ -0,0 +1,82 @@
+package orm
+
+import (
+	"testing"
+
+	apexLog "github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+type polymorphicComment struct {
+	ORM
+	ID    uint
+	Text  string
+	Owner PolymorphicReference
+}
+
+type polymorphicArticle struct {
+	ORM
+	ID    uint
+	Title string
+}
+
+type polymorphicPhoto struct {
+	ORM
+	ID  uint
+	URL string
+}
+
+func TestPolymorphicReference(t *testing.T) {
+	article := &polymorphicArticle{ID: 3}
+	reference := NewPolymorphicReference(article)
+	assert.Equal(t, "orm.polymorphicArticle", reference.EntityType)
+	assert.Equal(t, uint64(3), reference.EntityID)
+	assert.Same(t, article, reference.Get())
+
+	reference.EntityID = 4
+	assert.Nil(t, reference.Get())
+	reference.Set(nil)
+	assert.True(t, reference.IsEmpty())
+	assert.PanicsWithError(t, "polymorphic reference to not saved orm.polymorphicPhoto not valid", func() {
+		reference.Set(&polymorphicPhoto{})
+	})
+	assert.True(t, reference.IsEmpty())
+}
+
+func TestPolymorphicReferenceLoad(t *testing.T) {
+	var comment *polymorphicComment
+	var article *polymorphicArticle
+	var photo *polymorphicPhoto
+	engine := PrepareTables(t, &Registry{}, comment, article, photo)
+	schema := engine.GetRegistry().GetTableSchemaForEntity(comment)
+	assert.Equal(t, []string{"ID", "Text", "OwnerEntityID", "OwnerEntityType"}, schema.GetColumns())
+
+	article = &polymorphicArticle{Title: "Article"}
+	photo = &polymorphicPhoto{URL: "photo.jpg"}
+	engine.TrackAndFlush(article, photo)
+	engine.TrackAndFlush(&polymorphicComment{Text: "A", Owner: NewPolymorphicReference(article)},
+		&polymorphicComment{Text: "B", Owner: NewPolymorphicReference(photo)},
+		&polymorphicComment{Text: "C", Owner: NewPolymorphicReference(article)},
+		&polymorphicComment{Text: "D"})
+
+	var comments []*polymorphicComment
+	engine.LoadByIDs([]uint64{1, 2, 3, 4}, &comments)
+	assert.Nil(t, comments[0].Owner.Get())
+
+	DBLogger := memory.New()
+	engine.AddQueryLogger(DBLogger, apexLog.InfoLevel, QueryLoggerSourceDB)
+	engine.LoadPolymorphicReferences(comments, "Owner")
+	assert.Len(t, DBLogger.Entries, 2)
+	assert.Equal(t, "Article", comments[0].Owner.Get().(*polymorphicArticle).Title)
+	assert.Equal(t, "photo.jpg", comments[1].Owner.Get().(*polymorphicPhoto).URL)
+	assert.Same(t, comments[0].Owner.Get(), comments[2].Owner.Get())
+	assert.Nil(t, comments[3].Owner.Get())
+
+	comment = &polymorphicComment{}
+	engine.LoadByID(2, comment)
+	assert.Equal(t, "photo.jpg", engine.GetPolymorphicReference(&comment.Owner).(*polymorphicPhoto).URL)
+	assert.Panics(t, func() {
+		engine.LoadPolymorphicReferences(comments, "Text")
+	})
+}
@@ -0,0 +1,119 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/streadway/amqp"
+)
+
+type PoolConnectionError struct {
+	Pools map[string]error
+}
+
+func (e *PoolConnectionError) Error() string {
+	names := make([]string, 0, len(e.Pools))
+	for name := range e.Pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("%s: %s", name, e.Pools[name].Error())
+	}
+	return "pools not available: " + strings.Join(lines, ", ")
+}
+
+func connectAll(ctx context.Context, engine *Engine) error {
+	failed := pingPools(ctx, engine)
+	registry := engine.registry
+	for code, config := range registry.sqlClients {
+		if failed["mysql:"+code] != nil || config.version != "" {
+			continue
+		}
+		// config is shared by all engines, so probed values are set only in this engine
+		probed := *config
+		if err := probeSQLPool(ctx, &probed); err != nil {
+			failed["mysql:"+code] = err
+			continue
+		}
+		db := engine.GetMysql(code)
+		db.autoincrement = probed.autoincrement
+		db.version = probed.version
+	}
+	for code, config := range registry.rabbitMQChannelsToQueue {
+		server := "rabbitMQ:" + config.connection.config.code
+		if failed[server] != nil {
+			continue
+		}
+		if err := tryInitRabbitMQChannel(engine, code, config); err != nil {
+			failed[server] = err
+		}
+	}
+	if len(failed) > 0 {
+		return &PoolConnectionError{Pools: failed}
+	}
+	return nil
+}
+
+// pingPools checks all pools without changing them, rabbitMQ servers without open connection are dialed and closed
+func pingPools(ctx context.Context, engine *Engine) map[string]error {
+	failed := make(map[string]error)
+	registry := engine.registry
+	for code, config := range registry.sqlClients {
+		if err := config.db.PingContext(ctx); err != nil {
+			failed["mysql:"+code] = err
+		}
+	}
+	for code, config := range registry.clickHouseClients {
+		if err := config.db.PingContext(ctx); err != nil {
+			failed["clickhouse:"+code] = err
+		}
+	}
+	for code := range registry.redisServers {
+		client := engine.GetRedis(code).client.(*standardRedisClient)
+		var err error
+		if client.ring != nil {
+			err = client.ring.ForEachShard(func(shard *redis.Client) error {
+				return shard.WithContext(ctx).Ping().Err()
+			})
+		} else {
+			err = client.client.WithContext(ctx).Ping().Err()
+		}
+		if err != nil {
+			failed["redis:"+code] = err
+		}
+	}
+	for code, config := range registry.elasticServers {
+		if _, _, err := config.client.Ping(config.url).Do(ctx); err != nil {
+			failed["elastic:"+code] = err
+		}
+	}
+	for code, connection := range registry.rabbitMQServers {
+		if err := pingRabbitMQ(ctx, connection); err != nil {
+			failed["rabbitMQ:"+code] = err
+		}
+	}
+	return failed
+}
+
+func pingRabbitMQ(ctx context.Context, connection *rabbitMQConnection) error {
+	for _, client := range []*amqp.Connection{connection.clientSender, connection.clientReceivers} {
+		if client != nil && !client.IsClosed() {
+			return nil
+		}
+	}
+	timeout := statusPoolTimeout
+	if deadline, has := ctx.Deadline(); has {
+		timeout = time.Until(deadline)
+	}
+	client, err := amqp.DialConfig(connection.config.address, amqp.Config{Dial: amqp.DefaultDial(timeout)})
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
@@ -0,0 +1,81 @@
+package orm
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+type PoolResolver func(schema TableSchema) string
+
+func (e *Engine) SetPoolResolver(resolver PoolResolver) {
+	e.poolResolver = resolver
+}
+
+func (tableSchema *tableSchema) resolvePool(engine *Engine) string {
+	if engine.poolResolver == nil {
+		return ""
+	}
+	pool := engine.poolResolver(tableSchema)
+	if pool == tableSchema.mysqlPoolName {
+		return ""
+	}
+	return pool
+}
+
+func (tableSchema *tableSchema) getMysqlPoolName(engine *Engine) string {
+	if tableSchema.shard != nil {
+		pool, has := engine.shardPools[tableSchema]
+		if !has {
+			panic(errors.NotSupportedf("query without shard key on sharded entity %s", tableSchema.t.String()))
+		}
+		return pool
+	}
+	pool := tableSchema.resolvePool(engine)
+	if pool == "" {
+		return tableSchema.mysqlPoolName
+	}
+	return pool
+}
+
+func getNamespacedCode(code string, namespace string) string {
+	return code + "@" + namespace
+}
+
+func splitNamespacedCode(code string) (base string, namespace string, has bool) {
+	pos := strings.Index(code, "@")
+	if pos < 0 {
+		return code, "", false
+	}
+	return code[0:pos], code[pos+1:], true
+}
+
+func (e *Engine) getNamespacedRedis(code string) (*RedisCache, bool) {
+	base, namespace, has := splitNamespacedCode(code)
+	if !has {
+		return nil, false
+	}
+	cache, has := e.redis[base]
+	if !has {
+		return nil, false
+	}
+	client := cache.client.(*standardRedisClient)
+	namespaced := &RedisCache{engine: e, code: code,
+		client: &standardRedisClient{client.client, client.ring, client.prefix + namespace + ":"}}
+	e.redis[code] = namespaced
+	return namespaced, true
+}
+
+func (e *Engine) getNamespacedLocalCache(code string) (*LocalCache, bool) {
+	base, namespace, has := splitNamespacedCode(code)
+	if !has {
+		return nil, false
+	}
+	cache, has := e.localCache[base]
+	if !has {
+		return nil, false
+	}
+	namespaced := &LocalCache{engine: e, code: code, lru: cache.lru, ttl: cache.ttl, prefix: cache.prefix + namespace + ":"}
+	e.localCache[code] = namespaced
+	return namespaced, true
+}
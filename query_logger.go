@@ -113,8 +113,14 @@ func (h *redisDataDogHandler) HandleLog(e *apexLox.Entry) error {
 	operation := e.Fields.Get("operation").(string)
 	span, _ := tracer.StartSpanFromContext(h.engine.dataDog.ctx[len(h.engine.dataDog.ctx)-1], operation, tracer.StartTime(started))
 	span.SetTag(ext.SpanType, ext.SpanTypeRedis)
-	span.SetTag(ext.ServiceName, "redis."+e.Fields.Get("pool").(string))
-	span.SetTag(ext.ResourceName, operation)
+	if e.Fields.Get("target") == "locker" {
+		span.SetTag(ext.ServiceName, "locker."+e.Fields.Get("pool").(string))
+		span.SetTag(ext.ResourceName, operation+" "+e.Fields.Get("Key").(string))
+		span.SetTag("locker.key", e.Fields.Get("Key"))
+	} else {
+		span.SetTag(ext.ServiceName, "redis."+e.Fields.Get("pool").(string))
+		span.SetTag(ext.ResourceName, operation)
+	}
 	misses := e.Fields.Get("misses")
 	if misses != nil {
 		span.SetTag("redis.misses", misses)
@@ -132,6 +138,38 @@ func (h *redisDataDogHandler) HandleLog(e *apexLox.Entry) error {
 	return nil
 }
 
+type localCacheDataDogHandler struct {
+	withAnalytics bool
+	engine        *Engine
+}
+
+func newLocalCacheDataDogHandler(withAnalytics bool, engine *Engine) *localCacheDataDogHandler {
+	return &localCacheDataDogHandler{withAnalytics, engine}
+}
+
+func (h *localCacheDataDogHandler) HandleLog(e *apexLox.Entry) error {
+	started := time.Unix(0, e.Fields.Get("started").(int64))
+	operation := e.Fields.Get("operation").(string)
+	span, _ := tracer.StartSpanFromContext(h.engine.dataDog.ctx[len(h.engine.dataDog.ctx)-1], "local_cache."+operation, tracer.StartTime(started))
+	span.SetTag(ext.SpanType, ext.SpanTypeMemcached)
+	span.SetTag(ext.ServiceName, "local_cache."+e.Fields.Get("pool").(string))
+	span.SetTag(ext.ResourceName, operation)
+	misses := e.Fields.Get("misses")
+	if misses != nil {
+		span.SetTag("local_cache.misses", misses)
+	}
+	keys := e.Fields.Get("Keys")
+	if keys != nil {
+		span.SetTag("local_cache.keys", keys)
+	}
+	if h.withAnalytics {
+		span.SetTag(ext.AnalyticsEvent, true)
+	}
+	finished := time.Unix(0, e.Fields.Get("finished").(int64))
+	span.Finish(tracer.FinishTime(finished))
+	return nil
+}
+
 type elasticDataDogHandler struct {
 	withAnalytics bool
 	engine        *Engine
@@ -173,12 +211,17 @@ func newClickHouseDataDogHandler(withAnalytics bool, engine *Engine) *clickHouse
 
 func (h *clickHouseDataDogHandler) HandleLog(e *apexLox.Entry) error {
 	started := time.Unix(0, e.Fields.Get("started").(int64))
-	span, _ := tracer.StartSpanFromContext(h.engine.dataDog.ctx[len(h.engine.dataDog.ctx)-1], "clickhouse.query", tracer.StartTime(started))
+	operation := e.Fields.Get("operation").(string)
+	span, _ := tracer.StartSpanFromContext(h.engine.dataDog.ctx[len(h.engine.dataDog.ctx)-1], operation, tracer.StartTime(started))
 	queryType := e.Fields.Get("type")
 	span.SetTag(ext.SpanType, ext.SpanTypeSQL)
 	span.SetTag(ext.ServiceName, "clickhouse."+e.Fields.Get("pool").(string))
 	span.SetTag(ext.ResourceName, e.Fields.Get("Query"))
 	span.SetTag(ext.SQLType, queryType)
+	rows := e.Fields.Get("rows")
+	if rows != nil {
+		span.SetTag("clickhouse.rows", rows)
+	}
 	if h.withAnalytics {
 		span.SetTag(ext.AnalyticsEvent, true)
 	}
@@ -208,3 +251,11 @@ func injectLogError(err error, e *apexLox.Entry) *apexLox.Entry {
 		WithField("stack_full", fullStack).
 		WithField("error_type", reflect.TypeOf(errors.Cause(err)).String())
 }
+
+func (e *Engine) queryLoggerEntry(source QueryLoggerSource) *apexLox.Entry {
+	entry := e.queryLoggers[source].log
+	if len(e.logMetaData) > 0 {
+		entry = entry.WithFields(apexLox.Fields(e.logMetaData))
+	}
+	return entry
+}
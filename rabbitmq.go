@@ -204,6 +204,7 @@ type RabbitMQQueueConfig struct {
 	RouterKeys    []string
 	AutoDelete    bool
 	TTL           int
+	publishOnly   bool
 }
 
 type RabbitMQRouterConfig struct {
@@ -218,6 +219,13 @@ func (r *rabbitMQChannel) registerQueue(channel *amqp.Channel, name string) (*am
 	if config.TTL > 0 {
 		args = amqp.Table{"x-message-ttl": fmt.Sprintf("%d", config.TTL*1000)}
 	}
+	if config.Name == lazyDelayedQueueName {
+		if args == nil {
+			args = amqp.Table{}
+		}
+		args["x-dead-letter-exchange"] = ""
+		args["x-dead-letter-routing-key"] = lazyQueueName
+	}
 	q, err := channel.QueueDeclare(name, config.Durable, config.AutoDelete, false, false, args)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -241,6 +249,54 @@ func (r *RabbitMQQueue) Publish(body []byte) {
 	r.publish(false, false, r.config.Name, msg)
 }
 
+// Inspect panics when queue is not declared yet, it never creates queue
+func (r *RabbitMQQueue) Inspect() (messages int, consumers int) {
+	channel := r.initChannel(r.config.Name, true)
+	defer channel.Close()
+	q, err := channel.QueueDeclarePassive(r.config.Name, r.config.Durable, r.config.AutoDelete, false, false, nil)
+	if err != nil {
+		panic(errors.Trace(err))
+	}
+	return q.Messages, q.Consumers
+}
+
+// publishDelayed uses separate queue for every delay bucket, RabbitMQ expires only message at head of queue
+// so all messages in one queue must have the same TTL
+func (r *RabbitMQQueue) publishDelayed(body []byte, delay time.Duration) {
+	if r.connection.channelSender == nil {
+		r.initChannelSender()
+	}
+	bucket := getLazyDelayBucket(delay)
+	name := fmt.Sprintf("%s_%d", r.config.Name, bucket.Milliseconds())
+	if !r.delayedQueues[name] {
+		err := registerDelayedQueue(r.connection.channelSender, name, bucket)
+		if err != nil {
+			panic(err)
+		}
+		if r.delayedQueues == nil {
+			r.delayedQueues = make(map[string]bool)
+		}
+		r.delayedQueues[name] = true
+	}
+	msg := amqp.Publishing{
+		ContentType: "text/plain",
+		Body:        body,
+	}
+	r.publish(false, false, name, msg)
+}
+
+// registerDelayedQueue declares queue that moves expired messages to lazy queue, unused queue is removed
+func registerDelayedQueue(channel *amqp.Channel, name string, ttl time.Duration) error {
+	args := amqp.Table{
+		"x-message-ttl":             ttl.Milliseconds(),
+		"x-expires":                 (ttl + time.Hour).Milliseconds(),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": lazyQueueName,
+	}
+	_, err := channel.QueueDeclare(name, true, false, false, false, args)
+	return errors.Trace(err)
+}
+
 type RabbitMQRouter struct {
 	*rabbitMQChannel
 }
@@ -254,9 +310,10 @@ func (r *RabbitMQRouter) Publish(routerKey string, body []byte) {
 }
 
 type rabbitMQChannel struct {
-	engine     *Engine
-	connection *rabbitMQConnection
-	config     *RabbitMQQueueConfig
+	engine        *Engine
+	connection    *rabbitMQConnection
+	config        *RabbitMQQueueConfig
+	delayedQueues map[string]bool
 }
 
 func (r *rabbitMQChannel) NewConsumer(name string) RabbitMQConsumer {
@@ -327,7 +384,7 @@ func (r *rabbitMQChannel) initChannel(queueName string, sender bool) *amqp.Chann
 		if err != nil {
 			panic(err)
 		}
-		if sender {
+		if sender || r.config.publishOnly {
 			return channel
 		}
 	}
@@ -407,7 +464,7 @@ func (r *rabbitMQChannel) publish(mandatory, immediate bool, routingKey string,
 func fillRabbitMQLogFields(engine *Engine, message string, start time.Time, operation string, fields map[string]interface{}, err error) {
 	now := time.Now()
 	stop := time.Since(start).Microseconds()
-	e := engine.queryLoggers[QueryLoggerSourceRabbitMQ].log.
+	e := engine.queryLoggerEntry(QueryLoggerSourceRabbitMQ).
 		WithField("microseconds", stop).
 		WithField("operation", operation).
 		WithField("target", "rabbitMQ").
@@ -0,0 +1,115 @@
+package orm
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const maxRandomRounds = 5
+
+func searchRandom(engine *Engine, where *Where, limit int, entities reflect.Value, references []string) {
+	if limit <= 0 {
+		panic(errors.NotValidf("random rows limit %d", limit))
+	}
+	entityType, has := getEntityTypeForSlice(engine.registry, entities.Type())
+	if !has {
+		panic(EntityNotRegisteredError{Name: entities.Type().String()})
+	}
+	// ORDER BY has no meaning for random rows, it is removed
+	where, _, limitClause := where.splitTrailingClauses()
+	if limitClause != nil {
+		panic(errors.NotSupportedf("LIMIT in random search"))
+	}
+	if where.query == "" {
+		where.query = "1"
+	}
+	schema := getTableSchema(engine.registry, entityType)
+	tableName := schema.getTableName(engine)
+	skipFakeDelete := skipFakeDeleted(true, schema, where)
+	pool := schema.GetMysql(engine)
+
+	var minID, maxID uint64
+	rangeQuery := buildSearchQuery("IFNULL(MIN(`ID`), 0), IFNULL(MAX(`ID`), 0)", tableName, where.String(), skipFakeDelete, nil)
+	pool.QueryRow(&Where{query: rangeQuery, parameters: where.GetParameters()}, &minID, &maxID)
+	if maxID == 0 {
+		entities.SetLen(0)
+		return
+	}
+	ids := make([]uint64, 0, limit)
+	unique := make(map[uint64]bool, limit)
+	for round := 0; round < maxRandomRounds && len(ids) < limit; round++ {
+		missing := limit - len(ids)
+		queries := make([]string, missing)
+		parameters := make([]interface{}, 0, missing*(len(where.GetParameters())+1))
+		for i := 0; i < missing; i++ {
+			start := minID + uint64(rand.Int63n(int64(maxID-minID+1)))
+			queries[i] = "(" + buildSearchQuery("`ID`", tableName, "`ID` >= ? AND ("+where.String()+") ORDER BY `ID`", skipFakeDelete, nil) + ")"
+			parameters = append(parameters, start)
+			parameters = append(parameters, where.GetParameters()...)
+		}
+		var found int
+		ids, found = queryRandomIDs(pool, strings.Join(queries, " UNION ALL "), parameters, ids, unique)
+		if found == 0 {
+			break
+		}
+	}
+	if len(ids) < limit {
+		ids = fillRandomIDs(pool, tableName, where, skipFakeDelete, limit, ids, unique)
+	}
+	tryByIDs(engine, ids, entities, references)
+}
+
+// fillRandomIDs picks missing rows by random offsets when ID ranges are too sparse to find them
+func fillRandomIDs(pool *DB, tableName string, where *Where, skipFakeDelete bool, limit int, ids []uint64, unique map[uint64]bool) []uint64 {
+	total := 0
+	countQuery := buildSearchQuery("COUNT(1)", tableName, where.String(), skipFakeDelete, nil)
+	pool.QueryRow(&Where{query: countQuery, parameters: where.GetParameters()}, &total)
+	if total <= limit {
+		query := buildSearchQuery("`ID`", tableName, where.String()+" ORDER BY `ID`", skipFakeDelete, NewPager(1, limit))
+		picked := len(ids)
+		ids, _ = queryRandomIDs(pool, query, where.GetParameters(), ids, unique)
+		rand.Shuffle(len(ids)-picked, func(i, j int) {
+			ids[picked+i], ids[picked+j] = ids[picked+j], ids[picked+i]
+		})
+		return ids
+	}
+	usedOffsets := make(map[int]bool)
+	for len(ids) < limit && len(usedOffsets) < total {
+		missing := limit - len(ids)
+		if missing > total-len(usedOffsets) {
+			missing = total - len(usedOffsets)
+		}
+		queries := make([]string, 0, missing)
+		parameters := make([]interface{}, 0, missing*len(where.GetParameters()))
+		for len(queries) < missing {
+			offset := rand.Intn(total)
+			if usedOffsets[offset] {
+				continue
+			}
+			usedOffsets[offset] = true
+			queries = append(queries, "("+buildSearchQuery("`ID`", tableName, where.String()+" ORDER BY `ID`", skipFakeDelete, NewPager(offset+1, 1))+")")
+			parameters = append(parameters, where.GetParameters()...)
+		}
+		ids, _ = queryRandomIDs(pool, strings.Join(queries, " UNION ALL "), parameters, ids, unique)
+	}
+	return ids
+}
+
+func queryRandomIDs(pool *DB, query string, parameters []interface{}, ids []uint64, unique map[uint64]bool) ([]uint64, int) {
+	results, def := pool.Query(query, parameters...)
+	defer def()
+	found := 0
+	for results.Next() {
+		var id uint64
+		results.Scan(&id)
+		found++
+		if !unique[id] {
+			unique[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, found
+}
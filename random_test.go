@@ -0,0 +1,56 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type randomEntity struct {
+	ORM        `orm:"localCache"`
+	ID         uint
+	Age        int
+	FakeDelete bool
+}
+
+func TestSearchRandom(t *testing.T) {
+	var entity *randomEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	var rows []*randomEntity
+	engine.SearchRandom(NewWhere("1"), 3, &rows)
+	assert.Len(t, rows, 0)
+
+	for i := 1; i <= 100; i++ {
+		engine.Track(&randomEntity{Age: i % 2})
+	}
+	engine.Flush()
+	entity = &randomEntity{}
+	engine.LoadByID(100, entity)
+	engine.MarkToDelete(entity)
+	engine.Flush()
+
+	engine.SearchRandom(NewWhere("`Age` = ?", 0), 10, &rows)
+	assert.Len(t, rows, 10)
+	unique := make(map[uint]bool)
+	for _, row := range rows {
+		assert.Equal(t, 0, row.Age)
+		assert.False(t, row.FakeDelete)
+		unique[row.ID] = true
+	}
+	assert.Len(t, unique, 10)
+
+	engine.SearchRandom(NewWhere("`ID` IN ?", []uint{1, 2, 100}), 5, &rows)
+	assert.Len(t, rows, 2)
+
+	engine.SearchRandom(NewWhere("`ID` IN ? ORDER BY `ID` DESC", []uint{1, 2, 3, 4, 5, 6, 99}), 6, &rows)
+	assert.Len(t, rows, 6)
+	engine.SearchRandom(NewWhere("`ID` IN ?", []uint{1, 2, 3, 4, 5, 6, 7, 8, 99}), 6, &rows)
+	assert.Len(t, rows, 6)
+	assert.PanicsWithError(t, "LIMIT in random search not supported", func() {
+		engine.SearchRandom(NewWhere("1 LIMIT 2"), 1, &rows)
+	})
+	assert.PanicsWithError(t, "random rows limit 0 not valid", func() {
+		engine.SearchRandom(NewWhere("1"), 0, &rows)
+	})
+}
@@ -0,0 +1,123 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const redactedMask = "***"
+
+type redactedValue struct {
+	value interface{}
+}
+
+func (v redactedValue) Value() (driver.Value, error) {
+	return v.value, nil
+}
+
+func (v redactedValue) String() string {
+	return redactedMask
+}
+
+func (v redactedValue) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redactedMask + `"`), nil
+}
+
+func (tableSchema *tableSchema) redactedPositions(columns []string) []int {
+	if len(tableSchema.redactedColumns) == 0 {
+		return nil
+	}
+	var positions []int
+	for i, column := range columns {
+		if tableSchema.redactedColumns[column] {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+func expandRedactedPositions(positions []int, rowSize int, total int) []int {
+	if len(positions) == 0 || rowSize == 0 {
+		return nil
+	}
+	expanded := make([]int, 0, total/rowSize*len(positions))
+	for row := 0; row+rowSize <= total; row += rowSize {
+		for _, position := range positions {
+			expanded = append(expanded, row+position)
+		}
+	}
+	return expanded
+}
+
+func redactArguments(args []interface{}, positions []int) []interface{} {
+	if len(positions) == 0 {
+		return args
+	}
+	redacted := make([]interface{}, len(args))
+	copy(redacted, args)
+	for _, position := range positions {
+		if position < len(redacted) {
+			redacted[position] = redactedValue{redacted[position]}
+		}
+	}
+	return redacted
+}
+
+func redactCacheValue(registry *validatedRegistry, key string, value interface{}) interface{} {
+	pos := strings.Index(key, ":")
+	if pos <= 0 {
+		return value
+	}
+	schema, has := registry.redactedCachePrefixes[key[0:pos]]
+	if !has {
+		return value
+	}
+	asString, is := value.(string)
+	if !is {
+		return value
+	}
+	var data []string
+	err := jsoniter.ConfigFastest.UnmarshalFromString(asString, &data)
+	if err != nil {
+		return value
+	}
+	for i, column := range schema.columnNames[1:] {
+		if i < len(data) && schema.redactedColumns[column] {
+			data[i] = redactedMask
+		}
+	}
+	asString, _ = jsoniter.ConfigFastest.MarshalToString(data)
+	return asString
+}
+
+func redactRedisLogFields(registry *validatedRegistry, fields map[string]interface{}) map[string]interface{} {
+	if len(registry.redactedCachePrefixes) == 0 || fields == nil {
+		return fields
+	}
+	redacted := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		redacted[k] = v
+	}
+	pairs, has := fields["Pairs"].([]interface{})
+	if has {
+		redactedPairs := make([]interface{}, len(pairs))
+		copy(redactedPairs, pairs)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			key, is := pairs[i].(string)
+			if is {
+				redactedPairs[i+1] = redactCacheValue(registry, key, pairs[i+1])
+			}
+		}
+		redacted["Pairs"] = redactedPairs
+	}
+	key, has := fields["Key"].(string)
+	if has {
+		value, has := fields["value"]
+		if has {
+			redacted["value"] = redactCacheValue(registry, key, value)
+		}
+	}
+	return redacted
+}
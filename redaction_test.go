@@ -0,0 +1,49 @@
+package orm
+
+import (
+	"fmt"
+	"testing"
+
+	apexLog "github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactArguments(t *testing.T) {
+	schema := &tableSchema{redactedColumns: map[string]bool{"Password": true}}
+	positions := schema.redactedPositions([]string{"Name", "Password"})
+	assert.Equal(t, []int{1}, positions)
+	assert.Nil(t, (&tableSchema{}).redactedPositions([]string{"Name", "Password"}))
+
+	expanded := expandRedactedPositions(positions, 2, 6)
+	assert.Equal(t, []int{1, 3, 5}, expanded)
+
+	args := []interface{}{"john", "secret", "tom", "secret2", "adam", "secret3"}
+	redacted := redactArguments(args, expanded)
+	assert.Equal(t, "secret", args[1])
+	assert.Equal(t, "john", redacted[0])
+	assert.Equal(t, "***", redacted[1].(redactedValue).String())
+	value, err := redacted[3].(redactedValue).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret2", value)
+	assert.Equal(t, args, redactArguments(args, nil))
+}
+
+func TestExecRedactedOnlyInLog(t *testing.T) {
+	engine := (&validatedRegistry{}).clone(&Registry{}, nil).CreateEngine()
+	client := &middlewareSQLClient{}
+	db := &DB{engine: engine, client: client, code: "default"}
+	seen := make([]interface{}, 0)
+	engine.AddSQLMiddleware(func(query *SQLQuery, next func() error) error {
+		seen = append(seen, query.Args...)
+		return next()
+	})
+	logger := memory.New()
+	engine.AddQueryLogger(logger, apexLog.InfoLevel, QueryLoggerSourceDB)
+
+	db.exec("UPDATE `user` SET `Name` = ?, `Password` = ? WHERE `ID` = ?", []int{1}, []interface{}{"john", "secret", 1})
+	assert.Equal(t, []string{"UPDATE `user` SET `Name` = ?, `Password` = ? WHERE `ID` = ? [john secret 1]"}, client.queries)
+	assert.Equal(t, []interface{}{"john", "secret", 1}, seen)
+	assert.Len(t, logger.Entries, 1)
+	assert.Equal(t, "[john *** 1]", fmt.Sprintf("%v", logger.Entries[0].Fields["args"]))
+}
@@ -1,17 +1,21 @@
 package orm
 
 import (
+	"strings"
+	"sync"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
 
 	"github.com/go-redis/redis/v7"
 	"github.com/go-redis/redis_rate/v8"
+	"github.com/juju/errors"
 )
 
 const counterRedisAll = "redis.all"
 const counterRedisKeysSet = "redis.keysSet"
 const counterRedisKeysGet = "redis.keysGet"
+const maxRedisDeleteBatch = 1000
 
 type redisClient interface {
 	Get(key string) (string, error)
@@ -30,6 +34,7 @@ type redisClient interface {
 	SPop(key string) (string, error)
 	SPopN(key string, max int64) ([]string, error)
 	LLen(key string) (int64, error)
+	IncrBy(key string, value int64) (int64, error)
 	ZAdd(key string, members ...*redis.Z) (int64, error)
 	SAdd(key string, members ...interface{}) (int64, error)
 	HMSet(key string, fields map[string]interface{}) (bool, error)
@@ -37,190 +42,429 @@ type redisClient interface {
 	MGet(keys ...string) ([]interface{}, error)
 	Set(key string, value interface{}, expiration time.Duration) error
 	MSet(pairs ...interface{}) error
+	MSetWithTTL(expiration time.Duration, pairs ...interface{}) error
+	Expire(expiration time.Duration, keys ...string) error
 	Del(keys ...string) error
 	FlushDB() error
+	DBSize() (int64, error)
+	ScanKeys(match string) ([]string, error)
+	DelMatching(match string) (int, error)
+	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
+	Publish(channel string, message interface{}) error
+	Subscribe(channel string) *redis.PubSub
 }
 
 type standardRedisClient struct {
 	client *redis.Client
 	ring   *redis.Ring
+	prefix string
+}
+
+func (c *standardRedisClient) key(key string) string {
+	return c.prefix + key
+}
+
+func (c *standardRedisClient) keys(keys []string) []string {
+	if c.prefix == "" {
+		return keys
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+	return prefixed
 }
 
 func (c *standardRedisClient) Get(key string) (string, error) {
 	if c.ring != nil {
-		return c.ring.Get(key).Result()
+		return c.ring.Get(c.key(key)).Result()
 	}
-	return c.client.Get(key).Result()
+	return c.client.Get(c.key(key)).Result()
 }
 
 func (c *standardRedisClient) LRange(key string, start, stop int64) ([]string, error) {
 	if c.ring != nil {
-		return c.ring.LRange(key, start, stop).Result()
+		return c.ring.LRange(c.key(key), start, stop).Result()
 	}
-	return c.client.LRange(key, start, stop).Result()
+	return c.client.LRange(c.key(key), start, stop).Result()
 }
 
 func (c *standardRedisClient) HMGet(key string, fields ...string) ([]interface{}, error) {
 	if c.ring != nil {
-		return c.ring.HMGet(key, fields...).Result()
+		return c.ring.HMGet(c.key(key), fields...).Result()
 	}
-	return c.client.HMGet(key, fields...).Result()
+	return c.client.HMGet(c.key(key), fields...).Result()
 }
 
 func (c *standardRedisClient) HGetAll(key string) (map[string]string, error) {
 	if c.ring != nil {
-		return c.ring.HGetAll(key).Result()
+		return c.ring.HGetAll(c.key(key)).Result()
 	}
-	return c.client.HGetAll(key).Result()
+	return c.client.HGetAll(c.key(key)).Result()
 }
 
 func (c *standardRedisClient) LPush(key string, values ...interface{}) (int64, error) {
 	if c.ring != nil {
-		return c.ring.LPush(key, values...).Result()
+		return c.ring.LPush(c.key(key), values...).Result()
 	}
-	return c.client.LPush(key, values...).Result()
+	return c.client.LPush(c.key(key), values...).Result()
 }
 
 func (c *standardRedisClient) RPush(key string, values ...interface{}) (int64, error) {
 	if c.ring != nil {
-		return c.ring.RPush(key, values...).Result()
+		return c.ring.RPush(c.key(key), values...).Result()
 	}
-	return c.client.RPush(key, values...).Result()
+	return c.client.RPush(c.key(key), values...).Result()
 }
 
 func (c *standardRedisClient) RPop(key string) (string, error) {
 	if c.ring != nil {
-		return c.ring.RPop(key).Result()
+		return c.ring.RPop(c.key(key)).Result()
 	}
-	return c.client.RPop(key).Result()
+	return c.client.RPop(c.key(key)).Result()
 }
 
 func (c *standardRedisClient) LSet(key string, index int64, value interface{}) (string, error) {
 	if c.ring != nil {
-		return c.ring.LSet(key, index, value).Result()
+		return c.ring.LSet(c.key(key), index, value).Result()
 	}
-	return c.client.LSet(key, index, value).Result()
+	return c.client.LSet(c.key(key), index, value).Result()
 }
 
 func (c *standardRedisClient) LRem(key string, count int64, value interface{}) (int64, error) {
 	if c.ring != nil {
-		return c.ring.LRem(key, count, value).Result()
+		return c.ring.LRem(c.key(key), count, value).Result()
 	}
-	return c.client.LRem(key, count, value).Result()
+	return c.client.LRem(c.key(key), count, value).Result()
 }
 
 func (c *standardRedisClient) LTrim(key string, start, stop int64) (string, error) {
 	if c.ring != nil {
-		return c.ring.LTrim(key, start, stop).Result()
+		return c.ring.LTrim(c.key(key), start, stop).Result()
 	}
-	return c.client.LTrim(key, start, stop).Result()
+	return c.client.LTrim(c.key(key), start, stop).Result()
 }
 
 func (c *standardRedisClient) ZCard(key string) (int64, error) {
 	if c.ring != nil {
-		return c.ring.ZCard(key).Result()
+		return c.ring.ZCard(c.key(key)).Result()
 	}
-	return c.client.ZCard(key).Result()
+	return c.client.ZCard(c.key(key)).Result()
 }
 
 func (c *standardRedisClient) SCard(key string) (int64, error) {
 	if c.ring != nil {
-		return c.ring.SCard(key).Result()
+		return c.ring.SCard(c.key(key)).Result()
 	}
-	return c.client.SCard(key).Result()
+	return c.client.SCard(c.key(key)).Result()
 }
 
 func (c *standardRedisClient) ZCount(key string, min, max string) (int64, error) {
 	if c.ring != nil {
-		return c.ring.ZCount(key, min, max).Result()
+		return c.ring.ZCount(c.key(key), min, max).Result()
 	}
-	return c.client.ZCount(key, min, max).Result()
+	return c.client.ZCount(c.key(key), min, max).Result()
 }
 
 func (c *standardRedisClient) SPop(key string) (string, error) {
 	if c.ring != nil {
-		return c.ring.SPop(key).Result()
+		return c.ring.SPop(c.key(key)).Result()
 	}
-	return c.client.SPop(key).Result()
+	return c.client.SPop(c.key(key)).Result()
 }
 
 func (c *standardRedisClient) SPopN(key string, max int64) ([]string, error) {
 	if c.ring != nil {
-		return c.ring.SPopN(key, max).Result()
+		return c.ring.SPopN(c.key(key), max).Result()
 	}
-	return c.client.SPopN(key, max).Result()
+	return c.client.SPopN(c.key(key), max).Result()
 }
 
 func (c *standardRedisClient) LLen(key string) (int64, error) {
 	if c.ring != nil {
-		return c.ring.LLen(key).Result()
+		return c.ring.LLen(c.key(key)).Result()
 	}
-	return c.client.LLen(key).Result()
+	return c.client.LLen(c.key(key)).Result()
+}
+
+func (c *standardRedisClient) IncrBy(key string, value int64) (int64, error) {
+	if c.ring != nil {
+		return c.ring.IncrBy(c.key(key), value).Result()
+	}
+	return c.client.IncrBy(c.key(key), value).Result()
 }
 
 func (c *standardRedisClient) ZAdd(key string, members ...*redis.Z) (int64, error) {
 	if c.ring != nil {
-		return c.ring.ZAdd(key, members...).Result()
+		return c.ring.ZAdd(c.key(key), members...).Result()
 	}
-	return c.client.ZAdd(key, members...).Result()
+	return c.client.ZAdd(c.key(key), members...).Result()
 }
 
 func (c *standardRedisClient) SAdd(key string, members ...interface{}) (int64, error) {
 	if c.ring != nil {
-		return c.ring.SAdd(key, members...).Result()
+		return c.ring.SAdd(c.key(key), members...).Result()
 	}
-	return c.client.SAdd(key, members...).Result()
+	return c.client.SAdd(c.key(key), members...).Result()
 }
 
 func (c *standardRedisClient) HMSet(key string, fields map[string]interface{}) (bool, error) {
 	if c.ring != nil {
-		return c.ring.HMSet(key, fields).Result()
+		return c.ring.HMSet(c.key(key), fields).Result()
 	}
-	return c.client.HMSet(key, fields).Result()
+	return c.client.HMSet(c.key(key), fields).Result()
 }
 
 func (c *standardRedisClient) HSet(key string, field string, value interface{}) (int64, error) {
 	if c.ring != nil {
-		return c.ring.HSet(key, field, value).Result()
+		return c.ring.HSet(c.key(key), field, value).Result()
+	}
+	return c.client.HSet(c.key(key), field, value).Result()
+}
+
+func (c *standardRedisClient) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	if c.ring != nil {
+		return c.ring.Eval(script, c.keys(keys), args...).Result()
+	}
+	return c.client.Eval(script, c.keys(keys), args...).Result()
+}
+
+func (c *standardRedisClient) Publish(channel string, message interface{}) error {
+	if c.ring != nil {
+		return c.ring.Publish(c.key(channel), message).Err()
+	}
+	return c.client.Publish(c.key(channel), message).Err()
+}
+
+func (c *standardRedisClient) Subscribe(channel string) *redis.PubSub {
+	if c.ring != nil {
+		return c.ring.Subscribe(c.key(channel))
 	}
-	return c.client.HSet(key, field, value).Result()
+	return c.client.Subscribe(c.key(channel))
 }
 
 func (c *standardRedisClient) MGet(keys ...string) ([]interface{}, error) {
 	if c.ring != nil {
-		return c.ring.MGet(keys...).Result()
+		return c.ring.MGet(c.keys(keys)...).Result()
 	}
-	return c.client.MGet(keys...).Result()
+	return c.client.MGet(c.keys(keys)...).Result()
 }
 
 func (c *standardRedisClient) Set(key string, value interface{}, expiration time.Duration) error {
 	if c.ring != nil {
-		return c.ring.Set(key, value, expiration).Err()
+		return c.ring.Set(c.key(key), value, expiration).Err()
 	}
-	return c.client.Set(key, value, expiration).Err()
+	return c.client.Set(c.key(key), value, expiration).Err()
 }
 
 func (c *standardRedisClient) MSet(pairs ...interface{}) error {
+	if c.prefix != "" {
+		prefixed := make([]interface{}, len(pairs))
+		for i := 0; i < len(pairs); i += 2 {
+			prefixed[i] = c.key(pairs[i].(string))
+			prefixed[i+1] = pairs[i+1]
+		}
+		pairs = prefixed
+	}
 	if c.ring != nil {
 		return c.ring.MSet(pairs...).Err()
 	}
 	return c.client.MSet(pairs...).Err()
 }
 
-func (c *standardRedisClient) Del(keys ...string) error {
+func (c *standardRedisClient) MSetWithTTL(expiration time.Duration, pairs ...interface{}) error {
+	_, err := c.pipelined(func(pipe redis.Pipeliner) error {
+		for i := 0; i < len(pairs); i += 2 {
+			pipe.Set(c.key(pairs[i].(string)), pairs[i+1], expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *standardRedisClient) Expire(expiration time.Duration, keys ...string) error {
+	_, err := c.pipelined(func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Expire(c.key(key), expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *standardRedisClient) pipelined(fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
 	if c.ring != nil {
-		return c.ring.Del(keys...).Err()
+		return c.ring.Pipelined(fn)
 	}
-	return c.client.Del(keys...).Err()
+	return c.client.Pipelined(fn)
+}
+
+func (c *standardRedisClient) Del(keys ...string) error {
+	if c.ring == nil && len(keys) <= maxRedisDeleteBatch {
+		return c.client.Del(c.keys(keys)...).Err()
+	}
+	_, err := c.pipelined(func(pipe redis.Pipeliner) error {
+		if c.ring != nil {
+			for _, key := range keys {
+				pipe.Del(c.key(key))
+			}
+			return nil
+		}
+		prefixed := c.keys(keys)
+		for start := 0; start < len(prefixed); start += maxRedisDeleteBatch {
+			end := start + maxRedisDeleteBatch
+			if end > len(prefixed) {
+				end = len(prefixed)
+			}
+			pipe.Del(prefixed[start:end]...)
+		}
+		return nil
+	})
+	return err
 }
 
 func (c *standardRedisClient) FlushDB() error {
+	if c.prefix != "" {
+		keys, err := c.ScanKeys("*")
+		if err != nil || len(keys) == 0 {
+			return err
+		}
+		return c.Del(keys...)
+	}
 	if c.ring != nil {
 		return c.ring.FlushDB().Err()
 	}
 	return c.client.FlushDB().Err()
 }
 
+func (c *standardRedisClient) DBSize() (int64, error) {
+	if c.ring == nil {
+		return c.client.DBSize().Result()
+	}
+	var size int64
+	var mutex sync.Mutex
+	err := c.ring.ForEachShard(func(shard *redis.Client) error {
+		shardSize, err := shard.DBSize().Result()
+		mutex.Lock()
+		size += shardSize
+		mutex.Unlock()
+		return err
+	})
+	return size, err
+}
+
+func (c *standardRedisClient) ScanKeys(match string) ([]string, error) {
+	keys, err := c.scanRawKeys(c.key(match))
+	if c.prefix != "" {
+		for i, key := range keys {
+			keys[i] = strings.TrimPrefix(key, c.prefix)
+		}
+	}
+	return keys, err
+}
+
+func (c *standardRedisClient) scanRawKeys(match string) ([]string, error) {
+	if c.ring != nil {
+		keys := make([]string, 0)
+		var mutex sync.Mutex
+		err := c.ring.ForEachShard(func(client *redis.Client) error {
+			shardKeys, err := scanKeys(client, match)
+			mutex.Lock()
+			keys = append(keys, shardKeys...)
+			mutex.Unlock()
+			return err
+		})
+		return keys, err
+	}
+	return scanKeys(c.client, match)
+}
+
+// DelMatching scans keys in batches and removes every batch when it is returned
+func (c *standardRedisClient) DelMatching(match string) (int, error) {
+	match = c.key(match)
+	if c.ring != nil {
+		removed := 0
+		var mutex sync.Mutex
+		err := c.ring.ForEachShard(func(client *redis.Client) error {
+			shardRemoved, err := delMatching(client, match)
+			mutex.Lock()
+			removed += shardRemoved
+			mutex.Unlock()
+			return err
+		})
+		return removed, err
+	}
+	return delMatching(c.client, match)
+}
+
+func (c *standardRedisClient) migratePrefix(oldPrefix string, match string) (int, error) {
+	if oldPrefix == c.prefix {
+		return 0, nil
+	}
+	if c.ring != nil {
+		return 0, errors.NotSupportedf("key prefix migration in redis ring")
+	}
+	keys, err := c.scanRawKeys(match)
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, key := range keys {
+		if c.prefix != "" && strings.HasPrefix(c.prefix, oldPrefix) && strings.HasPrefix(key, c.prefix) {
+			continue
+		}
+		renamed, err := c.client.RenameNX(key, c.prefix+strings.TrimPrefix(key, oldPrefix)).Result()
+		if err != nil {
+			return moved, err
+		}
+		if renamed {
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+func scanKeys(client *redis.Client, match string) ([]string, error) {
+	keys := make([]string, 0)
+	err := scanKeyBatches(client, match, func(batch []string) error {
+		keys = append(keys, batch...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func delMatching(client *redis.Client, match string) (int, error) {
+	removed := 0
+	err := scanKeyBatches(client, match, func(batch []string) error {
+		deleted, err := client.Del(batch...).Result()
+		removed += int(deleted)
+		return err
+	})
+	return removed, err
+}
+
+func scanKeyBatches(client *redis.Client, match string, handler func(batch []string) error) error {
+	cursor := uint64(0)
+	for {
+		result, next, err := client.Scan(cursor, match, 1000).Result()
+		if err != nil {
+			return err
+		}
+		if len(result) > 0 {
+			if err = handler(result); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
 type RedisCache struct {
 	engine  *Engine
 	code    string
@@ -231,8 +475,19 @@ type RedisCache struct {
 type GetSetProvider func() interface{}
 
 func (r *RedisCache) RateLimit(key string, limit *redis_rate.Limit) bool {
+	allowed, err := r.TryRateLimit(key, limit)
+	if err != nil {
+		panic(err)
+	}
+	return allowed
+}
+
+func (r *RedisCache) TryRateLimit(key string, limit *redis_rate.Limit) (bool, error) {
+	c, is := r.client.(*standardRedisClient)
+	if !is {
+		return false, errors.NotSupportedf("rate limit in redis pool '%s'", r.code)
+	}
 	if r.limiter == nil {
-		c := r.client.(*standardRedisClient)
 		if c.client != nil {
 			r.limiter = redis_rate.NewLimiter(c.client)
 		} else {
@@ -240,7 +495,7 @@ func (r *RedisCache) RateLimit(key string, limit *redis_rate.Limit) bool {
 		}
 	}
 	start := time.Now()
-	res, err := r.limiter.Allow(key, limit)
+	res, err := r.limiter.Allow(c.key(key), limit)
 	if r.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
 		r.fillLogFields("[ORM][REDIS][RATE_LIMIT]", start,
 			"rate_limit", 0, 1, map[string]interface{}{"Key": key}, err)
@@ -248,9 +503,9 @@ func (r *RedisCache) RateLimit(key string, limit *redis_rate.Limit) bool {
 	r.engine.dataDog.incrementCounter(counterRedisAll, 1)
 	r.engine.dataDog.incrementCounter(counterRedisKeysSet, 1)
 	if err != nil {
-		panic(err)
+		return false, err
 	}
-	return res.Allowed
+	return res.Allowed, nil
 }
 
 func (r *RedisCache) GetSet(key string, ttlSeconds int, provider GetSetProvider) interface{} {
@@ -350,6 +605,21 @@ func (r *RedisCache) LLen(key string) int64 {
 	return val
 }
 
+func (r *RedisCache) IncrBy(key string, value int64) int64 {
+	start := time.Now()
+	val, err := r.client.IncrBy(key, value)
+	if r.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
+		r.fillLogFields("[ORM][REDIS][INCRBY]", start, "incrby", -1, 1,
+			map[string]interface{}{"Key": key, "value": value}, err)
+	}
+	r.engine.dataDog.incrementCounter(counterRedisAll, 1)
+	r.engine.dataDog.incrementCounter(counterRedisKeysSet, 1)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
 func (r *RedisCache) LRange(key string, start, stop int64) []string {
 	s := time.Now()
 	val, err := r.client.LRange(key, start, stop)
@@ -565,6 +835,33 @@ func (r *RedisCache) MSet(pairs ...interface{}) {
 	}
 }
 
+func (r *RedisCache) MSetWithTTL(ttlSeconds int, pairs ...interface{}) {
+	start := time.Now()
+	err := r.client.MSetWithTTL(time.Duration(ttlSeconds)*time.Second, pairs...)
+	if r.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
+		r.fillLogFields("[ORM][REDIS][MSET]", start, "mset", -1, len(pairs),
+			map[string]interface{}{"Pairs": pairs, "ttl": ttlSeconds}, err)
+	}
+	r.engine.dataDog.incrementCounter(counterRedisAll, 1)
+	r.engine.dataDog.incrementCounter(counterRedisKeysSet, uint(len(pairs)))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (r *RedisCache) Expire(ttlSeconds int, keys ...string) {
+	start := time.Now()
+	err := r.client.Expire(time.Duration(ttlSeconds)*time.Second, keys...)
+	if r.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
+		r.fillLogFields("[ORM][REDIS][EXPIRE]", start, "expire", -1, len(keys),
+			map[string]interface{}{"Keys": keys, "ttl": ttlSeconds}, err)
+	}
+	r.engine.dataDog.incrementCounter(counterRedisAll, 1)
+	if err != nil {
+		panic(err)
+	}
+}
+
 func (r *RedisCache) MGet(keys ...string) map[string]interface{} {
 	start := time.Now()
 	val, err := r.client.MGet(keys...)
@@ -673,6 +970,79 @@ func (r *RedisCache) Del(keys ...string) {
 	}
 }
 
+func (r *RedisCache) Keys(match string) []string {
+	start := time.Now()
+	keys, err := r.client.ScanKeys(match)
+	if r.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
+		r.fillLogFields("[ORM][REDIS][SCAN]", start, "scan", -1, len(keys),
+			map[string]interface{}{"Key": match}, err)
+	}
+	r.engine.dataDog.incrementCounter(counterRedisAll, 1)
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}
+
+// DelPrefix removes all keys starting with prefix, keys are scanned and removed in batches
+func (r *RedisCache) DelPrefix(prefix string) int {
+	start := time.Now()
+	match := escapeRedisPattern(prefix) + "*"
+	removed, err := r.client.DelMatching(match)
+	if r.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
+		r.fillLogFields("[ORM][REDIS][DEL]", start, "del", -1, removed,
+			map[string]interface{}{"Key": match}, err)
+	}
+	r.engine.dataDog.incrementCounter(counterRedisAll, 1)
+	r.engine.dataDog.incrementCounter(counterRedisKeysGet, uint(removed))
+	if err != nil {
+		panic(err)
+	}
+	return removed
+}
+
+func escapeRedisPattern(value string) string {
+	var builder strings.Builder
+	for _, char := range value {
+		switch char {
+		case '*', '?', '[', ']', '\\':
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(char)
+	}
+	return builder.String()
+}
+
+func (r *RedisCache) publish(channel string, message string) {
+	start := time.Now()
+	err := r.client.Publish(channel, message)
+	if r.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
+		r.fillLogFields("[ORM][REDIS][PUBLISH]", start, "publish", -1, 1,
+			map[string]interface{}{"Key": channel}, err)
+	}
+	r.engine.dataDog.incrementCounter(counterRedisAll, 1)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (r *RedisCache) eval(script string, keys []string, args ...interface{}) interface{} {
+	start := time.Now()
+	val, err := r.client.Eval(script, keys, args...)
+	if err == redis.Nil {
+		err = nil
+	}
+	if r.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
+		r.fillLogFields("[ORM][REDIS][EVAL]", start, "eval", -1, len(keys),
+			map[string]interface{}{"Keys": keys}, err)
+	}
+	r.engine.dataDog.incrementCounter(counterRedisAll, 1)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
 func (r *RedisCache) FlushDB() {
 	start := time.Now()
 	err := r.client.FlushDB()
@@ -686,10 +1056,34 @@ func (r *RedisCache) FlushDB() {
 	}
 }
 
+// MigrateKeyPrefix renames keys starting with oldPrefix and matching optional pattern (default "*"),
+// empty oldPrefix requires pattern so keys of other applications in the same database are not renamed
+func (r *RedisCache) MigrateKeyPrefix(oldPrefix string, match ...string) (int, error) {
+	c, is := r.client.(*standardRedisClient)
+	if !is {
+		return 0, errors.NotSupportedf("key prefix migration in redis pool '%s'", r.code)
+	}
+	pattern := "*"
+	if len(match) > 0 {
+		pattern = match[0]
+	} else if oldPrefix == "" {
+		return 0, errors.NotValidf("empty old prefix without keys pattern")
+	}
+	start := time.Now()
+	moved, err := c.migratePrefix(oldPrefix, escapeRedisPattern(oldPrefix)+pattern)
+	if r.engine.queryLoggers[QueryLoggerSourceRedis] != nil {
+		r.fillLogFields("[ORM][REDIS][RENAME]", start, "rename", -1, moved,
+			map[string]interface{}{"from": oldPrefix, "to": c.prefix, "Key": pattern}, err)
+	}
+	r.engine.dataDog.incrementCounter(counterRedisAll, 1)
+	r.engine.dataDog.incrementCounter(counterRedisKeysSet, uint(moved))
+	return moved, err
+}
+
 func (r *RedisCache) fillLogFields(message string, start time.Time, operation string, misses int, keys int, fields map[string]interface{}, err error) {
 	now := time.Now()
 	stop := time.Since(start).Microseconds()
-	e := r.engine.queryLoggers[QueryLoggerSourceRedis].log.
+	e := r.engine.queryLoggerEntry(QueryLoggerSourceRedis).
 		WithField("microseconds", stop).
 		WithField("operation", operation).
 		WithField("pool", r.code).
@@ -700,7 +1094,7 @@ func (r *RedisCache) fillLogFields(message string, start time.Time, operation st
 	if misses >= 0 {
 		e = e.WithField("misses", misses)
 	}
-	for k, v := range fields {
+	for k, v := range redactRedisLogFields(r.engine.registry, fields) {
 		e = e.WithField(k, v)
 	}
 	if err != nil {
@@ -0,0 +1,22 @@
+package orm
+
+import (
+	"github.com/juju/errors"
+)
+
+func getReference(engine *Engine, entity Entity, field string) Entity {
+	orm := initIfNeeded(engine, entity)
+	_, has := orm.tableSchema.tags[field]["ref"]
+	if !has {
+		panic(errors.NotValidf("reference %s in %s", field, orm.tableSchema.t.String()))
+	}
+	value := orm.attributes.elem.FieldByName(field)
+	if value.IsNil() {
+		return nil
+	}
+	reference := value.Interface().(Entity)
+	if !engine.Loaded(reference) {
+		engine.Load(reference)
+	}
+	return reference
+}
@@ -1,6 +1,7 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	log2 "log"
@@ -11,31 +12,61 @@ import (
 	"strings"
 	"time"
 
+	apexLog "github.com/apex/log"
 	"github.com/go-redis/redis/v7"
-	"github.com/golang/groupcache/lru"
 	"github.com/jmoiron/sqlx"
 	"github.com/juju/errors"
 	"github.com/olivere/elastic/v7"
+	"github.com/streadway/amqp"
+)
+
+type UnsupportedFieldsPolicy int
+
+const (
+	UnsupportedFieldsError UnsupportedFieldsPolicy = iota
+	UnsupportedFieldsSkip
 )
 
 type Registry struct {
-	sqlClients           map[string]*DBConfig
-	clickHouseClients    map[string]*ClickHouseConfig
-	localCacheContainers map[string]*LocalCacheConfig
-	redisServers         map[string]*RedisCacheConfig
-	elasticServers       map[string]*ElasticConfig
-	rabbitMQServers      map[string]*rabbitMQConfig
-	rabbitMQQueues       map[string][]*RabbitMQQueueConfig
-	rabbitMQRouters      map[string][]*RabbitMQRouterConfig
-	entities             map[string]reflect.Type
-	enums                map[string]Enum
-	dirtyQueues          map[string]int
-	locks                map[string]string
+	sqlClients              map[string]*DBConfig
+	clickHouseClients       map[string]*ClickHouseConfig
+	localCacheContainers    map[string]*LocalCacheConfig
+	redisServers            map[string]*RedisCacheConfig
+	elasticServers          map[string]*ElasticConfig
+	rabbitMQServers         map[string]*rabbitMQConfig
+	rabbitMQQueues          map[string][]*RabbitMQQueueConfig
+	rabbitMQRouters         map[string][]*RabbitMQRouterConfig
+	entities                map[string]reflect.Type
+	enums                   map[string]Enum
+	dirtyQueues             map[string]int
+	locks                   map[string]string
+	redLocks                map[string][]string
+	tracer                  Tracer
+	tagName                 string
+	logger                  apexLog.Interface
+	unsupportedFieldsPolicy UnsupportedFieldsPolicy
+	timeLocation            *time.Location
+	trackLimit              int
+	maxPageSize             int
+	sqlMiddlewares          []SQLMiddleware
+	secretResolver          SecretResolver
+	interpolationError      error
+	interpolated            bool
+	optionalPools           map[string]bool
+	shards                  map[string]*shardDefinition
+	tenantPools             map[string]string
+	idGenerators            map[string]IDGenerator
+	logWriters              map[string]LogWriter
+	localCacheBus           *localCacheBus
 }
 
 func (r *Registry) Validate() (ValidatedRegistry, error) {
+	if r.interpolationError != nil {
+		return nil, r.interpolationError
+	}
 	registry := &validatedRegistry{}
 	registry.registry = r
+	registry.tracer = r.tracer
 	l := len(r.entities)
 	registry.tableSchemas = make(map[reflect.Type]*tableSchema, l)
 	registry.entities = make(map[string]reflect.Type)
@@ -43,58 +74,20 @@ func (r *Registry) Validate() (ValidatedRegistry, error) {
 		registry.sqlClients = make(map[string]*DBConfig)
 	}
 	for k, v := range r.sqlClients {
-		db, err := sql.Open("mysql", v.dataSourceName)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		var autoincrement uint64
-		var maxConnections int
-		var skip string
-		err = db.QueryRow("SHOW VARIABLES LIKE 'auto_increment_increment'").Scan(&skip, &autoincrement)
-		if err != nil {
-			return nil, errors.Annotatef(err, "can't connect to mysql '%s'", v.code)
-		}
-		v.autoincrement = autoincrement
-
-		err = db.QueryRow("SHOW VARIABLES LIKE 'max_connections'").Scan(&skip, &maxConnections)
+		err := openSQLPool(v, isPoolOptional(r.optionalPools, "mysql", k))
 		if err != nil {
-			return nil, errors.Annotatef(err, "can't connect to mysql '%s'", v.code)
-		}
-		var waitTimeout int
-		err = db.QueryRow("SHOW VARIABLES LIKE 'wait_timeout'").Scan(&skip, &waitTimeout)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		maxConnections = int(math.Floor(float64(maxConnections) * 0.9))
-		if maxConnections == 0 {
-			maxConnections = 1
-		}
-		maxIdleConnections := int(math.Floor(float64(maxConnections) * 0.2))
-		if maxIdleConnections == 0 {
-			maxIdleConnections = 2
-		}
-		waitTimeout = int(math.Floor(float64(waitTimeout) * 0.8))
-		if waitTimeout == 0 {
-			waitTimeout = 1
+			return nil, err
 		}
-		db.SetMaxOpenConns(maxConnections)
-		db.SetMaxIdleConns(maxIdleConnections)
-		db.SetConnMaxLifetime(time.Duration(waitTimeout) * time.Second)
-		v.db = db
 		registry.sqlClients[k] = v
 	}
 	if registry.clickHouseClients == nil {
 		registry.clickHouseClients = make(map[string]*ClickHouseConfig)
 	}
 	for k, v := range r.clickHouseClients {
-		db, err := sqlx.Open("clickhouse", v.url)
+		err := openClickHouse(v, isPoolOptional(r.optionalPools, "clickhouse", k))
 		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		if err = db.Ping(); err != nil {
-			return nil, errors.Annotate(err, "issue while connecting to clickhouse")
+			return nil, err
 		}
-		v.db = db
 		registry.clickHouseClients[k] = v
 	}
 
@@ -110,6 +103,15 @@ func (r *Registry) Validate() (ValidatedRegistry, error) {
 	for k, v := range r.locks {
 		registry.lockServers[k] = v
 	}
+	if registry.redLockServers == nil {
+		registry.redLockServers = make(map[string][]string)
+	}
+	validationError := &ValidationError{}
+	for k, v := range r.redLocks {
+		if checkRedLock(r, k, v, validationError) {
+			registry.redLockServers[k] = v
+		}
+	}
 
 	if registry.localCacheContainers == nil {
 		registry.localCacheContainers = make(map[string]*LocalCacheConfig)
@@ -123,11 +125,20 @@ func (r *Registry) Validate() (ValidatedRegistry, error) {
 	for k, v := range r.redisServers {
 		registry.redisServers[k] = v
 	}
+	if r.localCacheBus != nil {
+		if _, has := r.redisServers[r.localCacheBus.pool]; !has {
+			return nil, errors.NotFoundf("local cache invalidation redis pool '%s'", r.localCacheBus.pool)
+		}
+	}
 
 	if registry.elasticServers == nil {
 		registry.elasticServers = make(map[string]*ElasticConfig)
 	}
 	for k, v := range r.elasticServers {
+		err := openElastic(v, isPoolOptional(r.optionalPools, "elastic", k))
+		if err != nil {
+			return nil, err
+		}
 		registry.elasticServers[k] = v
 	}
 
@@ -144,12 +155,14 @@ func (r *Registry) Validate() (ValidatedRegistry, error) {
 	for connectionCode, routers := range r.rabbitMQRouters {
 		_, has := registry.rabbitMQServers[connectionCode]
 		if !has {
-			return nil, errors.Errorf("rabbitMQ server '%s' is not registered", connectionCode)
+			validationError.add("", "", errors.Errorf("rabbitMQ server '%s' is not registered", connectionCode))
+			continue
 		}
 		for _, def := range routers {
 			_, has := registry.rabbitMQRouterConfigs[def.Name]
 			if has {
-				return nil, errors.Errorf("rabbitMQ router name '%s' already exists", def.Name)
+				validationError.add("", "", errors.Errorf("rabbitMQ router name '%s' already exists", def.Name))
+				continue
 			}
 			registry.rabbitMQRouterConfigs[def.Name] = def
 		}
@@ -161,17 +174,20 @@ func (r *Registry) Validate() (ValidatedRegistry, error) {
 	for connectionCode, queues := range r.rabbitMQQueues {
 		connection, has := registry.rabbitMQServers[connectionCode]
 		if !has {
-			return nil, errors.Errorf("rabbitMQ server '%s' is not registered", connectionCode)
+			validationError.add("", "", errors.Errorf("rabbitMQ server '%s' is not registered", connectionCode))
+			continue
 		}
 		for _, def := range queues {
 			_, has := registry.rabbitMQChannelsToQueue[def.Name]
 			if has {
-				return nil, errors.Errorf("rabbitMQ channel name '%s' already exists", def.Name)
+				validationError.add("", "", errors.Errorf("rabbitMQ channel name '%s' already exists", def.Name))
+				continue
 			}
 			if def.Router != "" {
 				_, has := registry.rabbitMQRouterConfigs[def.Router]
 				if !has {
-					return nil, errors.Errorf("rabbitMQ router name '%s' is not registered", def.Router)
+					validationError.add("", "", errors.Errorf("rabbitMQ router name '%s' is not registered", def.Router))
+					continue
 				}
 			}
 			channel := &rabbitMQChannelToQueue{connection: connection, config: def}
@@ -184,24 +200,65 @@ func (r *Registry) Validate() (ValidatedRegistry, error) {
 	for k, v := range r.enums {
 		registry.enums[k] = v
 	}
-	for name, entityType := range r.entities {
+	tableNames := make(map[string]string)
+	for _, name := range sortedEntityNames(r.entities) {
+		entityType := r.entities[name]
 		tableSchema, err := initTableSchema(r, entityType)
+		if err == nil {
+			err = checkGeneratedCode(entityType)
+		}
 		if err != nil {
-			return nil, err
+			validationError.add(entityType.String(), "", err)
+			continue
 		}
+		tableKey := tableSchema.mysqlPoolName + ":" + tableSchema.tableName
+		duplicated, has := tableNames[tableKey]
+		if has {
+			validationError.add(entityType.String(), "", errors.AlreadyExistsf("table '%s' in pool '%s' used also in %s",
+				tableSchema.tableName, tableSchema.mysqlPoolName, duplicated))
+			continue
+		}
+		tableNames[tableKey] = entityType.String()
 		registry.tableSchemas[entityType] = tableSchema
 		registry.entities[name] = entityType
 	}
-	engine := registry.CreateEngine()
+	for _, name := range sortedEntityNames(r.entities) {
+		schema, has := registry.tableSchemas[r.entities[name]]
+		if has {
+			addCachedQueryReferences(registry, schema)
+		}
+	}
+	for _, schema := range registry.tableSchemas {
+		if len(schema.redactedColumns) > 0 {
+			if registry.redactedCachePrefixes == nil {
+				registry.redactedCachePrefixes = make(map[string]*tableSchema)
+			}
+			registry.redactedCachePrefixes[schema.cachePrefix] = schema
+		}
+	}
+	engine := registry.createEngine()
 	hasLog := false
 	for _, schema := range registry.tableSchemas {
 		_, err := checkStruct(schema, engine, schema.t, make(map[string]*index), make(map[string]*foreignIndex), "")
 		if err != nil {
-			return nil, errors.Annotatef(err, "invalid entity struct '%s'", schema.t.String())
+			validationError.merge(schema.t.String(), err)
 		}
 		if schema.hasLog {
 			hasLog = true
 		}
+		if schema.hasChanges && registry.rabbitMQChannelsToQueue[schema.getChangesQueueName()] == nil {
+			connection, has := registry.rabbitMQServers["default"]
+			if !has {
+				return nil, errors.Errorf("missing default rabbitMQ connection to handle entity change stream")
+			}
+			name := schema.getChangesQueueName()
+			registry.rabbitMQRouterConfigs[name] = &RabbitMQRouterConfig{Name: name, Type: "fanout", Durable: true}
+			def := &RabbitMQQueueConfig{Name: name, Router: name, Durable: true, publishOnly: true}
+			registry.rabbitMQChannelsToQueue[def.Name] = &rabbitMQChannelToQueue{connection: connection, config: def}
+		}
+	}
+	if len(validationError.Problems) > 0 {
+		return nil, validationError
 	}
 	if hasLog && registry.rabbitMQChannelsToQueue[logQueueName] == nil {
 		connection, has := registry.rabbitMQServers["default"]
@@ -219,6 +276,11 @@ func (r *Registry) Validate() (ValidatedRegistry, error) {
 		def := &RabbitMQQueueConfig{Name: lazyQueueName, Durable: true}
 		registry.rabbitMQChannelsToQueue[lazyQueueName] = &rabbitMQChannelToQueue{connection: connection, config: def}
 	}
+	if registry.rabbitMQChannelsToQueue[lazyDelayedQueueName] == nil {
+		def := &RabbitMQQueueConfig{Name: lazyDelayedQueueName, Durable: true}
+		registry.rabbitMQChannelsToQueue[lazyDelayedQueueName] = &rabbitMQChannelToQueue{
+			connection: registry.rabbitMQChannelsToQueue[lazyQueueName].connection, config: def}
+	}
 	if registry.rabbitMQChannelsToQueue[flushCacheQueueName] == nil {
 		connection, has := registry.rabbitMQServers["default"]
 		if !has {
@@ -240,25 +302,245 @@ func (r *Registry) Validate() (ValidatedRegistry, error) {
 		}
 	}
 	//init rabbitMQ channels
-	engine = registry.CreateEngine()
-	for code, config := range registry.rabbitMQChannelsToQueue {
-		if config.config.Router == "" {
-			r := engine.GetRabbitMQQueue(code)
-			receiver := r.initChannel(config.config.Name, false)
-			err := receiver.Close()
-			if err != nil {
-				panic(err)
-			}
-		} else {
-			r := engine.GetRabbitMQRouter(code)
-			receiver := r.initChannel(config.config.Name, false)
-			err := receiver.Close()
-			if err != nil {
-				panic(err)
+	initRabbitMQChannels(registry.createEngine(), registry.rabbitMQChannelsToQueue, r.optionalPools)
+	return registry, nil
+}
+
+// mysql server defaults, used to limit optional pool that is not available yet
+const (
+	defaultMySQLMaxConnections = 151
+	defaultMySQLWaitTimeout    = 28800
+)
+
+func openSQLPool(v *DBConfig, optional bool) error {
+	err := applyMySQLPoolOptions(v)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("mysql", v.dataSourceName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	v.db = db
+	if v.readDataSourceName != "" {
+		readDB, err := sql.Open("mysql", v.readDataSourceName)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		v.readDB = readDB
+	}
+	err = probeSQLPool(context.Background(), v)
+	if err != nil && optional {
+		v.autoincrement = 1
+		setSQLPoolLimits(v, defaultMySQLMaxConnections, defaultMySQLWaitTimeout)
+		logUnavailablePool("mysql", v.code, err)
+		return nil
+	}
+	return err
+}
+
+func probeSQLPool(ctx context.Context, v *DBConfig) error {
+	db := v.db
+	var autoincrement uint64
+	var maxConnections int
+	var skip string
+	err := db.QueryRowContext(ctx, "SHOW VARIABLES LIKE 'auto_increment_increment'").Scan(&skip, &autoincrement)
+	if err != nil {
+		return errors.Annotatef(err, "can't connect to mysql '%s'", v.code)
+	}
+	v.autoincrement = autoincrement
+	err = db.QueryRowContext(ctx, "SHOW VARIABLES LIKE 'version'").Scan(&skip, &v.version)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = db.QueryRowContext(ctx, "SHOW VARIABLES LIKE 'max_connections'").Scan(&skip, &maxConnections)
+	if err != nil {
+		return errors.Annotatef(err, "can't connect to mysql '%s'", v.code)
+	}
+	var waitTimeout int
+	err = db.QueryRowContext(ctx, "SHOW VARIABLES LIKE 'wait_timeout'").Scan(&skip, &waitTimeout)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	setSQLPoolLimits(v, maxConnections, waitTimeout)
+	return nil
+}
+
+// setSQLPoolLimits keeps pool below mysql max_connections and closes connections before wait_timeout
+func setSQLPoolLimits(v *DBConfig, maxConnections int, waitTimeout int) {
+	db := v.db
+	maxConnections = int(math.Floor(float64(maxConnections) * 0.9))
+	if maxConnections == 0 {
+		maxConnections = 1
+	}
+	maxIdleConnections := int(math.Floor(float64(maxConnections) * 0.2))
+	if maxIdleConnections == 0 {
+		maxIdleConnections = 2
+	}
+	waitTimeout = int(math.Floor(float64(waitTimeout) * 0.8))
+	if waitTimeout == 0 {
+		waitTimeout = 1
+	}
+	if v.readDB != nil {
+		maxConnections = (maxConnections + 1) / 2
+		maxIdleConnections = (maxIdleConnections + 1) / 2
+		v.readDB.SetMaxOpenConns(maxConnections)
+		v.readDB.SetMaxIdleConns(maxIdleConnections)
+		v.readDB.SetConnMaxLifetime(time.Duration(waitTimeout) * time.Second)
+	}
+	db.SetMaxOpenConns(maxConnections)
+	db.SetMaxIdleConns(maxIdleConnections)
+	db.SetConnMaxLifetime(time.Duration(waitTimeout) * time.Second)
+}
+
+func openClickHouse(v *ClickHouseConfig, optional bool) error {
+	db, err := sqlx.Open("clickhouse", v.url)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	v.db = db
+	if err = db.Ping(); err != nil {
+		if optional {
+			logUnavailablePool("clickhouse", v.code, err)
+			return nil
+		}
+		return errors.Annotate(err, "issue while connecting to clickhouse")
+	}
+	return nil
+}
+
+func openElastic(v *ElasticConfig, optional bool) error {
+	if v.client != nil {
+		return nil
+	}
+	clientOptions := []elastic.ClientOptionFunc{elastic.SetSniff(false), elastic.SetURL(v.url),
+		elastic.SetHealthcheckInterval(5 * time.Second), elastic.SetRetrier(elastic.NewBackoffRetrier(elastic.NewExponentialBackoff(10*time.Millisecond, 5*time.Second)))}
+	if v.withTrace {
+		clientOptions = append(clientOptions, elastic.SetTraceLog(log2.New(os.Stdout, "", log2.LstdFlags)))
+	}
+	client, err := elastic.NewClient(clientOptions...)
+	if err != nil && optional {
+		logUnavailablePool("elastic", v.code, err)
+		client, err = elastic.NewClient(append(clientOptions, elastic.SetHealthcheck(false))...)
+	}
+	if err != nil {
+		return errors.Annotatef(err, "can't connect to elastic '%s'", v.code)
+	}
+	v.client = client
+	return nil
+}
+
+func logUnavailablePool(poolType string, code string, err error) {
+	apexLog.WithFields(apexLog.Fields{"type": poolType, "pool": code, "error": err.Error()}).Warn("[ORM][POOL][UNAVAILABLE]")
+}
+
+func checkRedLock(r *Registry, code string, pools []string, validationError *ValidationError) bool {
+	if len(pools) < 3 {
+		validationError.add("", "", errors.NotValidf("redlock '%s' with %d redis pools, at least 3 are required", code, len(pools)))
+		return false
+	}
+	for _, redisCode := range pools {
+		redisConfig, has := r.redisServers[redisCode]
+		if !has {
+			validationError.add("", "", errors.NotFoundf("redis pool '%s'", redisCode))
+		} else if redisConfig.client == nil {
+			validationError.add("", "", errors.NotSupportedf("redis ring '%s' in redlock", redisCode))
+		}
+	}
+	return true
+}
+
+func initRabbitMQChannels(engine *Engine, channels map[string]*rabbitMQChannelToQueue, optionalPools map[string]bool) {
+	for code, config := range channels {
+		server := config.connection.config.code
+		if !isPoolOptional(optionalPools, "rabbitMQ", server) {
+			initRabbitMQChannel(engine, code, config)
+			continue
+		}
+		err := tryInitRabbitMQChannel(engine, code, config)
+		if err != nil {
+			logUnavailablePool("rabbitMQ", server, err)
+		}
+	}
+}
+
+func tryInitRabbitMQChannel(engine *Engine, code string, config *rabbitMQChannelToQueue) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			asErr, is := rec.(error)
+			if !is {
+				asErr = errors.Errorf("%v", rec)
 			}
+			err = asErr
 		}
+	}()
+	initRabbitMQChannel(engine, code, config)
+	return nil
+}
+
+func initRabbitMQChannel(engine *Engine, code string, config *rabbitMQChannelToQueue) {
+	var receiver *amqp.Channel
+	if config.config.Router == "" {
+		receiver = engine.GetRabbitMQQueue(code).initChannel(config.config.Name, false)
+	} else {
+		receiver = engine.GetRabbitMQRouter(code).initChannel(config.config.Name, false)
+	}
+	err := receiver.Close()
+	if err != nil {
+		panic(err)
 	}
-	return registry, nil
+}
+
+// SetPoolRequired marks pool with code in all pool types, or only in one type when code is prefixed
+// like in PoolConnectionError: mysql:, clickhouse:, redis:, elastic:, rabbitMQ:
+func (r *Registry) SetPoolRequired(code string, required bool) {
+	if r.optionalPools == nil {
+		r.optionalPools = make(map[string]bool)
+	}
+	r.optionalPools[code] = !required
+}
+
+func isPoolOptional(optionalPools map[string]bool, kind string, code string) bool {
+	optional, has := optionalPools[kind+":"+code]
+	if has {
+		return optional
+	}
+	return optionalPools[code]
+}
+
+func (r *Registry) SetTagName(name string) {
+	r.tagName = name
+}
+
+func (r *Registry) SetLogger(logger apexLog.Interface) {
+	r.logger = logger
+}
+
+func (r *Registry) SetUnsupportedFieldsPolicy(policy UnsupportedFieldsPolicy) {
+	r.unsupportedFieldsPolicy = policy
+}
+
+func (r *Registry) SetTimeLocation(location *time.Location) {
+	r.timeLocation = location
+}
+
+func (r *Registry) SetTrackLimit(limit int) {
+	r.trackLimit = limit
+}
+
+func (r *Registry) getTagName() string {
+	if r.tagName == "" {
+		return "orm"
+	}
+	return r.tagName
+}
+
+func (r *Registry) getLogger() apexLog.Interface {
+	if r.logger == nil {
+		return apexLog.Log
+	}
+	return r.logger
 }
 
 func (r *Registry) RegisterEntity(entity ...interface{}) {
@@ -332,10 +614,11 @@ func (r *Registry) RegisterLocalCache(size int, code ...string) {
 	if r.localCacheContainers == nil {
 		r.localCacheContainers = make(map[string]*LocalCacheConfig)
 	}
-	r.localCacheContainers[dbCode] = &LocalCacheConfig{code: dbCode, lru: lru.New(size)}
+	r.localCacheContainers[dbCode] = &LocalCacheConfig{code: dbCode, lru: newShardedLRU(size)}
 }
 
 func (r *Registry) RegisterRedis(address string, db int, code ...string) {
+	address = r.interpolate(address)
 	client := redis.NewClient(&redis.Options{
 		Addr: address,
 		DB:   db,
@@ -354,7 +637,7 @@ func (r *Registry) RegisterRedis(address string, db int, code ...string) {
 func (r *Registry) RegisterRedisRing(addresses []string, db int, code ...string) {
 	list := make(map[string]string, len(addresses))
 	for i, address := range addresses {
-		list[fmt.Sprintf("shard%d", i+1)] = address
+		list[fmt.Sprintf("shard%d", i+1)] = r.interpolate(address)
 	}
 	ring := redis.NewRing(&redis.RingOptions{
 		Addrs: list,
@@ -371,12 +654,24 @@ func (r *Registry) RegisterRedisRing(addresses []string, db int, code ...string)
 	r.redisServers[dbCode] = redisCache
 }
 
+func (r *Registry) SetRedisKeyPrefix(prefix string, code ...string) {
+	dbCode := "default"
+	if len(code) > 0 {
+		dbCode = code[0]
+	}
+	redisCache, has := r.redisServers[dbCode]
+	if !has {
+		panic(errors.NotFoundf("redis pool '%s'", dbCode))
+	}
+	redisCache.prefix = prefix
+}
+
 func (r *Registry) RegisterRabbitMQServer(address string, code ...string) {
 	dbCode := "default"
 	if len(code) > 0 {
 		dbCode = code[0]
 	}
-	rabbitMQ := &rabbitMQConfig{code: dbCode, address: address}
+	rabbitMQ := &rabbitMQConfig{code: dbCode, address: r.interpolate(address)}
 	if r.rabbitMQServers == nil {
 		r.rabbitMQServers = make(map[string]*rabbitMQConfig)
 	}
@@ -425,11 +720,23 @@ func (r *Registry) RegisterLocker(code string, redisCode string) {
 	r.locks[code] = redisCode
 }
 
+func (r *Registry) RegisterTracer(tracer Tracer) {
+	r.tracer = tracer
+}
+
+func (r *Registry) RegisterRedLock(code string, redisCodes ...string) {
+	if r.redLocks == nil {
+		r.redLocks = make(map[string][]string)
+	}
+	r.redLocks[code] = redisCodes
+}
+
 func (r *Registry) registerSQLPool(dataSourceName string, code ...string) {
 	dbCode := "default"
 	if len(code) > 0 {
 		dbCode = code[0]
 	}
+	dataSourceName = r.interpolate(dataSourceName)
 	db := &DBConfig{code: dbCode, dataSourceName: dataSourceName}
 	if r.sqlClients == nil {
 		r.sqlClients = make(map[string]*DBConfig)
@@ -446,7 +753,7 @@ func (r *Registry) RegisterClickHouse(url string, code ...string) {
 	if len(code) > 0 {
 		dbCode = code[0]
 	}
-	db := &ClickHouseConfig{code: dbCode, url: url}
+	db := &ClickHouseConfig{code: dbCode, url: r.interpolate(url)}
 	if r.clickHouseClients == nil {
 		r.clickHouseClients = make(map[string]*ClickHouseConfig)
 	}
@@ -454,22 +761,11 @@ func (r *Registry) RegisterClickHouse(url string, code ...string) {
 }
 
 func (r *Registry) registerElastic(url string, withTrace bool, code ...string) {
-	clientOptions := []elastic.ClientOptionFunc{elastic.SetSniff(false), elastic.SetURL(url),
-		elastic.SetHealthcheckInterval(5 * time.Second), elastic.SetRetrier(elastic.NewBackoffRetrier(elastic.NewExponentialBackoff(10*time.Millisecond, 5*time.Second)))}
-	if withTrace {
-		clientOptions = append(clientOptions, elastic.SetTraceLog(log2.New(os.Stdout, "", log2.LstdFlags)))
-	}
-	client, err := elastic.NewClient(
-		clientOptions...,
-	)
-	if err != nil {
-		panic(err)
-	}
 	dbCode := "default"
 	if len(code) > 0 {
 		dbCode = code[0]
 	}
-	config := &ElasticConfig{code: dbCode, client: client}
+	config := &ElasticConfig{code: dbCode, url: r.interpolate(url), withTrace: withTrace}
 	if r.elasticServers == nil {
 		r.elasticServers = make(map[string]*ElasticConfig)
 	}
@@ -480,9 +776,12 @@ type RedisCacheConfig struct {
 	code   string
 	client *redis.Client
 	ring   *redis.Ring
+	prefix string
 }
 
 type ElasticConfig struct {
-	code   string
-	client *elastic.Client
+	code      string
+	url       string
+	withTrace bool
+	client    *elastic.Client
 }
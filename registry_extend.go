@@ -0,0 +1,434 @@
+package orm
+
+import (
+	"reflect"
+
+	"github.com/juju/errors"
+	"github.com/streadway/amqp"
+)
+
+func (r *validatedRegistry) current() *validatedRegistry {
+	root := r
+	if r.root != nil {
+		root = r.root
+	}
+	latest := root.latest.Load()
+	if latest == nil {
+		return root
+	}
+	return latest.(*validatedRegistry)
+}
+
+func (r *validatedRegistry) Extend(callback func(r *Registry)) error {
+	root := r
+	if r.root != nil {
+		root = r.root
+	}
+	root.extendLock.Lock()
+	defer root.extendLock.Unlock()
+	previous := root.current()
+	merged := previous.registry.clone()
+	callback(merged)
+	if merged.interpolationError != nil {
+		return merged.interpolationError
+	}
+	next := previous.clone(merged, root)
+	// pools opened here are closed when extension fails
+	opened := make([]func(), 0)
+	extended := false
+	defer func() {
+		if !extended {
+			for _, closePool := range opened {
+				closePool()
+			}
+		}
+	}()
+	newChannels := make(map[string]*rabbitMQChannelToQueue)
+	validationError := &ValidationError{}
+	for k, v := range merged.rabbitMQQueues {
+		if len(v) != len(previous.registry.rabbitMQQueues[k]) {
+			validationError.add("", "", errors.NotSupportedf("rabbitMQ queues in registry extension"))
+		}
+	}
+	for k, v := range merged.rabbitMQRouters {
+		if len(v) != len(previous.registry.rabbitMQRouters[k]) {
+			validationError.add("", "", errors.NotSupportedf("rabbitMQ routers in registry extension"))
+		}
+	}
+	for k, v := range merged.sqlClients {
+		if _, has := next.sqlClients[k]; !has {
+			err := openSQLPool(v, isPoolOptional(merged.optionalPools, "mysql", k))
+			opened = append(opened, closeSQLPool(v))
+			if err != nil {
+				return err
+			}
+			next.sqlClients[k] = v
+		}
+	}
+	for k, v := range merged.clickHouseClients {
+		if _, has := next.clickHouseClients[k]; !has {
+			err := openClickHouse(v, isPoolOptional(merged.optionalPools, "clickhouse", k))
+			opened = append(opened, closeClickHouse(v))
+			if err != nil {
+				return err
+			}
+			next.clickHouseClients[k] = v
+		}
+	}
+	for k, v := range merged.localCacheContainers {
+		if _, has := next.localCacheContainers[k]; !has {
+			next.localCacheContainers[k] = v
+		}
+	}
+	for k, v := range merged.redisServers {
+		if _, has := next.redisServers[k]; !has {
+			next.redisServers[k] = v
+		}
+	}
+	for k, v := range merged.elasticServers {
+		if _, has := next.elasticServers[k]; !has {
+			err := openElastic(v, isPoolOptional(merged.optionalPools, "elastic", k))
+			opened = append(opened, closeElastic(v))
+			if err != nil {
+				return err
+			}
+			next.elasticServers[k] = v
+		}
+	}
+	for k, v := range merged.rabbitMQServers {
+		if _, has := next.rabbitMQServers[k]; !has {
+			next.rabbitMQServers[k] = &rabbitMQConnection{config: v}
+		}
+	}
+	for k, v := range merged.locks {
+		next.lockServers[k] = v
+	}
+	for k, v := range merged.redLocks {
+		if _, has := next.redLockServers[k]; !has && checkRedLock(merged, k, v, validationError) {
+			next.redLockServers[k] = v
+		}
+	}
+	for k, v := range merged.enums {
+		next.enums[k] = v
+	}
+	for name, max := range merged.dirtyQueues {
+		if _, has := next.dirtyQueues[name]; has {
+			continue
+		}
+		connection, has := next.rabbitMQServers["default"]
+		if !has {
+			return errors.Errorf("missing default rabbitMQ connection to handle flushInCache")
+		}
+		next.dirtyQueues[name] = max
+		queueName := "dirty_queue_" + name
+		def := &RabbitMQQueueConfig{Name: queueName, Durable: false, PrefetchCount: max}
+		newChannels[queueName] = &rabbitMQChannelToQueue{connection: connection, config: def}
+		next.rabbitMQChannelsToQueue[queueName] = newChannels[queueName]
+	}
+
+	tableNames := make(map[string]string)
+	for _, schema := range next.tableSchemas {
+		tableNames[schema.mysqlPoolName+":"+schema.tableName] = schema.t.String()
+	}
+	added := make([]*tableSchema, 0)
+	for _, name := range sortedEntityNames(merged.entities) {
+		entityType := merged.entities[name]
+		if _, has := next.tableSchemas[entityType]; has {
+			continue
+		}
+		schema, err := initTableSchema(merged, entityType)
+		if err == nil {
+			err = checkGeneratedCode(entityType)
+		}
+		if err != nil {
+			validationError.add(entityType.String(), "", err)
+			continue
+		}
+		tableKey := schema.mysqlPoolName + ":" + schema.tableName
+		duplicated, has := tableNames[tableKey]
+		if has {
+			validationError.add(entityType.String(), "", errors.AlreadyExistsf("table '%s' in pool '%s' used also in %s",
+				schema.tableName, schema.mysqlPoolName, duplicated))
+			continue
+		}
+		tableNames[tableKey] = entityType.String()
+		next.tableSchemas[entityType] = schema
+		next.entities[name] = entityType
+		added = append(added, schema)
+	}
+	engine := next.createEngine()
+	for _, schema := range added {
+		addCachedQueryReferences(next, schema)
+		if len(schema.redactedColumns) > 0 {
+			next.redactedCachePrefixes[schema.cachePrefix] = schema
+		}
+		_, err := checkStruct(schema, engine, schema.t, make(map[string]*index), make(map[string]*foreignIndex), "")
+		if err != nil {
+			validationError.merge(schema.t.String(), err)
+		}
+		if schema.hasLog && next.rabbitMQChannelsToQueue[logQueueName] == nil {
+			connection, has := next.rabbitMQServers["default"]
+			if !has {
+				return errors.Errorf("missing default rabbitMQ connection to handle entity change log")
+			}
+			def := &RabbitMQQueueConfig{Name: logQueueName, Durable: true}
+			newChannels[logQueueName] = &rabbitMQChannelToQueue{connection: connection, config: def}
+			next.rabbitMQChannelsToQueue[logQueueName] = newChannels[logQueueName]
+		}
+	}
+	if len(validationError.Problems) > 0 {
+		return validationError
+	}
+	initRabbitMQChannels(next.createEngine(), newChannels, merged.optionalPools)
+	root.latest.Store(next)
+	extended = true
+	return nil
+}
+
+func closeSQLPool(v *DBConfig) func() {
+	return func() {
+		if v.db != nil {
+			_ = v.db.Close()
+			v.db = nil
+		}
+		if v.readDB != nil {
+			_ = v.readDB.Close()
+			v.readDB = nil
+		}
+	}
+}
+
+func closeClickHouse(v *ClickHouseConfig) func() {
+	return func() {
+		if v.db != nil {
+			_ = v.db.Close()
+			v.db = nil
+		}
+	}
+}
+
+func closeRedis(v *RedisCacheConfig) func() {
+	return func() {
+		if v.client != nil {
+			_ = v.client.Close()
+		}
+		if v.ring != nil {
+			_ = v.ring.Close()
+		}
+	}
+}
+
+func closeRabbitMQ(v *rabbitMQConnection) func() {
+	return func() {
+		for _, client := range []*amqp.Connection{v.clientSender, v.clientReceivers} {
+			if client != nil && !client.IsClosed() {
+				_ = client.Close()
+			}
+		}
+	}
+}
+
+func closeElastic(v *ElasticConfig) func() {
+	return func() {
+		if v.client != nil {
+			v.client.Stop()
+			v.client = nil
+		}
+	}
+}
+
+func (r *Registry) clone() *Registry {
+	c := &Registry{tracer: r.tracer, tagName: r.tagName, logger: r.logger, unsupportedFieldsPolicy: r.unsupportedFieldsPolicy,
+		timeLocation: r.timeLocation, trackLimit: r.trackLimit, maxPageSize: r.maxPageSize, secretResolver: r.secretResolver,
+		sqlMiddlewares: r.sqlMiddlewares, interpolated: r.interpolated}
+	c.sqlClients = make(map[string]*DBConfig, len(r.sqlClients))
+	for k, v := range r.sqlClients {
+		c.sqlClients[k] = v
+	}
+	c.clickHouseClients = make(map[string]*ClickHouseConfig, len(r.clickHouseClients))
+	for k, v := range r.clickHouseClients {
+		c.clickHouseClients[k] = v
+	}
+	c.localCacheContainers = make(map[string]*LocalCacheConfig, len(r.localCacheContainers))
+	for k, v := range r.localCacheContainers {
+		c.localCacheContainers[k] = v
+	}
+	c.redisServers = make(map[string]*RedisCacheConfig, len(r.redisServers))
+	for k, v := range r.redisServers {
+		c.redisServers[k] = v
+	}
+	c.elasticServers = make(map[string]*ElasticConfig, len(r.elasticServers))
+	for k, v := range r.elasticServers {
+		c.elasticServers[k] = v
+	}
+	c.rabbitMQServers = make(map[string]*rabbitMQConfig, len(r.rabbitMQServers))
+	for k, v := range r.rabbitMQServers {
+		c.rabbitMQServers[k] = v
+	}
+	c.rabbitMQQueues = make(map[string][]*RabbitMQQueueConfig, len(r.rabbitMQQueues))
+	for k, v := range r.rabbitMQQueues {
+		c.rabbitMQQueues[k] = v
+	}
+	c.rabbitMQRouters = make(map[string][]*RabbitMQRouterConfig, len(r.rabbitMQRouters))
+	for k, v := range r.rabbitMQRouters {
+		c.rabbitMQRouters[k] = v
+	}
+	c.entities = make(map[string]reflect.Type, len(r.entities))
+	for k, v := range r.entities {
+		c.entities[k] = v
+	}
+	c.enums = make(map[string]Enum, len(r.enums))
+	for k, v := range r.enums {
+		c.enums[k] = v
+	}
+	c.dirtyQueues = make(map[string]int, len(r.dirtyQueues))
+	for k, v := range r.dirtyQueues {
+		c.dirtyQueues[k] = v
+	}
+	c.locks = make(map[string]string, len(r.locks))
+	for k, v := range r.locks {
+		c.locks[k] = v
+	}
+	c.redLocks = make(map[string][]string, len(r.redLocks))
+	for k, v := range r.redLocks {
+		c.redLocks[k] = v
+	}
+	c.optionalPools = make(map[string]bool, len(r.optionalPools))
+	for k, v := range r.optionalPools {
+		c.optionalPools[k] = v
+	}
+	c.shards = make(map[string]*shardDefinition, len(r.shards))
+	for k, v := range r.shards {
+		c.shards[k] = v
+	}
+	c.tenantPools = make(map[string]string, len(r.tenantPools))
+	for k, v := range r.tenantPools {
+		c.tenantPools[k] = v
+	}
+	c.idGenerators = make(map[string]IDGenerator, len(r.idGenerators))
+	for k, v := range r.idGenerators {
+		c.idGenerators[k] = v
+	}
+	c.logWriters = make(map[string]LogWriter, len(r.logWriters))
+	for k, v := range r.logWriters {
+		c.logWriters[k] = v
+	}
+	c.localCacheBus = r.localCacheBus
+	return c
+}
+
+// clone copies schema with its maps, so extended registry never changes schema used by engines created earlier
+func (tableSchema *tableSchema) clone() *tableSchema {
+	c := *tableSchema
+	c.tags = make(map[string]map[string]string, len(tableSchema.tags))
+	for k, v := range tableSchema.tags {
+		c.tags[k] = make(map[string]string, len(v))
+		for tag, value := range v {
+			c.tags[k][tag] = value
+		}
+	}
+	c.cachedIndexes = copyCachedQueryDefinitions(tableSchema.cachedIndexes)
+	c.cachedIndexesOne = copyCachedQueryDefinitions(tableSchema.cachedIndexesOne)
+	c.cachedIndexesAll = copyCachedQueryDefinitions(tableSchema.cachedIndexesAll)
+	c.uniqueIndices = make(map[string][]string, len(tableSchema.uniqueIndices))
+	for k, v := range tableSchema.uniqueIndices {
+		c.uniqueIndices[k] = v
+	}
+	c.redactedColumns = make(map[string]bool, len(tableSchema.redactedColumns))
+	for k, v := range tableSchema.redactedColumns {
+		c.redactedColumns[k] = v
+	}
+	c.uuidColumns = make(map[string]bool, len(tableSchema.uuidColumns))
+	for k, v := range tableSchema.uuidColumns {
+		c.uuidColumns[k] = v
+	}
+	c.uuidReferences = make(map[string]string, len(tableSchema.uuidReferences))
+	for k, v := range tableSchema.uuidReferences {
+		c.uuidReferences[k] = v
+	}
+	c.enums = make(map[string]Enum, len(tableSchema.enums))
+	for k, v := range tableSchema.enums {
+		c.enums[k] = v
+	}
+	c.collections = make(map[string]string, len(tableSchema.collections))
+	for k, v := range tableSchema.collections {
+		c.collections[k] = v
+	}
+	c.queryReferences = append([]*cachedQueryReference{}, tableSchema.queryReferences...)
+	return &c
+}
+
+func copyCachedQueryDefinitions(definitions map[string]*cachedQueryDefinition) map[string]*cachedQueryDefinition {
+	c := make(map[string]*cachedQueryDefinition, len(definitions))
+	for k, v := range definitions {
+		c[k] = v
+	}
+	return c
+}
+
+func (r *validatedRegistry) clone(registry *Registry, root *validatedRegistry) *validatedRegistry {
+	c := &validatedRegistry{registry: registry, tracer: r.tracer, root: root}
+	c.tableSchemas = make(map[reflect.Type]*tableSchema, len(r.tableSchemas))
+	for k, v := range r.tableSchemas {
+		c.tableSchemas[k] = v.clone()
+	}
+	c.entities = make(map[string]reflect.Type, len(r.entities))
+	for k, v := range r.entities {
+		c.entities[k] = v
+	}
+	c.sqlClients = make(map[string]*DBConfig, len(r.sqlClients))
+	for k, v := range r.sqlClients {
+		c.sqlClients[k] = v
+	}
+	c.clickHouseClients = make(map[string]*ClickHouseConfig, len(r.clickHouseClients))
+	for k, v := range r.clickHouseClients {
+		c.clickHouseClients[k] = v
+	}
+	c.dirtyQueues = make(map[string]int, len(r.dirtyQueues))
+	for k, v := range r.dirtyQueues {
+		c.dirtyQueues[k] = v
+	}
+	c.localCacheContainers = make(map[string]*LocalCacheConfig, len(r.localCacheContainers))
+	for k, v := range r.localCacheContainers {
+		c.localCacheContainers[k] = v
+	}
+	c.redisServers = make(map[string]*RedisCacheConfig, len(r.redisServers))
+	for k, v := range r.redisServers {
+		c.redisServers[k] = v
+	}
+	c.elasticServers = make(map[string]*ElasticConfig, len(r.elasticServers))
+	for k, v := range r.elasticServers {
+		c.elasticServers[k] = v
+	}
+	c.rabbitMQServers = make(map[string]*rabbitMQConnection, len(r.rabbitMQServers))
+	for k, v := range r.rabbitMQServers {
+		c.rabbitMQServers[k] = v
+	}
+	c.rabbitMQChannelsToQueue = make(map[string]*rabbitMQChannelToQueue, len(r.rabbitMQChannelsToQueue))
+	for k, v := range r.rabbitMQChannelsToQueue {
+		c.rabbitMQChannelsToQueue[k] = v
+	}
+	c.rabbitMQRouterConfigs = make(map[string]*RabbitMQRouterConfig, len(r.rabbitMQRouterConfigs))
+	for k, v := range r.rabbitMQRouterConfigs {
+		c.rabbitMQRouterConfigs[k] = v
+	}
+	c.lockServers = make(map[string]string, len(r.lockServers))
+	for k, v := range r.lockServers {
+		c.lockServers[k] = v
+	}
+	c.redLockServers = make(map[string][]string, len(r.redLockServers))
+	for k, v := range r.redLockServers {
+		c.redLockServers[k] = v
+	}
+	c.redactedCachePrefixes = make(map[string]*tableSchema, len(r.redactedCachePrefixes))
+	for _, schema := range c.tableSchemas {
+		if len(schema.redactedColumns) > 0 {
+			c.redactedCachePrefixes[schema.cachePrefix] = schema
+		}
+	}
+	c.enums = make(map[string]Enum, len(r.enums))
+	for k, v := range r.enums {
+		c.enums[k] = v
+	}
+	return c
+}
@@ -0,0 +1,72 @@
+package orm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+func markToRestore(engine *Engine, entity Entity) {
+	orm := initIfNeeded(engine, entity)
+	schema := orm.tableSchema
+	if !schema.hasFakeDelete {
+		panic(errors.NotSupportedf("restore of %s without FakeDelete field", schema.t.String()))
+	}
+	id := entity.GetID()
+	if id == 0 {
+		panic(errors.NotValidf("restore of not saved %s", schema.t.String()))
+	}
+	if schema.shard == nil {
+		checkRestoreUniqueKeys(engine, schema, orm, id)
+	} else {
+		pool, _ := schema.shard.poolForID(id)
+		engine.withShardPool(schema, pool, func() {
+			checkRestoreUniqueKeys(engine, schema, orm, id)
+		})
+	}
+	orm.attributes.elem.FieldByName("FakeDelete").SetBool(false)
+	engine.Track(entity)
+}
+
+func checkRestoreUniqueKeys(engine *Engine, schema *tableSchema, orm *ORM, id uint64) {
+	indexes := make([]string, 0, len(schema.uniqueIndices))
+	for index := range schema.uniqueIndices {
+		indexes = append(indexes, index)
+	}
+	sort.Strings(indexes)
+	// values changed before restore are saved in the same flush, so they are checked instead of values from database
+	bind := createBind(id, schema, schema.t, orm.attributes.elem, nil, "")
+	for _, index := range indexes {
+		conditions := make([]string, 0)
+		values := make([]interface{}, 0)
+		for _, column := range schema.uniqueIndices[index] {
+			if column == "FakeDelete" {
+				continue
+			}
+			value := bind[column]
+			if value == nil {
+				conditions = nil
+				break
+			}
+			conditions = append(conditions, "`"+column+"` = "+schema.placeholder(column))
+			values = append(values, value)
+		}
+		if len(conditions) == 0 {
+			continue
+		}
+		where := NewWhere(strings.Join(conditions, " AND ")+" AND `ID` != ?", append(values, id)...)
+		found, err := existsRow(engine, schema, where, true)
+		if err != nil {
+			panic(err)
+		}
+		if found {
+			entry := make([]string, len(values))
+			for i, value := range values {
+				entry[i] = fmt.Sprintf("%v", value)
+			}
+			panic(&DuplicatedKeyError{Message: fmt.Sprintf("Duplicate entry '%s' for key '%s'", strings.Join(entry, "-"), index), Index: index})
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type restoreEntity struct {
+	ORM        `orm:"localCache;redisCache"`
+	ID         uint
+	Email      string `orm:"unique=Email;required"`
+	Age        int
+	FakeDelete bool         `orm:"unique=Email:2"`
+	IndexAge   *CachedQuery `query:":Age = ?"`
+}
+
+type restoreNoFakeDeleteEntity struct {
+	ORM
+	ID uint
+}
+
+func TestMarkToRestoreNotValid(t *testing.T) {
+	registry := &Registry{}
+	registry.sqlClients = map[string]*DBConfig{"default": {code: "default", databaseName: "test"}}
+	entityType := reflect.TypeOf(restoreNoFakeDeleteEntity{})
+	schema, err := initTableSchema(registry, entityType)
+	assert.NoError(t, err)
+	validated := (&validatedRegistry{}).clone(registry, nil)
+	validated.sqlClients = registry.sqlClients
+	validated.tableSchemas[entityType] = schema
+	engine := validated.CreateEngine()
+
+	assert.PanicsWithError(t, "restore of orm.restoreNoFakeDeleteEntity without FakeDelete field not supported", func() {
+		engine.MarkToRestore(&restoreNoFakeDeleteEntity{ID: 1})
+	})
+}
+
+func TestMarkToRestore(t *testing.T) {
+	var entity *restoreEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+	engine.TrackAndFlush(&restoreEntity{Email: "a@example.com", Age: 18}, &restoreEntity{Email: "b@example.com", Age: 18})
+
+	var rows []*restoreEntity
+	assert.Equal(t, 2, engine.CachedSearch(&rows, "IndexAge", nil, 18))
+	entity = &restoreEntity{}
+	engine.LoadByID(1, entity)
+	engine.MarkToDelete(entity)
+	engine.Flush()
+	assert.Equal(t, 1, engine.CachedSearch(&rows, "IndexAge", nil, 18))
+
+	engine.MarkToRestore(entity)
+	assert.False(t, entity.FakeDelete)
+	engine.Flush()
+	assert.Equal(t, 2, engine.CachedSearch(&rows, "IndexAge", nil, 18))
+	found, err := engine.Exists(NewWhere("`Email` = ?", "a@example.com"), entity)
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	engine.MarkToDelete(entity)
+	engine.Flush()
+	engine.TrackAndFlush(&restoreEntity{Email: "a@example.com", Age: 20})
+	assert.PanicsWithError(t, "Duplicate entry 'a@example.com' for key 'Email'", func() {
+		engine.MarkToRestore(entity)
+	})
+	assert.True(t, entity.FakeDelete)
+	entity.Email = "c@example.com"
+	engine.MarkToRestore(entity)
+	engine.Flush()
+	found, err = engine.Exists(NewWhere("`Email` = ?", "c@example.com"), entity)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.PanicsWithError(t, "restore of not saved orm.restoreEntity not valid", func() {
+		engine.MarkToRestore(&restoreEntity{})
+	})
+}
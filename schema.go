@@ -66,9 +66,13 @@ func getAlters(engine *Engine) (alters []Alter) {
 	if engine.registry.entities != nil {
 		for _, t := range engine.registry.entities {
 			tableSchema := getTableSchema(engine.registry, t)
-			tablesInEntities[tableSchema.mysqlPoolName][tableSchema.tableName] = true
+			for _, poolName := range tableSchema.getMysqlPools() {
+				tablesInEntities[poolName][tableSchema.tableName] = true
+			}
 			has, newAlters := tableSchema.GetSchemaChanges(engine)
-			if tableSchema.hasLog {
+			if tableSchema.hasLog && tableSchema.logClickHouse != "" {
+				tablesInEntities[tableSchema.logPoolName][tableSchema.logTableName] = true
+			} else if tableSchema.hasLog && !tableSchema.logExternal {
 				logPool := engine.GetMysql(tableSchema.logPoolName)
 				var tableDef string
 				hasLogTable := logPool.QueryRow(NewWhere(fmt.Sprintf("SHOW TABLES LIKE '%s'", tableSchema.logTableName)), &tableDef)
@@ -98,6 +102,7 @@ func getAlters(engine *Engine) (alters []Alter) {
 			}
 			alters = append(alters, newAlters...)
 		}
+		alters = append(alters, getSuffixTablesAlters(engine, tablesInDB, tablesInEntities)...)
 	}
 
 	for poolName, tables := range tablesInDB {
@@ -119,6 +124,10 @@ func getAlters(engine *Engine) (alters []Alter) {
 			}
 		}
 	}
+	return sortAlters(alters)
+}
+
+func sortAlters(alters []Alter) []Alter {
 	sortedNormal := make([]Alter, 0)
 	sortedDropForeign := make([]Alter, 0)
 	sortedAddForeign := make([]Alter, 0)
@@ -187,7 +196,9 @@ func getSchemaChanges(engine *Engine, tableSchema *tableSchema) (has bool, alter
 	pool := engine.GetMysql(tableSchema.mysqlPoolName)
 	createTableSQL := fmt.Sprintf("CREATE TABLE `%s`.`%s` (\n", pool.GetDatabaseName(), tableSchema.tableName)
 	createTableForiegnKeysSQL := fmt.Sprintf("ALTER TABLE `%s`.`%s`\n", pool.GetDatabaseName(), tableSchema.tableName)
-	columns[0][1] += " AUTO_INCREMENT"
+	if tableSchema.idGenerator == nil {
+		columns[0][1] += " AUTO_INCREMENT"
+	}
 	for _, value := range columns {
 		createTableSQL += fmt.Sprintf("  %s,\n", value[1])
 	}
@@ -207,7 +218,11 @@ func getSchemaChanges(engine *Engine, tableSchema *tableSchema) (has bool, alter
 	}
 
 	createTableSQL += "  PRIMARY KEY (`ID`)\n"
-	createTableSQL += ") ENGINE=InnoDB DEFAULT CHARSET=utf8;"
+	if tableSchema.autoIncrementStart > 1 && tableSchema.idGenerator == nil {
+		createTableSQL += fmt.Sprintf(") ENGINE=InnoDB AUTO_INCREMENT=%d DEFAULT CHARSET=utf8;", tableSchema.autoIncrementStart)
+	} else {
+		createTableSQL += ") ENGINE=InnoDB DEFAULT CHARSET=utf8;"
+	}
 
 	var skip string
 	hasTable := pool.QueryRow(NewWhere(fmt.Sprintf("SHOW TABLES LIKE '%s'", tableSchema.tableName)), &skip)
@@ -490,9 +505,14 @@ func getForeignKeys(engine *Engine, createTableDB string, tableName string, pool
 		for _, line := range strings.Split(createTableDB, "\n") {
 			line = strings.TrimSpace(strings.TrimRight(line, ","))
 			if strings.Index(line, fmt.Sprintf("CONSTRAINT `%s`", row.ConstraintName)) == 0 {
-				words := strings.Split(line, " ")
-				if strings.ToUpper(words[len(words)-2]) == "DELETE" {
-					row.OnDelete = strings.ToUpper(words[len(words)-1])
+				pos := strings.Index(strings.ToUpper(line), " ON DELETE ")
+				if pos > -1 {
+					onDelete := strings.ToUpper(line[pos+11:])
+					end := strings.Index(onDelete, " ON UPDATE")
+					if end > -1 {
+						onDelete = onDelete[0:end]
+					}
+					row.OnDelete = onDelete
 				}
 			}
 		}
@@ -569,12 +589,17 @@ func checkColumn(engine *Engine, schema *tableSchema, t reflect.Type, field *ref
 		unique := key == "unique"
 		if key == "index" && field.Type.Kind() == reflect.Ptr {
 			refOneSchema = getTableSchema(engine.registry, field.Type.Elem())
-			if refOneSchema != nil {
+			_, isUUID := schema.uuidReferences[columnName]
+			if refOneSchema != nil && refOneSchema.shard == nil && !isUUID {
 				onDelete := "RESTRICT"
 				_, hasCascade := attributes["cascade"]
 				if hasCascade {
 					onDelete = "CASCADE"
 				}
+				userOnDelete, has := attributes["onDelete"]
+				if has {
+					onDelete = strings.ToUpper(userOnDelete)
+				}
 				pool := refOneSchema.GetMysql(engine)
 				foreignKey := &foreignIndex{Column: field.Name, Table: refOneSchema.tableName,
 					ParentDatabase: pool.GetDatabaseName(), OnDelete: onDelete}
@@ -622,65 +647,103 @@ func checkColumn(engine *Engine, schema *tableSchema, t reflect.Type, field *ref
 	required, hasRequired := attributes["required"]
 	isRequired := hasRequired && required == "true"
 
+	_, isLazy := attributes["lazy"]
+	if isLazy && typeAsString != "string" && typeAsString != "[]uint8" {
+		return nil, errors.NotValidf("lazy field %s with type %s, only string and []byte", field.Name, typeAsString)
+	}
+
 	var err error
-	switch typeAsString {
-	case "uint",
-		"uint8",
-		"uint32",
-		"uint64",
-		"int8",
-		"int16",
-		"int32",
-		"int64",
-		"int":
-		definition, addNotNullIfNotSet, defaultValue = handleInt(typeAsString, attributes)
-	case "uint16":
-		if attributes["year"] == "true" {
-			if isRequired {
-				return [][2]string{{columnName, fmt.Sprintf("`%s` year(4) NOT NULL DEFAULT '0000'", columnName)}}, nil
+	if simpleFieldTypes[typeAsString] {
+		switch typeAsString {
+		case "uint",
+			"uint8",
+			"uint32",
+			"uint64",
+			"int8",
+			"int16",
+			"int32",
+			"int64",
+			"int":
+			definition, addNotNullIfNotSet, defaultValue = handleInt(typeAsString, attributes)
+		case "uint16":
+			if attributes["year"] == "true" {
+				if isRequired {
+					return [][2]string{{columnName, fmt.Sprintf("`%s` year(4) NOT NULL DEFAULT '0000'", columnName)}}, nil
+				}
+				return [][2]string{{columnName, fmt.Sprintf("`%s` year(4) DEFAULT NULL", columnName)}}, nil
 			}
-			return [][2]string{{columnName, fmt.Sprintf("`%s` year(4) DEFAULT NULL", columnName)}}, nil
-		}
-		definition, addNotNullIfNotSet, defaultValue = handleInt(typeAsString, attributes)
-	case "bool":
-		if columnName == "FakeDelete" {
+			definition, addNotNullIfNotSet, defaultValue = handleInt(typeAsString, attributes)
+		case "bool":
+			if columnName == "FakeDelete" {
+				return nil, nil
+			}
+			definition, addNotNullIfNotSet, defaultValue = "tinyint(1)", true, "'0'"
+		case "string", "[]string":
+			definition, addNotNullIfNotSet, addDefaultNullIfNullable, defaultValue, err = handleString(engine.registry, attributes, false)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+		case "interface {}":
+			definition, addNotNullIfNotSet, addDefaultNullIfNullable, defaultValue, err = handleString(engine.registry, attributes, true)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+		case "float32":
+			definition, addNotNullIfNotSet, defaultValue = handleFloat("float", attributes)
+		case "float64":
+			definition, addNotNullIfNotSet, defaultValue = handleFloat("double", attributes)
+		case "decimal.Decimal":
+			if !isValidDecimalTag(attributes["decimal"]) {
+				return nil, errors.NotValidf("decimal field %s without decimal=precision,scale tag", field.Name)
+			}
+			definition, addNotNullIfNotSet, defaultValue = handleFloat("", attributes)
+		case "*decimal.Decimal":
+			if !isValidDecimalTag(attributes["decimal"]) {
+				return nil, errors.NotValidf("decimal field %s without decimal=precision,scale tag", field.Name)
+			}
+			definition, _, _ = handleFloat("", attributes)
+			addDefaultNullIfNullable = true
+		case "time.Time":
+			definition, addNotNullIfNotSet, addDefaultNullIfNullable, defaultValue, err = handleTime(attributes, false)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+		case "*time.Time":
+			definition, addNotNullIfNotSet, addDefaultNullIfNullable, defaultValue, err = handleTime(attributes, true)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+		case "[]uint8":
+			definition, addDefaultNullIfNullable = handleBlob(attributes)
+		case "*orm.CachedQuery":
 			return nil, nil
 		}
-		definition, addNotNullIfNotSet, defaultValue = "tinyint(1)", true, "'0'"
-	case "string", "[]string":
-		definition, addNotNullIfNotSet, addDefaultNullIfNullable, defaultValue, err = handleString(engine.registry, attributes, false)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-	case "interface {}":
-		definition, addNotNullIfNotSet, addDefaultNullIfNullable, defaultValue, err = handleString(engine.registry, attributes, true)
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-	case "float32":
-		definition, addNotNullIfNotSet, defaultValue = handleFloat("float", attributes)
-	case "float64":
-		definition, addNotNullIfNotSet, defaultValue = handleFloat("double", attributes)
-	case "time.Time":
-		definition, addNotNullIfNotSet, addDefaultNullIfNullable, defaultValue = handleTime(attributes, false)
-	case "*time.Time":
-		definition, addNotNullIfNotSet, addDefaultNullIfNullable, defaultValue = handleTime(attributes, true)
-	case "[]uint8":
-		definition, addDefaultNullIfNullable = handleBlob(attributes)
-	case "*orm.CachedQuery":
-		return nil, nil
-	default:
+	} else {
 		kind := field.Type.Kind().String()
 		valid := false
-		if kind == "struct" {
-			structFields, err := checkStruct(schema, engine, field.Type, indexes, foreignKeys, field.Name)
+		if isValuerType(field.Type) {
+			column, has := attributes["column"]
+			if !has {
+				return nil, errors.NotValidf("missing column definition for %s %s, use orm:\"column=...\"", field.Name, field.Type.String())
+			}
+			definition = column
+			addDefaultNullIfNullable = true
+			valid = true
+		} else if kind == "struct" {
+			structFields, err := checkStruct(schema, engine, field.Type, indexes, foreignKeys, embeddedPrefix(field.Name, attributes))
 			if err != nil {
 				return nil, errors.Trace(err)
 			}
 			return structFields, nil
 		} else if kind == "ptr" {
 			subSchema := getTableSchema(engine.registry, field.Type.Elem())
-			if subSchema != nil {
+			_, isUUID := schema.uuidReferences[columnName]
+			if subSchema != nil && isUUID {
+				definition = "binary(16)"
+				addNotNullIfNotSet = false
+				addDefaultNullIfNullable = true
+				valid = true
+			} else if subSchema != nil {
 				definition = handleReferenceOne(subSchema, attributes)
 				addNotNullIfNotSet = false
 				addDefaultNullIfNullable = true
@@ -726,6 +789,19 @@ func handleFloat(floatDefinition string, attributes map[string]string) (string,
 	return definition, true, defaultValue
 }
 
+func isValidDecimalTag(tag string) bool {
+	args := strings.Split(tag, ",")
+	if len(args) != 2 {
+		return false
+	}
+	precision, err := strconv.Atoi(args[0])
+	if err != nil || precision <= 0 {
+		return false
+	}
+	scale, err := strconv.Atoi(args[1])
+	return err == nil && scale >= 0 && scale <= precision
+}
+
 func handleBlob(attributes map[string]string) (string, bool) {
 	definition := "blob"
 	if attributes["mediumblob"] == "true" {
@@ -740,6 +816,10 @@ func handleBlob(attributes map[string]string) (string, bool) {
 
 func handleString(registry *validatedRegistry, attributes map[string]string, forceMax bool) (string, bool, bool, string, error) {
 	var definition string
+	_, isUUID := attributes["uuidBinary"]
+	if isUUID {
+		return "binary(16)", false, true, "nil", nil
+	}
 	enum, hasEnum := attributes["enum"]
 	if hasEnum {
 		return handleSetEnum(registry, "enum", enum, attributes)
@@ -799,16 +879,24 @@ func handleSetEnum(registry *validatedRegistry, fieldType string, attribute stri
 	return definition, hasRequired && required == "true", true, defaultValue, nil
 }
 
-func handleTime(attributes map[string]string, nullable bool) (string, bool, bool, string) {
+func handleTime(attributes map[string]string, nullable bool) (string, bool, bool, string, error) {
 	t := attributes["time"]
 	defaultValue := "nil"
 	if t == "true" {
-		return "datetime", !nullable, true, "nil"
+		precision, has := attributes["precision"]
+		if !has || precision == "0" {
+			return "datetime", !nullable, true, "nil", nil
+		}
+		value, err := strconv.Atoi(precision)
+		if err != nil || value < 0 || value > 6 {
+			return "", false, false, "", errors.NotValidf("datetime precision '%s'", precision)
+		}
+		return fmt.Sprintf("datetime(%d)", value), !nullable, true, "nil", nil
 	}
 	if !nullable {
 		defaultValue = "'0001-01-01'"
 	}
-	return "date", !nullable, true, defaultValue
+	return "date", !nullable, true, defaultValue, nil
 }
 
 func handleReferenceOne(schema *tableSchema, attributes map[string]string) string {
@@ -849,20 +937,26 @@ func convertIntToSchema(typeAsString string, attributes map[string]string) strin
 func checkStruct(tableSchema *tableSchema, engine *Engine, t reflect.Type, indexes map[string]*index,
 	foreignKeys map[string]*foreignIndex, prefix string) ([][2]string, error) {
 	columns := make([][2]string, 0, t.NumField())
+	validationError := &ValidationError{}
 	max := t.NumField() - 1
 	for i := 0; i <= max; i++ {
-		if i == 0 && prefix == "" {
+		if i == 0 && t == tableSchema.t {
 			continue
 		}
 		field := t.Field(i)
 		fieldColumns, err := checkColumn(engine, tableSchema, t, &field, indexes, foreignKeys, prefix)
 		if err != nil {
-			return nil, errors.Trace(err)
+			validationError.merge("", err)
+			validationError.setField(prefix + field.Name)
+			continue
 		}
 		if fieldColumns != nil {
 			columns = append(columns, fieldColumns...)
 		}
 	}
+	if len(validationError.Problems) > 0 {
+		return nil, validationError
+	}
 	if tableSchema.hasFakeDelete {
 		def := fmt.Sprintf("`FakeDelete` %s unsigned NOT NULL DEFAULT '0'", strings.Split(columns[0][1], " ")[1])
 		columns = append(columns, [2]string{"FakeDelete", def})
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/juju/errors"
+	"github.com/shopspring/decimal"
 )
 
 func searchIDsWithCount(skipFakeDelete bool, engine *Engine, where *Where, pager *Pager, entityType reflect.Type) (results []uint64, totalRows int) {
@@ -18,12 +20,7 @@ func searchIDsWithCount(skipFakeDelete bool, engine *Engine, where *Where, pager
 func searchRow(skipFakeDelete bool, engine *Engine, where *Where, entity Entity, references []string) bool {
 	orm := initIfNeeded(engine, entity)
 	schema := orm.tableSchema
-	whereQuery := where.String()
-	if skipFakeDelete && schema.hasFakeDelete {
-		whereQuery = fmt.Sprintf("`FakeDelete` = 0 AND %s", whereQuery)
-	}
-	/* #nosec */
-	query := fmt.Sprintf("SELECT %s FROM `%s` WHERE %s LIMIT 1", schema.fieldsQuery, schema.tableName, whereQuery)
+	query := buildSearchQuery(schema.fieldsQuery, schema.getTableName(engine), where.String(), skipFakeDeleted(skipFakeDelete, schema, where), nil)
 
 	pool := schema.GetMysql(engine)
 	results, def := pool.Query(query, where.GetParameters()...)
@@ -58,7 +55,7 @@ func searchRow(skipFakeDelete bool, engine *Engine, where *Where, entity Entity,
 
 func search(skipFakeDelete bool, engine *Engine, where *Where, pager *Pager, withCount bool, entities reflect.Value, references ...string) int {
 	if pager == nil {
-		pager = NewPager(1, 50000)
+		pager = NewPager(1, defaultPageSize)
 	}
 	entities.SetLen(0)
 	entityType, has := getEntityTypeForSlice(engine.registry, entities.Type())
@@ -66,13 +63,7 @@ func search(skipFakeDelete bool, engine *Engine, where *Where, pager *Pager, wit
 		panic(EntityNotRegisteredError{Name: entities.String()})
 	}
 	schema := getTableSchema(engine.registry, entityType)
-	whereQuery := where.String()
-	if skipFakeDelete && schema.hasFakeDelete {
-		whereQuery = fmt.Sprintf("`FakeDelete` = 0 AND %s", whereQuery)
-	}
-	/* #nosec */
-	query := fmt.Sprintf("SELECT %s FROM `%s` WHERE %s %s", schema.fieldsQuery, schema.tableName, whereQuery,
-		fmt.Sprintf("LIMIT %d,%d", (pager.CurrentPage-1)*pager.PageSize, pager.PageSize))
+	query := buildSearchQuery(schema.fieldsQuery, schema.getTableName(engine), where.String(), skipFakeDeleted(skipFakeDelete, schema, where), pager)
 	pool := schema.GetMysql(engine)
 	results, def := pool.Query(query, where.GetParameters()...)
 	defer def()
@@ -101,7 +92,7 @@ func search(skipFakeDelete bool, engine *Engine, where *Where, pager *Pager, wit
 		i++
 	}
 	def()
-	totalRows := getTotalRows(engine, withCount, pager, where, schema, i)
+	totalRows := getTotalRows(engine, withCount, pager, where, schema, i, skipFakeDeleted(skipFakeDelete, schema, where))
 	if len(references) > 0 && i > 0 {
 		warmUpReferences(engine, schema, val, references, true)
 	}
@@ -118,14 +109,7 @@ func searchIDs(skipFakeDelete bool, engine *Engine, where *Where, pager *Pager,
 	if schema == nil {
 		panic(EntityNotRegisteredError{Name: entityType.String()})
 	}
-	whereQuery := where.String()
-	if skipFakeDelete && schema.hasFakeDelete {
-		/* #nosec */
-		whereQuery = fmt.Sprintf("`FakeDelete` = 0 AND %s", whereQuery)
-	}
-	/* #nosec */
-	query := fmt.Sprintf("SELECT `ID` FROM `%s` WHERE %s %s", schema.tableName, whereQuery,
-		fmt.Sprintf("LIMIT %d,%d", (pager.CurrentPage-1)*pager.PageSize, pager.PageSize))
+	query := buildSearchQuery("`ID`", schema.getTableName(engine), where.String(), skipFakeDeleted(skipFakeDelete, schema, where), pager)
 	pool := schema.GetMysql(engine)
 	results, def := pool.Query(query, where.GetParameters()...)
 	defer def()
@@ -136,17 +120,48 @@ func searchIDs(skipFakeDelete bool, engine *Engine, where *Where, pager *Pager,
 		result = append(result, row)
 	}
 	def()
-	totalRows := getTotalRows(engine, withCount, pager, where, schema, len(result))
+	totalRows := getTotalRows(engine, withCount, pager, where, schema, len(result), skipFakeDeleted(skipFakeDelete, schema, where))
 	return result, totalRows
 }
 
-func getTotalRows(engine *Engine, withCount bool, pager *Pager, where *Where, schema *tableSchema, foundRows int) int {
+func buildSearchQuery(columns string, tableName string, where string, skipFakeDelete bool, pager *Pager) string {
+	var query strings.Builder
+	query.Grow(len(columns) + len(tableName) + len(where) + 64)
+	query.WriteString("SELECT ")
+	query.WriteString(columns)
+	query.WriteString(" FROM `")
+	query.WriteString(tableName)
+	query.WriteString("` WHERE ")
+	if skipFakeDelete {
+		query.WriteString("`FakeDelete` = 0 AND ")
+	}
+	query.WriteString(where)
+	if pager == nil {
+		query.WriteString(" LIMIT 1")
+		return query.String()
+	}
+	query.WriteString(" LIMIT ")
+	query.WriteString(strconv.Itoa((pager.CurrentPage - 1) * pager.PageSize))
+	query.WriteByte(',')
+	query.WriteString(strconv.Itoa(pager.PageSize))
+	return query.String()
+}
+
+func skipFakeDeleted(skipFakeDelete bool, schema *tableSchema, where *Where) bool {
+	return skipFakeDelete && schema.hasFakeDelete && !where.withFakeDeleted
+}
+
+func getTotalRows(engine *Engine, withCount bool, pager *Pager, where *Where, schema *tableSchema, foundRows int, skipFakeDelete bool) int {
 	totalRows := 0
 	if withCount {
 		totalRows = foundRows
 		if totalRows == pager.GetPageSize() || (foundRows == 0 && pager.CurrentPage > 1) {
+			whereQuery := where.String()
+			if skipFakeDelete {
+				whereQuery = "`FakeDelete` = 0 AND " + whereQuery
+			}
 			/* #nosec */
-			query := fmt.Sprintf("SELECT count(1) FROM `%s` WHERE %s", schema.tableName, where)
+			query := fmt.Sprintf("SELECT count(1) FROM `%s` WHERE %s", schema.getTableName(engine), whereQuery)
 			var foundTotal string
 			pool := schema.GetMysql(engine)
 			pool.QueryRow(NewWhere(query, where.GetParameters()...), &foundTotal)
@@ -162,7 +177,11 @@ func fillFromDBRow(id uint64, engine *Engine, data []string, entity Entity) {
 	orm := initIfNeeded(engine, entity)
 	elem := orm.attributes.elem
 	orm.attributes.idElem.SetUint(id)
-	_ = fillStruct(engine, 0, data, orm.tableSchema.fields, elem)
+	if generated, is := entity.(GeneratedEntity); is {
+		generated.OrmFill(data)
+	} else {
+		_ = fillStruct(engine, 0, data, orm.tableSchema.fields, elem)
+	}
 	orm.dBData["ID"] = id
 	orm.attributes.loaded = true
 	for key, column := range orm.tableSchema.columnNames[1:] {
@@ -186,9 +205,23 @@ func convertStringToInt(value string) int64 {
 	return v
 }
 
+func parseTime(engine *Engine, value string) time.Time {
+	if len(value) < 19 {
+		t, _ := time.Parse("2006-01-02", value)
+		return t
+	}
+	location := engine.registry.registry.timeLocation
+	if location != nil && !strings.HasPrefix(value, "0001-01-01") {
+		t, _ := time.ParseInLocation("2006-01-02 15:04:05", value, location)
+		return t
+	}
+	t, _ := time.Parse("2006-01-02 15:04:05", value)
+	return t
+}
+
 func fillStruct(engine *Engine, index uint16, data []string, fields *tableFields, value reflect.Value) uint16 {
 	skip := 1
-	if fields.prefix != "" {
+	if fields.embedded {
 		skip = -1
 	}
 	for _, i := range fields.uintegers {
@@ -203,7 +236,12 @@ func fillStruct(engine *Engine, index uint16, data []string, fields *tableFields
 		index++
 	}
 	for _, i := range fields.strings {
-		value.Field(i).SetString(data[index])
+		ordered, isUUID := fields.uuids[i]
+		if isUUID {
+			value.Field(i).SetString(uuidFromHex(data[index], ordered))
+		} else {
+			value.Field(i).SetString(data[index])
+		}
 		index++
 	}
 	for _, i := range fields.sliceStrings {
@@ -248,28 +286,56 @@ func fillStruct(engine *Engine, index uint16, data []string, fields *tableFields
 		value.Field(i).SetFloat(float)
 		index++
 	}
-	for _, i := range fields.timesNullable {
+	for _, i := range fields.decimals {
+		val, _ := decimal.NewFromString(data[index])
+		value.Field(i).Set(reflect.ValueOf(val))
+		index++
+	}
+	for _, i := range fields.decimalsNullable {
+		field := value.Field(i)
+		if data[index] == "" {
+			field.Set(reflect.Zero(field.Type()))
+		} else {
+			val, _ := decimal.NewFromString(data[index])
+			field.Set(reflect.ValueOf(&val))
+		}
+		index++
+	}
+	for _, i := range fields.valuers {
 		field := value.Field(i)
 		if data[index] == "" {
 			field.Set(reflect.Zero(field.Type()))
 		} else {
-			layout := "2006-01-02"
-			if len(data[index]) == 19 {
-				layout += " 15:04:05"
+			t := field.Type()
+			if t.Kind() == reflect.Ptr {
+				t = t.Elem()
 			}
-			value, _ := time.Parse(layout, data[index])
+			val := reflect.New(t)
+			err := val.Interface().(sql.Scanner).Scan([]byte(data[index]))
+			if err != nil {
+				panic(errors.Annotatef(err, "scan of field %s in %s", fields.fields[i].Name, value.Type().String()))
+			}
+			if field.Kind() == reflect.Ptr {
+				field.Set(val)
+			} else {
+				field.Set(val.Elem())
+			}
+		}
+		index++
+	}
+	for _, i := range fields.timesNullable {
+		field := value.Field(i)
+		if data[index] == "" {
+			field.Set(reflect.Zero(field.Type()))
+		} else {
+			value := parseTime(engine, data[index])
 			field.Set(reflect.ValueOf(&value))
 		}
 		index++
 	}
 	for _, i := range fields.times {
 		field := value.Field(i)
-		layout := "2006-01-02"
-		if len(data[index]) == 19 {
-			layout += " 15:04:05"
-		}
-		val, _ := time.Parse(layout, data[index])
-		field.Set(reflect.ValueOf(val))
+		field.Set(reflect.ValueOf(parseTime(engine, data[index])))
 		index++
 	}
 	for _, i := range fields.jsons {
@@ -285,6 +351,11 @@ func fillStruct(engine *Engine, index uint16, data []string, fields *tableFields
 	}
 	for k, i := range fields.refs {
 		field := value.Field(i)
+		if fields.uuidRefs[i] {
+			fillUUIDReference(engine, field, fields.refsTypes[k], data[index])
+			index++
+			continue
+		}
 		integer := uint64(0)
 		if data[index] != "" {
 			integer, _ = strconv.ParseUint(data[index], 10, 64)
@@ -301,11 +372,11 @@ func fillStruct(engine *Engine, index uint16, data []string, fields *tableFields
 		}
 		index++
 	}
-	for i, subFields := range fields.structs {
+	for _, i := range fields.structsOrder() {
 		field := value.Field(i)
 		newVal := reflect.New(field.Type())
 		value := newVal.Elem()
-		newIndex := fillStruct(engine, index, data, subFields, value)
+		newIndex := fillStruct(engine, index, data, fields.structs[i], value)
 		field.Set(value)
 		index = newIndex
 	}
@@ -317,3 +388,24 @@ func getEntityTypeForSlice(registry *validatedRegistry, sliceType reflect.Type)
 	e, has := registry.entities[name]
 	return e, has
 }
+
+// searchInIDOrder loads all rows matching where in chunks ordered by ID, so no row is skipped or repeated between chunks
+func searchInIDOrder(engine *Engine, t reflect.Type, where *Where, chunkSize int, handle func(rows reflect.Value)) {
+	lastID := uint64(0)
+	for {
+		parameters := append(append(make([]interface{}, 0, len(where.GetParameters())+1), where.GetParameters()...), lastID)
+		/* #nosec */
+		chunkWhere := NewWhere(fmt.Sprintf("(%s) AND `ID` > ? ORDER BY `ID`", where.String()), parameters...)
+		chunkWhere.withFakeDeleted = where.withFakeDeleted
+		rows := reflect.New(reflect.SliceOf(reflect.PtrTo(t)))
+		engine.Search(chunkWhere, NewPager(1, chunkSize), rows.Interface())
+		total := rows.Elem().Len()
+		if total > 0 {
+			handle(rows.Elem())
+			lastID = rows.Elem().Index(total - 1).Interface().(Entity).GetID()
+		}
+		if total < chunkSize {
+			return
+		}
+	}
+}
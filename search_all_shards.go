@@ -0,0 +1,132 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+const maxSearchAllShardsRows = 10000
+
+var orderByPattern = regexp.MustCompile(`(?is)\bORDER\s+BY\s+(.+)$`)
+
+type ShardSearchError struct {
+	Shards map[string]error
+}
+
+func (e *ShardSearchError) Error() string {
+	names := make([]string, 0, len(e.Shards))
+	for name := range e.Shards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("%s: %s", name, e.Shards[name].Error())
+	}
+	return "search failed in shards: " + strings.Join(lines, ", ")
+}
+
+func searchAllShards(engine *Engine, where *Where, pager *Pager, entities reflect.Value, references []string) error {
+	pager = getSearchAllShardsPager(pager)
+	entityType, has := getEntityTypeForSlice(engine.registry, entities.Type())
+	if !has {
+		panic(EntityNotRegisteredError{Name: entities.String()})
+	}
+	schema := getTableSchema(engine.registry, entityType)
+	if schema.shard == nil {
+		panic(errors.Errorf("entity %s is not sharded", entityType.String()))
+	}
+	limit := pager.GetCurrentPage() * pager.GetPageSize()
+	if limit > maxSearchAllShardsRows {
+		return errors.NotSupportedf("search across shards deeper than %d rows", maxSearchAllShardsRows)
+	}
+	results := make([]reflect.Value, len(schema.shard.pools))
+	failed := make(map[string]error)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for i, pool := range schema.shard.pools {
+		worker, release := engine.newFlushWorker(pool)
+		defer release()
+		wg.Add(1)
+		go func(i int, pool string) {
+			defer func() {
+				if r := recover(); r != nil {
+					mutex.Lock()
+					asErr, is := r.(error)
+					if !is {
+						asErr = errors.Errorf("%v", r)
+					}
+					failed[pool] = asErr
+					mutex.Unlock()
+				}
+				wg.Done()
+			}()
+			rows := reflect.New(entities.Type()).Elem()
+			worker.withShardPool(schema, pool, func() {
+				search(true, worker, where, NewPager(1, limit), false, rows)
+			})
+			results[i] = rows
+		}(i, pool)
+	}
+	wg.Wait()
+	merged := make([]reflect.Value, 0)
+	for _, rows := range results {
+		if !rows.IsValid() {
+			continue
+		}
+		for i := 0; i < rows.Len(); i++ {
+			row := rows.Index(i)
+			row.Interface().(Entity).getORM().engine = engine
+			merged = append(merged, row)
+		}
+	}
+	sortShardRows(merged, parseSearchOrder(schema, where.String()))
+	start := (pager.GetCurrentPage() - 1) * pager.GetPageSize()
+	if start > len(merged) {
+		start = len(merged)
+	}
+	end := start + pager.GetPageSize()
+	if end > len(merged) {
+		end = len(merged)
+	}
+	val := reflect.MakeSlice(entities.Type(), 0, end-start)
+	for _, row := range merged[start:end] {
+		val = reflect.Append(val, row)
+	}
+	if len(references) > 0 && val.Len() > 0 {
+		warmUpReferences(engine, schema, val, references, true)
+	}
+	entities.Set(val)
+	if len(failed) > 0 {
+		return &ShardSearchError{Shards: failed}
+	}
+	return nil
+}
+
+func getSearchAllShardsPager(pager *Pager) *Pager {
+	if pager == nil {
+		return NewPager(1, maxSearchAllShardsRows)
+	}
+	return pager
+}
+
+func parseSearchOrder(schema *tableSchema, query string) []cachedOrderField {
+	order := make([]cachedOrderField, 0)
+	matches := orderByPattern.FindStringSubmatch(query)
+	if matches != nil {
+		order = parseCachedOrder(schema, matches[1])
+	}
+	return append(order, cachedOrderField{field: "ID"})
+}
+
+func sortShardRows(rows []reflect.Value, order []cachedOrderField) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareCachedOrder(rows[i].Elem(), rows[j].Elem(), order)
+	})
+}
@@ -0,0 +1,47 @@
+package orm
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/juju/errors"
+)
+
+var interpolationPattern = regexp.MustCompile(`\$\{([^${}]+)\}`)
+
+type SecretResolver interface {
+	Resolve(name string) (value string, has bool)
+}
+
+// SetSecretResolver must be called before pools with ${NAME} in address are registered,
+// secrets are resolved when pool is registered
+func (r *Registry) SetSecretResolver(resolver SecretResolver) {
+	if r.interpolated {
+		panic(errors.NotSupportedf("setting secret resolver after pool with secret in address is registered"))
+	}
+	r.secretResolver = resolver
+}
+
+// interpolate replaces missing secrets with empty string, error is returned from Validate
+func (r *Registry) interpolate(value string) string {
+	var missing []string
+	result := interpolationPattern.ReplaceAllStringFunc(value, func(match string) string {
+		r.interpolated = true
+		name := match[2 : len(match)-1]
+		if r.secretResolver != nil {
+			resolved, has := r.secretResolver.Resolve(name)
+			if has {
+				return resolved
+			}
+		}
+		resolved, has := os.LookupEnv(name)
+		if !has {
+			missing = append(missing, name)
+		}
+		return resolved
+	})
+	if len(missing) > 0 && r.interpolationError == nil {
+		r.interpolationError = errors.NotFoundf("secret %s", missing[0])
+	}
+	return result
+}
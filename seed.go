@@ -0,0 +1,86 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+type Seed struct {
+	Entities []Entity
+	Key      []string
+	Update   []string
+}
+
+func seed(engine *Engine, seeds ...*Seed) {
+	for _, seed := range seeds {
+		if len(seed.Key) == 0 {
+			panic(errors.NotValidf("seed without key"))
+		}
+		found := make(map[Entity]Entity)
+		toFlush := make([]Entity, 0, len(seed.Entities))
+		for _, entity := range seed.Entities {
+			orm := initIfNeeded(engine, entity)
+			elem := orm.attributes.elem
+			for _, update := range seed.Update {
+				if !elem.FieldByName(update).IsValid() {
+					panic(errors.NotValidf("seed update field '%s' in %s", update, elem.Type().String()))
+				}
+			}
+			fields := make([]string, len(seed.Key))
+			values := make([]interface{}, 0, len(seed.Key))
+			for i, key := range seed.Key {
+				field := elem.FieldByName(key)
+				if !field.IsValid() {
+					panic(errors.NotFoundf("seed key field '%s' in %s", key, elem.Type().String()))
+				}
+				value := seedKeyValue(field)
+				if value == nil {
+					fields[i] = fmt.Sprintf("`%s` IS NULL", key)
+					continue
+				}
+				ordered, isUUID := orm.tableSchema.uuidColumns[key]
+				if isUUID && value != "" {
+					value = uuidToHex(value.(string), ordered)
+				}
+				fields[i] = fmt.Sprintf("`%s` = %s", key, orm.tableSchema.placeholder(key))
+				values = append(values, value)
+			}
+			existing := reflect.New(elem.Type()).Interface().(Entity)
+			if !engine.SearchOne(NewWhere(strings.Join(fields, " AND "), values...), existing) {
+				assignGeneratedID(engine, entity)
+				toFlush = append(toFlush, entity)
+				continue
+			}
+			existingElem := existing.getORM().attributes.elem
+			for _, update := range seed.Update {
+				existingElem.FieldByName(update).Set(elem.FieldByName(update))
+			}
+			found[entity] = existing
+			toFlush = append(toFlush, existing)
+		}
+		// only seeded entities are flushed, entities tracked before Seed stay tracked
+		if len(toFlush) > 0 {
+			engine.flushEntities(false, false, toFlush...)
+		}
+		for entity, existing := range found {
+			entity.getORM().attributes.idElem.SetUint(existing.GetID())
+		}
+	}
+}
+
+func seedKeyValue(field reflect.Value) interface{} {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		_, is := field.Interface().(Entity)
+		if is {
+			return field.Elem().FieldByName("ID").Uint()
+		}
+		return field.Elem().Interface()
+	}
+	return field.Interface()
+}
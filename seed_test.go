@@ -0,0 +1,52 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type seedEntity struct {
+	ORM
+	ID   uint
+	Code string `orm:"unique=code"`
+	Name string
+	Age  int
+}
+
+func TestSeed(t *testing.T) {
+	var entity *seedEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	pl := &seedEntity{Code: "pl", Name: "Poland", Age: 1}
+	de := &seedEntity{Code: "de", Name: "Germany", Age: 1}
+	engine.Seed(&Seed{Entities: []Entity{pl, de}, Key: []string{"Code"}, Update: []string{"Name"}})
+	assert.Equal(t, uint(1), pl.ID)
+	assert.Equal(t, uint(2), de.ID)
+
+	pl2 := &seedEntity{Code: "pl", Name: "Polska", Age: 2}
+	engine.Seed(&Seed{Entities: []Entity{pl2}, Key: []string{"Code"}, Update: []string{"Name"}})
+	assert.Equal(t, uint(1), pl2.ID)
+	entity = &seedEntity{}
+	engine.LoadByID(1, entity)
+	assert.Equal(t, "Polska", entity.Name)
+	assert.Equal(t, 1, entity.Age)
+
+	var rows []*seedEntity
+	engine.Search(NewWhere("1"), NewPager(1, 10), &rows)
+	assert.Len(t, rows, 2)
+
+	assert.PanicsWithError(t, "seed without key not valid", func() {
+		engine.Seed(&Seed{Entities: []Entity{pl}})
+	})
+	assert.PanicsWithError(t, "seed update field 'Title' in orm.seedEntity not valid", func() {
+		engine.Seed(&Seed{Entities: []Entity{pl}, Key: []string{"Code"}, Update: []string{"Title"}})
+	})
+
+	tracked := &seedEntity{Code: "fr", Name: "France"}
+	engine.Track(tracked)
+	engine.Seed(&Seed{Entities: []Entity{&seedEntity{Code: "it", Name: "Italy"}}, Key: []string{"Code"}})
+	assert.Equal(t, uint(0), tracked.ID)
+	engine.Flush()
+	assert.Equal(t, uint(4), tracked.ID)
+}
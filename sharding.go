@@ -0,0 +1,263 @@
+package orm
+
+import (
+	"reflect"
+
+	"github.com/juju/errors"
+)
+
+const shardIDBits = 48
+
+type ShardResolver func(key interface{}) int
+
+type shardDefinition struct {
+	resolver ShardResolver
+	pools    []string
+}
+
+type shardConfig struct {
+	field    string
+	resolver ShardResolver
+	pools    []string
+}
+
+type shardGroup struct {
+	pool     string
+	schemas  []*tableSchema
+	entities []Entity
+}
+
+func (r *Registry) RegisterShards(entity interface{}, resolver ShardResolver, pools ...string) {
+	t := reflect.TypeOf(entity)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if r.shards == nil {
+		r.shards = make(map[string]*shardDefinition)
+	}
+	r.shards[t.String()] = &shardDefinition{resolver: resolver, pools: pools}
+}
+
+func GetShardFromID(id uint64) int {
+	return int(id >> shardIDBits)
+}
+
+func initShardConfig(registry *Registry, entityType reflect.Type, tags map[string]map[string]string) (*shardConfig, error) {
+	field := ""
+	for column, values := range tags {
+		if _, has := values["shardKey"]; has {
+			if field != "" {
+				return nil, errors.Errorf("duplicated shard key %s and %s in %s", field, column, entityType.String())
+			}
+			field = column
+		}
+	}
+	definition, has := registry.shards[entityType.String()]
+	if !has {
+		if field != "" {
+			return nil, errors.NotFoundf("shard resolver for %s", entityType.String())
+		}
+		return nil, nil
+	}
+	if field == "" {
+		return nil, errors.Errorf("missing shard key in %s", entityType.String())
+	}
+	if _, has := entityType.FieldByName(field); !has {
+		return nil, errors.Errorf("shard key %s must be a top level field in %s", field, entityType.String())
+	}
+	idField, _ := entityType.FieldByName("ID")
+	if idField.Type.Kind() != reflect.Uint64 {
+		return nil, errors.Errorf("sharded entity %s must use uint64 ID", entityType.String())
+	}
+	if definition.resolver == nil || len(definition.pools) == 0 || len(definition.pools) > 1<<(64-shardIDBits) {
+		return nil, errors.NotValidf("shard definition for %s", entityType.String())
+	}
+	for _, pool := range definition.pools {
+		if _, has := registry.sqlClients[pool]; !has {
+			return nil, errors.NotFoundf("mysql pool '%s'", pool)
+		}
+	}
+	return &shardConfig{field: field, resolver: definition.resolver, pools: definition.pools}, nil
+}
+
+func (s *shardConfig) poolForID(id uint64) (string, bool) {
+	shard := GetShardFromID(id)
+	if shard >= len(s.pools) {
+		return "", false
+	}
+	return s.pools[shard], true
+}
+
+func (s *shardConfig) poolForKey(key interface{}) string {
+	shard := s.resolver(key)
+	if shard < 0 || shard >= len(s.pools) {
+		panic(errors.NotValidf("shard %d for key %v", shard, key))
+	}
+	return s.pools[shard]
+}
+
+func (s *shardConfig) poolForEntity(entity Entity) string {
+	pool := s.poolForKey(entity.getORM().attributes.elem.FieldByName(s.field).Interface())
+	id := entity.GetID()
+	if id == 0 {
+		return pool
+	}
+	idPool, valid := s.poolForID(id)
+	if !valid {
+		panic(errors.NotValidf("shard in ID %d", id))
+	}
+	if idPool != pool {
+		panic(errors.NotSupportedf("moving %s [%d] to another shard", entity.getORM().tableSchema.t.String(), id))
+	}
+	return idPool
+}
+
+func (tableSchema *tableSchema) getEntityMysql(engine *Engine, entity Entity) *DB {
+	if tableSchema.shard != nil {
+		return engine.GetMysql(tableSchema.shard.poolForEntity(entity))
+	}
+	return tableSchema.GetMysql(engine)
+}
+
+func (tableSchema *tableSchema) getMysqlPools() []string {
+	if tableSchema.shard != nil {
+		return tableSchema.shard.pools
+	}
+	return []string{tableSchema.mysqlPoolName}
+}
+
+func (e *Engine) withShardPool(schema *tableSchema, pool string, callback func()) {
+	saved := e.shardPools
+	defer func() {
+		e.shardPools = saved
+	}()
+	pools := make(map[*tableSchema]string, len(saved)+1)
+	for k, v := range saved {
+		pools[k] = v
+	}
+	pools[schema] = pool
+	e.shardPools = pools
+	callback()
+}
+
+func groupEntitiesByShard(engine *Engine, entities []Entity) []*shardGroup {
+	var groups []*shardGroup
+	var byPool map[string]*shardGroup
+	needed := false
+	for _, entity := range entities {
+		schema := entity.getORM().tableSchema
+		pool := ""
+		if schema.shard != nil {
+			pool = schema.shard.poolForEntity(entity)
+			if current, has := engine.shardPools[schema]; !has || current != pool {
+				needed = true
+			}
+		}
+		if byPool == nil {
+			byPool = make(map[string]*shardGroup)
+		}
+		group, has := byPool[pool]
+		if !has {
+			group = &shardGroup{pool: pool}
+			byPool[pool] = group
+			groups = append(groups, group)
+		}
+		if schema.shard != nil {
+			hasSchema := false
+			for _, s := range group.schemas {
+				if s == schema {
+					hasSchema = true
+					break
+				}
+			}
+			if !hasSchema {
+				group.schemas = append(group.schemas, schema)
+			}
+		}
+		group.entities = append(group.entities, entity)
+	}
+	if !needed {
+		return nil
+	}
+	return groups
+}
+
+func flushShards(engine *Engine, lazy bool, transaction bool, groups []*shardGroup) {
+	saved := engine.shardPools
+	defer func() {
+		engine.shardPools = saved
+	}()
+	for _, group := range groups {
+		pools := make(map[*tableSchema]string, len(saved)+len(group.schemas))
+		for k, v := range saved {
+			pools[k] = v
+		}
+		for _, schema := range group.schemas {
+			pools[schema] = group.pool
+		}
+		engine.shardPools = pools
+		flush(engine, lazy, transaction, group.entities...)
+	}
+}
+
+func searchShardedIDs(engine *Engine, schema *tableSchema, ids []uint64, entities reflect.Value) {
+	groups := make(map[string][]uint64)
+	for _, id := range ids {
+		pool, valid := schema.shard.poolForID(id)
+		if valid {
+			groups[pool] = append(groups[pool], id)
+		}
+	}
+	found := reflect.MakeSlice(entities.Type(), 0, len(ids))
+	for _, pool := range schema.shard.pools {
+		poolIDs, has := groups[pool]
+		if !has {
+			continue
+		}
+		engine.withShardPool(schema, pool, func() {
+			_ = search(false, engine, NewWhere("`ID` IN ?", poolIDs), NewPager(1, len(poolIDs)), false, entities)
+		})
+		found = reflect.AppendSlice(found, entities)
+	}
+	entities.Set(found)
+}
+
+func searchInShard(engine *Engine, shardKey interface{}, where *Where, pager *Pager, entities reflect.Value, references []string) {
+	entityType, has := getEntityTypeForSlice(engine.registry, entities.Type())
+	if !has {
+		panic(EntityNotRegisteredError{Name: entities.String()})
+	}
+	schema := getTableSchema(engine.registry, entityType)
+	if schema.shard == nil {
+		panic(errors.Errorf("entity %s is not sharded", entityType.String()))
+	}
+	engine.withShardPool(schema, schema.shard.poolForKey(shardKey), func() {
+		search(true, engine, where, pager, false, entities, references...)
+	})
+}
+
+func searchOneInShard(engine *Engine, shardKey interface{}, where *Where, entity Entity, references []string) (found bool) {
+	schema := initIfNeeded(engine, entity).tableSchema
+	if schema.shard == nil {
+		panic(errors.Errorf("entity %s is not sharded", schema.t.String()))
+	}
+	engine.withShardPool(schema, schema.shard.poolForKey(shardKey), func() {
+		found = searchOne(true, engine, where, entity, references)
+	})
+	return found
+}
+
+func getShardedSchemaChanges(engine *Engine, tableSchema *tableSchema) (has bool, alters []Alter) {
+	for i, pool := range tableSchema.shard.pools {
+		shardSchema := *tableSchema
+		shardSchema.mysqlPoolName = pool
+		shardSchema.shard = nil
+		shardSchema.autoIncrementStart = uint64(i)<<shardIDBits + 1
+		shardHas, shardAlters := getSchemaChanges(engine, &shardSchema)
+		if shardHas {
+			has = true
+			alters = append(alters, shardAlters...)
+		}
+	}
+	return has, alters
+}
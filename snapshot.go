@@ -0,0 +1,119 @@
+package orm
+
+import (
+	"fmt"
+	"strings"
+)
+
+const snapshotInsertBatch = 1000
+
+type Snapshot struct {
+	engine *Engine
+	tables []*tableSnapshot
+}
+
+type tableSnapshot struct {
+	schema    *tableSchema
+	tableName string
+	columns   []string
+	rows      [][]interface{}
+}
+
+func snapshotTables(engine *Engine, entities []Entity) *Snapshot {
+	snapshot := &Snapshot{engine: engine}
+	for _, schema := range getEntitiesSchemas(engine, entities) {
+		pool := schema.GetMysql(engine)
+		/* #nosec */
+		tableName := schema.getTableName(engine)
+		results, def := pool.Query(fmt.Sprintf("SELECT * FROM `%s`", tableName))
+		table := &tableSnapshot{schema: schema, tableName: tableName, columns: results.Columns()}
+		for results.Next() {
+			values := make([]interface{}, len(table.columns))
+			pointers := make([]interface{}, len(table.columns))
+			for i := range values {
+				pointers[i] = &values[i]
+			}
+			results.Scan(pointers...)
+			for i, value := range values {
+				asBytes, is := value.([]byte)
+				if is {
+					values[i] = string(asBytes)
+				}
+			}
+			table.rows = append(table.rows, values)
+		}
+		def()
+		snapshot.tables = append(snapshot.tables, table)
+	}
+	return snapshot
+}
+
+func (s *Snapshot) Restore() {
+	pools := make(map[string][]*tableSnapshot)
+	poolsOrder := make([]string, 0)
+	for _, table := range s.tables {
+		code := table.schema.mysqlPoolName
+		_, has := pools[code]
+		if !has {
+			poolsOrder = append(poolsOrder, code)
+		}
+		pools[code] = append(pools[code], table)
+	}
+	for _, code := range poolsOrder {
+		db := s.engine.GetMysql(code)
+		db.Begin()
+		func() {
+			defer db.Rollback()
+			db.Exec("SET FOREIGN_KEY_CHECKS = 0")
+			func() {
+				// connection goes back to pool, checks are enabled again also when restore fails
+				defer db.Exec("SET FOREIGN_KEY_CHECKS = 1")
+				for _, table := range pools[code] {
+					table.restore(db)
+				}
+			}()
+			db.Commit()
+		}()
+		// ALTER commits transaction implicitly, InnoDB sets counter to MAX(ID) + 1
+		for _, table := range pools[code] {
+			/* #nosec */
+			db.Exec(fmt.Sprintf("ALTER TABLE `%s` AUTO_INCREMENT = 1", table.tableName))
+		}
+	}
+	clearedLocalCaches := make(map[string]bool)
+	for _, table := range s.tables {
+		clearEntityCache(s.engine, table.schema, clearedLocalCaches)
+	}
+}
+
+func (t *tableSnapshot) restore(db *DB) {
+	// TRUNCATE commits transaction implicitly, DELETE is rolled back with inserts
+	/* #nosec */
+	db.Exec(fmt.Sprintf("DELETE FROM `%s`", t.tableName))
+	if len(t.rows) == 0 {
+		return
+	}
+	columns := make([]string, len(t.columns))
+	placeholders := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		columns[i] = "`" + column + "`"
+		placeholders[i] = "?"
+	}
+	row := "(" + strings.Join(placeholders, ",") + ")"
+	for start := 0; start < len(t.rows); start += snapshotInsertBatch {
+		end := start + snapshotInsertBatch
+		if end > len(t.rows) {
+			end = len(t.rows)
+		}
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*len(t.columns))
+		for _, row := range t.rows[start:end] {
+			args = append(args, row...)
+		}
+		for i := start; i < end; i++ {
+			values = append(values, row)
+		}
+		/* #nosec */
+		db.Exec(fmt.Sprintf("INSERT INTO `%s`(%s) VALUES %s", t.tableName, strings.Join(columns, ","), strings.Join(values, ",")), args...)
+	}
+}
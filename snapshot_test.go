@@ -0,0 +1,41 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotTables(t *testing.T) {
+	var entity *flushEntity
+	var reference *flushEntityReference
+	engine := PrepareTables(t, &Registry{}, entity, reference)
+
+	entity = &flushEntity{Name: "Tom", NameRequired: "required", ReferenceOne: &flushEntityReference{Name: "John"}}
+	engine.TrackAndFlush(entity)
+	snapshot := engine.SnapshotTables(entity, reference)
+
+	entity.Name = "Tom 2"
+	engine.TrackAndFlush(entity, &flushEntity{Name: "Adam", NameRequired: "required"})
+	engine.LoadByID(1, entity)
+	assert.Equal(t, "Tom 2", entity.Name)
+
+	snapshot.Restore()
+	entity = &flushEntity{}
+	assert.True(t, engine.LoadByID(1, entity, "ReferenceOne"))
+	assert.Equal(t, "Tom", entity.Name)
+	assert.Equal(t, "John", entity.ReferenceOne.Name)
+	assert.False(t, engine.LoadByID(2, entity))
+
+	entity = &flushEntity{Name: "Adam", NameRequired: "required"}
+	engine.TrackAndFlush(entity)
+	assert.Equal(t, uint(2), entity.ID)
+
+	broken := engine.SnapshotTables(reference)
+	broken.tables[0].rows[0] = append(broken.tables[0].rows[0], "invalid")
+	assert.Panics(t, func() {
+		broken.Restore()
+	})
+	assert.True(t, engine.LoadByID(2, &flushEntity{}))
+	assert.True(t, engine.LoadByID(1, &flushEntityReference{}))
+}
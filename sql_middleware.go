@@ -0,0 +1,59 @@
+package orm
+
+import (
+	"time"
+
+	"github.com/juju/errors"
+)
+
+type SQLQuery struct {
+	Pool string
+	// Operation is one of: exec, select, begin, commit, rollback
+	Operation string
+	Query     string
+	Args      []interface{}
+	// Duration of query execution, set when next returns
+	Duration time.Duration
+}
+
+// SQLMiddleware wraps every MySQL query, query and args can be changed before calling next,
+// returning without calling next stops query, nil error is then replaced with "query not executed"
+type SQLMiddleware func(query *SQLQuery, next func() error) error
+
+func (r *Registry) RegisterSQLMiddleware(middleware ...SQLMiddleware) {
+	r.sqlMiddlewares = append(r.sqlMiddlewares, middleware...)
+}
+
+func (e *Engine) AddSQLMiddleware(middleware ...SQLMiddleware) {
+	e.sqlMiddlewares = append(e.sqlMiddlewares, middleware...)
+}
+
+// withMiddlewares returns query and args that were passed to execute, so they can be logged
+func (db *DB) withMiddlewares(operation string, query string, args []interface{}, execute func(query string, args []interface{}) error) (string, []interface{}, error) {
+	fromRegistry := db.engine.registry.registry.sqlMiddlewares
+	if len(fromRegistry) == 0 && len(db.engine.sqlMiddlewares) == 0 {
+		return query, args, execute(query, args)
+	}
+	middlewares := make([]SQLMiddleware, 0, len(fromRegistry)+len(db.engine.sqlMiddlewares))
+	middlewares = append(append(middlewares, fromRegistry...), db.engine.sqlMiddlewares...)
+	sqlQuery := &SQLQuery{Pool: db.code, Operation: operation, Query: query, Args: args}
+	executed := false
+	var call func(i int) error
+	call = func(i int) error {
+		if i == len(middlewares) {
+			executed = true
+			start := time.Now()
+			err := execute(sqlQuery.Query, sqlQuery.Args)
+			sqlQuery.Duration = time.Since(start)
+			return err
+		}
+		return middlewares[i](sqlQuery, func() error {
+			return call(i + 1)
+		})
+	}
+	err := call(0)
+	if err == nil && !executed {
+		err = errors.New("query not executed")
+	}
+	return sqlQuery.Query, sqlQuery.Args, err
+}
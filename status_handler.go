@@ -0,0 +1,214 @@
+package orm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+)
+
+const statusPoolTimeout = 5 * time.Second
+
+var statusEndpoints = []string{"pools", "alters", "cache", "queues", "entities"}
+
+type statusHandler struct {
+	engine *Engine
+}
+
+type poolStatus struct {
+	Pool  string `json:"pool"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type alterStatus struct {
+	Pool string `json:"pool"`
+	SQL  string `json:"sql"`
+	Safe bool   `json:"safe"`
+}
+
+type cacheStatus struct {
+	Pool    string `json:"pool"`
+	Type    string `json:"type"`
+	Entries int64  `json:"entries"`
+	Error   string `json:"error,omitempty"`
+}
+
+type queueStatus struct {
+	Queue     string `json:"queue"`
+	Messages  int    `json:"messages"`
+	Consumers int    `json:"consumers"`
+	Error     string `json:"error,omitempty"`
+}
+
+type entityStatus struct {
+	Entity        string `json:"entity"`
+	Table         string `json:"table"`
+	MySQLPool     string `json:"mysqlPool"`
+	LocalCache    string `json:"localCache,omitempty"`
+	RedisCache    string `json:"redisCache,omitempty"`
+	CachedQueries int    `json:"cachedQueries"`
+	FakeDelete    bool   `json:"fakeDelete"`
+}
+
+// NewStatusHandler returns handler with JSON endpoints: /pools, /alters, /cache, /queues and /entities
+func NewStatusHandler(engine *Engine) http.Handler {
+	return &statusHandler{engine: engine}
+}
+
+func (h *statusHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	engine := h.engine.registry.CreateEngine()
+	engine.log = h.engine.log
+	engine.poolResolver = h.engine.poolResolver
+	var response interface{}
+	defer func() {
+		if r := recover(); r != nil {
+			http.Error(writer, statusError(r), http.StatusInternalServerError)
+		}
+	}()
+	switch path.Base(request.URL.Path) {
+	case "pools":
+		response = getPoolsStatus(request.Context(), engine)
+	case "alters":
+		response = getAltersStatus(engine)
+	case "cache":
+		response = getCacheStatus(engine)
+	case "queues":
+		response = getQueuesStatus(engine)
+	case "entities":
+		response = getEntitiesStatus(engine)
+	case "/", ".", "status":
+		response = statusEndpoints
+	default:
+		http.NotFound(writer, request)
+		return
+	}
+	asJSON, _ := json.Marshal(response)
+	writer.Header().Set("Content-Type", "application/json")
+	_, _ = writer.Write(asJSON)
+}
+
+func statusError(r interface{}) string {
+	asErr, is := r.(error)
+	if is {
+		return asErr.Error()
+	}
+	return http.StatusText(http.StatusInternalServerError)
+}
+
+func getPoolsStatus(ctx context.Context, engine *Engine) []poolStatus {
+	ctx, cancel := context.WithTimeout(ctx, statusPoolTimeout)
+	defer cancel()
+	failed := pingPools(ctx, engine)
+	registry := engine.registry
+	names := make([]string, 0)
+	for code := range registry.sqlClients {
+		names = append(names, "mysql:"+code)
+	}
+	for code := range registry.clickHouseClients {
+		names = append(names, "clickhouse:"+code)
+	}
+	for code := range registry.redisServers {
+		names = append(names, "redis:"+code)
+	}
+	for code := range registry.elasticServers {
+		names = append(names, "elastic:"+code)
+	}
+	for code := range registry.rabbitMQServers {
+		names = append(names, "rabbitMQ:"+code)
+	}
+	sort.Strings(names)
+	result := make([]poolStatus, len(names))
+	for i, name := range names {
+		result[i] = poolStatus{Pool: name, OK: failed[name] == nil}
+		if failed[name] != nil {
+			result[i].Error = failed[name].Error()
+		}
+	}
+	return result
+}
+
+func getAltersStatus(engine *Engine) []alterStatus {
+	alters := getAlters(engine)
+	result := make([]alterStatus, len(alters))
+	for i, alter := range alters {
+		result[i] = alterStatus{Pool: alter.Pool, SQL: alter.SQL, Safe: alter.Safe}
+	}
+	return result
+}
+
+func getCacheStatus(engine *Engine) []cacheStatus {
+	registry := engine.registry
+	result := make([]cacheStatus, 0, len(registry.localCacheContainers)+len(registry.redisServers))
+	for code := range registry.localCacheContainers {
+		result = append(result, cacheStatus{Pool: code, Type: "local", Entries: int64(engine.GetLocalCache(code).lru.Len())})
+	}
+	for code := range registry.redisServers {
+		status := cacheStatus{Pool: code, Type: "redis"}
+		size, err := engine.GetRedis(code).client.DBSize()
+		if err != nil {
+			status.Error = err.Error()
+		}
+		status.Entries = size
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Type != result[j].Type {
+			return result[i].Type < result[j].Type
+		}
+		return result[i].Pool < result[j].Pool
+	})
+	return result
+}
+
+func getQueuesStatus(engine *Engine) []queueStatus {
+	names := engine.registry.GetRabbitMQQueues()
+	result := make([]queueStatus, 0, len(names))
+	for _, name := range names {
+		status := queueStatus{Queue: name}
+		queue, err := engine.TryGetRabbitMQQueue(name)
+		if err == nil {
+			err = inspectQueue(queue, &status)
+		}
+		if err != nil {
+			status.Error = err.Error()
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+func inspectQueue(queue *RabbitMQQueue, status *queueStatus) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if asErr, is := r.(error); is {
+				err = asErr
+				return
+			}
+			panic(r)
+		}
+	}()
+	status.Messages, status.Consumers = queue.Inspect()
+	return nil
+}
+
+func getEntitiesStatus(engine *Engine) []entityStatus {
+	registry := engine.registry
+	result := make([]entityStatus, 0, len(registry.entities))
+	for name, t := range registry.entities {
+		schema := getTableSchema(registry, t)
+		result = append(result, entityStatus{Entity: name, Table: schema.tableName, MySQLPool: schema.mysqlPoolName,
+			LocalCache: schema.localCacheName, RedisCache: schema.redisCacheName, CachedQueries: len(schema.cachedIndexesAll),
+			FakeDelete: schema.hasFakeDelete})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Entity < result[j].Entity
+	})
+	return result
+}
@@ -0,0 +1,54 @@
+package orm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type statusHandlerEntity struct {
+	ORM        `orm:"localCache"`
+	ID         uint
+	FakeDelete bool
+}
+
+func TestStatusHandler(t *testing.T) {
+	registry := &Registry{}
+	registry.RegisterLocalCache(100)
+	registry.sqlClients = map[string]*DBConfig{"default": {code: "default", databaseName: "test"}}
+	entityType := reflect.TypeOf(statusHandlerEntity{})
+	schema, err := initTableSchema(registry, entityType)
+	assert.NoError(t, err)
+	validated := (&validatedRegistry{}).clone(registry, nil)
+	validated.localCacheContainers = registry.localCacheContainers
+	validated.sqlClients = map[string]*DBConfig{}
+	validated.tableSchemas[entityType] = schema
+	validated.entities = map[string]reflect.Type{"orm.statusHandlerEntity": entityType}
+	engine := validated.CreateEngine()
+	engine.GetLocalCache().Set("a", 1)
+
+	handler := http.StripPrefix("/admin/orm", NewStatusHandler(engine))
+	get := func(url string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		return recorder
+	}
+	response := get("/admin/orm/")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+	assert.Equal(t, `["pools","alters","cache","queues","entities"]`, response.Body.String())
+
+	assert.Equal(t, `[{"entity":"orm.statusHandlerEntity","table":"statusHandlerEntity","mysqlPool":"default",`+
+		`"localCache":"default","cachedQueries":0,"fakeDelete":true}]`, get("/admin/orm/entities").Body.String())
+	assert.Equal(t, `[{"pool":"default","type":"local","entries":1}]`, get("/admin/orm/cache").Body.String())
+	assert.Equal(t, `[]`, get("/admin/orm/pools").Body.String())
+	assert.Equal(t, `[]`, get("/admin/orm/queues").Body.String())
+	assert.Equal(t, http.StatusNotFound, get("/admin/orm/missing").Code)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/orm/pools", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
@@ -1,12 +1,17 @@
 package orm
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	apexLog "github.com/apex/log"
 	"github.com/juju/errors"
 
 	"github.com/segmentio/fasthash/fnv1a"
@@ -14,12 +19,18 @@ import (
 
 type CachedQuery struct{}
 
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
 type cachedQueryDefinition struct {
 	Max           int
 	Query         string
 	TrackedFields []string
 	QueryFields   []string
 	OrderFields   []string
+	LocalCache    string
+	RedisCache    string
+	Composite     bool
 }
 
 type Enum interface {
@@ -82,47 +93,81 @@ type TableSchema interface {
 }
 
 type tableSchema struct {
-	tableName        string
-	mysqlPoolName    string
-	t                reflect.Type
-	fields           *tableFields
-	fieldsQuery      string
-	tags             map[string]map[string]string
-	cachedIndexes    map[string]*cachedQueryDefinition
-	cachedIndexesOne map[string]*cachedQueryDefinition
-	cachedIndexesAll map[string]*cachedQueryDefinition
-	columnNames      []string
-	uniqueIndices    map[string][]string
-	refOne           []string
-	columnsStamp     string
-	localCacheName   string
-	redisCacheName   string
-	cachePrefix      string
-	hasFakeDelete    bool
-	hasLog           bool
-	logPoolName      string //name of redis or rabbitMQ
-	logTableName     string
-	skipLogs         []string
+	tableName            string
+	mysqlPoolName        string
+	t                    reflect.Type
+	fields               *tableFields
+	fieldsQuery          string
+	tags                 map[string]map[string]string
+	tagName              string
+	cachedIndexes        map[string]*cachedQueryDefinition
+	cachedIndexesOne     map[string]*cachedQueryDefinition
+	cachedIndexesAll     map[string]*cachedQueryDefinition
+	columnNames          []string
+	uniqueIndices        map[string][]string
+	refOne               []string
+	columnsStamp         string
+	localCacheName       string
+	redisCacheName       string
+	redisCacheTTL        int
+	redisCacheRefresh    bool
+	cachePrefix          string
+	hasFakeDelete        bool
+	hasLog               bool
+	logPoolName          string //name of redis or rabbitMQ
+	logTableName         string
+	logClickHouse        string
+	logWriter            string
+	logExternal          bool
+	logRetentionDays     uint64
+	logRetentionVersions uint64
+	skipLogs             []string
+	redactedColumns      map[string]bool
+	timeLocation         *time.Location
+	uuidColumns          map[string]bool
+	uuidKey              string
+	uuidReferences       map[string]string
+	enums                map[string]Enum
+	collections          map[string]string
+	queryReferences      []*cachedQueryReference
+	shard                *shardConfig
+	hasTableSuffix       bool
+	tableSuffixPattern   *regexp.Regexp
+	hasChanges           bool
+	idGenerator          IDGenerator
+	autoIncrementStart   uint64
+}
+
+type cachedQueryReference struct {
+	schema    *tableSchema
+	indexName string
+	column    string
 }
 
 type tableFields struct {
-	t             reflect.Type
-	fields        map[int]reflect.StructField
-	prefix        string
-	uintegers     []int
-	integers      []int
-	strings       []int
-	sliceStrings  []int
-	bytes         []int
-	fakeDelete    int
-	booleans      []int
-	floats        []int
-	timesNullable []int
-	times         []int
-	jsons         []int
-	structs       map[int]*tableFields
-	refs          []int
-	refsTypes     []reflect.Type
+	t                reflect.Type
+	fields           map[int]reflect.StructField
+	prefix           string
+	embedded         bool
+	uintegers        []int
+	integers         []int
+	strings          []int
+	sliceStrings     []int
+	bytes            []int
+	fakeDelete       int
+	booleans         []int
+	floats           []int
+	decimals         []int
+	decimalsNullable []int
+	valuers          []int
+	uuids            map[int]bool
+	uuidRefs         map[int]bool
+	timesNullable    []int
+	times            []int
+	jsons            []int
+	structs          map[int]*tableFields
+	refs             []int
+	refsTypes        []reflect.Type
 }
 
 func getTableSchema(registry *validatedRegistry, entityType reflect.Type) *tableSchema {
@@ -138,50 +183,128 @@ func (tableSchema *tableSchema) GetType() reflect.Type {
 }
 
 func (tableSchema *tableSchema) DropTable(engine *Engine) {
-	pool := tableSchema.GetMysql(engine)
-	pool.Exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s`;", pool.GetDatabaseName(), tableSchema.tableName))
+	tableSchema.forEachMysqlPool(engine, func(pool *DB) {
+		pool.Exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s`;", pool.GetDatabaseName(), tableSchema.getTableName(engine)))
+	})
 }
 
 func (tableSchema *tableSchema) TruncateTable(engine *Engine) {
-	pool := tableSchema.GetMysql(engine)
-	_ = pool.Exec("SET FOREIGN_KEY_CHECKS = 0")
-	_ = pool.Exec(fmt.Sprintf("TRUNCATE TABLE `%s`.`%s`;",
-		pool.GetDatabaseName(), tableSchema.tableName))
-	_ = pool.Exec("SET FOREIGN_KEY_CHECKS = 1")
+	tableSchema.forEachMysqlPool(engine, func(pool *DB) {
+		_ = pool.Exec("SET FOREIGN_KEY_CHECKS = 0")
+		_ = pool.Exec(fmt.Sprintf("TRUNCATE TABLE `%s`.`%s`;",
+			pool.GetDatabaseName(), tableSchema.getTableName(engine)))
+		_ = pool.Exec("SET FOREIGN_KEY_CHECKS = 1")
+	})
 }
 
 func (tableSchema *tableSchema) UpdateSchema(engine *Engine) {
-	pool := tableSchema.GetMysql(engine)
 	has, alters := tableSchema.GetSchemaChanges(engine)
 	if has {
 		for _, alter := range alters {
-			_ = pool.Exec(alter.SQL)
+			if tableSchema.shard != nil {
+				_ = engine.GetMysql(alter.Pool).Exec(alter.SQL)
+				continue
+			}
+			_ = tableSchema.GetMysql(engine).Exec(alter.SQL)
 		}
 	}
 }
 
 func (tableSchema *tableSchema) UpdateSchemaAndTruncateTable(engine *Engine) {
 	tableSchema.UpdateSchema(engine)
-	pool := tableSchema.GetMysql(engine)
-	_ = pool.Exec(fmt.Sprintf("TRUNCATE TABLE `%s`.`%s`;", pool.GetDatabaseName(), tableSchema.tableName))
+	tableSchema.forEachMysqlPool(engine, func(pool *DB) {
+		_ = pool.Exec(fmt.Sprintf("TRUNCATE TABLE `%s`.`%s`;", pool.GetDatabaseName(), tableSchema.getTableName(engine)))
+	})
+}
+
+func (tableSchema *tableSchema) forEachMysqlPool(engine *Engine, callback func(pool *DB)) {
+	if tableSchema.shard == nil {
+		callback(tableSchema.GetMysql(engine))
+		return
+	}
+	for _, pool := range tableSchema.shard.pools {
+		callback(engine.GetMysql(pool))
+	}
 }
 
 func (tableSchema *tableSchema) GetMysql(engine *Engine) *DB {
-	return engine.GetMysql(tableSchema.mysqlPoolName)
+	return engine.GetMysql(tableSchema.getMysqlPoolName(engine))
 }
 
 func (tableSchema *tableSchema) GetLocalCache(engine *Engine) (cache *LocalCache, has bool) {
 	if tableSchema.localCacheName == "" {
 		return nil, false
 	}
-	return engine.GetLocalCache(tableSchema.localCacheName), true
+	return tableSchema.getLocalCache(engine, tableSchema.localCacheName), true
 }
 
 func (tableSchema *tableSchema) GetRedisCache(engine *Engine) (cache *RedisCache, has bool) {
 	if tableSchema.redisCacheName == "" {
 		return nil, false
 	}
-	return engine.GetRedis(tableSchema.redisCacheName), true
+	return tableSchema.getRedisCache(engine, tableSchema.redisCacheName), true
+}
+
+func (tableSchema *tableSchema) getLocalCache(engine *Engine, code string) *LocalCache {
+	namespace := tableSchema.getCacheNamespace(engine)
+	if namespace == "" {
+		return engine.GetLocalCache(code)
+	}
+	pool := tableSchema.resolvePool(engine)
+	if _, has := engine.localCache[pool]; has && pool != "" {
+		code = pool
+	}
+	return engine.GetLocalCache(getNamespacedCode(code, namespace))
+}
+
+func (tableSchema *tableSchema) getRedisCache(engine *Engine, code string) *RedisCache {
+	namespace := tableSchema.getCacheNamespace(engine)
+	if namespace == "" {
+		return engine.GetRedis(code)
+	}
+	pool := tableSchema.resolvePool(engine)
+	if _, has := engine.redis[pool]; has && pool != "" {
+		code = pool
+	}
+	return engine.GetRedis(getNamespacedCode(code, namespace))
+}
+
+func (tableSchema *tableSchema) getQueryLocalCache(engine *Engine, definition *cachedQueryDefinition) (cache *LocalCache, has bool) {
+	if definition.LocalCache != "" {
+		return tableSchema.getLocalCache(engine, definition.LocalCache), true
+	}
+	return tableSchema.GetLocalCache(engine)
+}
+
+func (tableSchema *tableSchema) getQueryRedisCache(engine *Engine, definition *cachedQueryDefinition) (cache *RedisCache, has bool) {
+	if definition.RedisCache != "" {
+		return tableSchema.getRedisCache(engine, definition.RedisCache), true
+	}
+	return tableSchema.GetRedisCache(engine)
+}
+
+func getCachedQueryPools(registry *Registry, values map[string]string) (localCache string, redisCache string, err error) {
+	localCache = values["localCache"]
+	if localCache == "true" {
+		localCache = "default"
+	}
+	if localCache != "" {
+		_, has := registry.localCacheContainers[localCache]
+		if !has {
+			return "", "", errors.NotFoundf("local cache pool '%s'", localCache)
+		}
+	}
+	redisCache = values["redisCache"]
+	if redisCache == "true" {
+		redisCache = "default"
+	}
+	if redisCache != "" {
+		_, has := registry.redisServers[redisCache]
+		if !has {
+			return "", "", errors.NotFoundf("redis cache pool '%s'", redisCache)
+		}
+	}
+	return localCache, redisCache, nil
 }
 
 func (tableSchema *tableSchema) GetReferences() []string {
@@ -200,7 +323,8 @@ func (tableSchema *tableSchema) GetUsage(registry ValidatedRegistry) map[reflect
 			schema := getTableSchema(vRegistry, t)
 			for _, columnName := range schema.refOne {
 				ref, has := schema.tags[columnName]["ref"]
-				if has && ref == tableSchema.t.String() {
+				_, isUUID := schema.uuidReferences[columnName]
+				if has && !isUUID && ref == tableSchema.t.String() {
 					if results[t] == nil {
 						results[t] = make([]string, 0)
 					}
@@ -212,8 +336,33 @@ func (tableSchema *tableSchema) GetUsage(registry ValidatedRegistry) map[reflect
 	return results
 }
 
+func addCachedQueryReferences(registry *validatedRegistry, schema *tableSchema) {
+	indexNames := make([]string, 0, len(schema.cachedIndexesAll))
+	for indexName := range schema.cachedIndexesAll {
+		indexNames = append(indexNames, indexName)
+	}
+	sort.Strings(indexNames)
+	for _, indexName := range indexNames {
+		for _, column := range schema.cachedIndexesAll[indexName].QueryFields {
+			ref, has := schema.tags[column]["ref"]
+			if !has {
+				continue
+			}
+			refSchema := getTableSchema(registry, registry.entities[ref])
+			if refSchema != nil {
+				refSchema.queryReferences = append(refSchema.queryReferences,
+					&cachedQueryReference{schema: schema, indexName: indexName, column: column})
+			}
+		}
+	}
+}
+
 func (tableSchema *tableSchema) GetSchemaChanges(engine *Engine) (has bool, alters []Alter) {
-	return getSchemaChanges(engine, tableSchema)
+	schema := tableSchema.withTableName(tableSchema.getTableName(engine))
+	if schema.shard != nil {
+		return getShardedSchemaChanges(engine, schema)
+	}
+	return getSchemaChanges(engine, schema)
 }
 
 func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema, error) {
@@ -223,6 +372,13 @@ func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema,
 	if !has {
 		mysql = "default"
 	}
+	shard, err := initShardConfig(registry, entityType, tags)
+	if err != nil {
+		return nil, err
+	}
+	if shard != nil {
+		mysql = shard.pools[0]
+	}
 	_, has = registry.sqlClients[mysql]
 	if !has {
 		return nil, errors.NotFoundf("mysql pool '%s'", mysql)
@@ -231,6 +387,19 @@ func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema,
 	if !has {
 		table = entityType.Name()
 	}
+	tableSuffix, hasTableSuffix := tags["ORM"]["tableSuffix"]
+	tableSuffixPattern, err := getTableSuffixPattern(tableSuffix, hasTableSuffix)
+	if err != nil {
+		return nil, errors.Annotatef(err, "invalid entity %s", entityType.String())
+	}
+	_, hasChanges := tags["ORM"]["changes"]
+	var idGenerator IDGenerator
+	if generatorCode, has := tags["ORM"]["idGenerator"]; has {
+		idGenerator, has = registry.idGenerators[generatorCode]
+		if !has {
+			return nil, errors.NotFoundf("id generator '%s'", generatorCode)
+		}
+	}
 	localCache := ""
 	redisCache := ""
 	userValue, has := tags["ORM"]["localCache"]
@@ -246,12 +415,24 @@ func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema,
 			return nil, errors.NotFoundf("local cache pool '%s'", localCache)
 		}
 	}
+	redisCacheTTL := 0
+	redisCacheRefresh := false
 	userValue, has = tags["ORM"]["redisCache"]
 	if has {
-		if userValue == "true" {
+		parts := strings.Split(userValue, ":")
+		userValue = parts[0]
+		if userValue == "true" || userValue == "" {
 			userValue = "default"
 		}
 		redisCache = userValue
+		if len(parts) > 1 {
+			ttl, err := strconv.Atoi(parts[1])
+			if err != nil || ttl <= 0 || len(parts) > 3 || (len(parts) == 3 && parts[2] != "refresh") {
+				return nil, errors.NotValidf("redis cache definition '%s'", tags["ORM"]["redisCache"])
+			}
+			redisCacheTTL = ttl
+			redisCacheRefresh = len(parts) == 3
+		}
 	}
 	if redisCache != "" {
 		_, has = registry.redisServers[redisCache]
@@ -327,6 +508,10 @@ func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema,
 				}
 			}
 
+			localCachePool, redisCachePool, err := getCachedQueryPools(registry, values)
+			if err != nil {
+				return nil, errors.Annotatef(err, "cached query %s", key)
+			}
 			if !isOne {
 				max := 50000
 				maxAttribute, has := values["max"]
@@ -335,13 +520,26 @@ func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema,
 					if err != nil {
 						return nil, errors.Trace(err)
 					}
+					if maxFromUser < 1 {
+						return nil, errors.NotValidf("max rows %d in cached query %s", maxFromUser, key)
+					}
 					max = maxFromUser
 				}
-				def := &cachedQueryDefinition{max, query, fieldsTracked, fieldsQuery, fieldsOrder}
+				composite := values["composite"] == "true"
+				if composite {
+					err := checkCompositeQuery(query, fieldsQuery, fieldsOrder)
+					if err != nil {
+						return nil, errors.Annotatef(err, "cached query %s in %s", key, entityType.String())
+					}
+				}
+				def := &cachedQueryDefinition{max, query, fieldsTracked, fieldsQuery, fieldsOrder, localCachePool, redisCachePool, composite}
 				cachedQueries[key] = def
 				cachedQueriesAll[key] = def
 			} else {
-				def := &cachedQueryDefinition{1, query, fieldsTracked, fieldsQuery, fieldsOrder}
+				if values["composite"] == "true" {
+					return nil, errors.Errorf("composite not allowed in cached query one %s in %s", key, entityType.String())
+				}
+				def := &cachedQueryDefinition{1, query, fieldsTracked, fieldsQuery, fieldsOrder, localCachePool, redisCachePool, false}
 				cachedQueriesOne[key] = def
 				cachedQueriesAll[key] = def
 			}
@@ -350,15 +548,98 @@ func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema,
 		if has {
 			oneRefs = append(oneRefs, key)
 		}
+		onDelete, has := values["onDelete"]
+		if has {
+			switch strings.ToUpper(onDelete) {
+			case "RESTRICT", "CASCADE", "NO ACTION":
+			case "SET NULL":
+				if values["required"] == "true" {
+					return nil, errors.Errorf("on delete set null not allowed for required field %s in %s", key, entityType.String())
+				}
+			default:
+				return nil, errors.NotValidf("on delete rule '%s' for field %s in %s", onDelete, key, entityType.String())
+			}
+			_, isRef := values["ref"]
+			if !isRef {
+				return nil, errors.Errorf("on delete rule set for not reference field %s in %s", key, entityType.String())
+			}
+		}
+	}
+	collections := make(map[string]string)
+	collectionFields, has := tags["ORM"]["cached_collection"]
+	if has {
+		for _, field := range strings.Split(collectionFields, ",") {
+			refType, isRef := tags[field]["ref"]
+			if !isRef {
+				return nil, errors.Errorf("cached collection field '%s' is not a reference in %s", field, entityType.String())
+			}
+			_, has := collections[refType]
+			if has {
+				return nil, errors.Errorf("duplicated cached collection for %s in %s", refType, entityType.String())
+			}
+			indexName := "_collection_" + field
+			tracked := []string{field}
+			if hasFakeDelete {
+				tracked = append(tracked, "FakeDelete")
+			}
+			def := &cachedQueryDefinition{50000, fmt.Sprintf("`%s` = ? ORDER BY `ID`", field), tracked, []string{field}, nil, "", "", false}
+			cachedQueries[indexName] = def
+			cachedQueriesAll[indexName] = def
+			collections[refType] = indexName
+		}
 	}
 	logPoolName := tags["ORM"]["log"]
 	if logPoolName == "true" {
 		logPoolName = mysql
 	}
+	logClickHouse := tags["ORM"]["clickhouseLog"]
+	if logClickHouse == "true" {
+		logClickHouse = "default"
+	}
+	if logClickHouse != "" {
+		_, has = registry.clickHouseClients[logClickHouse]
+		if !has {
+			return nil, errors.NotFoundf("clickhouse pool '%s'", logClickHouse)
+		}
+		if logPoolName == "" {
+			logPoolName = mysql
+		}
+	}
+	logWriter := tags["ORM"]["logWriter"]
+	logExternal := false
+	if logWriter != "" {
+		writer, has := registry.logWriters[logWriter]
+		if !has {
+			return nil, errors.NotFoundf("log writer '%s'", logWriter)
+		}
+		// logs table is created and read in the same pool writer uses
+		switch w := writer.(type) {
+		case *mySQLLogWriter:
+			if _, has = registry.sqlClients[w.pool]; !has {
+				return nil, errors.NotFoundf("mysql pool '%s'", w.pool)
+			}
+			logPoolName = w.pool
+		case *clickHouseLogWriter:
+			if _, has = registry.clickHouseClients[w.pool]; !has {
+				return nil, errors.NotFoundf("clickhouse pool '%s'", w.pool)
+			}
+			logClickHouse = w.pool
+		default:
+			logExternal = true
+		}
+		if logPoolName == "" {
+			logPoolName = mysql
+		}
+	}
+	logRetentionDays, logRetentionVersions, err := getLogRetention(tags["ORM"], logPoolName != "", logClickHouse != "")
+	if err != nil {
+		return nil, err
+	}
 	uniqueIndices := make(map[string]map[int]string)
 	uniqueIndicesSimple := make(map[string][]string)
 	indices := make(map[string]map[int]string)
 	skipLogs := make([]string, 0)
+	var redactedColumns map[string]bool
 	for k, v := range tags {
 		keys, has := v["unique"]
 		if has {
@@ -373,10 +654,6 @@ func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema,
 					uniqueIndices[parts[0]] = make(map[int]string)
 				}
 				uniqueIndices[parts[0]][int(id)] = k
-				if uniqueIndicesSimple[parts[0]] == nil {
-					uniqueIndicesSimple[parts[0]] = make([]string, 0)
-				}
-				uniqueIndicesSimple[parts[0]] = append(uniqueIndicesSimple[parts[0]], k)
 			}
 		}
 		keys, has = v["index"]
@@ -398,6 +675,24 @@ func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema,
 		if has {
 			skipLogs = append(skipLogs, k)
 		}
+		_, has = v["redact"]
+		if has {
+			if redactedColumns == nil {
+				redactedColumns = make(map[string]bool)
+			}
+			redactedColumns[k] = true
+		}
+	}
+	for name, columns := range uniqueIndices {
+		ordered := make([]int, 0, len(columns))
+		for position := range columns {
+			ordered = append(ordered, position)
+		}
+		sort.Ints(ordered)
+		uniqueIndicesSimple[name] = make([]string, len(ordered))
+		for i, position := range ordered {
+			uniqueIndicesSimple[name][i] = columns[position]
+		}
 	}
 	for _, ref := range oneRefs {
 		has := false
@@ -421,33 +716,87 @@ func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema,
 	}
 	fields := buildTableFields(entityType, 1, "", tags)
 	columns := fields.getColumnNames()
+	enums := make(map[string]Enum)
+	for column, attributes := range tags {
+		for _, key := range []string{"enum", "set"} {
+			enum, has := registry.enums[attributes[key]]
+			if has {
+				enums[column] = enum
+			}
+		}
+	}
 	fieldsQuery := ""
+	uuidColumns := make(map[string]bool)
+	uuidReferences := make(map[string]string)
 	for _, column := range columns {
-		fieldsQuery += ",`" + column + "`"
+		uuid, isUUID := tags[column]["uuidBinary"]
+		ref := tags[column]["ref"]
+		if isUUID && ref != "" {
+			_, hasCascade := tags[column]["cascade"]
+			_, hasOnDelete := tags[column]["onDelete"]
+			if hasCascade || hasOnDelete {
+				return nil, errors.NotSupportedf("on delete action of uuidBinary reference %s in %s", column, entityType.String())
+			}
+			key, ordered := getUUIDKey(extractTags(registry, registry.entities[ref], ""))
+			if key == "" {
+				return nil, errors.NotValidf("uuidBinary reference %s in %s, %s has no unique uuidBinary field", column, entityType.String(), ref)
+			}
+			uuidReferences[column] = key
+			uuidColumns[column] = ordered
+			fieldsQuery += ",LOWER(HEX(`" + column + "`))"
+			continue
+		}
+		if isUUID {
+			uuidColumns[column] = uuid == "ordered"
+			fieldsQuery += ",LOWER(HEX(`" + column + "`))"
+		} else {
+			fieldsQuery += ",`" + column + "`"
+		}
 	}
 	columnsStamp := fmt.Sprintf("%d", fnv1a.HashString32(fieldsQuery))
+	uuidKey, _ := getUUIDKey(tags)
 
 	tableSchema := &tableSchema{tableName: table,
-		mysqlPoolName:    mysql,
-		t:                entityType,
-		fields:           fields,
-		fieldsQuery:      fieldsQuery[1:],
-		tags:             tags,
-		columnNames:      columns,
-		columnsStamp:     columnsStamp,
-		cachedIndexes:    cachedQueries,
-		cachedIndexesOne: cachedQueriesOne,
-		cachedIndexesAll: cachedQueriesAll,
-		localCacheName:   localCache,
-		redisCacheName:   redisCache,
-		refOne:           oneRefs,
-		cachePrefix:      cachePrefix,
-		uniqueIndices:    uniqueIndicesSimple,
-		hasFakeDelete:    hasFakeDelete,
-		hasLog:           logPoolName != "",
-		logPoolName:      logPoolName,
-		logTableName:     fmt.Sprintf("_log_%s_%s", mysql, table),
-		skipLogs:         skipLogs}
+		mysqlPoolName:        mysql,
+		t:                    entityType,
+		fields:               fields,
+		fieldsQuery:          fieldsQuery[1:],
+		tags:                 tags,
+		tagName:              registry.getTagName(),
+		columnNames:          columns,
+		columnsStamp:         columnsStamp,
+		cachedIndexes:        cachedQueries,
+		cachedIndexesOne:     cachedQueriesOne,
+		cachedIndexesAll:     cachedQueriesAll,
+		localCacheName:       localCache,
+		redisCacheName:       redisCache,
+		redisCacheTTL:        redisCacheTTL,
+		redisCacheRefresh:    redisCacheRefresh,
+		refOne:               oneRefs,
+		cachePrefix:          cachePrefix,
+		uniqueIndices:        uniqueIndicesSimple,
+		timeLocation:         registry.timeLocation,
+		uuidColumns:          uuidColumns,
+		uuidKey:              uuidKey,
+		uuidReferences:       uuidReferences,
+		enums:                enums,
+		collections:          collections,
+		hasFakeDelete:        hasFakeDelete,
+		hasLog:               logPoolName != "",
+		logPoolName:          logPoolName,
+		logTableName:         fmt.Sprintf("_log_%s_%s", mysql, table),
+		logClickHouse:        logClickHouse,
+		logWriter:            logWriter,
+		logExternal:          logExternal,
+		logRetentionDays:     logRetentionDays,
+		logRetentionVersions: logRetentionVersions,
+		skipLogs:             skipLogs,
+		redactedColumns:      redactedColumns,
+		shard:                shard,
+		hasTableSuffix:       hasTableSuffix,
+		tableSuffixPattern:   tableSuffixPattern,
+		hasChanges:           hasChanges,
+		idGenerator:          idGenerator}
 
 	all := make(map[string]map[int]string)
 	for k, v := range uniqueIndices {
@@ -535,24 +884,66 @@ func initTableSchema(registry *Registry, entityType reflect.Type) (*tableSchema,
 		if !ok {
 			return nil, errors.Errorf("missing index for cached query '%s' in %s", k, entityType.String())
 		}
+		if v.Composite {
+			for _, field := range v.QueryFields {
+				if field == "FakeDelete" {
+					continue
+				}
+				ok = false
+				for _, columns := range all {
+					if columns[1] == field {
+						ok = true
+						break
+					}
+				}
+				if !ok {
+					return nil, errors.Errorf("missing index for field %s in composite cached query '%s' in %s", field, k, entityType.String())
+				}
+			}
+		}
 	}
 	return tableSchema, nil
 }
 
+func checkCompositeQuery(query string, fieldsQuery []string, fieldsOrder []string) error {
+	if len(fieldsOrder) > 1 || (len(fieldsOrder) == 1 && fieldsOrder[0] != "ID") {
+		return errors.NotSupportedf("composite order other than ID")
+	}
+	conditions := 0
+	for _, field := range fieldsQuery {
+		if field == "FakeDelete" {
+			continue
+		}
+		if !strings.Contains(query, fmt.Sprintf("`%s` = ?", field)) {
+			return errors.NotSupportedf("composite condition for field %s other than equal", field)
+		}
+		conditions++
+	}
+	if conditions == 0 {
+		return errors.NotValidf("composite without conditions")
+	}
+	return nil
+}
+
 func buildTableFields(t reflect.Type, start int, prefix string, schemaTags map[string]map[string]string) *tableFields {
 	fields := &tableFields{t: t, prefix: prefix, uintegers: make([]int, 0), integers: make([]int, 0), strings: make([]int, 0),
 		fields: make(map[int]reflect.StructField), sliceStrings: make([]int, 0),
-		bytes: make([]int, 0), booleans: make([]int, 0), floats: make([]int, 0), timesNullable: make([]int, 0), times: make([]int, 0),
+		bytes: make([]int, 0), booleans: make([]int, 0), floats: make([]int, 0),
+		decimals: make([]int, 0), decimalsNullable: make([]int, 0), valuers: make([]int, 0), uuids: make(map[int]bool), uuidRefs: make(map[int]bool), timesNullable: make([]int, 0), times: make([]int, 0),
 		jsons: make([]int, 0), structs: make(map[int]*tableFields), refs: make([]int, 0), refsTypes: make([]reflect.Type, 0)}
 	for i := start; i < t.NumField(); i++ {
 		f := t.Field(i)
 		fields.fields[i] = f
-		tags := schemaTags[f.Name]
+		tags := schemaTags[prefix+f.Name]
 		typeName := f.Type.String()
 		_, has := tags["ignore"]
 		if has {
 			continue
 		}
+		_, has = tags["lazy"]
+		if has {
+			continue
+		}
 		switch typeName {
 		case "uint",
 			"uint8",
@@ -568,6 +959,10 @@ func buildTableFields(t reflect.Type, start int, prefix string, schemaTags map[s
 			fields.integers = append(fields.integers, i)
 		case "string":
 			fields.strings = append(fields.strings, i)
+			uuid, isUUID := tags["uuidBinary"]
+			if isUUID {
+				fields.uuids[i] = uuid == "ordered"
+			}
 		case "[]string":
 			fields.sliceStrings = append(fields.sliceStrings, i)
 		case "[]uint8":
@@ -581,6 +976,10 @@ func buildTableFields(t reflect.Type, start int, prefix string, schemaTags map[s
 		case "float32",
 			"float64":
 			fields.floats = append(fields.floats, i)
+		case "decimal.Decimal":
+			fields.decimals = append(fields.decimals, i)
+		case "*decimal.Decimal":
+			fields.decimalsNullable = append(fields.decimalsNullable, i)
 		case "*time.Time":
 			fields.timesNullable = append(fields.timesNullable, i)
 		case "time.Time":
@@ -589,13 +988,20 @@ func buildTableFields(t reflect.Type, start int, prefix string, schemaTags map[s
 			fields.jsons = append(fields.jsons, i)
 		default:
 			k := f.Type.Kind().String()
-			if k == "struct" {
-				fields.structs[i] = buildTableFields(f.Type, 0, f.Name, schemaTags)
+			if isValuerType(f.Type) {
+				fields.valuers = append(fields.valuers, i)
+			} else if k == "struct" {
+				fields.structs[i] = buildTableFields(f.Type, 0, embeddedPrefix(f.Name, tags), schemaTags)
+				fields.structs[i].embedded = true
 			} else if k == "ptr" {
 				modelType := reflect.TypeOf((*Entity)(nil)).Elem()
 				if f.Type.Implements(modelType) {
 					fields.refs = append(fields.refs, i)
 					fields.refsTypes = append(fields.refsTypes, f.Type)
+					_, isUUID := tags["uuidBinary"]
+					if isUUID {
+						fields.uuidRefs[i] = true
+					}
 				}
 			}
 		}
@@ -603,6 +1009,23 @@ func buildTableFields(t reflect.Type, start int, prefix string, schemaTags map[s
 	return fields
 }
 
+// getUUIDKey returns unique uuidBinary field, uuidBinary references to entity store value of this field
+func getUUIDKey(tags map[string]map[string]string) (field string, ordered bool) {
+	fields := make([]string, 0)
+	for name, attributes := range tags {
+		_, isUUID := attributes["uuidBinary"]
+		_, isUnique := attributes["unique"]
+		if isUUID && isUnique && attributes["ref"] == "" {
+			fields = append(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return "", false
+	}
+	sort.Strings(fields)
+	return fields[0], tags[fields[0]]["uuidBinary"] == "ordered"
+}
+
 func extractTags(registry *Registry, entityType reflect.Type, prefix string) (fields map[string]map[string]string) {
 	fields = make(map[string]map[string]string)
 	for i := 0; i < entityType.NumField(); i++ {
@@ -610,10 +1033,26 @@ func extractTags(registry *Registry, entityType reflect.Type, prefix string) (fi
 		for k, v := range extractTag(registry, field) {
 			fields[prefix+k] = v
 		}
-		_, hasIgnore := fields[field.Name]["ignore"]
+		_, hasIgnore := fields[prefix+field.Name]["ignore"]
 		if hasIgnore {
 			continue
 		}
+		if field.PkgPath != "" {
+			if fields[prefix+field.Name] == nil {
+				fields[prefix+field.Name] = make(map[string]string)
+			}
+			fields[prefix+field.Name]["ignore"] = "true"
+			continue
+		}
+		if registry.unsupportedFieldsPolicy == UnsupportedFieldsSkip && !isSupportedField(registry, field) {
+			if fields[prefix+field.Name] == nil {
+				fields[prefix+field.Name] = make(map[string]string)
+			}
+			fields[prefix+field.Name]["ignore"] = "true"
+			registry.getLogger().WithFields(apexLog.Fields{"struct": entityType.String(), "field": prefix + field.Name,
+				"type": field.Type.String()}).Warn("[ORM][SCHEMA][SKIPPED]")
+			continue
+		}
 		refOne := ""
 		hasRef := false
 		if field.Type.Kind().String() == "ptr" {
@@ -649,7 +1088,7 @@ func extractTags(registry *Registry, entityType reflect.Type, prefix string) (fi
 }
 
 func extractTag(registry *Registry, field reflect.StructField) map[string]map[string]string {
-	tag, ok := field.Tag.Lookup("orm")
+	tag, ok := field.Tag.Lookup(registry.getTagName())
 	if ok {
 		args := strings.Split(tag, ";")
 		length := len(args)
@@ -662,16 +1101,85 @@ func extractTag(registry *Registry, field reflect.StructField) map[string]map[st
 				attributes[arg[0]] = arg[1]
 			}
 		}
-		return map[string]map[string]string{field.Name: attributes}
-	} else if field.Type.Kind().String() == "struct" {
-		t := field.Type.String()
-		if t != "orm.ORM" && t != "time.Time" {
-			return extractTags(registry, field.Type, field.Name)
+		if isEmbeddedStruct(field) {
+			fields := extractTags(registry, field.Type, embeddedPrefix(field.Name, attributes))
+			fields[field.Name] = attributes
+			return fields
 		}
+		return map[string]map[string]string{field.Name: attributes}
+	} else if isEmbeddedStruct(field) {
+		return extractTags(registry, field.Type, field.Name)
 	}
 	return make(map[string]map[string]string)
 }
 
+func isEmbeddedStruct(field reflect.StructField) bool {
+	if field.Type.Kind() != reflect.Struct {
+		return false
+	}
+	t := field.Type.String()
+	return t != "orm.ORM" && t != "time.Time" && !isValuerType(field.Type)
+}
+
+func isValuerType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	ptr := reflect.PtrTo(t)
+	return (t.Implements(valuerType) || ptr.Implements(valuerType)) && ptr.Implements(scannerType)
+}
+
+// simpleFieldTypes lists field types mapped by checkColumn without looking at other entities
+var simpleFieldTypes = map[string]bool{
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"bool": true, "string": true, "[]string": true, "interface {}": true,
+	"float32": true, "float64": true, "decimal.Decimal": true, "*decimal.Decimal": true,
+	"time.Time": true, "*time.Time": true, "[]uint8": true, "*orm.CachedQuery": true,
+}
+
+func isSupportedField(registry *Registry, field reflect.StructField) bool {
+	if simpleFieldTypes[field.Type.String()] || isValuerType(field.Type) || field.Type.Kind() == reflect.Struct {
+		return true
+	}
+	if field.Type.Kind() == reflect.Ptr {
+		_, has := registry.entities[field.Type.Elem().String()]
+		return has
+	}
+	return false
+}
+
+func embeddedPrefix(name string, attributes map[string]string) string {
+	prefix, has := attributes["prefix"]
+	if !has {
+		return name
+	}
+	if prefix == "-" {
+		return ""
+	}
+	return prefix
+}
+
+func (tableSchema *tableSchema) validateEnumValues(column string, values ...string) error {
+	enum, has := tableSchema.enums[column]
+	if !has {
+		return nil
+	}
+	for _, value := range values {
+		if !enum.Has(value) {
+			return &InvalidEnumValueError{Entity: tableSchema.t.String(), Field: column, Value: value, Allowed: enum.GetFields()}
+		}
+	}
+	return nil
+}
+
+func (tableSchema *tableSchema) checkEnumValues(column string, values ...string) {
+	err := tableSchema.validateEnumValues(column, values...)
+	if err != nil {
+		panic(err)
+	}
+}
+
 func (tableSchema *tableSchema) getCacheKey(id uint64) string {
 	return tableSchema.cachePrefix + ":" + tableSchema.columnsStamp + ":" + strconv.FormatUint(id, 10)
 }
@@ -688,6 +1196,9 @@ func (fields *tableFields) getColumnNames() []string {
 	}
 	ids = append(ids, fields.booleans...)
 	ids = append(ids, fields.floats...)
+	ids = append(ids, fields.decimals...)
+	ids = append(ids, fields.decimalsNullable...)
+	ids = append(ids, fields.valuers...)
 	ids = append(ids, fields.timesNullable...)
 	ids = append(ids, fields.times...)
 	ids = append(ids, fields.jsons...)
@@ -696,8 +1207,17 @@ func (fields *tableFields) getColumnNames() []string {
 		name := fields.prefix + fields.fields[i].Name
 		columns = append(columns, name)
 	}
-	for _, subFields := range fields.structs {
-		columns = append(columns, subFields.getColumnNames()...)
+	for _, i := range fields.structsOrder() {
+		columns = append(columns, fields.structs[i].getColumnNames()...)
 	}
 	return columns
 }
+
+func (fields *tableFields) structsOrder() []int {
+	order := make([]int, 0, len(fields.structs))
+	for i := range fields.structs {
+		order = append(order, i)
+	}
+	sort.Ints(order)
+	return order
+}
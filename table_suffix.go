@@ -0,0 +1,113 @@
+package orm
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// defaultTableSuffixPattern matches date suffixes like 2024_05, other suffixes require pattern in tag
+var defaultTableSuffixPattern = regexp.MustCompile(`^[0-9]+(_[0-9]+)*$`)
+
+type TableSuffixResolver func(schema TableSchema) string
+
+func (e *Engine) SetTableSuffixResolver(resolver TableSuffixResolver) {
+	e.tableSuffixResolver = resolver
+}
+
+func (tableSchema *tableSchema) getTableSuffix(engine *Engine) string {
+	if !tableSchema.hasTableSuffix || engine.tableSuffixResolver == nil {
+		return ""
+	}
+	suffix := engine.tableSuffixResolver(tableSchema)
+	if suffix != "" && !tableSchema.tableSuffixPattern.MatchString(suffix) {
+		panic(errors.NotValidf("table suffix '%s' in %s", suffix, tableSchema.t.String()))
+	}
+	return suffix
+}
+
+func getTableSuffixPattern(tag string, hasTableSuffix bool) (*regexp.Regexp, error) {
+	if !hasTableSuffix {
+		return nil, nil
+	}
+	if tag == "true" {
+		return defaultTableSuffixPattern, nil
+	}
+	pattern, err := regexp.Compile("^(?:" + tag + ")$")
+	if err != nil {
+		return nil, errors.NotValidf("table suffix pattern '%s'", tag)
+	}
+	return pattern, nil
+}
+
+func (tableSchema *tableSchema) getTableName(engine *Engine) string {
+	suffix := tableSchema.getTableSuffix(engine)
+	if suffix == "" {
+		return tableSchema.tableName
+	}
+	return tableSchema.tableName + "_" + suffix
+}
+
+func (tableSchema *tableSchema) withTableName(tableName string) *tableSchema {
+	if tableName == tableSchema.tableName {
+		return tableSchema
+	}
+	suffixSchema := *tableSchema
+	suffixSchema.tableName = tableName
+	suffixSchema.hasTableSuffix = false
+	return &suffixSchema
+}
+
+func (tableSchema *tableSchema) getCacheNamespace(engine *Engine) string {
+	pool := tableSchema.resolvePool(engine)
+	suffix := tableSchema.getTableSuffix(engine)
+	if suffix == "" {
+		return pool
+	}
+	return pool + "/" + suffix
+}
+
+func getSuffixTablesAlters(engine *Engine, tablesInDB map[string]map[string]bool, tablesInEntities map[string]map[string]bool) []Alter {
+	schemas := make([]*tableSchema, 0)
+	for _, t := range engine.registry.entities {
+		tableSchema := getTableSchema(engine.registry, t)
+		if tableSchema.hasTableSuffix {
+			schemas = append(schemas, tableSchema)
+		}
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return len(schemas[i].tableName) > len(schemas[j].tableName)
+	})
+	alters := make([]Alter, 0)
+	for _, tableSchema := range schemas {
+		pools := tableSchema.getMysqlPools()
+		tables := make(map[string]bool)
+		for _, poolName := range pools {
+			for table := range tablesInDB[poolName] {
+				if !tablesInEntities[poolName][table] && strings.HasPrefix(table, tableSchema.tableName+"_") &&
+					tableSchema.tableSuffixPattern.MatchString(strings.TrimPrefix(table, tableSchema.tableName+"_")) {
+					tables[table] = true
+				}
+			}
+		}
+		for table := range tables {
+			for _, poolName := range pools {
+				tablesInEntities[poolName][table] = true
+			}
+			suffixSchema := tableSchema.withTableName(table)
+			var has bool
+			var newAlters []Alter
+			if suffixSchema.shard != nil {
+				has, newAlters = getShardedSchemaChanges(engine, suffixSchema)
+			} else {
+				has, newAlters = getSchemaChanges(engine, suffixSchema)
+			}
+			if has {
+				alters = append(alters, newAlters...)
+			}
+		}
+	}
+	return alters
+}
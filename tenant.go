@@ -0,0 +1,90 @@
+package orm
+
+import (
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/juju/errors"
+)
+
+func (r *validatedRegistry) RegisterTenant(code string, templatePool string, databaseName string) error {
+	template, has := r.current().registry.sqlClients[templatePool]
+	if !has {
+		return errors.NotFoundf("mysql pool '%s'", templatePool)
+	}
+	if _, has := r.current().registry.sqlClients[code]; has {
+		return errors.AlreadyExistsf("mysql pool '%s'", code)
+	}
+	config, err := mysql.ParseDSN(template.dataSourceName)
+	if err != nil {
+		return errors.Annotatef(err, "invalid mysql pool '%s'", templatePool)
+	}
+	config.DBName = databaseName
+	tenant := &DBConfig{code: code, dataSourceName: config.FormatDSN(), databaseName: databaseName, options: template.options}
+	if template.readDataSourceName != "" {
+		config.InterpolateParams = true
+		tenant.readDataSourceName = config.FormatDSN()
+	}
+	return r.Extend(func(registry *Registry) {
+		registry.sqlClients[code] = tenant
+		if registry.tenantPools == nil {
+			registry.tenantPools = make(map[string]string)
+		}
+		registry.tenantPools[code] = templatePool
+		if isPoolOptional(registry.optionalPools, "mysql", templatePool) {
+			registry.SetPoolRequired("mysql:"+code, false)
+		}
+	})
+}
+
+func (r *validatedRegistry) CreateTenantEngine(code string) *Engine {
+	current := r.current()
+	templatePool, has := current.registry.tenantPools[code]
+	if !has {
+		panic(errors.NotFoundf("tenant '%s'", code))
+	}
+	engine := current.createEngine()
+	engine.tenant = code
+	engine.poolResolver = func(schema TableSchema) string {
+		if schema.(*tableSchema).mysqlPoolName == templatePool {
+			return code
+		}
+		return ""
+	}
+	return engine
+}
+
+func getTenantAlters(engine *Engine) []Alter {
+	templatePool := engine.registry.registry.tenantPools[engine.tenant]
+	pool := engine.GetMysql(engine.tenant)
+	tablesInEntities := make(map[string]bool)
+	alters := make([]Alter, 0)
+	for _, t := range engine.registry.entities {
+		schema := getTableSchema(engine.registry, t)
+		if schema.mysqlPoolName != templatePool || schema.shard != nil {
+			continue
+		}
+		tenantSchema := *schema
+		tenantSchema.mysqlPoolName = engine.tenant
+		tablesInEntities[schema.tableName] = true
+		if schema.hasLog && schema.logPoolName == templatePool {
+			tablesInEntities[schema.logTableName] = true
+		}
+		has, newAlters := getSchemaChanges(engine, &tenantSchema)
+		if has {
+			alters = append(alters, newAlters...)
+		}
+	}
+	for _, tableName := range getAllTables(pool.client) {
+		if tablesInEntities[tableName] {
+			continue
+		}
+		dropForeignKeyAlter := getDropForeignKeysAlter(engine, tableName, engine.tenant)
+		if dropForeignKeyAlter != "" {
+			alters = append(alters, Alter{SQL: dropForeignKeyAlter, Safe: true, Pool: engine.tenant})
+		}
+		dropSQL := fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s`;", pool.GetDatabaseName(), tableName)
+		alters = append(alters, Alter{SQL: dropSQL, Safe: isTableEmptyInPool(engine, engine.tenant, tableName), Pool: engine.tenant})
+	}
+	return sortAlters(alters)
+}
@@ -0,0 +1,103 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apexLog "github.com/apex/log"
+)
+
+type Tracer interface {
+	StartSpan(ctx context.Context, operation string, start time.Time) (span TracerSpan, spanCtx context.Context)
+}
+
+type TracerSpan interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End(finish time.Time)
+}
+
+type tracerHandler struct {
+	engine *Engine
+}
+
+var tracerSystems = map[string]string{"mysql": "mysql", "redis": "redis", "locker": "redis", "rabbitMQ": "rabbitmq",
+	"elastic": "elasticsearch", "clickhouse": "clickhouse", "local_cache": "local_cache"}
+
+func (h *tracerHandler) HandleLog(e *apexLog.Entry) error {
+	target, _ := e.Fields.Get("target").(string)
+	operation, _ := e.Fields.Get("operation").(string)
+	if operation == "" {
+		operation, _ = e.Fields.Get("type").(string)
+	}
+	started := e.Timestamp
+	asInt, ok := e.Fields.Get("started").(int64)
+	if ok {
+		started = time.Unix(0, asInt)
+	}
+	finished := e.Timestamp
+	asInt, ok = e.Fields.Get("finished").(int64)
+	if ok {
+		finished = time.Unix(0, asInt)
+	}
+	span, _ := h.engine.registry.tracer.StartSpan(h.engine.getTracerContext(), target+"."+operation, started)
+	span.SetAttribute("db.system", tracerSystems[target])
+	span.SetAttribute("db.operation", operation)
+	for key, value := range e.Fields {
+		switch key {
+		case "Query":
+			span.SetAttribute("db.statement", value)
+		case "pool", "db", "misses", "keys", "Queue", "Router", "Key", "Index", "rows":
+			span.SetAttribute("orm."+key, value)
+		}
+	}
+	err := e.Fields.Get("error")
+	if err != nil {
+		asErr, is := err.(error)
+		if !is {
+			asErr = fmt.Errorf("%v", err)
+		}
+		span.RecordError(asErr)
+	}
+	span.End(finished)
+	return nil
+}
+
+func (e *Engine) SetTracerContext(ctx context.Context) {
+	e.tracerContext = ctx
+}
+
+func (e *Engine) getTracerContext() context.Context {
+	if e.tracerContext == nil {
+		return context.Background()
+	}
+	return e.tracerContext
+}
+
+func (e *Engine) startTracerSpan(operation string) TracerSpan {
+	if e.registry.tracer == nil {
+		return nil
+	}
+	span, ctx := e.registry.tracer.StartSpan(e.getTracerContext(), operation, time.Now())
+	e.tracerContextStack = append(e.tracerContextStack, e.tracerContext)
+	e.tracerContext = ctx
+	return span
+}
+
+func (e *Engine) finishTracerSpan(span TracerSpan, err interface{}) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		asErr, is := err.(error)
+		if !is {
+			asErr = fmt.Errorf("%v", err)
+		}
+		span.RecordError(asErr)
+	}
+	span.End(time.Now())
+	last := len(e.tracerContextStack) - 1
+	e.tracerContext = e.tracerContextStack[last]
+	e.tracerContextStack = e.tracerContextStack[:last]
+}
@@ -0,0 +1,55 @@
+package orm
+
+import "reflect"
+
+type entityTracker struct {
+	entities []Entity
+	index    map[interface{}]int
+}
+
+type trackedEntityKey struct {
+	t  reflect.Type
+	id uint64
+}
+
+func (t *entityTracker) add(entity Entity, deduplicate bool) bool {
+	if !deduplicate {
+		t.entities = append(t.entities, entity)
+		return true
+	}
+	if t.index == nil {
+		t.index = make(map[interface{}]int)
+	}
+	_, has := t.index[entity]
+	if has {
+		return false
+	}
+	orm := entity.getORM()
+	var key *trackedEntityKey
+	if orm.attributes.loaded && orm.GetID() > 0 {
+		key = &trackedEntityKey{orm.tableSchema.t, orm.GetID()}
+		position, has := t.index[*key]
+		if has {
+			delete(t.index, t.entities[position])
+			t.entities[position] = entity
+			t.index[entity] = position
+			return false
+		}
+	}
+	position := len(t.entities)
+	t.entities = append(t.entities, entity)
+	t.index[entity] = position
+	if key != nil {
+		t.index[*key] = position
+	}
+	return true
+}
+
+func (t *entityTracker) len() int {
+	return len(t.entities)
+}
+
+func (t *entityTracker) clear() {
+	t.entities = nil
+	t.index = nil
+}
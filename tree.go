@@ -0,0 +1,138 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/juju/errors"
+)
+
+// maxTreeDepth limits number of levels loaded, also when tree has cycles
+const maxTreeDepth = 100
+
+func loadAncestors(engine *Engine, entity Entity, field string, ancestors interface{}) {
+	orm := initIfNeeded(engine, entity)
+	schema := orm.tableSchema
+	checkTreeField(schema, field)
+	value := orm.attributes.elem.FieldByName(field)
+	if value.IsNil() {
+		reflect.ValueOf(ancestors).Elem().SetLen(0)
+		return
+	}
+	parentID := value.Elem().Field(1).Uint()
+	db := schema.GetMysql(engine)
+	fakeDelete := ""
+	if schema.hasFakeDelete {
+		fakeDelete = " AND `FakeDelete` = 0"
+	}
+	if db.supportsRecursiveQueries() {
+		/* #nosec */
+		query := fmt.Sprintf("WITH RECURSIVE `tree` AS (SELECT `ID`, `%[1]s`, 1 AS `Depth` FROM `%[2]s` WHERE `ID` = ?%[3]s "+
+			"UNION ALL SELECT t.`ID`, t.`%[1]s`, tree.`Depth` + 1 FROM `%[2]s` t JOIN `tree` ON t.`ID` = tree.`%[1]s` "+
+			"WHERE tree.`Depth` < ?%[3]s) SELECT `ID` FROM `tree` ORDER BY `Depth`", field, schema.getTableName(engine), fakeDelete)
+		engine.LoadByIDs(queryTreeIDs(db, query, parentID, maxTreeDepth), ancestors)
+		return
+	}
+	ids := make([]uint64, 0)
+	visited := make(map[uint64]bool)
+	for parentID > 0 && !visited[parentID] && len(ids) < maxTreeDepth {
+		visited[parentID] = true
+		parent := reflect.New(schema.t).Interface().(Entity)
+		if !engine.LoadByID(parentID, parent) {
+			break
+		}
+		if schema.hasFakeDelete && reflect.ValueOf(parent).Elem().FieldByName("FakeDelete").Bool() {
+			break
+		}
+		ids = append(ids, parentID)
+		parentID = 0
+		value = reflect.ValueOf(parent).Elem().FieldByName(field)
+		if !value.IsNil() {
+			parentID = value.Elem().Field(1).Uint()
+		}
+	}
+	engine.LoadByIDs(ids, ancestors)
+}
+
+func loadDescendants(engine *Engine, entity Entity, field string, descendants interface{}) {
+	orm := initIfNeeded(engine, entity)
+	schema := orm.tableSchema
+	checkTreeField(schema, field)
+	id := orm.GetID()
+	if id == 0 {
+		reflect.ValueOf(descendants).Elem().SetLen(0)
+		return
+	}
+	indexName, hasCollection := schema.collections[schema.t.String()]
+	if hasCollection && schema.cachedIndexes[indexName].QueryFields[0] == field {
+		engine.LoadByIDs(collectDescendants(id, func(parents []uint64) []uint64 {
+			ids := make([]uint64, 0)
+			for _, parentID := range parents {
+				_, children := cachedSearch(engine, entity, indexName, nil, []interface{}{parentID}, nil)
+				ids = append(ids, children...)
+			}
+			return ids
+		}), descendants)
+		return
+	}
+	db := schema.GetMysql(engine)
+	fakeDelete := ""
+	if schema.hasFakeDelete {
+		fakeDelete = " AND `FakeDelete` = 0"
+	}
+	if db.supportsRecursiveQueries() {
+		/* #nosec */
+		query := fmt.Sprintf("WITH RECURSIVE `tree` AS (SELECT `ID`, 1 AS `Depth` FROM `%[2]s` WHERE `%[1]s` = ?%[3]s "+
+			"UNION ALL SELECT t.`ID`, tree.`Depth` + 1 FROM `%[2]s` t JOIN `tree` ON t.`%[1]s` = tree.`ID` WHERE tree.`Depth` < ?%[3]s) "+
+			"SELECT `ID` FROM `tree` ORDER BY `Depth`, `ID`", field, schema.getTableName(engine), fakeDelete)
+		engine.LoadByIDs(queryTreeIDs(db, query, id, maxTreeDepth), descendants)
+		return
+	}
+	engine.LoadByIDs(collectDescendants(id, func(parents []uint64) []uint64 {
+		/* #nosec */
+		query := fmt.Sprintf("SELECT `ID` FROM `%s` WHERE `%s` IN ?%s ORDER BY `ID`", schema.getTableName(engine), field, fakeDelete)
+		where := NewWhere(query, parents)
+		return queryTreeIDs(db, where.String(), where.GetParameters()...)
+	}), descendants)
+}
+
+func collectDescendants(id uint64, children func(parents []uint64) []uint64) []uint64 {
+	ids := make([]uint64, 0)
+	visited := map[uint64]bool{id: true}
+	level := []uint64{id}
+	for depth := 0; len(level) > 0 && depth < maxTreeDepth; depth++ {
+		next := make([]uint64, 0)
+		for _, childID := range children(level) {
+			if !visited[childID] {
+				visited[childID] = true
+				next = append(next, childID)
+			}
+		}
+		ids = append(ids, next...)
+		level = next
+	}
+	return ids
+}
+
+// queryTreeIDs returns unique IDs in query order, rows scan and close errors panic
+func queryTreeIDs(db *DB, query string, args ...interface{}) []uint64 {
+	results, def := db.Query(query, args...)
+	defer def()
+	ids := make([]uint64, 0)
+	unique := make(map[uint64]bool)
+	for results.Next() {
+		var id uint64
+		results.Scan(&id)
+		if !unique[id] {
+			unique[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func checkTreeField(schema *tableSchema, field string) {
+	if schema.tags[field]["ref"] != schema.t.String() {
+		panic(errors.NotValidf("self reference %s in %s", field, schema.t.String()))
+	}
+}
@@ -0,0 +1,113 @@
+package orm
+
+import (
+	"testing"
+
+	apexLog "github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+type treeCategory struct {
+	ORM
+	ID     uint
+	Name   string
+	Parent *treeCategory
+}
+
+type treeCachedCategory struct {
+	ORM    `orm:"localCache;cached_collection=Parent"`
+	ID     uint
+	Name   string
+	Parent *treeCachedCategory
+}
+
+func TestCollectDescendants(t *testing.T) {
+	tree := map[uint64][]uint64{1: {2, 3}, 2: {4}, 4: {1, 5}}
+	ids := collectDescendants(1, func(parents []uint64) []uint64 {
+		children := make([]uint64, 0)
+		for _, parent := range parents {
+			children = append(children, tree[parent]...)
+		}
+		return children
+	})
+	assert.Equal(t, []uint64{2, 3, 4, 5}, ids)
+
+	ids = collectDescendants(1, func(parents []uint64) []uint64 {
+		return []uint64{parents[0] + 1}
+	})
+	assert.Len(t, ids, maxTreeDepth)
+	assert.Equal(t, uint64(maxTreeDepth+1), ids[maxTreeDepth-1])
+}
+
+func TestSupportsRecursiveQueries(t *testing.T) {
+	assert.True(t, (&DB{version: "8.0.21"}).supportsRecursiveQueries())
+	assert.False(t, (&DB{version: "5.7.30-log"}).supportsRecursiveQueries())
+	assert.True(t, (&DB{version: "10.5.8-MariaDB-1:10.5.8+maria~focal"}).supportsRecursiveQueries())
+	assert.False(t, (&DB{version: "10.1.48-MariaDB"}).supportsRecursiveQueries())
+	assert.False(t, (&DB{}).supportsRecursiveQueries())
+}
+
+func TestTree(t *testing.T) {
+	var entity *treeCategory
+	engine := PrepareTables(t, &Registry{}, entity)
+	root := &treeCategory{Name: "Root"}
+	engine.TrackAndFlush(root)
+	child := &treeCategory{Name: "Child", Parent: root}
+	sibling := &treeCategory{Name: "Sibling", Parent: root}
+	engine.TrackAndFlush(child, sibling)
+	leaf := &treeCategory{Name: "Leaf", Parent: child}
+	engine.TrackAndFlush(leaf)
+
+	version := engine.GetMysql().version
+	for _, v := range []string{version, "5.7.0"} {
+		engine.GetMysql().version = v
+		var rows []*treeCategory
+		engine.LoadAncestors(leaf, "Parent", &rows)
+		assert.Len(t, rows, 2)
+		assert.Equal(t, "Child", rows[0].Name)
+		assert.Equal(t, "Root", rows[1].Name)
+
+		engine.LoadAncestors(root, "Parent", &rows)
+		assert.Len(t, rows, 0)
+
+		engine.LoadDescendants(root, "Parent", &rows)
+		assert.Len(t, rows, 3)
+		assert.Equal(t, "Child", rows[0].Name)
+		assert.Equal(t, "Sibling", rows[1].Name)
+		assert.Equal(t, "Leaf", rows[2].Name)
+
+		engine.LoadDescendants(leaf, "Parent", &rows)
+		assert.Len(t, rows, 0)
+	}
+	engine.GetMysql().version = version
+
+	assert.Panics(t, func() {
+		engine.LoadDescendants(root, "Name", &[]*treeCategory{})
+	})
+}
+
+func TestTreeCached(t *testing.T) {
+	var entity *treeCachedCategory
+	engine := PrepareTables(t, &Registry{}, entity)
+	root := &treeCachedCategory{Name: "Root"}
+	engine.TrackAndFlush(root)
+	child := &treeCachedCategory{Name: "Child", Parent: root}
+	engine.TrackAndFlush(child)
+	engine.TrackAndFlush(&treeCachedCategory{Name: "Leaf", Parent: child})
+
+	var rows []*treeCachedCategory
+	engine.LoadDescendants(root, "Parent", &rows)
+	assert.Len(t, rows, 2)
+
+	DBLogger := memory.New()
+	engine.AddQueryLogger(DBLogger, apexLog.InfoLevel, QueryLoggerSourceDB)
+	engine.LoadDescendants(root, "Parent", &rows)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "Leaf", rows[1].Name)
+	assert.Len(t, DBLogger.Entries, 0)
+
+	engine.TrackAndFlush(&treeCachedCategory{Name: "Leaf 2", Parent: child})
+	engine.LoadDescendants(root, "Parent", &rows)
+	assert.Len(t, rows, 3)
+}
@@ -0,0 +1,63 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+)
+
+func truncateTables(engine *Engine, schemas []*tableSchema) {
+	clearedLocalCaches := make(map[string]bool)
+	for _, schema := range schemas {
+		schema.TruncateTable(engine)
+		if schema.hasLog && !schema.logExternal {
+			if schema.logClickHouse != "" {
+				_ = engine.GetClickHouse(schema.logClickHouse).Exec(fmt.Sprintf("TRUNCATE TABLE IF EXISTS `%s`", schema.logTableName))
+			} else {
+				pool := engine.GetMysql(schema.logPoolName)
+				_ = pool.Exec(fmt.Sprintf("TRUNCATE TABLE `%s`.`%s`;", pool.GetDatabaseName(), schema.logTableName))
+			}
+		}
+		clearEntityCache(engine, schema, clearedLocalCaches)
+	}
+}
+
+func clearEntityCache(engine *Engine, schema *tableSchema, clearedLocalCaches map[string]bool) {
+	localCache, has := schema.GetLocalCache(engine)
+	if has && !clearedLocalCaches[localCache.code] {
+		localCache.Clear()
+		clearedLocalCaches[localCache.code] = true
+	}
+	redisCache, has := schema.GetRedisCache(engine)
+	if has {
+		keys := redisCache.Keys(schema.cachePrefix + ":*")
+		for indexName := range schema.cachedIndexes {
+			keys = append(keys, redisCache.Keys(schema.cachePrefix+"_"+indexName+"_*")...)
+		}
+		if len(keys) > 0 {
+			redisCache.Del(keys...)
+		}
+	}
+}
+
+func truncateEntities(engine *Engine, entities []Entity) {
+	truncateTables(engine, getEntitiesSchemas(engine, entities))
+}
+
+func getEntitiesSchemas(engine *Engine, entities []Entity) []*tableSchema {
+	schemas := make([]*tableSchema, len(entities))
+	for i, entity := range entities {
+		schemas[i] = getTableSchema(engine.registry, reflect.TypeOf(entity).Elem())
+		if schemas[i] == nil {
+			panic(EntityNotRegisteredError{Name: reflect.TypeOf(entity).Elem().String()})
+		}
+	}
+	return schemas
+}
+
+func truncateAll(engine *Engine) {
+	schemas := make([]*tableSchema, 0, len(engine.registry.tableSchemas))
+	for _, schema := range engine.registry.tableSchemas {
+		schemas = append(schemas, schema)
+	}
+	truncateTables(engine, schemas)
+}
@@ -0,0 +1,127 @@
+package orm
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+func updateByWhere(engine *Engine, entity Entity, set map[string]interface{}, where *Where, chunkSize int) int64 {
+	schema := initIfNeeded(engine, entity).tableSchema
+	columns := make([]string, 0, len(set))
+	for column := range set {
+		if column == "ID" || column == "FakeDelete" || !hasColumn(schema, column) {
+			panic(errors.NotValidf("column '%s' in %s", column, schema.t.String()))
+		}
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return 0
+	}
+	sort.Strings(columns)
+	assignments := make([]string, len(columns))
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		assignments[i] = fmt.Sprintf("`%s` = %s", column, schema.placeholder(column))
+		values[i] = set[column]
+	}
+	if chunkSize <= 0 {
+		chunkSize = math.MaxInt32
+	}
+	// rows are always updated in ID order, so ORDER BY is ignored unless used with LIMIT
+	condition, orderBy, limit := where.splitTrailingClauses()
+	remaining := math.MaxInt32
+	if limit != nil {
+		if orderBy != nil {
+			panic(errors.NotSupportedf("ORDER BY with LIMIT in update by where"))
+		}
+		remaining = getUpdateByWhereLimit(limit)
+	}
+	db := schema.GetMysql(engine)
+	updated := int64(0)
+	lastID := uint64(0)
+	for remaining > 0 {
+		pageSize := chunkSize
+		if remaining < pageSize {
+			pageSize = remaining
+		}
+		/* #nosec */
+		chunkWhere := NewWhere(fmt.Sprintf("(%s) AND `ID` > ? ORDER BY `ID`", condition.String()), append(condition.GetParameters(), lastID)...)
+		chunkWhere.withFakeDeleted = where.withFakeDeleted
+		rows := reflect.New(reflect.SliceOf(reflect.PtrTo(schema.t)))
+		engine.Search(chunkWhere, NewPager(1, pageSize), rows.Interface())
+		total := rows.Elem().Len()
+		if total == 0 {
+			break
+		}
+		ids := make([]interface{}, total)
+		old := make(map[uint64]map[string]interface{}, total)
+		for i := 0; i < total; i++ {
+			row := rows.Elem().Index(i).Interface().(Entity)
+			ids[i] = row.GetID()
+			old[row.GetID()] = row.getORM().dBData
+		}
+		lastID = ids[total-1].(uint64)
+		remaining -= total
+
+		phase := startFlushPhase(engine, "update")
+		in := NewWhere("`ID` IN ?", ids)
+		/* #nosec */
+		sql := fmt.Sprintf("UPDATE `%s` SET %s WHERE %s", schema.getTableName(engine), strings.Join(assignments, ","), in)
+		updated += int64(db.exec(sql, schema.redactedPositions(columns),
+			append(values, in.GetParameters()...)).RowsAffected())
+
+		localCacheDeletes := make(map[string]map[string]bool)
+		redisKeysToDelete := make(map[string]map[string]bool)
+		dirtyQueues := make(map[string][]*DirtyQueueValue)
+		logQueues := make([]*LogQueueValue, 0)
+		localCache, hasLocalCache := schema.GetLocalCache(engine)
+		redisCache, hasRedis := schema.GetRedisCache(engine)
+		fresh := reflect.New(reflect.SliceOf(reflect.PtrTo(schema.t)))
+		engine.Search(in, NewPager(1, total), fresh.Interface())
+		for i := 0; i < fresh.Elem().Len(); i++ {
+			row := fresh.Elem().Index(i).Interface().(Entity)
+			id := row.GetID()
+			newData := row.getORM().dBData
+			bind := make(map[string]interface{}, len(columns))
+			for _, column := range columns {
+				bind[column] = newData[column]
+			}
+			if hasLocalCache {
+				addCacheDeletes(localCacheDeletes, localCache.code, schema.getCacheKey(id))
+			}
+			if hasRedis {
+				addCacheDeletes(redisKeysToDelete, redisCache.code, schema.getCacheKey(id))
+			}
+			addCacheQueriesDeletes(engine, schema, bind, old[id], false, localCacheDeletes, redisKeysToDelete)
+			addCacheQueriesDeletes(engine, schema, bind, newData, false, localCacheDeletes, redisKeysToDelete)
+			addDirtyQueues(dirtyQueues, bind, schema, id, "u")
+			addEntityChange(engine, schema, id, old[id], newData)
+			logQueues = addToLogQueue(logQueues, schema, id, old[id], bind, nil)
+		}
+		phase = flushCacheAndQueues(engine, phase, false, false, make(map[string]interface{}),
+			make(map[string]map[string][]interface{}), localCacheDeletes, redisKeysToDelete, dirtyQueues, logQueues)
+		phase.finish()
+		if total < pageSize {
+			break
+		}
+	}
+	return updated
+}
+
+func getUpdateByWhereLimit(limit *Where) int {
+	value := strings.TrimSpace(limit.String()[len("LIMIT"):])
+	if value == "?" && len(limit.GetParameters()) == 1 {
+		value = fmt.Sprintf("%v", limit.GetParameters()[0])
+	}
+	rows, err := strconv.Atoi(value)
+	if err != nil || rows < 0 {
+		panic(errors.NotSupportedf("%s in update by where", limit.String()))
+	}
+	return rows
+}
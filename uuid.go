@@ -0,0 +1,106 @@
+package orm
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+func UUIDToBinary(uuid string, ordered bool) []byte {
+	value, err := hex.DecodeString(uuidToHex(uuid, ordered))
+	if err != nil {
+		panic(errors.NotValidf("uuid '%s'", uuid))
+	}
+	return value
+}
+
+func uuidToHex(uuid string, ordered bool) string {
+	value := strings.ToLower(strings.ReplaceAll(uuid, "-", ""))
+	if len(value) != 32 {
+		panic(errors.NotValidf("uuid '%s'", uuid))
+	}
+	for _, c := range value {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			panic(errors.NotValidf("uuid '%s'", uuid))
+		}
+	}
+	if ordered {
+		value = value[12:16] + value[8:12] + value[0:8] + value[16:]
+	}
+	return value
+}
+
+func uuidFromHex(value string, ordered bool) string {
+	if len(value) != 32 {
+		return ""
+	}
+	value = strings.ToLower(value)
+	if ordered {
+		value = value[8:16] + value[4:8] + value[0:4] + value[16:]
+	}
+	return value[0:8] + "-" + value[8:12] + "-" + value[12:16] + "-" + value[16:20] + "-" + value[20:]
+}
+
+func (tableSchema *tableSchema) placeholder(column string) string {
+	_, has := tableSchema.uuidColumns[column]
+	if has {
+		return "UNHEX(?)"
+	}
+	return "?"
+}
+
+// fillUUIDReference sets not loaded reference with only UUID key, ID is known when reference is loaded
+func fillUUIDReference(engine *Engine, field reflect.Value, refType reflect.Type, value string) {
+	if value == "" {
+		field.Set(reflect.Zero(refType))
+		return
+	}
+	n := reflect.New(refType.Elem())
+	schema := initIfNeeded(engine, n.Interface().(Entity)).tableSchema
+	n.Elem().FieldByName(schema.uuidKey).SetString(uuidFromHex(value, schema.uuidColumns[schema.uuidKey]))
+	field.Set(n)
+}
+
+func getUUIDReferenceValue(schema *tableSchema, column string, reference reflect.Value) string {
+	uuid := reference.Elem().FieldByName(schema.uuidReferences[column]).String()
+	if uuid == "" {
+		panic(errors.NotValidf("reference %s in %s without %s", column, schema.t.String(), schema.uuidReferences[column]))
+	}
+	return uuidToHex(uuid, schema.uuidColumns[column])
+}
+
+func getUUIDKeyWhere(schema *tableSchema, uuids ...string) *Where {
+	ordered := schema.uuidColumns[schema.uuidKey]
+	values := make([]interface{}, len(uuids))
+	for i, uuid := range uuids {
+		values[i] = UUIDToBinary(uuid, ordered)
+	}
+	return NewWhere("`"+schema.uuidKey+"` IN ?", values)
+}
+
+func loadByUUIDKey(engine *Engine, entity Entity, references []string) bool {
+	orm := initIfNeeded(engine, entity)
+	uuid := orm.attributes.elem.FieldByName(orm.tableSchema.uuidKey).String()
+	return searchRow(false, engine, getUUIDKeyWhere(orm.tableSchema, uuid), entity, references)
+}
+
+func getUUIDKeyValue(schema *tableSchema, entity reflect.Value) string {
+	return uuidFromHex(uuidToHex(entity.Elem().FieldByName(schema.uuidKey).String(), false), false)
+}
+
+func warmUpUUIDReferences(engine *Engine, schema *tableSchema, refs map[string][]reflect.Value, references []string) {
+	uuids := make([]string, 0, len(refs))
+	for uuid := range refs {
+		uuids = append(uuids, uuid)
+	}
+	rows := reflect.New(reflect.SliceOf(reflect.PtrTo(schema.t)))
+	engine.Search(getUUIDKeyWhere(schema, uuids...), NewPager(1, len(uuids)), rows.Interface(), references...)
+	for i := 0; i < rows.Elem().Len(); i++ {
+		row := rows.Elem().Index(i)
+		for _, ref := range refs[getUUIDKeyValue(schema, row)] {
+			ref.Set(row)
+		}
+	}
+}
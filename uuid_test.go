@@ -0,0 +1,124 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type uuidEntity struct {
+	ORM    `orm:"redisCache"`
+	ID     uint
+	UUID   string `orm:"uuidBinary=ordered;unique=UUID;required"`
+	Parent string `orm:"uuidBinary"`
+}
+
+type uuidReferenceEntity struct {
+	ORM
+	ID     uint
+	Parent *uuidEntity `orm:"uuidBinary"`
+}
+
+type uuidPlainEntity struct {
+	ORM
+	ID   uint
+	Name string
+}
+
+type uuidInvalidReferenceEntity struct {
+	ORM
+	ID     uint
+	Parent *uuidPlainEntity `orm:"uuidBinary"`
+}
+
+type uuidCascadeReferenceEntity struct {
+	ORM
+	ID     uint
+	Parent *uuidEntity `orm:"uuidBinary;cascade"`
+}
+
+func TestUUIDConversion(t *testing.T) {
+	uuid := "6ccd780c-baba-1026-9564-5b8c656024db"
+	assert.Equal(t, "6ccd780cbaba102695645b8c656024db", uuidToHex(uuid, false))
+	assert.Equal(t, "1026baba6ccd780c95645b8c656024db", uuidToHex(uuid, true))
+	assert.Equal(t, uuid, uuidFromHex(uuidToHex(uuid, true), true))
+	assert.Equal(t, uuid, uuidFromHex("6CCD780CBABA102695645B8C656024DB", false))
+	assert.Len(t, UUIDToBinary(uuid, true), 16)
+	assert.Equal(t, "", uuidFromHex("", false))
+	assert.Panics(t, func() {
+		uuidToHex("invalid", false)
+	})
+
+	registry := &Registry{}
+	registry.RegisterMySQLPool("root:root@tcp(localhost:3310)/test")
+	registry.RegisterRedis("localhost:6380", 15)
+	registry.RegisterEntity(&uuidEntity{}, &uuidPlainEntity{})
+	schema, err := initTableSchema(registry, reflect.TypeOf(uuidReferenceEntity{}))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Parent": "UUID"}, schema.uuidReferences)
+	assert.True(t, schema.uuidColumns["Parent"])
+	assert.Equal(t, "UNHEX(?)", schema.placeholder("Parent"))
+	schema, err = initTableSchema(registry, reflect.TypeOf(uuidEntity{}))
+	assert.NoError(t, err)
+	assert.Equal(t, "UUID", schema.uuidKey)
+	_, err = initTableSchema(registry, reflect.TypeOf(uuidInvalidReferenceEntity{}))
+	assert.EqualError(t, err, "uuidBinary reference Parent in orm.uuidInvalidReferenceEntity, orm.uuidPlainEntity has no unique uuidBinary field not valid")
+	_, err = initTableSchema(registry, reflect.TypeOf(uuidCascadeReferenceEntity{}))
+	assert.EqualError(t, err, "on delete action of uuidBinary reference Parent in orm.uuidCascadeReferenceEntity not supported")
+}
+
+func TestUUIDBinary(t *testing.T) {
+	var entity *uuidEntity
+	engine := PrepareTables(t, &Registry{}, entity)
+
+	entity = &uuidEntity{UUID: "6CCD780C-BABA-1026-9564-5B8C656024DB"}
+	engine.TrackAndFlush(entity)
+	entity = &uuidEntity{}
+	assert.True(t, engine.LoadByID(1, entity))
+	assert.Equal(t, "6ccd780c-baba-1026-9564-5b8c656024db", entity.UUID)
+	assert.Equal(t, "", entity.Parent)
+	assert.False(t, engine.IsDirty(entity))
+
+	entity.Parent = "2d1f0b3e-6f51-4a5c-9c7a-1b2c3d4e5f60"
+	engine.TrackAndFlush(entity)
+
+	found := &uuidEntity{}
+	where := NewWhere("`UUID` = ?", UUIDToBinary("6ccd780c-baba-1026-9564-5b8c656024db", true))
+	assert.True(t, engine.SearchOne(where, found))
+	assert.Equal(t, "2d1f0b3e-6f51-4a5c-9c7a-1b2c3d4e5f60", found.Parent)
+}
+
+func TestUUIDReference(t *testing.T) {
+	var entity *uuidEntity
+	var reference *uuidReferenceEntity
+	engine := PrepareTables(t, &Registry{}, entity, reference)
+
+	entity = &uuidEntity{UUID: "6ccd780c-baba-1026-9564-5b8c656024db"}
+	reference = &uuidReferenceEntity{Parent: entity}
+	engine.TrackAndFlush(entity, reference)
+
+	reference = &uuidReferenceEntity{}
+	assert.True(t, engine.LoadByID(1, reference))
+	assert.Equal(t, uint(0), reference.Parent.ID)
+	assert.Equal(t, "6ccd780c-baba-1026-9564-5b8c656024db", reference.Parent.UUID)
+	assert.False(t, engine.IsDirty(reference))
+	engine.Load(reference.Parent)
+	assert.Equal(t, uint(1), reference.Parent.ID)
+
+	reference = &uuidReferenceEntity{}
+	assert.True(t, engine.LoadByID(1, reference, "Parent"))
+	assert.True(t, engine.Loaded(reference.Parent))
+	assert.Equal(t, uint(1), reference.Parent.ID)
+
+	var rows []*uuidReferenceEntity
+	engine.Search(NewWhere("`Parent` = ?", UUIDToBinary(entity.UUID, true)), nil, &rows, "Parent")
+	assert.Len(t, rows, 1)
+	assert.Equal(t, uint(1), rows[0].Parent.ID)
+
+	reference.Parent = nil
+	engine.TrackAndFlush(reference)
+	reference = &uuidReferenceEntity{}
+	assert.True(t, engine.LoadByID(1, reference))
+	assert.Nil(t, reference.Parent)
+}
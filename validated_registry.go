@@ -3,7 +3,10 @@ package orm
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 
@@ -23,8 +26,15 @@ type ValidatedRegistry interface {
 	GetTableSchema(entityName string) TableSchema
 	GetTableSchemaForEntity(entity Entity) TableSchema
 	GetDirtyQueues() map[string]int
+	GetEntities() map[string]reflect.Type
+	GetRabbitMQQueues() []string
 	RegisterRabbitMQQueue(config *RabbitMQQueueConfig, serverPool ...string)
 	RegisterRabbitMQRouter(config *RabbitMQRouterConfig, serverPool ...string)
+	Extend(callback func(r *Registry)) error
+	RegisterTenant(code string, templatePool string, databaseName string) error
+	CreateTenantEngine(code string) *Engine
+	EnginePool() *EnginePool
+	Close()
 }
 
 type validatedRegistry struct {
@@ -41,10 +51,19 @@ type validatedRegistry struct {
 	rabbitMQChannelsToQueue map[string]*rabbitMQChannelToQueue
 	rabbitMQRouterConfigs   map[string]*RabbitMQRouterConfig
 	lockServers             map[string]string
+	redLockServers          map[string][]string
+	tracer                  Tracer
+	redactedCachePrefixes   map[string]*tableSchema
 	enums                   map[string]Enum
+	root                    *validatedRegistry
+	latest                  atomic.Value
+	extendLock              sync.Mutex
+	enginePool              *EnginePool
+	enginePoolOnce          sync.Once
 }
 
 func (r *validatedRegistry) RegisterRabbitMQQueue(config *RabbitMQQueueConfig, serverPool ...string) {
+	r = r.current()
 	dbCode := "default"
 	if len(serverPool) > 0 {
 		dbCode = serverPool[0]
@@ -64,6 +83,7 @@ func (r *validatedRegistry) RegisterRabbitMQQueue(config *RabbitMQQueueConfig, s
 }
 
 func (r *validatedRegistry) RegisterRabbitMQRouter(config *RabbitMQRouterConfig, serverPool ...string) {
+	r = r.current()
 	dbCode := "default"
 	if len(serverPool) > 0 {
 		dbCode = serverPool[0]
@@ -79,15 +99,42 @@ func (r *validatedRegistry) RegisterRabbitMQRouter(config *RabbitMQRouterConfig,
 	r.rabbitMQRouterConfigs[config.Name] = config
 }
 
+// Close closes connections to all pools, engines created from this registry can't be used after it
+func (r *validatedRegistry) Close() {
+	r = r.current()
+	for _, v := range r.sqlClients {
+		closeSQLPool(v)()
+	}
+	for _, v := range r.clickHouseClients {
+		closeClickHouse(v)()
+	}
+	for _, v := range r.elasticServers {
+		closeElastic(v)()
+	}
+	for _, v := range r.redisServers {
+		closeRedis(v)()
+	}
+	for _, v := range r.rabbitMQServers {
+		closeRabbitMQ(v)()
+	}
+}
+
 func (r *validatedRegistry) CreateEngine() *Engine {
-	e := &Engine{registry: r}
+	return r.current().createEngine()
+}
+
+func (r *validatedRegistry) createEngine() *Engine {
+	e := &Engine{registry: r, trackLimit: r.registry.trackLimit}
 	e.dataDog = &dataDog{engine: e}
 	e.dbs = make(map[string]*DB)
-	e.trackedEntities = make([]Entity, 0)
 	if e.registry.sqlClients != nil {
 		for key, val := range e.registry.sqlClients {
+			client := &standardSQLClient{db: val.db}
+			if val.readDB != nil {
+				client.read = val.readDB
+			}
 			e.dbs[key] = &DB{engine: e, code: val.code, databaseName: val.databaseName,
-				client: &standardSQLClient{db: val.db}, autoincrement: val.autoincrement}
+				client: client, autoincrement: val.autoincrement, version: val.version}
 		}
 	}
 	if e.registry.clickHouseClients != nil {
@@ -105,7 +152,7 @@ func (r *validatedRegistry) CreateEngine() *Engine {
 	e.redis = make(map[string]*RedisCache)
 	if e.registry.redisServers != nil {
 		for key, val := range e.registry.redisServers {
-			e.redis[key] = &RedisCache{engine: e, code: val.code, client: &standardRedisClient{val.client, val.ring}}
+			e.redis[key] = &RedisCache{engine: e, code: val.code, client: &standardRedisClient{val.client, val.ring, val.prefix}}
 		}
 	}
 	e.elastic = make(map[string]*Elastic)
@@ -125,14 +172,26 @@ func (r *validatedRegistry) CreateEngine() *Engine {
 	e.locks = make(map[string]*Locker)
 	if e.registry.lockServers != nil {
 		for key, val := range e.registry.lockServers {
-			locker := &standardLockerClient{client: redislock.New(e.registry.redisServers[val].client)}
+			locker := &standardLockerClient{client: redislock.New(e.registry.redisServers[val].client),
+				prefix: e.registry.redisServers[val].prefix}
 			e.locks[key] = &Locker{locker: locker, code: val, engine: e}
 		}
 	}
+	for key, val := range e.registry.redLockServers {
+		clients := make([]*redislock.Client, len(val))
+		prefixes := make([]string, len(val))
+		for i, redisCode := range val {
+			clients[i] = redislock.New(e.registry.redisServers[redisCode].client)
+			prefixes[i] = e.registry.redisServers[redisCode].prefix
+		}
+		e.locks[key] = &Locker{locker: &redLockerClient{clients: clients, prefixes: prefixes}, code: strings.Join(val, ","), engine: e}
+	}
+	e.enableRegistryTracer()
 	return e
 }
 
 func (r *validatedRegistry) GetTableSchema(entityName string) TableSchema {
+	r = r.current()
 	t, has := r.entities[entityName]
 	if !has {
 		return nil
@@ -141,6 +200,7 @@ func (r *validatedRegistry) GetTableSchema(entityName string) TableSchema {
 }
 
 func (r *validatedRegistry) GetTableSchemaForEntity(entity Entity) TableSchema {
+	r = r.current()
 	t := reflect.TypeOf(entity)
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -153,9 +213,23 @@ func (r *validatedRegistry) GetTableSchemaForEntity(entity Entity) TableSchema {
 }
 
 func (r *validatedRegistry) GetEnum(code string) Enum {
-	return r.enums[code]
+	return r.current().enums[code]
 }
 
 func (r *validatedRegistry) GetDirtyQueues() map[string]int {
-	return r.dirtyQueues
+	return r.current().dirtyQueues
+}
+
+func (r *validatedRegistry) GetEntities() map[string]reflect.Type {
+	return r.current().entities
+}
+
+func (r *validatedRegistry) GetRabbitMQQueues() []string {
+	r = r.current()
+	names := make([]string, 0, len(r.rabbitMQChannelsToQueue))
+	for name := range r.rabbitMQChannelsToQueue {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,73 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type ValidationProblem struct {
+	Entity string
+	Field  string
+	Err    error
+}
+
+func (p *ValidationProblem) Error() string {
+	if p.Entity == "" {
+		return p.Err.Error()
+	}
+	if p.Field == "" {
+		return fmt.Sprintf("invalid entity struct '%s': %s", p.Entity, p.Err.Error())
+	}
+	return fmt.Sprintf("invalid entity struct '%s' field '%s': %s", p.Entity, p.Field, p.Err.Error())
+}
+
+type ValidationError struct {
+	Problems []*ValidationProblem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].Error()
+	}
+	lines := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		lines[i] = " - " + problem.Error()
+	}
+	return fmt.Sprintf("%d registry validation errors:\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+func (e *ValidationError) add(entity string, field string, err error) {
+	e.Problems = append(e.Problems, &ValidationProblem{Entity: entity, Field: field, Err: err})
+}
+
+func (e *ValidationError) merge(entity string, err error) {
+	asValidationError, is := err.(*ValidationError)
+	if !is {
+		e.add(entity, "", err)
+		return
+	}
+	for _, problem := range asValidationError.Problems {
+		if problem.Entity == "" {
+			problem.Entity = entity
+		}
+		e.Problems = append(e.Problems, problem)
+	}
+}
+
+func (e *ValidationError) setField(field string) {
+	problem := e.Problems[len(e.Problems)-1]
+	if problem.Field == "" {
+		problem.Field = field
+	}
+}
+
+func sortedEntityNames(entities map[string]reflect.Type) []string {
+	names := make([]string, 0, len(entities))
+	for name := range entities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,14 +1,18 @@
 package orm
 
 import (
-	"fmt"
 	"reflect"
 	"strings"
 )
 
+const cachedPlaceholders = 128
+
+var inPlaceholders = buildInPlaceholders()
+
 type Where struct {
-	query      string
-	parameters []interface{}
+	query           string
+	parameters      []interface{}
+	withFakeDeleted bool
 }
 
 func (where *Where) String() string {
@@ -19,28 +23,157 @@ func (where *Where) GetParameters() []interface{} {
 	return where.parameters
 }
 
+func (where *Where) WithFakeDeleted() *Where {
+	where.withFakeDeleted = true
+	return where
+}
+
 func (where *Where) Append(query string, parameters ...interface{}) {
 	newWhere := NewWhere(query, parameters...)
-	where.query += " " + newWhere.query
+	var builder strings.Builder
+	builder.Grow(len(where.query) + len(newWhere.query) + 1)
+	builder.WriteString(where.query)
+	builder.WriteByte(' ')
+	builder.WriteString(newWhere.query)
+	where.query = builder.String()
 	where.parameters = append(where.parameters, newWhere.parameters...)
 }
 
 func NewWhere(query string, parameters ...interface{}) *Where {
+	var builder *strings.Builder
+	position := 0
 	finalParameters := make([]interface{}, 0, len(parameters))
 	for _, value := range parameters {
-		switch reflect.TypeOf(value).Kind().String() {
-		case "slice", "array":
+		var values []interface{}
+		switch v := value.(type) {
+		case nil, string, int, int64, int32, uint, uint64, uint32, uint8, float64, bool, []byte:
+			finalParameters = append(finalParameters, value)
+			continue
+		case []uint64:
+			values = make([]interface{}, len(v))
+			for i, id := range v {
+				values[i] = id
+			}
+		case []string:
+			values = make([]interface{}, len(v))
+			for i, s := range v {
+				values[i] = s
+			}
+		case []interface{}:
+			values = v
+		default:
+			kind := reflect.TypeOf(value).Kind()
+			if kind != reflect.Slice && kind != reflect.Array {
+				finalParameters = append(finalParameters, value)
+				continue
+			}
 			val := reflect.ValueOf(value)
-			length := val.Len()
-			in := strings.Repeat(",?", length)
-			in = strings.TrimLeft(in, ",")
-			query = strings.Replace(query, "IN ?", fmt.Sprintf("IN (%s)", in), 1)
-			for i := 0; i < length; i++ {
-				finalParameters = append(finalParameters, val.Index(i).Interface())
+			values = make([]interface{}, val.Len())
+			for i := range values {
+				values[i] = val.Index(i).Interface()
 			}
+		}
+		finalParameters = append(finalParameters, values...)
+		index := strings.Index(query[position:], "IN ?")
+		if index == -1 {
 			continue
 		}
-		finalParameters = append(finalParameters, value)
+		if builder == nil {
+			builder = &strings.Builder{}
+			builder.Grow(len(query) + len(parameters)*2 + len(values)*2)
+		}
+		builder.WriteString(query[position : position+index])
+		builder.WriteString("IN (")
+		builder.WriteString(getPlaceholders(len(values)))
+		builder.WriteByte(')')
+		position += index + 4
+	}
+	if builder != nil {
+		builder.WriteString(query[position:])
+		query = builder.String()
+	}
+	return &Where{query: query, parameters: finalParameters}
+}
+
+func getPlaceholders(length int) string {
+	if length < cachedPlaceholders {
+		return inPlaceholders[length]
+	}
+	return strings.TrimLeft(strings.Repeat(",?", length), ",")
+}
+
+func buildInPlaceholders() []string {
+	placeholders := make([]string, cachedPlaceholders)
+	all := strings.TrimLeft(strings.Repeat(",?", cachedPlaceholders), ",")
+	for i := 1; i < cachedPlaceholders; i++ {
+		placeholders[i] = all[:i*2-1]
+	}
+	return placeholders
+}
+
+// splitTrailingClauses cuts top level ORDER BY and LIMIT clauses from the end of where,
+// parameters are split between returned parts, orderBy and limit are nil when missing
+func (where *Where) splitTrailingClauses() (condition *Where, orderBy *Where, limit *Where) {
+	query := where.query
+	orderPosition, limitPosition := -1, -1
+	placeholders := make([]int, 0, len(where.parameters))
+	var quote byte
+	depth := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '?':
+			placeholders = append(placeholders, i)
+		case depth == 0 && (i == 0 || query[i-1] == ' ' || query[i-1] == '\n' || query[i-1] == '\t'):
+			if orderPosition == -1 && limitPosition == -1 && hasKeywordAt(query, i, "ORDER BY") {
+				orderPosition = i
+			} else if limitPosition == -1 && hasKeywordAt(query, i, "LIMIT") {
+				limitPosition = i
+			}
+		}
+	}
+	end := len(query)
+	part := func(start int, stop int) *Where {
+		parameters := make([]interface{}, 0)
+		for i, position := range placeholders {
+			if position >= start && position < stop && i < len(where.parameters) {
+				parameters = append(parameters, where.parameters[i])
+			}
+		}
+		return &Where{query: strings.TrimSpace(query[start:stop]), parameters: parameters, withFakeDeleted: where.withFakeDeleted}
+	}
+	if limitPosition != -1 {
+		limit = part(limitPosition, end)
+		end = limitPosition
+	}
+	if orderPosition != -1 {
+		orderBy = part(orderPosition, end)
+		end = orderPosition
+	}
+	condition = part(0, end)
+	if condition.query == "" {
+		condition.query = "1"
+	}
+	return condition, orderBy, limit
+}
+
+func hasKeywordAt(query string, position int, keyword string) bool {
+	end := position + len(keyword)
+	if end >= len(query) || !strings.EqualFold(query[position:end], keyword) {
+		return false
 	}
-	return &Where{query, finalParameters}
+	next := query[end]
+	return next == ' ' || next == '\n' || next == '\t'
 }
@@ -0,0 +1,70 @@
+package orm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhere(t *testing.T) {
+	where := NewWhere("`ID` IN ? AND `Name` IN ? AND `Age` > ?", []uint64{1, 2}, []string{"a", "b", "c"}, 10)
+	assert.Equal(t, "`ID` IN (?,?) AND `Name` IN (?,?,?) AND `Age` > ?", where.String())
+	assert.Equal(t, []interface{}{uint64(1), uint64(2), "a", "b", "c", 10}, where.GetParameters())
+
+	where = NewWhere("`ID` IN ? AND `Code` = ?", []interface{}{1, "a"}, nil)
+	assert.Equal(t, "`ID` IN (?,?) AND `Code` = ?", where.String())
+	assert.Equal(t, []interface{}{1, "a", nil}, where.GetParameters())
+
+	where = NewWhere("`ID` IN ?", []int{1, 2, 3})
+	assert.Equal(t, "`ID` IN (?,?,?)", where.String())
+	assert.Equal(t, []interface{}{1, 2, 3}, where.GetParameters())
+
+	where = NewWhere("`UUID` = ?", []byte{1, 2})
+	assert.Equal(t, "`UUID` = ?", where.String())
+	assert.Equal(t, []interface{}{[]byte{1, 2}}, where.GetParameters())
+
+	ids := make([]uint64, 200)
+	where = NewWhere("`ID` IN ?", ids)
+	assert.Equal(t, "`ID` IN ("+strings.TrimLeft(strings.Repeat(",?", 200), ",")+")", where.String())
+	assert.Len(t, where.GetParameters(), 200)
+
+	where = NewWhere("`ID` = ?", 1)
+	where.Append("AND `Name` IN ?", []string{"a", "b"})
+	assert.Equal(t, "`ID` = ? AND `Name` IN (?,?)", where.String())
+	assert.Equal(t, []interface{}{1, "a", "b"}, where.GetParameters())
+
+	assert.Equal(t, "SELECT `ID` FROM `table` WHERE `FakeDelete` = 0 AND 1 LIMIT 20,10",
+		buildSearchQuery("`ID`", "table", "1", true, NewPager(3, 10)))
+	assert.Equal(t, "SELECT `ID` FROM `table` WHERE 1 LIMIT 1", buildSearchQuery("`ID`", "table", "1", false, nil))
+
+	schema := &tableSchema{hasFakeDelete: true}
+	assert.True(t, skipFakeDeleted(true, schema, NewWhere("1")))
+	assert.False(t, skipFakeDeleted(true, schema, NewWhere("1").WithFakeDeleted()))
+	assert.False(t, skipFakeDeleted(false, schema, NewWhere("1")))
+	assert.False(t, skipFakeDeleted(true, &tableSchema{}, NewWhere("1")))
+}
+
+func TestWhereSplitTrailingClauses(t *testing.T) {
+	where := NewWhere("`Name` = 'ORDER BY x' AND `ID` IN ? ORDER BY `ID` DESC LIMIT ?", []uint64{1, 2}, 10)
+	condition, orderBy, limit := where.splitTrailingClauses()
+	assert.Equal(t, "`Name` = 'ORDER BY x' AND `ID` IN (?,?)", condition.String())
+	assert.Equal(t, []interface{}{uint64(1), uint64(2)}, condition.GetParameters())
+	assert.Equal(t, "ORDER BY `ID` DESC", orderBy.String())
+	assert.Equal(t, "LIMIT ?", limit.String())
+	assert.Equal(t, []interface{}{10}, limit.GetParameters())
+	assert.Equal(t, 10, getUpdateByWhereLimit(limit))
+
+	condition, orderBy, limit = NewWhere("`ID` IN (SELECT `ID` FROM `a` ORDER BY `ID` LIMIT 5)").splitTrailingClauses()
+	assert.Equal(t, "`ID` IN (SELECT `ID` FROM `a` ORDER BY `ID` LIMIT 5)", condition.String())
+	assert.Nil(t, orderBy)
+	assert.Nil(t, limit)
+
+	condition, orderBy, limit = NewWhere("ORDER BY `Name`").splitTrailingClauses()
+	assert.Equal(t, "1", condition.String())
+	assert.Equal(t, "ORDER BY `Name`", orderBy.String())
+	assert.Nil(t, limit)
+	assert.PanicsWithError(t, "LIMIT 5,10 in update by where not supported", func() {
+		getUpdateByWhereLimit(NewWhere("LIMIT 5,10"))
+	})
+}
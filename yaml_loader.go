@@ -42,6 +42,16 @@ func InitByYaml(yaml map[string]interface{}) (registry *Registry) {
 			case "locker":
 				valAsString := validateOrmString(value, key)
 				registry.RegisterLocker(key, valAsString)
+			case "redlock":
+				def, ok := value.([]interface{})
+				if !ok {
+					panic(errors.NotValidf("redlock definition: %v", value))
+				}
+				redisCodes := make([]string, len(def))
+				for i, v := range def {
+					redisCodes[i] = validateOrmString(v, key)
+				}
+				registry.RegisterRedLock(key, redisCodes...)
 			case "dirty_queues":
 				def, ok := value.(map[interface{}]interface{})
 				if !ok {
@@ -208,8 +218,8 @@ func validateOrmRabbitMQ(registry *Registry, value interface{}, key string) {
 				}
 			}
 			prefetchCount, _ := strconv.ParseInt(fmt.Sprintf("%v", asMap["prefetchCount"]), 10, 64)
-			config := &RabbitMQQueueConfig{asString, int(prefetchCount), router, durable,
-				routerKeys, autoDeleted, ttl}
+			config := &RabbitMQQueueConfig{Name: asString, PrefetchCount: int(prefetchCount), Router: router, Durable: durable,
+				RouterKeys: routerKeys, AutoDelete: autoDeleted, TTL: ttl}
 			registry.RegisterRabbitMQQueue(config, key)
 		}
 	}